@@ -0,0 +1,123 @@
+// Command breachbloom builds the Bloom filter file used by the "bloom"
+// breached-password check from a SHA-1 breached-password dataset: either a
+// single file or a range directory, with one "HASH:COUNT" line per password
+// (a range file holds only the hash suffix, its name being the prefix).
+//
+//	go run ./cmd/breachbloom -in pwned-passwords-sha1.txt -out data/pwned.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"task-management-api/pkg/breach"
+)
+
+func main() {
+	in := flag.String("in", "", "dataset file, or directory of range files")
+	out := flag.String("out", "pwned.bloom", "filter file to write")
+	rate := flag.Float64("fp", 0.001, "rate of false positives")
+	minCount := flag.Int("min-count", 1, "skip passwords seen fewer times than this")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := datasetFiles(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The filter is sized up front, so count the entries first
+	var n uint64
+	if err := eachHash(files, *minCount, func(string) error { n++; return nil }); err != nil {
+		log.Fatal(err)
+	}
+	builder, err := breach.NewBloomBuilder(n, *rate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := eachHash(files, *minCount, builder.AddHash); err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	if _, err := builder.WriteTo(w); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %d passwords to %s\n", n, *out)
+}
+
+// datasetFiles maps each dataset file to the hash prefix its lines omit
+func datasetFiles(path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return map[string]string{path: ""}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		files[filepath.Join(path, entry.Name())] = strings.TrimSuffix(entry.Name(), ".txt")
+	}
+	return files, nil
+}
+
+func eachHash(files map[string]string, minCount int, fn func(hash string) error) error {
+	for path, prefix := range files {
+		if err := eachLine(path, func(line string) error {
+			hash, countStr, _ := strings.Cut(line, ":")
+			if count, err := strconv.Atoi(countStr); err == nil && count < minCount {
+				return nil
+			}
+			return fn(prefix + hash)
+		}); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func eachLine(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
 
-	"github.com/gin-gonic/gin"
 	"task-management-api/config"
 	"task-management-api/internal/api"
 	"task-management-api/internal/api/handlers"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/outbox"
+	"task-management-api/internal/password"
 	"task-management-api/internal/repository"
+	"task-management-api/internal/search"
 	"task-management-api/internal/service"
+	"task-management-api/pkg/breach"
 	"task-management-api/pkg/database"
+	"task-management-api/pkg/mail"
+	"task-management-api/pkg/oidc"
+	"task-management-api/pkg/storage"
 )
 
 func main() {
@@ -26,27 +35,166 @@ func main() {
 	}
 	defer db.Close()
 
+	// Initialize blob storage for attachments
+	blobs, err := storage.New(cfg.Attachments.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize attachment storage: %v", err)
+	}
+
+	// Initialize the mail sender
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mail sender: %v", err)
+	}
+
+	// Initialize the breached password check, if configured
+	breached, err := breach.New(cfg.Passwords.Breached)
+	if err != nil {
+		log.Fatalf("Failed to open breached password dataset: %v", err)
+	}
+
 	// Initialize repositories
-	taskRepo := repository.NewTaskRepository(db)
+	taskRepo := repository.NewTaskRepository(db, repository.ParentDeletePolicy(cfg.Tasks.OnParentDelete))
 	userRepo := repository.NewUserRepository(db)
+	dependencyRepo := repository.NewDependencyRepository(db)
+	taskRevisionRepo := repository.NewTaskRevisionRepository(db)
+	labelRepo := repository.NewLabelRepository(db)
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	commentRepo := repository.NewCommentRepository(db)
+	filterRepo := repository.NewSavedFilterRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
+	accessTokenRepo := repository.NewAccessTokenRepository(db)
+	oidcRepo := repository.NewOIDCRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	teamRepo := repository.NewTeamRepository(db)
+	invitationRepo := repository.NewInvitationRepository(db)
+	txRunner := repository.NewTxRunner(db)
+
+	// The repositories above refuse to run queries until they are restricted
+	// to an organisation. Sign-in, accounts and the background jobs work
+	// across organisations and get unrestricted copies; everything else is
+	// restricted per request through ForTenant.
+	allTasks := taskRepo.AllTenants()
+	allUsers := userRepo.AllTenants()
+
+	// Initialize the search index
+	searchIndex, err := search.New(cfg.Search.Backend, taskRepo, labelRepo.AllTenants(), commentRepo)
+	if err != nil {
+		log.Fatalf("Failed to initialize search index: %v", err)
+	}
+	if memoryIndex, ok := searchIndex.(*search.MemoryIndex); ok {
+		tasks, err := allTasks.GetAllTasks(models.TaskFilter{IncludeArchived: true})
+		if err != nil {
+			log.Fatalf("Failed to load tasks into search index: %v", err)
+		}
+		comments, err := commentRepo.GetAllComments()
+		if err != nil {
+			log.Fatalf("Failed to load comments into search index: %v", err)
+		}
+		memoryIndex.Rebuild(tasks, comments)
+	}
 
 	// Initialize services
-	taskService := service.NewTaskService(taskRepo)
-	userService := service.NewUserService(userRepo)
+	webhookService := service.NewWebhookService(webhookRepo, cfg.Webhooks)
+	eventBus := events.NewBus(cfg.Stream.BacklogSize, cfg.Stream.ClientBuffer)
+	attachmentService := service.NewAttachmentService(attachmentRepo, taskRepo, blobs, txRunner, cfg.Attachments.MaxSize, cfg.Attachments.AllowedTypes)
+	taskService := service.NewTaskService(allTasks, dependencyRepo, taskRevisionRepo.AllTenants(), attachmentService, searchIndex, txRunner, outboxRepo)
+	sessionService := service.NewSessionService(sessionRepo, allUsers)
+	passwordService := service.NewPasswordService(allUsers, password.NewPolicy(cfg.Passwords, breached), cfg.Passwords)
+	loginThrottle := service.NewLoginThrottle(loginThrottleRepo, allUsers, outboxRepo, cfg.Lockout)
+	mfaService := service.NewMFAService(allUsers, mfaRepo, txRunner, outboxRepo, sessionService, loginThrottle, cfg.MFA)
+	userService := service.NewUserService(allUsers, organizationRepo, txRunner, outboxRepo, sessionService, mfaService, loginThrottle, passwordService, cfg.Accounts, cfg.Registration)
+	accountService := service.NewAccountService(allUsers, userTokenRepo, txRunner, outboxRepo, passwordService, sessionService, mailer, cfg.Accounts)
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.Enabled {
+		oidcProvider = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		})
+	}
+	oidcService := service.NewOIDCService(oidcProvider, oidcRepo, allUsers, txRunner, outboxRepo, sessionService, cfg.OIDC)
+	oauthService := service.NewOAuthService(oauthRepo, allUsers, txRunner, outboxRepo, cfg.OAuth)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo, allUsers, outboxRepo, cfg.AccessTokens)
+	labelService := service.NewLabelService(labelRepo, taskRepo)
+	commentService := service.NewCommentService(commentRepo, taskRepo, searchIndex)
+	bulkService := service.NewBulkService(taskService, labelRepo, userRepo, txRunner, cfg.Tasks.BulkMaxItems)
+	importService := service.NewImportService(taskService, txRunner, cfg.Tasks.ImportMaxRows)
+	filterService := service.NewFilterService(filterRepo, taskRepo, userRepo)
+	organizationService := service.NewOrganizationService(organizationRepo, userRepo, txRunner, outboxRepo)
+	teamService := service.NewTeamService(teamRepo)
+	invitationService := service.NewInvitationService(invitationRepo, organizationRepo, teamRepo, userRepo, userService, txRunner, mailer, cfg.Registration)
 
 	// Initialize handlers
-	taskHandler := handlers.NewTaskHandler(taskService)
-	userHandler := handlers.NewUserHandler(userService)
+	taskHandler := handlers.NewTaskHandler(taskService, bulkService, importService, cfg.Tasks.ImportMaxSize)
+	userHandler := handlers.NewUserHandler(userService, accountService)
+	labelHandler := handlers.NewLabelHandler(labelService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService, cfg.Attachments.MaxSize)
+	commentHandler := handlers.NewCommentHandler(commentService)
+	filterHandler := handlers.NewFilterHandler(filterService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	streamHandler := handlers.NewStreamHandler(eventBus, cfg.Stream.Heartbeat)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	meHandler := handlers.NewMeHandler(userService, accountService, sessionService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	securityHandler := handlers.NewSecurityHandler(loginThrottle)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cfg.OIDC)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, cfg.OAuth)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, teamService, userService, accountService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	trashHandler := handlers.NewTrashHandler(taskService, userService)
+
+	// Relay events from the outbox to the bus, webhooks and external sinks
+	sinks, err := outbox.NewSinks(cfg.Outbox.Sinks)
+	if err != nil {
+		log.Fatalf("Failed to initialize event sinks: %v", err)
+	}
+	sinks = append([]outbox.Sink{{Name: "bus", Publisher: eventBus}, {Name: "webhooks", Publisher: webhookService}}, sinks...)
+	outbox.NewRelay(outboxRepo, sinks, cfg.Outbox).Start(context.Background())
+
+	// Start delivering queued webhook events
+	webhookService.StartDispatcher(context.Background())
+
+	// Delete accounts whose deletion grace period has ended
+	userService.StartDeletionPurger(context.Background(), cfg.Accounts.PurgeInterval)
+
+	// Purge deleted tasks and users once their retention period is over
+	service.StartTrashPurger(context.Background(), taskService, userService, cfg.Trash)
+
+	// Archive projects that have been completed for a while
+	service.StartAutoArchiver(context.Background(), taskService, cfg.Archive)
+
+	// Forget failed logins once they no longer count
+	loginThrottle.StartCleanup(context.Background())
+
+	// Forget single sign-on logins that were never completed
+	oidcService.StartCleanup(context.Background())
+
+	// Forget expired OAuth codes and tokens
+	oauthService.StartCleanup(context.Background())
 
 	// Set up Gin router
-	router := gin.Default()
+	router, err := api.NewRouter(cfg.Server)
+	if err != nil {
+		log.Fatalf("Failed to set up router: %v", err)
+	}
+	batchHandler := handlers.NewBatchHandler(router, cfg.Batch)
 
 	// Set up routes
-	api.SetupRoutes(router, taskHandler, userHandler)
+	api.SetupRoutes(router, taskHandler, userHandler, labelHandler, attachmentHandler, commentHandler, filterHandler, webhookHandler, streamHandler, accountHandler, meHandler, mfaHandler, securityHandler, accessTokenHandler, oidcHandler, oauthHandler, organizationHandler, invitationHandler, trashHandler, batchHandler, sessionService, accessTokenService, oauthService, organizationService)
 
 	// Start the server
 	log.Printf("Starting server on %s", cfg.Server.Addr)
 	if err := router.Run(cfg.Server.Addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
-}
\ No newline at end of file
+}
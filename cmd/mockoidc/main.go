@@ -0,0 +1,292 @@
+// Command mockoidc is a minimal OpenID Connect provider for trying out and
+// testing single sign-on locally. It signs in anyone: the authorization page
+// asks for the identity to log in as, prefilled from the flags, or with
+// -auto skips the page and uses the flags directly. It supports what the
+// API needs and no more: discovery, the authorization code flow with PKCE
+// (S256) and RS256 ID tokens. State is kept in memory.
+//
+//	go run ./cmd/mockoidc -addr :9998 -groups task-admins
+//
+// Point oidc.issuer at http://localhost:9998 and set oidc.cookie_secure to
+// false to use it.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// authorization is a code waiting to be redeemed
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	identity    identity
+	expiresAt   time.Time
+}
+
+// identity is who the user logs in as
+type identity struct {
+	Subject  string
+	Email    string
+	Name     string
+	Username string
+	Groups   string
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	auto         bool
+	defaults     identity
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+const keyID = "mockoidc"
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<title>Mock OIDC provider</title>
+<h1>Sign in as</h1>
+<form method="post">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">
+{{end}}<p><label>Subject <input name="sub" value="{{.Identity.Subject}}"></label>
+<p><label>Email <input name="email" value="{{.Identity.Email}}"></label>
+<p><label>Name <input name="name" value="{{.Identity.Name}}"></label>
+<p><label>Username <input name="preferred_username" value="{{.Identity.Username}}"></label>
+<p><label>Groups <input name="groups" value="{{.Identity.Groups}}"> (comma-separated)</label>
+<p><button>Sign in</button>
+</form>
+`))
+
+func main() {
+	addr := flag.String("addr", ":9998", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9998", "issuer URL, as the API reaches it")
+	clientID := flag.String("client-id", "task-management-api", "client ID to accept")
+	clientSecret := flag.String("client-secret", "", "client secret to require, if any")
+	auto := flag.Bool("auto", false, "log in as the identity from the flags without asking")
+	sub := flag.String("sub", "mock-user-1", "subject of the identity")
+	email := flag.String("email", "mock.user@example.com", "email of the identity")
+	name := flag.String("name", "Mock User", "name of the identity")
+	username := flag.String("username", "mockuser", "preferred username of the identity")
+	groups := flag.String("groups", "", "comma-separated groups of the identity")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := &provider{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		auto:         *auto,
+		defaults:     identity{Subject: *sub, Email: *email, Name: *name, Username: *username, Groups: *groups},
+		key:          key,
+		codes:        make(map[string]*authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+
+	log.Printf("Mock OIDC provider %s listening on %s", p.issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	redirectURI := params.Get("redirect_uri")
+	if params.Get("client_id") != p.clientID || redirectURI == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if params.Get("response_type") != "code" || params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		http.Error(w, "only the code flow with S256 PKCE is supported", http.StatusBadRequest)
+		return
+	}
+
+	id := p.defaults
+	if r.Method == http.MethodPost {
+		id = identity{
+			Subject:  params.Get("sub"),
+			Email:    params.Get("email"),
+			Name:     params.Get("name"),
+			Username: params.Get("preferred_username"),
+			Groups:   params.Get("groups"),
+		}
+	} else if !p.auto {
+		query := url.Values{}
+		for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			if v := params.Get(name); v != "" {
+				query.Set(name, v)
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		authorizePage.Execute(w, map[string]interface{}{"Params": query, "Identity": id})
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		clientID:    p.clientID,
+		redirectURI: redirectURI,
+		challenge:   params.Get("code_challenge"),
+		nonce:       params.Get("nonce"),
+		identity:    id,
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := target.Query()
+	query.Set("code", code)
+	if state := params.Get("state"); state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != p.clientID || (p.clientSecret != "" && secret != p.clientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("grant_type") != "authorization_code" || auth == nil || time.Now().After(auth.expiresAt) ||
+		auth.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.issuer,
+		"sub":                auth.identity.Subject,
+		"aud":                p.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              auth.nonce,
+		"email":              auth.identity.Email,
+		"email_verified":     true,
+		"name":               auth.identity.Name,
+		"preferred_username": auth.identity.Username,
+	}
+	var groups []string
+	for _, g := range strings.Split(auth.identity.Groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	claims["groups"] = groups
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -39,4 +39,533 @@ ADD CONSTRAINT fk_task_user
     ON DELETE SET NULL;
 
 -- Create an index for the new foreign key
-CREATE INDEX idx_user_id ON tasks(user_id);
\ No newline at end of file
+CREATE INDEX idx_user_id ON tasks(user_id);
+
+-- Subtasks: a task may belong to a parent task, at any depth
+ALTER TABLE tasks
+ADD COLUMN parent_id INT NULL AFTER id,
+ADD CONSTRAINT fk_task_parent
+    FOREIGN KEY (parent_id)
+    REFERENCES tasks(id)
+    ON DELETE SET NULL;
+
+CREATE INDEX idx_parent_id ON tasks(parent_id);
+
+-- Task dependencies: blocker_id has to be DONE before blocked_id can progress
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT fk_dependency_blocker FOREIGN KEY (blocker_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT fk_dependency_blocked FOREIGN KEY (blocked_id) REFERENCES tasks(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_blocked_id ON task_dependencies(blocked_id);
+
+-- Labels, either global (project_id NULL) or scoped to a top-level task
+CREATE TABLE IF NOT EXISTS labels (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    color CHAR(7) NOT NULL DEFAULT '#808080',
+    project_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_label_project FOREIGN KEY (project_id) REFERENCES tasks(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_label_name ON labels(name);
+
+CREATE TABLE IF NOT EXISTS task_labels (
+    task_id INT NOT NULL,
+    label_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, label_id),
+    CONSTRAINT fk_task_label_task FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT fk_task_label_label FOREIGN KEY (label_id) REFERENCES labels(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_task_label_label ON task_labels(label_id);
+
+-- File attachments; contents are stored in blob storage keyed by checksum
+CREATE TABLE IF NOT EXISTS attachments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    task_id INT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    uploaded_by INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_attachment_task FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+    CONSTRAINT fk_attachment_user FOREIGN KEY (uploaded_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_attachment_task ON attachments(task_id);
+CREATE INDEX idx_attachment_checksum ON attachments(checksum);
+
+-- One row per blob in blob storage. Uploads and deletions lock the row, so
+-- that a blob is never deleted while an upload is about to reuse it.
+CREATE TABLE IF NOT EXISTS blobs (
+    checksum CHAR(64) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Full-text search over tasks
+ALTER TABLE tasks ADD FULLTEXT INDEX ft_tasks (title, description);
+
+-- Task priority and due date
+ALTER TABLE tasks
+ADD COLUMN priority ENUM('LOW', 'MEDIUM', 'HIGH') NOT NULL DEFAULT 'MEDIUM' AFTER status,
+ADD COLUMN due_date DATETIME NULL AFTER priority;
+
+CREATE INDEX idx_priority ON tasks(priority);
+CREATE INDEX idx_due_date ON tasks(due_date);
+
+-- Saved task filters, private to their owner unless shared
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_saved_filter_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_saved_filter_user ON saved_filters(user_id);
+
+CREATE TABLE IF NOT EXISTS saved_filter_shares (
+    filter_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (filter_id, user_id),
+    CONSTRAINT fk_filter_share_filter FOREIGN KEY (filter_id) REFERENCES saved_filters(id) ON DELETE CASCADE,
+    CONSTRAINT fk_filter_share_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_filter_share_user ON saved_filter_shares(user_id);
+
+-- Webhook subscriptions
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types VARCHAR(1000) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_webhook_user FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Outbox of webhook deliveries, kept until delivered or out of retries
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    webhook_id INT NOT NULL,
+    event_id CHAR(32) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSON NOT NULL,
+    status ENUM('PENDING', 'DELIVERED', 'DEAD') NOT NULL DEFAULT 'PENDING',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NULL,
+    last_status_code INT NULL,
+    last_error TEXT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME NULL,
+    CONSTRAINT fk_delivery_webhook FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_delivery_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_delivery_webhook ON webhook_deliveries(webhook_id, created_at);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    delivery_id INT NOT NULL,
+    status_code INT NULL,
+    error TEXT NULL,
+    response_body TEXT NULL,
+    duration_ms INT NOT NULL,
+    attempted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_attempt_delivery FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_attempt_delivery ON webhook_delivery_attempts(delivery_id);
+
+-- A relayed event is queued at most once per webhook
+ALTER TABLE webhook_deliveries ADD UNIQUE KEY uq_delivery_event (webhook_id, event_id);
+
+-- Domain events written in the same transaction as the change they describe,
+-- relayed to the event sinks in id order
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id CHAR(32) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    aggregate_type VARCHAR(20) NOT NULL,
+    aggregate_id INT NOT NULL,
+    payload JSON NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    published_at DATETIME NULL,
+    UNIQUE KEY uq_outbox_event (event_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_outbox_pending ON outbox(published_at, id);
+
+-- The sinks that have accepted an outbox entry, so that an entry some sinks
+-- failed to take is offered again only to those
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    outbox_id BIGINT NOT NULL,
+    sink VARCHAR(50) NOT NULL,
+    delivered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (outbox_id, sink),
+    CONSTRAINT fk_outbox_delivery_entry FOREIGN KEY (outbox_id) REFERENCES outbox(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Email verification; accounts that existed before are treated as verified
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+UPDATE users SET email_verified_at = created_at;
+
+-- Single-use tokens sent by email, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose ENUM('EMAIL_VERIFICATION', 'PASSWORD_RESET') NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_user_token_hash (token_hash),
+    CONSTRAINT fk_user_token_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_user_token_user ON user_tokens(user_id, purpose);
+
+-- Self-service account changes: a new address awaiting confirmation, and
+-- deletion after a grace period
+ALTER TABLE users
+    ADD COLUMN pending_email VARCHAR(100) NULL,
+    ADD COLUMN deletion_scheduled_at DATETIME NULL;
+
+ALTER TABLE user_tokens MODIFY purpose ENUM('EMAIL_VERIFICATION', 'PASSWORD_RESET', 'EMAIL_CHANGE') NOT NULL;
+
+-- Login sessions; each token carries its session ID, so revoking a session
+-- invalidates the token
+CREATE TABLE IF NOT EXISTS sessions (
+    id CHAR(32) PRIMARY KEY,
+    user_id INT NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    CONSTRAINT fk_session_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_session_user ON sessions(user_id, expires_at);
+CREATE INDEX idx_user_deletion ON users(deletion_scheduled_at);
+
+-- Two-factor authentication with authenticator apps (TOTP). The secret is
+-- set when enrolment starts and enabled once confirmed with a code;
+-- mfa_last_step stops a code from being used twice.
+ALTER TABLE users
+    ADD COLUMN mfa_secret VARCHAR(64) NULL,
+    ADD COLUMN mfa_enabled_at DATETIME NULL,
+    ADD COLUMN mfa_last_step BIGINT NULL;
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_mfa_recovery_code (user_id, code_hash),
+    CONSTRAINT fk_mfa_recovery_code_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Roles whose users must use two-factor authentication, set by admins
+CREATE TABLE IF NOT EXISTS mfa_required_roles (
+    role ENUM('USER', 'ADMIN') PRIMARY KEY
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Failed logins per username (whether or not it exists) and per client
+-- address, for progressive delays and lockouts
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope ENUM('ACCOUNT', 'IP') NOT NULL,
+    throttle_key VARCHAR(100) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    first_failed_at DATETIME NOT NULL,
+    last_failed_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    PRIMARY KEY (scope, throttle_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Recent password hashes, so that they cannot be reused
+CREATE TABLE IF NOT EXISTS password_history (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_password_history_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_password_history_user ON password_history(user_id, id);
+
+-- Personal access tokens, which scripts use instead of logging in; only a
+-- hash of each token is kept
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    scopes VARCHAR(500) NOT NULL,
+    token_prefix VARCHAR(12) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NULL,
+    last_used_at DATETIME NULL,
+    last_used_ip VARCHAR(45) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME NULL,
+    CONSTRAINT fk_personal_access_token_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens(user_id);
+
+-- Single sign-on logins waiting for the identity provider's callback
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state_hash CHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Accounts at identity providers linked to users, for single sign-on
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME NULL,
+    UNIQUE KEY uq_user_identity (issuer, subject),
+    CONSTRAINT fk_user_identity_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Third-party apps registered to act for users through OAuth 2.0
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL UNIQUE,
+    owner_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    -- NULL for public apps, which have no secret
+    secret_hash CHAR(64) NULL,
+    redirect_uris TEXT NOT NULL,
+    scopes VARCHAR(500) NOT NULL,
+    grant_types VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_oauth_client_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Authorization codes waiting to be exchanged for tokens
+CREATE TABLE IF NOT EXISTS oauth_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    client_id INT NOT NULL,
+    user_id INT NOT NULL,
+    redirect_uri VARCHAR(2000) NOT NULL,
+    scopes VARCHAR(500) NOT NULL,
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    CONSTRAINT fk_oauth_code_client FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    CONSTRAINT fk_oauth_code_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Access tokens issued to apps, with their refresh tokens, so that they can be revoked
+CREATE TABLE IF NOT EXISTS oauth_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    token_id CHAR(32) NOT NULL UNIQUE,
+    client_id INT NOT NULL,
+    user_id INT NOT NULL,
+    scopes VARCHAR(500) NOT NULL,
+    refresh_hash CHAR(64) NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    refresh_expires_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_oauth_token_client FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    CONSTRAINT fk_oauth_token_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_oauth_tokens_user_client ON oauth_tokens(user_id, client_id);
+
+-- Scopes users allowed each app, so that they are not asked again
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id INT NOT NULL,
+    client_id INT NOT NULL,
+    scopes VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id),
+    CONSTRAINT fk_oauth_consent_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_oauth_consent_client FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Organisations (tenants): users and tasks belong to exactly one and are
+-- invisible to the others. Existing rows go to the default organisation.
+CREATE TABLE IF NOT EXISTS organizations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO organizations (id, name, slug) VALUES (1, 'Default', 'default');
+
+-- Usernames are unique within an organisation; emails stay unique overall
+ALTER TABLE users
+ADD COLUMN org_id INT NOT NULL DEFAULT 1 AFTER id,
+ADD COLUMN org_role ENUM('MEMBER', 'ORG_ADMIN') NOT NULL DEFAULT 'MEMBER' AFTER org_id,
+DROP INDEX username,
+ADD UNIQUE KEY uq_users_org_username (org_id, username),
+ADD CONSTRAINT fk_user_org FOREIGN KEY (org_id) REFERENCES organizations(id);
+
+ALTER TABLE tasks
+ADD COLUMN org_id INT NOT NULL DEFAULT 1 AFTER id,
+ADD CONSTRAINT fk_task_org FOREIGN KEY (org_id) REFERENCES organizations(id);
+
+CREATE INDEX idx_tasks_org ON tasks(org_id, created_at);
+
+-- Teams group members of an organisation
+CREATE TABLE IF NOT EXISTS teams (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    org_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_teams_org_name (org_id, name),
+    CONSTRAINT fk_team_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    CONSTRAINT fk_team_member_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+    CONSTRAINT fk_team_member_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Invitations to create an account in an organisation, with a role and team
+-- chosen in advance; only a hash of each token is kept
+CREATE TABLE IF NOT EXISTS invitations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    org_id INT NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    role ENUM('USER', 'ADMIN') NOT NULL DEFAULT 'USER',
+    org_role ENUM('MEMBER', 'ORG_ADMIN') NOT NULL DEFAULT 'MEMBER',
+    team_id INT NULL,
+    invited_by INT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_invitation_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    CONSTRAINT fk_invitation_team FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL,
+    CONSTRAINT fk_invitation_inviter FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_invitations_org_email ON invitations(org_id, email);
+
+-- Soft deletion: deleted tasks and users stay in the trash, where they can
+-- be restored, until the retention job purges them
+ALTER TABLE tasks ADD COLUMN deleted_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME NULL;
+
+CREATE INDEX idx_tasks_deleted_at ON tasks(deleted_at);
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+
+-- Archived tasks are moved out of tasks, so that the day-to-day queries do
+-- not have to skip them. Labels, attachments and dependencies stay keyed by
+-- the task ID while it moves between the two tables, so they lose their
+-- foreign keys to tasks and are deleted along with the task when it is purged.
+CREATE TABLE IF NOT EXISTS archived_tasks (
+    id INT PRIMARY KEY,
+    org_id INT NOT NULL,
+    parent_id INT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    status ENUM('TODO', 'IN_PROGRESS', 'DONE') NOT NULL,
+    priority ENUM('LOW', 'MEDIUM', 'HIGH') NOT NULL,
+    due_date DATETIME NULL,
+    user_id INT NULL,
+    deleted_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    archived_at DATETIME NOT NULL,
+    FULLTEXT INDEX ft_archived_tasks (title, description),
+    CONSTRAINT fk_archived_task_org FOREIGN KEY (org_id) REFERENCES organizations(id),
+    CONSTRAINT fk_archived_task_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_archived_tasks_org ON archived_tasks(org_id, created_at);
+CREATE INDEX idx_archived_tasks_parent ON archived_tasks(parent_id, archived_at);
+
+ALTER TABLE task_labels DROP FOREIGN KEY fk_task_label_task;
+ALTER TABLE attachments DROP FOREIGN KEY fk_attachment_task;
+ALTER TABLE labels DROP FOREIGN KEY fk_label_project;
+ALTER TABLE task_dependencies
+    DROP FOREIGN KEY fk_dependency_blocker,
+    DROP FOREIGN KEY fk_dependency_blocked;
+
+-- Every state a task has been in, as a JSON snapshot taken after each
+-- change. There is no foreign key to tasks since the history follows the
+-- task into the archive; it is deleted when the task is purged.
+CREATE TABLE IF NOT EXISTS task_revisions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    task_id INT NOT NULL,
+    org_id INT NOT NULL,
+    revision INT NOT NULL,
+    actor_id INT NULL,
+    snapshot JSON NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE KEY uq_task_revision (task_id, revision),
+    CONSTRAINT fk_task_revision_actor FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_task_revisions_time ON task_revisions(task_id, created_at);
+
+-- Comments on tasks. Like the other rows belonging to a task they follow it
+-- into the archive, so there is no foreign key to tasks; they are deleted
+-- when the task is purged.
+CREATE TABLE IF NOT EXISTS task_comments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    task_id INT NOT NULL,
+    user_id INT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FULLTEXT INDEX ft_task_comments (body),
+    CONSTRAINT fk_task_comment_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE INDEX idx_task_comments_task ON task_comments(task_id, created_at);
+
+-- Labels belong to an organisation like the tasks they tag
+ALTER TABLE labels
+ADD COLUMN org_id INT NOT NULL DEFAULT 1 AFTER id,
+ADD CONSTRAINT fk_label_org FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_labels_org_name ON labels(org_id, name);
+
+-- Label names are unique within their scope whatever their case, so that
+-- concurrent creates cannot add the same label twice. project_scope stands in
+-- for project_id in the key, as NULLs never collide in a unique key.
+ALTER TABLE labels
+MODIFY name VARCHAR(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NOT NULL,
+ADD COLUMN project_scope INT AS (IFNULL(project_id, 0)) STORED,
+ADD UNIQUE KEY uq_labels_scope_name (org_id, project_scope, name);
+
+-- Outbox entries that ran out of attempts are set aside, so that they no
+-- longer hold back the later events of their aggregate
+ALTER TABLE outbox ADD COLUMN dead_at DATETIME NULL;
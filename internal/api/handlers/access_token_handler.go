@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// AccessTokenHandler serves personal access tokens: the user's own, and
+// other users' for admins
+type AccessTokenHandler struct {
+	tokenService service.AccessTokenService
+}
+
+func NewAccessTokenHandler(tokenService service.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{tokenService: tokenService}
+}
+
+// ListTokens lists the current user's access tokens, without their secrets
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	h.listTokens(c, c.GetInt("userID"))
+}
+
+// CreateToken creates an access token; its secret is only shown in this response
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	var req models.NewPersonalAccessToken
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	token, err := h.tokenService.CreateToken(c.GetInt("userID"), &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to create access token")
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// RevokeToken revokes one of the current user's access tokens
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	h.revokeToken(c, c.GetInt("userID"))
+}
+
+// ListUserTokens lists a user's access tokens
+func (h *AccessTokenHandler) ListUserTokens(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.listTokens(c, id)
+}
+
+// RevokeUserToken revokes one of a user's access tokens
+func (h *AccessTokenHandler) RevokeUserToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.revokeToken(c, id)
+}
+
+func (h *AccessTokenHandler) listTokens(c *gin.Context, userID int) {
+	tokens, err := h.tokenService.ListTokens(userID)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve access tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AccessTokenHandler) revokeToken(c *gin.Context, userID int) {
+	tokenID, err := strconv.Atoi(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := h.tokenService.RevokeToken(c.GetInt("userID"), userID, tokenID); err != nil {
+		respondWithError(c, err, "Failed to revoke access token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked"})
+}
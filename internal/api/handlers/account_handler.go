@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+type AccountHandler struct {
+	accountService service.AccountService
+}
+
+func NewAccountHandler(accountService service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// VerifyEmail redeems an email verification token
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req models.EmailVerification
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req.Token); err != nil {
+		respondWithError(c, err, "Failed to verify email")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification sends a new verification link to an unverified address
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.accountService.ResendVerification(req.Email); err != nil {
+		respondWithError(c, err, "Failed to send verification email")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an unverified account, a verification link has been sent"})
+}
+
+// ForgotPassword sends a password reset link
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.accountService.ForgotPassword(req.Email); err != nil {
+		respondWithError(c, err, "Failed to send password reset email")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an account, a password reset link has been sent"})
+}
+
+// ResetPassword sets a new password with a reset token
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req models.PasswordReset
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(req.Token, req.Password); err != nil {
+		respondWithError(c, err, "Failed to reset password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
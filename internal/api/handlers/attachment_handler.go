@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+type AttachmentHandler struct {
+	attachmentService service.AttachmentService
+	maxSize           int64
+}
+
+func NewAttachmentHandler(attachmentService service.AttachmentService, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: attachmentService, maxSize: maxSize}
+}
+
+// UploadAttachment stores the multipart "file" field as an attachment of the task
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	if h.maxSize > 0 {
+		// Leave some room for the multipart envelope around the file
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum size of %d bytes", h.maxSize)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file must be uploaded in the 'file' form field"})
+		}
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.ForTenant(tenant(c)).Upload(taskID, header.Filename, file, header.Size, c.GetInt("userID"))
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			respondWithError(c, err, "Failed to upload attachment")
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// ListAttachments lists the attachments of a task
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	attachments, err := h.attachmentService.ForTenant(tenant(c)).ListAttachments(taskID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attachments"})
+		}
+		return
+	}
+
+	if attachments == nil {
+		attachments = []*models.Attachment{}
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DownloadAttachment streams the attachment contents, honouring Range headers
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachments := h.attachmentService.ForTenant(tenant(c))
+	attachment, err := attachments.GetAttachment(taskID, id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve attachment")
+		return
+	}
+
+	content := attachments.OpenAttachment(attachment)
+	defer content.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	c.Header("ETag", strconv.Quote(attachment.Checksum))
+	http.ServeContent(c.Writer, c.Request, attachment.FileName, attachment.CreatedAt, content)
+}
+
+// DeleteAttachment removes an attachment from a task
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	if err := h.attachmentService.ForTenant(tenant(c)).DeleteAttachment(taskID, id); err != nil {
+		respondWithError(c, err, "Failed to delete attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+)
+
+// apiPrefix is where the paths of batched requests are resolved
+const apiPrefix = "/api/v1"
+
+var (
+	batchID        = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	batchReference = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)((?:\.[A-Za-z0-9_-]+)*)\s*\}\}`)
+	// quotedReference is a reference making up a whole JSON string, which
+	// is replaced with the JSON value it refers to rather than its text
+	quotedReference = regexp.MustCompile(`"\{\{\s*[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*\s*\}\}"`)
+)
+
+// BatchHandler runs several API requests in one round trip by handing each
+// of them to the router, with the caller's credentials
+type BatchHandler struct {
+	router      http.Handler
+	maxRequests int
+}
+
+func NewBatchHandler(router http.Handler, cfg config.BatchConfig) *BatchHandler {
+	return &BatchHandler{router: router, maxRequests: cfg.MaxRequests}
+}
+
+// Batch runs the requests in order and returns the status and body of each.
+// A request whose dependencies failed is not run and gets 424 Failed
+// Dependency.
+func (h *BatchHandler) Batch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+	if h.maxRequests > 0 && len(req.Requests) > h.maxRequests {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a batch may contain at most %d requests", h.maxRequests)})
+		return
+	}
+
+	dependencies := make([][]string, len(req.Requests))
+	seen := map[string]bool{}
+	for i, item := range req.Requests {
+		deps, err := batchDependencies(item, seen)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("request %d: %v", i, err)})
+			return
+		}
+		dependencies[i] = deps
+		if item.ID != "" {
+			seen[item.ID] = true
+		}
+	}
+
+	results := make([]*models.BatchItemResult, len(req.Requests))
+	byID := map[string]*models.BatchItemResult{}
+	for i, item := range req.Requests {
+		results[i] = h.run(c, item, dependencies[i], byID)
+		if item.ID != "" {
+			byID[item.ID] = results[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// batchDependencies checks a request of a batch and returns the IDs of the
+// requests it depends on, all of which have to be among the earlier ones
+func batchDependencies(item *models.BatchItem, earlier map[string]bool) ([]string, error) {
+	if item.ID != "" {
+		if !batchID.MatchString(item.ID) {
+			return nil, fmt.Errorf("id may only contain letters, digits, '_' and '-'")
+		}
+		if earlier[item.ID] {
+			return nil, fmt.Errorf("id %q is used twice", item.ID)
+		}
+	}
+	target := strings.SplitN(item.Path, "?", 2)[0]
+	if target == "/batch" || strings.HasPrefix(target, "/batch/") || target == "/stream" || strings.HasPrefix(target, "/stream/") {
+		return nil, fmt.Errorf("%s cannot be batched", target)
+	}
+
+	deps := append([]string{}, item.DependsOn...)
+	for _, match := range batchReference.FindAllStringSubmatch(item.Path+string(item.Body), -1) {
+		deps = append(deps, match[1])
+	}
+	for _, dep := range deps {
+		if !earlier[dep] {
+			return nil, fmt.Errorf("%q does not name an earlier request", dep)
+		}
+	}
+	return deps, nil
+}
+
+// run sends one request of a batch through the router
+func (h *BatchHandler) run(c *gin.Context, item *models.BatchItem, deps []string,
+	results map[string]*models.BatchItemResult) *models.BatchItemResult {
+	result := &models.BatchItemResult{ID: item.ID}
+	for _, dep := range deps {
+		if results[dep].Status >= http.StatusBadRequest {
+			result.Status = http.StatusFailedDependency
+			result.Body = gin.H{"error": fmt.Sprintf("request %q failed", dep)}
+			return result
+		}
+	}
+
+	path, body, err := resolveBatchReferences(item, results)
+	if err != nil {
+		result.Status = http.StatusBadRequest
+		result.Body = gin.H{"error": err.Error()}
+		return result
+	}
+
+	sub, err := http.NewRequestWithContext(c.Request.Context(), item.Method, apiPrefix+path, bytes.NewReader(body))
+	if err != nil {
+		result.Status = http.StatusBadRequest
+		result.Body = gin.H{"error": "Invalid path"}
+		return result
+	}
+	// The caller's credentials and client details apply to every request
+	sub.Header = c.Request.Header.Clone()
+	sub.Header.Del("Content-Length")
+	sub.Header.Del("Content-Type")
+	if len(body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	sub.RemoteAddr = c.Request.RemoteAddr
+
+	recorder := httptest.NewRecorder()
+	h.router.ServeHTTP(recorder, sub)
+
+	result.Status = recorder.Code
+	var parsed interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &parsed); err == nil {
+		result.Body = parsed
+	} else if recorder.Body.Len() > 0 {
+		result.Body = recorder.Body.String()
+	}
+	return result
+}
+
+// resolveBatchReferences replaces the references in the path and body of a
+// request with the values they point to. A reference making up a whole JSON
+// string in the body is replaced with the value itself, so that numbers stay
+// numbers.
+func resolveBatchReferences(item *models.BatchItem, results map[string]*models.BatchItemResult) (string, []byte, error) {
+	var resolveErr error
+	lookup := func(reference string) interface{} {
+		match := batchReference.FindStringSubmatch(reference)
+		value, err := batchLookup(results[match[1]].Body, strings.Split(strings.TrimPrefix(match[2], "."), "."), match[2] == "")
+		if err != nil && resolveErr == nil {
+			resolveErr = fmt.Errorf("%s: %v", strings.TrimSpace(reference), err)
+		}
+		return value
+	}
+
+	path := batchReference.ReplaceAllStringFunc(item.Path, func(reference string) string {
+		return url.PathEscape(batchText(lookup(reference)))
+	})
+
+	body := quotedReference.ReplaceAllFunc(item.Body, func(reference []byte) []byte {
+		value, _ := json.Marshal(lookup(string(reference[1 : len(reference)-1])))
+		return value
+	})
+	body = batchReference.ReplaceAllFunc(body, func(reference []byte) []byte {
+		// The text goes inside a JSON string, so it is escaped as one
+		quoted, _ := json.Marshal(batchText(lookup(string(reference))))
+		return quoted[1 : len(quoted)-1]
+	})
+
+	return path, body, resolveErr
+}
+
+// batchLookup follows a path of object keys and array indexes into a JSON
+// value. whole returns the value itself.
+func batchLookup(value interface{}, path []string, whole bool) (interface{}, error) {
+	if whole {
+		return value, nil
+	}
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("no field %q", key)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("no element %q", key)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("no field %q", key)
+		}
+	}
+	return value, nil
+}
+
+// batchText renders a JSON value for use inside a path or string
+func batchText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+)
+
+// newBatchRouter stands in for the API: POST /tasks creates task 5 with the
+// title and parent_id it is given, GET /tasks/5 finds it and other tasks are
+// not found. Every response reports the Authorization header it saw.
+func newBatchRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group(apiPrefix)
+	v1.POST("/tasks", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"id": 5, "title": body["title"], "parent_id": body["parent_id"], "auth": c.GetHeader("Authorization"),
+		})
+	})
+	v1.GET("/tasks/:id", func(c *gin.Context) {
+		if c.Param("id") != "5" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": 5, "q": c.Query("q"), "auth": c.GetHeader("Authorization")})
+	})
+	return router
+}
+
+// runBatch posts a batch and returns the status and the decoded results
+func runBatch(t *testing.T, body string) (int, []*models.BatchItemResult) {
+	t.Helper()
+	h := NewBatchHandler(newBatchRouter(), config.BatchConfig{MaxRequests: 3})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/batch", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("Authorization", "Bearer caller")
+	h.Batch(c)
+
+	var response struct {
+		Results []*models.BatchItemResult `json:"results"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, response.Results
+}
+
+func batchField(result *models.BatchItemResult, field string) interface{} {
+	body, _ := result.Body.(map[string]interface{})
+	return body[field]
+}
+
+func TestBatchResolvesReferences(t *testing.T) {
+	status, results := runBatch(t, `{"requests": [
+		{"id": "task", "method": "POST", "path": "/tasks", "body": {"title": "parent"}},
+		{"method": "POST", "path": "/tasks", "body": {"title": "child of {{task.title}}", "parent_id": "{{task.id}}"}},
+		{"method": "GET", "path": "/tasks/{{task.id}}?q={{task.title}}"}
+	]}`)
+	if status != http.StatusOK || len(results) != 3 {
+		t.Fatalf("status = %d, %d results", status, len(results))
+	}
+	for i, result := range results {
+		if result.Status >= http.StatusBadRequest {
+			t.Fatalf("request %d: status %d, %v", i, result.Status, result.Body)
+		}
+		// The caller's credentials apply to every request
+		if auth := batchField(result, "auth"); auth != "Bearer caller" {
+			t.Errorf("request %d ran with Authorization %v", i, auth)
+		}
+	}
+	if results[0].ID != "task" {
+		t.Errorf("first result has id %q", results[0].ID)
+	}
+	// A whole-string reference keeps the type of the value
+	if parent := batchField(results[1], "parent_id"); parent != float64(5) {
+		t.Errorf("parent_id = %#v, want the number 5", parent)
+	}
+	if title := batchField(results[1], "title"); title != "child of parent" {
+		t.Errorf("title = %v", title)
+	}
+	if q := batchField(results[2], "q"); q != "parent" {
+		t.Errorf("q = %v", q)
+	}
+}
+
+func TestBatchSkipsDependentsOfFailedRequests(t *testing.T) {
+	status, results := runBatch(t, `{"requests": [
+		{"id": "missing", "method": "GET", "path": "/tasks/9"},
+		{"method": "GET", "path": "/tasks/{{missing.id}}"},
+		{"method": "GET", "path": "/tasks/5", "depends_on": ["missing"]}
+	]}`)
+	if status != http.StatusOK || len(results) != 3 {
+		t.Fatalf("status = %d, %d results", status, len(results))
+	}
+	want := []int{http.StatusNotFound, http.StatusFailedDependency, http.StatusFailedDependency}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("request %d: status %d, want %d", i, result.Status, want[i])
+		}
+	}
+}
+
+func TestBatchReportsBadReferences(t *testing.T) {
+	_, results := runBatch(t, `{"requests": [
+		{"id": "task", "method": "POST", "path": "/tasks", "body": {"title": "t"}},
+		{"method": "GET", "path": "/tasks/{{task.nope}}"}
+	]}`)
+	if len(results) != 2 || results[1].Status != http.StatusBadRequest {
+		t.Fatalf("results = %+v, want the second refused", results)
+	}
+}
+
+func TestBatchRefusesInvalidBatches(t *testing.T) {
+	tests := map[string]string{
+		"too many requests": `{"requests": [{"method": "GET", "path": "/tasks/5"}, {"method": "GET", "path": "/tasks/5"},
+			{"method": "GET", "path": "/tasks/5"}, {"method": "GET", "path": "/tasks/5"}]}`,
+		"nested batch":       `{"requests": [{"method": "POST", "path": "/batch"}]}`,
+		"stream":             `{"requests": [{"method": "GET", "path": "/stream?since=1"}]}`,
+		"duplicate id":       `{"requests": [{"id": "a", "method": "GET", "path": "/tasks/5"}, {"id": "a", "method": "GET", "path": "/tasks/5"}]}`,
+		"invalid id":         `{"requests": [{"id": "a b", "method": "GET", "path": "/tasks/5"}]}`,
+		"forward reference":  `{"requests": [{"method": "GET", "path": "/tasks/{{later.id}}"}, {"id": "later", "method": "GET", "path": "/tasks/5"}]}`,
+		"unknown dependency": `{"requests": [{"method": "GET", "path": "/tasks/5", "depends_on": ["nobody"]}]}`,
+		"relative path":      `{"requests": [{"method": "GET", "path": "tasks/5"}]}`,
+		"unsupported method": `{"requests": [{"method": "HEAD", "path": "/tasks/5"}]}`,
+		"no requests":        `{"requests": []}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if status, _ := runBatch(t, body); status != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", status)
+			}
+		})
+	}
+}
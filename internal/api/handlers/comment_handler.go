@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+type CommentHandler struct {
+	commentService service.CommentService
+}
+
+func NewCommentHandler(commentService service.CommentService) *CommentHandler {
+	return &CommentHandler{commentService: commentService}
+}
+
+// ListComments lists the comments of a task, oldest first
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	comments, err := h.commentService.ForTenant(tenant(c)).ListComments(taskID)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve comments")
+		return
+	}
+
+	if comments == nil {
+		comments = []*models.Comment{}
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+// AddComment adds a comment by the current user to a task
+func (h *CommentHandler) AddComment(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req models.Comment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	comment, err := h.commentService.ForTenant(tenant(c)).AddComment(taskID, c.GetInt("userID"), req.Body)
+	if err != nil {
+		respondWithError(c, err, "Failed to add comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// DeleteComment removes a comment. Authors can delete their own comments,
+// organisation admins any comment.
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	moderator := models.OrgRole(c.GetString("orgRole")) == models.OrgRoleAdmin ||
+		models.UserRole(c.GetString("userRole")) == models.UserRoleAdmin
+	if err := h.commentService.ForTenant(tenant(c)).DeleteComment(taskID, id, c.GetInt("userID"), moderator); err != nil {
+		respondWithError(c, err, "Failed to delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
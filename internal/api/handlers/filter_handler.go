@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+type FilterHandler struct {
+	filterService service.FilterService
+}
+
+func NewFilterHandler(filterService service.FilterService) *FilterHandler {
+	return &FilterHandler{filterService: filterService}
+}
+
+// CreateFilter saves a filter expression for the current user
+func (h *FilterHandler) CreateFilter(c *gin.Context) {
+	var f models.SavedFilter
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+	f.UserID = c.GetInt("userID")
+
+	if err := h.filterService.ForTenant(tenant(c)).CreateFilter(&f); err != nil {
+		respondWithError(c, err, "Failed to save filter")
+		return
+	}
+
+	c.JSON(http.StatusCreated, f)
+}
+
+// ListFilters lists the filters of the current user and those shared with them
+func (h *FilterHandler) ListFilters(c *gin.Context) {
+	filters, err := h.filterService.ForTenant(tenant(c)).ListFilters(c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve filters"})
+		return
+	}
+
+	if filters == nil {
+		filters = []*models.SavedFilter{}
+	}
+	c.JSON(http.StatusOK, filters)
+}
+
+// GetFilter retrieves a saved filter by ID
+func (h *FilterHandler) GetFilter(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter ID"})
+		return
+	}
+
+	f, err := h.filterService.ForTenant(tenant(c)).GetFilter(id, c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve filter")
+		return
+	}
+
+	c.JSON(http.StatusOK, f)
+}
+
+// UpdateFilter replaces a saved filter
+func (h *FilterHandler) UpdateFilter(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter ID"})
+		return
+	}
+
+	var f models.SavedFilter
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+	f.ID = id
+	f.UserID = c.GetInt("userID")
+
+	if err := h.filterService.ForTenant(tenant(c)).UpdateFilter(&f); err != nil {
+		respondWithError(c, err, "Failed to update filter")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Filter updated successfully"})
+}
+
+// DeleteFilter deletes a saved filter
+func (h *FilterHandler) DeleteFilter(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter ID"})
+		return
+	}
+
+	if err := h.filterService.ForTenant(tenant(c)).DeleteFilter(id, c.GetInt("userID")); err != nil {
+		respondWithError(c, err, "Failed to delete filter")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Filter deleted successfully"})
+}
+
+// RunFilter returns the tasks matching a saved filter
+func (h *FilterHandler) RunFilter(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter ID"})
+		return
+	}
+
+	tasks, err := h.filterService.ForTenant(tenant(c)).RunFilter(id, c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to run filter")
+		return
+	}
+
+	if tasks == nil {
+		tasks = []*models.Task{}
+	}
+	c.JSON(http.StatusOK, tasks)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// InvitationHandler serves the invitations of the current user's
+// organisation, and accepting them, which is public
+type InvitationHandler struct {
+	invitationService service.InvitationService
+}
+
+func NewInvitationHandler(invitationService service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitationService: invitationService}
+}
+
+// CreateInvitation invites someone to the organisation by email. Only
+// platform admins may invite other platform admins.
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req models.NewInvitation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+	if req.Role == models.UserRoleAdmin && models.UserRole(c.GetString("userRole")) != models.UserRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only platform admins can invite admins"})
+		return
+	}
+
+	invitation, err := h.invitationService.ForTenant(tenant(c)).CreateInvitation(c.GetInt("userID"), &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to create invitation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitations lists the invitations not accepted yet
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.invitationService.ForTenant(tenant(c)).ListInvitations()
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve invitations")
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation deletes an invitation, so that it can no longer be accepted
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.invitationService.ForTenant(tenant(c)).RevokeInvitation(id); err != nil {
+		respondWithError(c, err, "Failed to revoke invitation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation creates the account an invitation is for
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.InvitationAcceptance
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(&req)
+	if err != nil {
+		if err.Error() == "username already exists" || err.Error() == "email already exists" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			respondWithError(c, err, "Failed to accept invitation")
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
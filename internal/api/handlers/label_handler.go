@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/errors"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/password"
+	"task-management-api/internal/service"
+)
+
+type LabelHandler struct {
+	labelService service.LabelService
+}
+
+func NewLabelHandler(labelService service.LabelService) *LabelHandler {
+	return &LabelHandler{labelService: labelService}
+}
+
+// CreateLabel creates a global or project-scoped label
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	var label models.Label
+	if err := c.ShouldBindJSON(&label); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.labelService.ForTenant(tenant(c)).CreateLabel(&label); err != nil {
+		respondWithError(c, err, "Failed to create label")
+		return
+	}
+
+	c.JSON(http.StatusCreated, label)
+}
+
+// ListLabels lists all labels with their usage counts
+func (h *LabelHandler) ListLabels(c *gin.Context) {
+	labels, err := h.labelService.ForTenant(tenant(c)).ListLabels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve labels"})
+		return
+	}
+
+	if labels == nil {
+		labels = []*models.Label{}
+	}
+	c.JSON(http.StatusOK, labels)
+}
+
+// GetLabel retrieves a label by ID
+func (h *LabelHandler) GetLabel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	label, err := h.labelService.ForTenant(tenant(c)).GetLabelByID(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve label")
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// UpdateLabel renames or recolours a label
+func (h *LabelHandler) UpdateLabel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	var updates models.UpdateLabel
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	label, err := h.labelService.ForTenant(tenant(c)).UpdateLabel(id, &updates)
+	if err != nil {
+		respondWithError(c, err, "Failed to update label")
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// DeleteLabel deletes a label and removes it from every task
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	if err := h.labelService.ForTenant(tenant(c)).DeleteLabel(id); err != nil {
+		respondWithError(c, err, "Failed to delete label")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label deleted successfully"})
+}
+
+// MergeLabel merges the label into the target label given in the body
+func (h *LabelHandler) MergeLabel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	var merge models.MergeLabels
+	if err := c.ShouldBindJSON(&merge); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	label, err := h.labelService.ForTenant(tenant(c)).MergeLabels(id, merge.TargetID)
+	if err != nil {
+		respondWithError(c, err, "Failed to merge labels")
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+// GetTaskLabels lists the labels attached to a task
+func (h *LabelHandler) GetTaskLabels(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	labels, err := h.labelService.ForTenant(tenant(c)).GetTaskLabels(taskID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve labels"})
+		}
+		return
+	}
+
+	if labels == nil {
+		labels = []*models.Label{}
+	}
+	c.JSON(http.StatusOK, labels)
+}
+
+// AttachLabel attaches a label to a task
+func (h *LabelHandler) AttachLabel(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var taskLabel models.TaskLabel
+	if err := c.ShouldBindJSON(&taskLabel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.labelService.ForTenant(tenant(c)).AttachLabel(taskID, taskLabel.LabelID); err != nil {
+		respondWithError(c, err, "Failed to attach label")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label attached successfully"})
+}
+
+// DetachLabel removes a label from a task
+func (h *LabelHandler) DetachLabel(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	labelID, err := strconv.Atoi(c.Param("labelId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	if err := h.labelService.ForTenant(tenant(c)).DetachLabel(taskID, labelID); err != nil {
+		respondWithError(c, err, "Failed to detach label")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label detached successfully"})
+}
+
+// respondWithError writes the status and message of an APIError, a 400 for
+// an invalid filter expression or a password the policy rejects, a 429 for a
+// locked login, or a 500 with the given message for any other error
+func respondWithError(c *gin.Context, err error, message string) {
+	switch e := err.(type) {
+	case *errors.APIError:
+		c.JSON(e.StatusCode, gin.H{"error": e.Message})
+	case *filter.ParseError:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": e.Message, "position": e.Pos})
+	case *password.PolicyError:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet the policy", "details": e.Violations})
+	case *service.LoginLockedError:
+		c.Header("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts; try again later"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// MeHandler serves the authenticated user's own account
+type MeHandler struct {
+	userService    service.UserService
+	accountService service.AccountService
+	sessionService service.SessionService
+}
+
+func NewMeHandler(userService service.UserService, accountService service.AccountService, sessionService service.SessionService) *MeHandler {
+	return &MeHandler{userService: userService, accountService: accountService, sessionService: sessionService}
+}
+
+// GetProfile returns the current user
+func (h *MeHandler) GetProfile(c *gin.Context) {
+	user, err := h.userService.GetUserByID(c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateProfile updates the current user's profile fields
+func (h *MeHandler) UpdateProfile(c *gin.Context) {
+	var req models.UpdateProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	if err := h.userService.UpdateUser(userID, &models.UpdateUser{FullName: req.FullName}); err != nil {
+		respondWithError(c, err, "Failed to update profile")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword sets a new password and signs out the user's other sessions
+func (h *MeHandler) ChangePassword(c *gin.Context) {
+	var req models.PasswordChange
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.userService.ChangePassword(c.GetInt("userID"), c.GetString("sessionID"), &req); err != nil {
+		respondWithError(c, err, "Failed to change password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ChangeEmail sends a confirmation link to the new address
+func (h *MeHandler) ChangeEmail(c *gin.Context) {
+	var req models.EmailChange
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.accountService.RequestEmailChange(c.GetInt("userID"), &req); err != nil {
+		respondWithError(c, err, "Failed to change email")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new address"})
+}
+
+// ListSessions lists the user's active sessions
+func (h *MeHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessionService.ListSessions(c.GetInt("userID"), c.GetString("sessionID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession signs out one of the user's sessions
+func (h *MeHandler) RevokeSession(c *gin.Context) {
+	if err := h.sessionService.RevokeSession(c.GetInt("userID"), c.Param("sessionId")); err != nil {
+		respondWithError(c, err, "Failed to revoke session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs out every session but the current one
+func (h *MeHandler) RevokeOtherSessions(c *gin.Context) {
+	if err := h.sessionService.RevokeOtherSessions(c.GetInt("userID"), c.GetString("sessionID")); err != nil {
+		respondWithError(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked successfully"})
+}
+
+// DeleteAccount schedules the user's account for deletion after a grace period
+func (h *MeHandler) DeleteAccount(c *gin.Context) {
+	var req models.PasswordConfirmation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	user, err := h.userService.ScheduleDeletion(c.GetInt("userID"), req.Password)
+	if err != nil {
+		respondWithError(c, err, "Failed to delete account")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account scheduled for deletion", "user": user})
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (h *MeHandler) CancelDeletion(c *gin.Context) {
+	user, err := h.userService.CancelDeletion(c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to cancel account deletion")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
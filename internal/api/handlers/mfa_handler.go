@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// MFAHandler serves two-factor authentication: the user's own setup, the
+// second login step, and the admin policy
+type MFAHandler struct {
+	mfaService service.MFAService
+}
+
+func NewMFAHandler(mfaService service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// GetStatus reports the current user's two-factor setup
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	status, err := h.mfaService.Status(c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve two-factor status")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// StartEnrollment creates a secret for the user's authenticator app
+func (h *MFAHandler) StartEnrollment(c *gin.Context) {
+	var req models.PasswordConfirmation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	enrollment, err := h.mfaService.StartEnrollment(c.GetInt("userID"), req.Password)
+	if err != nil {
+		respondWithError(c, err, "Failed to start two-factor enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmEnrollment enables two-factor authentication with a first code
+func (h *MFAHandler) ConfirmEnrollment(c *gin.Context) {
+	var req models.MFACode
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmEnrollment(c.GetInt("userID"), req.Code, clientInfo(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// Disable turns off two-factor authentication
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req models.MFADisable
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	if err := h.mfaService.Disable(c.GetInt("userID"), &req, clientInfo(c)); err != nil {
+		respondWithError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACode
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.GetInt("userID"), req.Code, clientInfo(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to generate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// Login finishes a login with a two-factor code
+func (h *MFAHandler) Login(c *gin.Context) {
+	var req models.MFALogin
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	result, err := h.mfaService.CompleteLogin(req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to log in")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": result.User, "token": result.Token})
+}
+
+// StartLoginEnrollment creates a secret for a user who must enrol to log in
+func (h *MFAHandler) StartLoginEnrollment(c *gin.Context) {
+	var req models.MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	enrollment, err := h.mfaService.StartLoginEnrollment(req.MFAToken)
+	if err != nil {
+		respondWithError(c, err, "Failed to start two-factor enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// CompleteLoginEnrollment enables two-factor authentication and logs in
+func (h *MFAHandler) CompleteLoginEnrollment(c *gin.Context) {
+	var req models.MFALogin
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	result, err := h.mfaService.CompleteLoginEnrollment(req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Login successful",
+		"user":           result.User,
+		"token":          result.Token,
+		"recovery_codes": result.RecoveryCodes,
+	})
+}
+
+// ResetUser turns off two-factor authentication for a user who lost their device
+func (h *MFAHandler) ResetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.mfaService.Reset(c.GetInt("userID"), id); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		respondWithError(c, err, "Failed to reset two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// GetPolicy lists the roles that must use two-factor authentication
+func (h *MFAHandler) GetPolicy(c *gin.Context) {
+	policy, err := h.mfaService.GetPolicy()
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve two-factor policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdatePolicy sets the roles that must use two-factor authentication
+func (h *MFAHandler) UpdatePolicy(c *gin.Context) {
+	var req models.MFAPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	policy, err := h.mfaService.SetPolicy(&req)
+	if err != nil {
+		respondWithError(c, err, "Failed to update two-factor policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
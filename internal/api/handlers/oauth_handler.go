@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// OAuthHandler serves the OAuth 2.0 authorization server: the protocol
+// endpoints apps call, the consent step behind the frontend's consent page,
+// and the management of registered apps and of the apps users authorized
+type OAuthHandler struct {
+	oauthService service.OAuthService
+	cfg          config.OAuthConfig
+}
+
+func NewOAuthHandler(oauthService service.OAuthService, cfg config.OAuthConfig) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService, cfg: cfg}
+}
+
+// respondWithOAuthError answers a protocol endpoint in the error format of
+// RFC 6749
+func respondWithOAuthError(c *gin.Context, err error) {
+	if oauthErr, ok := err.(*service.OAuthError); ok {
+		if oauthErr.StatusCode == http.StatusUnauthorized {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		c.JSON(oauthErr.StatusCode, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+}
+
+// clientCredentials takes the app's credentials from HTTP Basic
+// authentication, whose parts are form-encoded, when they are given that way
+func clientCredentials(c *gin.Context, clientID, clientSecret *string) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		*clientID, _ = url.QueryUnescape(id)
+		*clientSecret, _ = url.QueryUnescape(secret)
+	}
+}
+
+// Metadata publishes the server's endpoints, as described by RFC 8414
+func (h *OAuthHandler) Metadata(c *gin.Context) {
+	base := h.cfg.Issuer + "/api/v1/oauth"
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                h.cfg.Issuer,
+		"authorization_endpoint":                h.cfg.AuthorizeURL,
+		"token_endpoint":                        base + "/token",
+		"introspection_endpoint":                base + "/introspect",
+		"revocation_endpoint":                   base + "/revoke",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"scopes_supported":                      models.TokenScopes,
+	})
+}
+
+// Token issues tokens for an authorization code, a refresh token or the
+// app's own credentials
+func (h *OAuthHandler) Token(c *gin.Context) {
+	var req models.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	c.Header("Cache-Control", "no-store")
+	response, err := h.oauthService.Token(&req)
+	if err != nil {
+		respondWithOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect tells a resource server whether a token is active
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	var req models.TokenLookup
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	result, err := h.oauthService.Introspect(&req)
+	if err != nil {
+		respondWithOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Revoke revokes an access or refresh token issued to the app
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	var req models.TokenLookup
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+		return
+	}
+	clientCredentials(c, &req.ClientID, &req.ClientSecret)
+
+	if err := h.oauthService.Revoke(&req); err != nil {
+		respondWithOAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetAuthorization describes an authorization request for the consent page
+func (h *OAuthHandler) GetAuthorization(c *gin.Context) {
+	var req models.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	prompt, err := h.oauthService.PrepareAuthorization(c.GetInt("userID"), &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to check authorization request")
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// Authorize records the user's decision on the consent page and returns
+// where to send them back to the app
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req models.AuthorizationDecision
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	redirectTo, err := h.oauthService.Authorize(c.GetInt("userID"), &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to authorize app")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+// ListClients lists the user's registered apps, or all of them for admins
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c.GetInt("userID"), models.UserRole(c.GetString("userRole")))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve apps")
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// CreateClient registers an app; its secret is only shown in this response
+func (h *OAuthHandler) CreateClient(c *gin.Context) {
+	var req models.NewOAuthClient
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	client, err := h.oauthService.RegisterClient(c.GetInt("userID"), &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to register app")
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+// GetClient retrieves a registered app
+func (h *OAuthHandler) GetClient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app ID"})
+		return
+	}
+
+	client, err := h.oauthService.GetClient(c.GetInt("userID"), models.UserRole(c.GetString("userRole")), id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve app")
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// UpdateClient updates a registered app
+func (h *OAuthHandler) UpdateClient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app ID"})
+		return
+	}
+
+	var req models.UpdateOAuthClient
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	client, err := h.oauthService.UpdateClient(c.GetInt("userID"), models.UserRole(c.GetString("userRole")), id, &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to update app")
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// DeleteClient deletes a registered app, revoking its tokens
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app ID"})
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(c.GetInt("userID"), models.UserRole(c.GetString("userRole")), id); err != nil {
+		respondWithError(c, err, "Failed to delete app")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "App deleted successfully"})
+}
+
+// RotateSecret gives a confidential app a new secret
+func (h *OAuthHandler) RotateSecret(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid app ID"})
+		return
+	}
+
+	client, err := h.oauthService.RotateSecret(c.GetInt("userID"), models.UserRole(c.GetString("userRole")), id)
+	if err != nil {
+		respondWithError(c, err, "Failed to rotate app secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, client)
+}
+
+// ListAuthorizations lists the apps the current user authorized
+func (h *OAuthHandler) ListAuthorizations(c *gin.Context) {
+	consents, err := h.oauthService.ListAuthorizations(c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve authorized apps")
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// RevokeAuthorization withdraws the current user's authorization of an app
+func (h *OAuthHandler) RevokeAuthorization(c *gin.Context) {
+	if err := h.oauthService.RevokeAuthorization(c.GetInt("userID"), c.Param("clientId")); err != nil {
+		respondWithError(c, err, "Failed to revoke app authorization")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "App authorization revoked"})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// oidcStateCookie keeps the login state in the browser that started the
+// login, scoped to the single sign-on routes
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/v1/users/oidc"
+)
+
+// OIDCHandler serves single sign-on through an OpenID Connect provider
+type OIDCHandler struct {
+	oidcService service.OIDCService
+	cfg         config.OIDCConfig
+}
+
+func NewOIDCHandler(oidcService service.OIDCService, cfg config.OIDCConfig) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService, cfg: cfg}
+}
+
+// Login sends the browser to the identity provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context())
+	if err != nil {
+		respondWithError(c, err, "Failed to start single sign-on")
+		return
+	}
+
+	// Lax lets the cookie through on the provider's redirect back
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(h.cfg.StateTTL.Seconds()), oidcCookiePath, "", h.cfg.CookieSecure, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes single sign-on when the provider sends the browser back
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req models.OIDCCallback
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	browserState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCookiePath, "", h.cfg.CookieSecure, true)
+
+	result, err := h.oidcService.CompleteLogin(c.Request.Context(), &req, browserState, clientInfo(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to log in")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": result.User, "token": result.Token})
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// tenant returns the organisation of the current user, which every request
+// is confined to
+func tenant(c *gin.Context) int {
+	return c.GetInt("orgID")
+}
+
+// actor is the authenticated user the request acts for
+func actor(c *gin.Context) models.Actor {
+	return models.Actor{
+		UserID:  c.GetInt("userID"),
+		Role:    models.UserRole(c.GetString("userRole")),
+		OrgRole: models.OrgRole(c.GetString("orgRole")),
+	}
+}
+
+// OrganizationHandler serves organisations: all of them for platform
+// admins, and the current user's own with its members and teams
+type OrganizationHandler struct {
+	orgService     service.OrganizationService
+	teamService    service.TeamService
+	userService    service.UserService
+	accountService service.AccountService
+}
+
+func NewOrganizationHandler(orgService service.OrganizationService, teamService service.TeamService,
+	userService service.UserService, accountService service.AccountService) *OrganizationHandler {
+	return &OrganizationHandler{orgService: orgService, teamService: teamService, userService: userService, accountService: accountService}
+}
+
+// ListOrganizations lists every organisation
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.orgService.ListOrganizations()
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve organizations")
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+// CreateOrganization creates an organisation; its first admin is added
+// with CreateOrganizationMember
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.NewOrganization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(&req)
+	if err != nil {
+		respondWithError(c, err, "Failed to create organization")
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// GetOrganization retrieves an organisation by ID
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	h.getOrganization(c, id)
+}
+
+// UpdateOrganization updates an organisation by ID
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	h.updateOrganization(c, id)
+}
+
+// CreateOrganizationMember creates a user in an organisation by ID
+func (h *OrganizationHandler) CreateOrganizationMember(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	if _, err := h.orgService.GetOrganization(id); err != nil {
+		respondWithError(c, err, "Failed to retrieve organization")
+		return
+	}
+
+	h.createMember(c, id)
+}
+
+// GetCurrent retrieves the current user's organisation
+func (h *OrganizationHandler) GetCurrent(c *gin.Context) {
+	h.getOrganization(c, tenant(c))
+}
+
+// UpdateCurrent updates the current user's organisation
+func (h *OrganizationHandler) UpdateCurrent(c *gin.Context) {
+	h.updateOrganization(c, tenant(c))
+}
+
+// CreateMember creates a user in the current user's organisation
+func (h *OrganizationHandler) CreateMember(c *gin.Context) {
+	h.createMember(c, tenant(c))
+}
+
+// SetMemberRole changes a member's role in the current user's organisation
+func (h *OrganizationHandler) SetMemberRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.OrgRoleChange
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	user, err := h.orgService.SetOrgRole(c.GetInt("userID"), tenant(c), id, req.OrgRole)
+	if err != nil {
+		respondWithError(c, err, "Failed to change organization role")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *OrganizationHandler) getOrganization(c *gin.Context, id int) {
+	org, err := h.orgService.GetOrganization(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve organization")
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+func (h *OrganizationHandler) updateOrganization(c *gin.Context, id int) {
+	var req models.UpdateOrganization
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	org, err := h.orgService.UpdateOrganization(id, &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to update organization")
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// createMember creates a user in the organisation. Only platform admins may
+// create other platform admins.
+func (h *OrganizationHandler) createMember(c *gin.Context, orgID int) {
+	var newUser models.NewUser
+	if err := c.ShouldBindJSON(&newUser); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+	if newUser.Role == models.UserRoleAdmin && models.UserRole(c.GetString("userRole")) != models.UserRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only platform admins can create admins"})
+		return
+	}
+
+	user, err := h.userService.ForTenant(orgID).CreateUser(&newUser)
+	if err != nil {
+		if err.Error() == "username already exists" || err.Error() == "email already exists" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			respondWithError(c, err, "Failed to create user")
+		}
+		return
+	}
+
+	if err := h.accountService.SendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// ListTeams lists the teams of the current user's organisation
+func (h *OrganizationHandler) ListTeams(c *gin.Context) {
+	teams, err := h.teamService.ForTenant(tenant(c)).ListTeams()
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve teams")
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// CreateTeam creates a team in the current user's organisation
+func (h *OrganizationHandler) CreateTeam(c *gin.Context) {
+	var req models.NewTeam
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	team, err := h.teamService.ForTenant(tenant(c)).CreateTeam(&req)
+	if err != nil {
+		respondWithError(c, err, "Failed to create team")
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// GetTeam retrieves a team of the current user's organisation
+func (h *OrganizationHandler) GetTeam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	team, err := h.teamService.ForTenant(tenant(c)).GetTeam(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve team")
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// UpdateTeam updates a team of the current user's organisation
+func (h *OrganizationHandler) UpdateTeam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	var req models.UpdateTeam
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	team, err := h.teamService.ForTenant(tenant(c)).UpdateTeam(id, &req)
+	if err != nil {
+		respondWithError(c, err, "Failed to update team")
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam deletes a team of the current user's organisation
+func (h *OrganizationHandler) DeleteTeam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	if err := h.teamService.ForTenant(tenant(c)).DeleteTeam(id); err != nil {
+		respondWithError(c, err, "Failed to delete team")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// ListTeamMembers lists the members of a team
+func (h *OrganizationHandler) ListTeamMembers(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	members, err := h.teamService.ForTenant(tenant(c)).ListMembers(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve team members")
+		return
+	}
+
+	if members == nil {
+		members = []*models.User{}
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// AddTeamMember adds a member of the organisation to a team
+func (h *OrganizationHandler) AddTeamMember(c *gin.Context) {
+	id, userID, ok := teamMemberParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.teamService.ForTenant(tenant(c)).AddMember(id, userID); err != nil {
+		respondWithError(c, err, "Failed to add team member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member added successfully"})
+}
+
+// RemoveTeamMember removes a member from a team
+func (h *OrganizationHandler) RemoveTeamMember(c *gin.Context) {
+	id, userID, ok := teamMemberParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.teamService.ForTenant(tenant(c)).RemoveMember(id, userID); err != nil {
+		respondWithError(c, err, "Failed to remove team member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member removed successfully"})
+}
+
+func teamMemberParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return 0, 0, false
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, 0, false
+	}
+	return id, userID, true
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/service"
+)
+
+// SecurityHandler lets admins lift login lockouts
+type SecurityHandler struct {
+	throttle service.LoginThrottle
+}
+
+func NewSecurityHandler(throttle service.LoginThrottle) *SecurityHandler {
+	return &SecurityHandler{throttle: throttle}
+}
+
+// UnlockUser lifts a lockout of a user's account after failed logins
+func (h *SecurityHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.throttle.Unlock(c.GetInt("userID"), id); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		respondWithError(c, err, "Failed to unlock user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// ListBlockedIPs lists the addresses currently blocked after failed logins
+func (h *SecurityHandler) ListBlockedIPs(c *gin.Context) {
+	blocked, err := h.throttle.ListBlockedIPs()
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve blocked addresses")
+		return
+	}
+
+	c.JSON(http.StatusOK, blocked)
+}
+
+// UnblockIP lifts the block on an address
+func (h *SecurityHandler) UnblockIP(c *gin.Context) {
+	if err := h.throttle.UnblockIP(c.GetInt("userID"), c.Param("ip")); err != nil {
+		respondWithError(c, err, "Failed to unblock address")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address unblocked successfully"})
+}
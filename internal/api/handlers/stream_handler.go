@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+)
+
+// streamWriteTimeout bounds how long a single write to a WebSocket client may take
+const streamWriteTimeout = 10 * time.Second
+
+type StreamHandler struct {
+	bus       *events.Bus
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(bus *events.Bus, heartbeat time.Duration) *StreamHandler {
+	return &StreamHandler{bus: bus, heartbeat: heartbeat}
+}
+
+// wsFrame is a message sent to WebSocket clients
+type wsFrame struct {
+	// Type is "event", "heartbeat" or "reset"
+	Type  string        `json:"type"`
+	ID    uint64        `json:"id,omitempty"`
+	Event *events.Event `json:"event,omitempty"`
+}
+
+// Stream pushes the task events of the user's organisation as Server-Sent
+// Events, or over a WebSocket when the request asks for an upgrade. Clients
+// resume with the Last-Event-ID header or the last_event_id query parameter;
+// when the events since then are no longer available a "reset" is sent first
+// and the client should reload its tasks. A client that cannot keep up is disconnected and
+// is expected to reconnect and resume.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	orgID := c.GetInt("orgID")
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastSeq uint64
+	resume := lastEventID != ""
+	if resume {
+		var err error
+		if lastSeq, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+	}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		h.serveWebSocket(c, orgID, lastSeq, resume)
+	} else {
+		h.serveSSE(c, orgID, lastSeq, resume)
+	}
+}
+
+func (h *StreamHandler) serveSSE(c *gin.Context, orgID int, lastSeq uint64, resume bool) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		return
+	}
+
+	sub, complete := h.bus.Subscribe(lastSeq, resume)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg, open := <-sub.C:
+			if !open {
+				return
+			}
+			if !visibleEvent(msg.Event, orgID) {
+				continue
+			}
+			data, err := json.Marshal(msg.Event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.Seq, msg.Event.Type, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (h *StreamHandler) serveWebSocket(c *gin.Context, orgID int, lastSeq uint64, resume bool) {
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		sub, complete := h.bus.Subscribe(lastSeq, resume)
+		defer sub.Close()
+
+		// Clients don't send anything; reading only tells us when they go away
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, ws)
+			close(closed)
+		}()
+
+		send := func(frame wsFrame) bool {
+			ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			return websocket.JSON.Send(ws, frame) == nil
+		}
+
+		if !complete && !send(wsFrame{Type: "reset"}) {
+			return
+		}
+
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-closed:
+				return
+			case msg, open := <-sub.C:
+				if !open {
+					return
+				}
+				if !visibleEvent(msg.Event, orgID) {
+					continue
+				}
+				event := msg.Event
+				if !send(wsFrame{Type: "event", ID: msg.Seq, Event: &event}) {
+					return
+				}
+			case <-ticker.C:
+				if !send(wsFrame{Type: "heartbeat"}) {
+					return
+				}
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// visibleEvent reports whether the event is about a task of the user's
+// organisation, all of which its members see
+func visibleEvent(event events.Event, orgID int) bool {
+	switch data := event.Data.(type) {
+	case *models.Task:
+		return data.OrgID == orgID
+	case events.StatusChangedData:
+		return data.Task.OrgID == orgID
+	case events.TaskDeletedData:
+		return data.OrgID == orgID
+	default:
+		return false
+	}
+}
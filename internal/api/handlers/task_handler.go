@@ -1,22 +1,35 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"task-management-api/internal/errors"
+	taskfilter "task-management-api/internal/filter"
 	"task-management-api/internal/models"
 	"task-management-api/internal/service"
-	"task-management-api/internal/errors"
+	"task-management-api/internal/taskfile"
 )
 
 type TaskHandler struct {
-	taskService service.TaskService
+	taskService   service.TaskService
+	bulkService   service.BulkService
+	importService service.ImportService
+	importMaxSize int64
 }
 
-func NewTaskHandler(taskService service.TaskService) *TaskHandler {
-	return &TaskHandler{taskService: taskService}
+func NewTaskHandler(taskService service.TaskService, bulkService service.BulkService, importService service.ImportService,
+	importMaxSize int64) *TaskHandler {
+	return &TaskHandler{taskService: taskService, bulkService: bulkService, importService: importService,
+		importMaxSize: importMaxSize}
 }
 
 func (h *TaskHandler) CreateTask(c *gin.Context) {
@@ -26,8 +39,15 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.taskService.CreateTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+	userID := c.GetInt("userID")
+	task.UserID = &userID
+
+	if err := h.taskService.ForTenant(tenant(c)).CreateTask(&task); err != nil {
+		if apiErr, ok := err.(*errors.APIError); ok {
+			c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+		}
 		return
 	}
 
@@ -42,7 +62,17 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.GetTaskByID(id)
+	var task *models.Task
+	if asOf := c.Query("as_of"); asOf != "" {
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, errors.NewBadRequestError("as_of must be an RFC 3339 timestamp"))
+			return
+		}
+		task, err = h.taskService.ForTenant(tenant(c)).GetTaskAsOf(id, at)
+	} else {
+		task, err = h.taskService.ForTenant(tenant(c)).GetTaskByID(id)
+	}
 	if err != nil {
 		if apiErr, ok := err.(*errors.APIError); ok {
 			c.JSON(apiErr.StatusCode, apiErr)
@@ -56,7 +86,12 @@ func (h *TaskHandler) GetTaskByID(c *gin.Context) {
 }
 
 func (h *TaskHandler) GetAllTasks(c *gin.Context) {
-	tasks, err := h.taskService.GetAllTasks()
+	filter, ok := taskListFilter(c)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.taskService.ForTenant(tenant(c)).GetAllTasks(filter)
 	if err != nil {
 		log.Printf("Error fetching tasks: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks", "details": err.Error()})
@@ -71,6 +106,182 @@ func (h *TaskHandler) GetAllTasks(c *gin.Context) {
 	c.JSON(http.StatusOK, tasks)
 }
 
+// taskListFilter reads the filters of the task list from the query string,
+// responding with an error if they are invalid
+func taskListFilter(c *gin.Context) (models.TaskFilter, bool) {
+	var filter models.TaskFilter
+	if labels := c.Query("labels"); labels != "" {
+		// Label names compare case-insensitively, so "bug,Bug" is one label
+		seen := map[string]bool{}
+		for _, name := range strings.Split(labels, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[strings.ToLower(name)] {
+				seen[strings.ToLower(name)] = true
+				filter.Labels = append(filter.Labels, name)
+			}
+		}
+	}
+	switch c.DefaultQuery("label_match", "any") {
+	case "any":
+	case "all":
+		filter.MatchAllLabels = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label_match must be 'any' or 'all'"})
+		return filter, false
+	}
+	if q := c.Query("q"); q != "" {
+		expr, err := taskfilter.Parse(q, taskfilter.Env{Now: time.Now(), UserID: c.GetInt("userID")})
+		if err != nil {
+			respondWithError(c, err, "Invalid filter")
+			return filter, false
+		}
+		filter.Condition = expr
+	}
+	includeArchived, err := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include_archived must be 'true' or 'false'"})
+		return filter, false
+	}
+	filter.IncludeArchived = includeArchived
+	return filter, true
+}
+
+// ExportTasks streams the tasks matching the filters of the task list as a
+// CSV, NDJSON or XLSX file
+func (h *TaskHandler) ExportTasks(c *gin.Context) {
+	format := c.DefaultQuery("format", taskfile.FormatCSV)
+	if format != taskfile.FormatCSV && format != taskfile.FormatNDJSON && format != taskfile.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'csv', 'ndjson' or 'xlsx'"})
+		return
+	}
+	filter, ok := taskListFilter(c)
+	if !ok {
+		return
+	}
+
+	// The file is started with the first task, so that a failing query can
+	// still be answered with an error status
+	var w taskfile.Writer
+	start := func() error {
+		c.Header("Content-Type", taskfile.ContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tasks.%s"`, format))
+		c.Status(http.StatusOK)
+		var err error
+		w, err = taskfile.NewWriter(format, c.Writer)
+		return err
+	}
+	written := 0
+	err := h.taskService.ForTenant(tenant(c)).EachTask(filter, func(task *models.Task) error {
+		if w == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := w.Write(task); err != nil {
+			return err
+		}
+		written++
+		if written%100 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && w == nil {
+		err = start()
+	}
+	if err != nil {
+		log.Printf("Error exporting tasks: %v", err)
+		// Once streaming has started the status cannot change, so a failure
+		// only cuts the file short
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+		}
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Error exporting tasks: %v", err)
+	}
+}
+
+// ImportTasks creates tasks from an uploaded CSV, NDJSON or XLSX file. The
+// multipart form has the "file", and optionally its "format", a JSON
+// "mapping" of file columns to task fields and "dry_run" to only validate
+// the file.
+func (h *TaskHandler) ImportTasks(c *gin.Context) {
+	if h.importMaxSize > 0 {
+		// Leave some room for the multipart envelope around the file
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.importMaxSize+1<<20)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum size of %d bytes", h.importMaxSize)})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file must be uploaded in the 'file' form field"})
+		}
+		return
+	}
+	if h.importMaxSize > 0 && header.Size > h.importMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("File exceeds the maximum size of %d bytes", h.importMaxSize)})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = taskfile.FormatOf(header.Filename)
+	}
+	if format != taskfile.FormatCSV && format != taskfile.FormatNDJSON && format != taskfile.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be 'csv', 'ndjson' or 'xlsx'"})
+		return
+	}
+
+	var mapping map[string]string
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping must be a JSON object of column names to task fields"})
+			return
+		}
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be 'true' or 'false'"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	records, err := taskfile.Read(format, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + format + " file", "details": err.Error()})
+		return
+	}
+
+	report, err := h.importService.ForTenant(tenant(c)).Import(records, mapping, dryRun, c.GetInt("userID"))
+	if err != nil {
+		respondWithError(c, err, "Failed to import tasks")
+		return
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, report)
+	case report.Committed:
+		c.JSON(http.StatusCreated, report)
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
 func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -80,16 +291,20 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	}
 
 	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
+	if err := c.ShouldBindBodyWith(&task, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task data"})
 		return
 	}
 
 	task.ID = id
+	// Leaving out parent_id keeps the parent; only null detaches the task
+	task.KeepParent = !hasBodyField(c, "parent_id")
 
-	err = h.taskService.UpdateTask(&task)
+	err = h.taskService.ForTenant(tenant(c)).UpdateTask(&task, actor(c))
 	if err != nil {
-		if err.Error() == "task not found" {
+		if apiErr, ok := err.(*errors.APIError); ok {
+			c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		} else if err.Error() == "task not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
@@ -100,6 +315,21 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Task updated successfully"})
 }
 
+// hasBodyField reports whether the JSON object bound with ShouldBindBodyWith
+// has the given key, even if its value is null
+func hasBodyField(c *gin.Context, key string) bool {
+	body, ok := c.Get(gin.BodyBytesKey)
+	if !ok {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body.([]byte), &fields); err != nil {
+		return false
+	}
+	_, ok = fields[key]
+	return ok
+}
+
 func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -108,9 +338,11 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	err = h.taskService.DeleteTask(id)
+	err = h.taskService.ForTenant(tenant(c)).DeleteTask(id, actor(c))
 	if err != nil {
-		if err.Error() == "task not found" {
+		if apiErr, ok := err.(*errors.APIError); ok {
+			c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		} else if err.Error() == "task not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
@@ -119,4 +351,279 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
-}
\ No newline at end of file
+}
+
+// BulkTasks creates, updates and deletes many tasks in one transaction,
+// reporting the outcome of every operation
+func (h *TaskHandler) BulkTasks(c *gin.Context) {
+	var req models.BulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	result, err := h.bulkService.ForTenant(tenant(c)).Run(&req, actor(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to run bulk operations")
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Committed {
+		// Report the failure of the operation that rolled everything back
+		status = result.Results[len(result.Results)-1].Status
+	}
+	c.JSON(status, result)
+}
+
+// GetTaskHistory lists the revisions of a task with the fields each changed
+func (h *TaskHandler) GetTaskHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	revisions, err := h.taskService.ForTenant(tenant(c)).GetTaskHistory(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to fetch task history")
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// RevertTask brings a task back to the state of an earlier revision
+func (h *TaskHandler) RevertTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision"})
+		return
+	}
+
+	task, err := h.taskService.ForTenant(tenant(c)).RevertTask(id, revision, actor(c))
+	if err != nil {
+		respondWithError(c, err, "Failed to revert task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// ArchiveTask moves a completed task and its subtasks to the archive
+func (h *TaskHandler) ArchiveTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	task, err := h.taskService.ForTenant(tenant(c)).ArchiveTask(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to archive task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// UnarchiveTask brings a task back from the archive
+func (h *TaskHandler) UnarchiveTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	task, err := h.taskService.ForTenant(tenant(c)).UnarchiveTask(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to unarchive task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// GetSubtasks lists the direct children of a task
+func (h *TaskHandler) GetSubtasks(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	subtasks, err := h.taskService.ForTenant(tenant(c)).GetSubtasks(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtasks"})
+		}
+		return
+	}
+
+	if subtasks == nil {
+		subtasks = []*models.Task{}
+	}
+	c.JSON(http.StatusOK, subtasks)
+}
+
+// GetTaskTree returns a task with its full subtask hierarchy and roll-up progress
+func (h *TaskHandler) GetTaskTree(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	tree, err := h.taskService.ForTenant(tenant(c)).GetTaskTree(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task tree"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetDependencies lists the tasks blocking a task and the tasks it blocks
+func (h *TaskHandler) GetDependencies(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	deps, err := h.taskService.ForTenant(tenant(c)).GetDependencies(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dependencies"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, deps)
+}
+
+// AddDependency marks the task as blocked by another task
+func (h *TaskHandler) AddDependency(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var dep models.TaskDependency
+	if err := c.ShouldBindJSON(&dep); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency data"})
+		return
+	}
+	dep.BlockedID = id
+
+	if err := h.taskService.ForTenant(tenant(c)).AddDependency(dep.BlockerID, dep.BlockedID); err != nil {
+		if apiErr, ok := err.(*errors.APIError); ok {
+			c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		} else if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add dependency"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Dependency added successfully"})
+}
+
+// RemoveDependency removes a blocker from the task
+func (h *TaskHandler) RemoveDependency(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	blockerID, err := strconv.Atoi(c.Param("blockerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocking task ID"})
+		return
+	}
+
+	if err := h.taskService.ForTenant(tenant(c)).RemoveDependency(blockerID, id); err != nil {
+		if apiErr, ok := err.(*errors.APIError); ok {
+			c.JSON(apiErr.StatusCode, gin.H{"error": apiErr.Message})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove dependency"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully"})
+}
+
+// GetTaskPlan returns a task and its subtasks in dependency order together
+// with their critical path
+func (h *TaskHandler) GetTaskPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	plan, err := h.taskService.ForTenant(tenant(c)).GetTaskPlan(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build task plan"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// SearchTasks runs a full-text search over the tasks of the caller's
+// organisation
+func (h *TaskHandler) SearchTasks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	var cond taskfilter.Expr
+	if f := c.Query("filter"); f != "" {
+		cond, err = taskfilter.Parse(f, taskfilter.Env{Now: time.Now(), UserID: c.GetInt("userID")})
+		if err != nil {
+			respondWithError(c, err, "Invalid filter")
+			return
+		}
+	}
+
+	includeArchived, err := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include_archived must be 'true' or 'false'"})
+		return
+	}
+
+	query := c.Query("q")
+	results, err := h.taskService.ForTenant(tenant(c)).SearchTasks(query, cond, includeArchived, limit)
+	if err != nil {
+		respondWithError(c, err, "Failed to search tasks")
+		return
+	}
+
+	if results == nil {
+		results = []*models.SearchResult{}
+	}
+	c.JSON(http.StatusOK, gin.H{"query": query, "results": results})
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+func TestHasBodyField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"title": "t", "status": "TODO"}`, false},
+		{`{"title": "t", "status": "TODO", "parent_id": null}`, true},
+		{`{"title": "t", "status": "TODO", "parent_id": 3}`, true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, "/tasks/1", strings.NewReader(tt.body))
+		var task models.Task
+		if err := c.ShouldBindBodyWith(&task, binding.JSON); err != nil {
+			t.Fatalf("binding %s: %v", tt.body, err)
+		}
+		if got := hasBodyField(c, "parent_id"); got != tt.want {
+			t.Errorf("hasBodyField(%s) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestTaskListFilterDeduplicatesLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?labels=bug,%20Bug,ui,,bug&label_match=all", nil)
+
+	filter, ok := taskListFilter(c)
+	if !ok {
+		t.Fatal("taskListFilter refused the query")
+	}
+	if len(filter.Labels) != 2 || filter.Labels[0] != "bug" || filter.Labels[1] != "ui" {
+		t.Errorf("labels = %q, want [bug ui]", filter.Labels)
+	}
+	if !filter.MatchAllLabels {
+		t.Error("label_match=all was not applied")
+	}
+}
+
+func TestTaskListFilterRefusesUnknownLabelMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks?labels=bug&label_match=some", nil)
+
+	if _, ok := taskListFilter(c); ok {
+		t.Fatal("taskListFilter accepted label_match=some")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestTaskListFilterIncludeArchived(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		query      string
+		want       bool
+		wantStatus int
+	}{
+		{"", false, 0},
+		{"include_archived=true", true, 0},
+		{"include_archived=false", false, 0},
+		{"include_archived=maybe", false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/tasks?"+tt.query, nil)
+
+		filter, ok := taskListFilter(c)
+		if tt.wantStatus != 0 {
+			if ok || w.Code != tt.wantStatus {
+				t.Errorf("%q: ok = %v, status %d; want status %d", tt.query, ok, w.Code, tt.wantStatus)
+			}
+			continue
+		}
+		if !ok || filter.IncludeArchived != tt.want {
+			t.Errorf("%q: IncludeArchived = %v, ok = %v; want %v", tt.query, filter.IncludeArchived, ok, tt.want)
+		}
+	}
+}
+
+// exportTasks is a task service that streams count tasks and then fails with
+// err, if set
+type exportTasks struct {
+	service.TaskService
+	count int
+	err   error
+}
+
+func (s exportTasks) ForTenant(orgID int) service.TaskService { return s }
+
+func (s exportTasks) EachTask(filter models.TaskFilter, fn func(task *models.Task) error) error {
+	for id := 1; id <= s.count; id++ {
+		if err := fn(&models.Task{ID: id, Title: fmt.Sprintf("=task %d", id), Status: models.TaskStatusTodo}); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func runExport(tasks exportTasks, query string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/tasks/export?"+query, nil)
+	NewTaskHandler(tasks, nil, nil, 0).ExportTasks(c)
+	return w
+}
+
+func TestExportTasks(t *testing.T) {
+	w := runExport(exportTasks{count: 150}, "format=csv")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("status = %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 151 || !strings.HasPrefix(lines[150], "150,,'=task 150,") {
+		t.Errorf("%d lines ending in %q, want a header and 150 escaped tasks", len(lines), lines[len(lines)-1])
+	}
+
+	// An export without tasks is a file with only the header
+	w = runExport(exportTasks{}, "format=csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id,parent_id,title,") {
+		t.Errorf("empty export: status = %d, body %q", w.Code, w.Body.String())
+	}
+
+	w = runExport(exportTasks{}, "format=pdf")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("format=pdf: status = %d, want 400", w.Code)
+	}
+}
+
+func TestExportTasksFailure(t *testing.T) {
+	// A query that fails before any task is read can still be reported
+	w := runExport(exportTasks{err: errors.New("connection lost")}, "format=ndjson")
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Failed to export tasks") {
+		t.Errorf("status = %d, body %q; want 500", w.Code, w.Body.String())
+	}
+
+	// Later, the file can only be cut short
+	w = runExport(exportTasks{count: 2, err: errors.New("connection lost")}, "format=ndjson")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "error") || strings.Count(w.Body.String(), "\n") != 2 {
+		t.Errorf("status = %d, body %q; want the two tasks read", w.Code, w.Body.String())
+	}
+}
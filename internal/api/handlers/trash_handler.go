@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+// TrashHandler serves the deleted tasks and users of the current user's
+// organisation, which can be restored until they are purged
+type TrashHandler struct {
+	taskService service.TaskService
+	userService service.UserService
+}
+
+func NewTrashHandler(taskService service.TaskService, userService service.UserService) *TrashHandler {
+	return &TrashHandler{taskService: taskService, userService: userService}
+}
+
+// GetTrash lists the deleted tasks, and the deleted users for organisation admins
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	var trash models.Trash
+	var err error
+	if trash.Tasks, err = h.taskService.ForTenant(tenant(c)).ListDeletedTasks(); err != nil {
+		respondWithError(c, err, "Failed to retrieve the trash")
+		return
+	}
+	if trash.Tasks == nil {
+		trash.Tasks = []*models.Task{}
+	}
+	if isOrgAdmin(c) {
+		if trash.Users, err = h.userService.ForTenant(tenant(c)).ListDeletedUsers(); err != nil {
+			respondWithError(c, err, "Failed to retrieve the trash")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// RestoreTask takes a task, and the subtasks deleted along with it, out of the trash
+func (h *TrashHandler) RestoreTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	task, err := h.taskService.ForTenant(tenant(c)).RestoreTask(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to restore task")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// PurgeTask permanently deletes a task in the trash
+func (h *TrashHandler) PurgeTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	if err := h.taskService.ForTenant(tenant(c)).PurgeTask(id); err != nil {
+		respondWithError(c, err, "Failed to purge task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task permanently deleted"})
+}
+
+// RestoreUser takes a user out of the trash
+func (h *TrashHandler) RestoreUser(c *gin.Context) {
+	id, ok := h.deletedUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.ForTenant(tenant(c)).RestoreUser(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to restore user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// PurgeUser permanently deletes a user in the trash; their tasks are kept
+// without an owner
+func (h *TrashHandler) PurgeUser(c *gin.Context) {
+	id, ok := h.deletedUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.ForTenant(tenant(c)).PurgeUser(id); err != nil {
+		respondWithError(c, err, "Failed to purge user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User permanently deleted"})
+}
+
+// deletedUser returns the ID of the deleted user named by the :id parameter,
+// unless they are a platform admin and the caller is not, as with the
+// users that are not deleted
+func (h *TrashHandler) deletedUser(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+
+	user, err := h.userService.ForTenant(tenant(c)).GetDeletedUser(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve user")
+		return 0, false
+	}
+	if user.Role == models.UserRoleAdmin && models.UserRole(c.GetString("userRole")) != models.UserRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return 0, false
+	}
+	return id, true
+}
+
+// isOrgAdmin reports whether the caller administers their organisation,
+// which platform admins do too
+func isOrgAdmin(c *gin.Context) bool {
+	return models.OrgRole(c.GetString("orgRole")) == models.OrgRoleAdmin ||
+		models.UserRole(c.GetString("userRole")) == models.UserRoleAdmin
+}
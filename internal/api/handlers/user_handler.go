@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
@@ -13,11 +14,12 @@ import (
 )
 
 type UserHandler struct {
-	userService service.UserService
+	userService    service.UserService
+	accountService service.AccountService
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService service.UserService, accountService service.AccountService) *UserHandler {
+	return &UserHandler{userService: userService, accountService: accountService}
 }
 
 // RegisterUser handles user registration
@@ -39,9 +41,6 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 	if newUser.Password == "" {
 		errors["password"] = "required"
 	}
-	if newUser.Role == "" {
-		errors["role"] = "required"
-	}
 
 	if len(errors) > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": errors})
@@ -55,22 +54,24 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	// Validate password length
-	if len(newUser.Password) < 8 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": gin.H{"password": "too short, minimum 8 characters required"}})
-		return
-	}
-
-	user, err := h.userService.CreateUser(&newUser)
+	// The password policy and registration mode are applied by the service.
+	// Registering joins the default organisation as a USER; other
+	// organisations add or invite their members themselves.
+	user, err := h.userService.Register(&newUser)
 	if err != nil {
 		if err.Error() == "username already exists" || err.Error() == "email already exists" {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			respondWithError(c, err, "Failed to create user")
 		}
 		return
 	}
 
+	// The account exists either way; the user can ask for another link
+	if err := h.accountService.SendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -94,13 +95,30 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.userService.Authenticate(&credentials)
+	result, err := h.userService.Authenticate(&credentials, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if _, ok := err.(*service.LoginLockedError); ok {
+			respondWithError(c, err, "Failed to log in")
+		} else if err.Error() == "email not verified" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		}
+		return
+	}
+
+	if result.MFAToken != "" {
+		// The password was right; the login finishes at /users/login/mfa
+		c.JSON(http.StatusOK, gin.H{
+			"message":                 "Two-factor authentication required",
+			"mfa_required":            result.MFARequired,
+			"mfa_enrollment_required": result.MFAEnrollmentRequired,
+			"mfa_token":               result.MFAToken,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": user, "token": token})
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful", "user": result.User, "token": result.Token})
 }
 
 // GetUser retrieves a user by ID
@@ -112,7 +130,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(id)
+	user, err := h.userService.ForTenant(tenant(c)).GetUserByID(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -136,7 +154,13 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	err = h.userService.UpdateUser(id, &updates)
+	// Organisation admins manage their members but not platform roles
+	if updates.Role != nil && models.UserRole(c.GetString("userRole")) != models.UserRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only platform admins can change roles"})
+		return
+	}
+
+	err = h.userService.ForTenant(tenant(c)).UpdateUser(id, &updates)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -154,7 +178,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err = h.userService.DeleteUser(id)
+	err = h.userService.ForTenant(tenant(c)).DeleteUser(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -180,7 +204,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		return
 	}
 
-	users, err := h.userService.ListUsers(page, pageSize)
+	users, err := h.userService.ForTenant(tenant(c)).ListUsers(page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
 		return
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+	"task-management-api/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// CreateWebhook subscribes a URL to events. If no secret is given one is generated.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.NewWebhook
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	webhook := &models.Webhook{URL: req.URL, Secret: req.Secret, EventTypes: req.EventTypes}
+	if err := h.webhookService.CreateWebhook(webhook, c.GetInt("userID")); err != nil {
+		respondWithError(c, err, "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreatedWebhook{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// ListWebhooks lists all webhook subscriptions
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhookService.ListWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks"})
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook retrieves a webhook by ID
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook changes the URL, secret, event types or active flag of a webhook
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var updates models.UpdateWebhook
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": getValidationErrors(err)})
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(id, &updates)
+	if err != nil {
+		respondWithError(c, err, "Failed to update webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook deletes a webhook and its delivery history
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(id); err != nil {
+		respondWithError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListDeliveries lists the most recent deliveries of a webhook
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(id)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery retrieves a delivery with the log of its attempts
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(id, deliveryID)
+	if err != nil {
+		respondWithError(c, err, "Failed to retrieve delivery")
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Redeliver queues a delivery to be sent again, including dead ones
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(id, deliveryID)
+	if err != nil {
+		respondWithError(c, err, "Failed to redeliver")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func deliveryParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, 0, false
+	}
+	deliveryID, err := strconv.Atoi(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return 0, 0, false
+	}
+	return id, deliveryID, true
+}
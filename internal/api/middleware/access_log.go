@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedParams are query parameters that carry credentials and must not
+// end up in the access log
+var redactedParams = map[string]bool{"access_token": true}
+
+// AccessLog logs every request like gin's default logger, but with the
+// values of credential query parameters such as access_token replaced
+func AccessLog() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: accessLogFormatter})
+}
+
+// accessLogFormatter formats a request the way gin's default formatter does
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactQuery replaces the values of the redacted parameters in the query of
+// a path, leaving the rest of it as it was sent
+func redactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	params := strings.Split(path[i+1:], "&")
+	for j, param := range params {
+		name := param
+		if k := strings.IndexByte(param, '='); k >= 0 {
+			name = param[:k]
+		}
+		if redactedParams[name] {
+			params[j] = name + "=REDACTED"
+		}
+	}
+	return path[:i+1] + strings.Join(params, "&")
+}
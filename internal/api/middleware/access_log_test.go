@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/stream":                                    "/api/v1/stream",
+		"/api/v1/stream?access_token=secret":                "/api/v1/stream?access_token=REDACTED",
+		"/api/v1/stream?last_id=4&access_token=a%2Bb&x=1":   "/api/v1/stream?last_id=4&access_token=REDACTED&x=1",
+		"/api/v1/stream?access_token":                       "/api/v1/stream?access_token=REDACTED",
+		"/api/v1/stream?my_access_token=kept&access_token=": "/api/v1/stream?my_access_token=kept&access_token=REDACTED",
+	}
+	for path, want := range tests {
+		if got := redactQuery(path); got != want {
+			t.Errorf("redactQuery(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAccessLogLeavesOutQueryTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &out
+	defer func() { gin.DefaultWriter = defaultWriter }()
+
+	router := gin.New()
+	router.Use(AccessLog(), TokenFromQuery())
+	router.GET("/stream", func(c *gin.Context) { c.String(http.StatusOK, c.GetHeader("Authorization")) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream?access_token=secret-token", nil))
+
+	if w.Body.String() != "Bearer secret-token" {
+		t.Errorf("handler saw %q, want the token from the query", w.Body.String())
+	}
+	if strings.Contains(out.String(), "secret-token") {
+		t.Errorf("access log contains the token: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "/stream?access_token=REDACTED") {
+		t.Errorf("access log = %q, want the redacted path", out.String())
+	}
+}
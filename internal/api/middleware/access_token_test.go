@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, route string
+		want          string
+		ok            bool
+	}{
+		{http.MethodGet, "/api/v1/tasks/:id", "tasks:read", true},
+		{http.MethodHead, "/api/v1/tasks", "tasks:read", true},
+		{http.MethodPut, "/api/v1/tasks/:id", "tasks:write", true},
+		{http.MethodGet, "/api/v1/search", "tasks:read", true},
+		{http.MethodPost, "/api/v1/labels", "labels:write", true},
+		{http.MethodGet, "/api/v1/me", "users:read", true},
+		{http.MethodGet, "/api/v1/users/:id/tokens", "", false},
+		{http.MethodPost, "/api/v1/users/:id/mfa", "", false},
+		{http.MethodGet, "/api/v1/organizations", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := requiredScope(tt.method, tt.route)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("requiredScope(%s %s) = %q, %v; want %q, %v", tt.method, tt.route, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		granted []string
+		scope   string
+		want    bool
+	}{
+		{[]string{"tasks:read"}, "tasks:read", true},
+		{[]string{"tasks:write"}, "tasks:read", true},
+		{[]string{"tasks:read"}, "tasks:write", false},
+		{[]string{"labels:write"}, "tasks:read", false},
+		{nil, "tasks:read", false},
+	}
+	for _, tt := range tests {
+		if got := hasScope(tt.granted, tt.scope); got != tt.want {
+			t.Errorf("hasScope(%v, %s) = %v, want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,22 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
 	"task-management-api/pkg/jwt"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// SessionChecker reports whether the session a token was issued for is still
+// active, so that revoked sessions stop working before their tokens expire
+type SessionChecker interface {
+	CheckSession(sessionID string, userID int) error
+}
+
+func AuthMiddleware(sessions SessionChecker, tokens TokenAuthenticator, oauth OAuthTokenChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,16 +32,41 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Personal access tokens are told apart from JWTs by their prefix
+		if strings.HasPrefix(bearerToken[1], models.TokenPrefix) {
+			authenticateAccessToken(c, tokens, bearerToken[1])
+			return
+		}
+
+		// Tokens issued to OAuth clients are JWTs too, with their own purpose
+		if claims, err := jwt.ValidateOAuthToken(bearerToken[1]); err == nil {
+			authenticateOAuthToken(c, oauth, claims)
+			return
+		}
+
 		claims, err := jwt.ValidateToken(bearerToken[1])
+		// Tokens issued before there were organisations do not name one
+		if err == nil && claims.OrgID == 0 {
+			err = errors.New("token has no organization")
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		if err := sessions.CheckSession(claims.ID, claims.UserID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked or has expired"})
+			c.Abort()
+			return
+		}
+
 		// Set user information in the context
 		c.Set("userID", claims.UserID)
 		c.Set("userRole", claims.Role)
+		c.Set("orgID", claims.OrgID)
+		c.Set("orgRole", claims.OrgRole)
+		c.Set("sessionID", claims.ID)
 
 		c.Next()
 	}
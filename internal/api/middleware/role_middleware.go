@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/internal/models"
+)
+
+// RequireRole only lets through users whose role, as set by AuthMiddleware,
+// is one of the given roles
+func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := models.UserRole(c.GetString("userRole"))
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// RequireOrgAdmin only lets through admins of the user's organisation and
+// platform admins. What they can reach is still confined to the
+// organisation; see TenantUser.
+func RequireOrgAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if models.OrgRole(c.GetString("orgRole")) == models.OrgRoleAdmin ||
+			models.UserRole(c.GetString("userRole")) == models.UserRoleAdmin {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// TenantMemberLookup finds the users of an organisation
+type TenantMemberLookup interface {
+	GetMember(orgID, userID int) (*models.User, error)
+}
+
+// TenantUser refuses requests about a user, named by the :id parameter, who
+// is not in the caller's organisation, as if there were no such user
+func TenantUser(members TenantMemberLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenantUser(c, members) != nil {
+			c.Next()
+		}
+	}
+}
+
+// ManagedUser is TenantUser for requests that change the user, which
+// organisation admins may not do to platform admins
+func ManagedUser(members TenantMemberLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := tenantUser(c, members)
+		if user == nil {
+			return
+		}
+		if user.Role == models.UserRoleAdmin && models.UserRole(c.GetString("userRole")) != models.UserRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// tenantUser returns the user named by the :id parameter if they are in the
+// caller's organisation, or else aborts the request and returns nil
+func tenantUser(c *gin.Context, members TenantMemberLookup) *models.User {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Abort()
+		return nil
+	}
+	user, err := members.GetMember(c.GetInt("orgID"), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.Abort()
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+	"task-management-api/internal/api/middleware"
+)
+
+// NewRouter creates the Gin engine with the middleware shared by all routes.
+// The access log leaves out tokens passed in the query string, and client
+// addresses only come from X-Forwarded-For when a trusted proxy sent it.
+func NewRouter(cfg config.ServerConfig) (*gin.Engine, error) {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+	router.Use(middleware.AccessLog(), gin.Recovery())
+	return router, nil
+}
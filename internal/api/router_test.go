@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"task-management-api/config"
+)
+
+func clientIP(t *testing.T, cfg config.ServerConfig, remoteAddr, forwardedFor string) string {
+	t.Helper()
+	router, err := NewRouter(cfg)
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestNewRouterClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	behindProxy := config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}}
+
+	tests := []struct {
+		name       string
+		cfg        config.ServerConfig
+		remoteAddr string
+		want       string
+	}{
+		{"no trusted proxies ignores the header", config.ServerConfig{}, "10.0.0.5:4000", "10.0.0.5"},
+		{"a trusted proxy is believed", behindProxy, "10.0.0.5:4000", "203.0.113.7"},
+		{"anyone else is not", behindProxy, "198.51.100.9:4000", "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIP(t, tt.cfg, tt.remoteAddr, "203.0.113.7"); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRouterRejectsInvalidProxies(t *testing.T) {
+	if _, err := NewRouter(config.ServerConfig{TrustedProxies: []string{"not an address"}}); err == nil {
+		t.Error("NewRouter accepted an invalid proxy")
+	}
+}
@@ -4,29 +4,169 @@ import (
 	"github.com/gin-gonic/gin"
 	"task-management-api/internal/api/handlers"
 	"task-management-api/internal/api/middleware"
+	"task-management-api/internal/models"
 )
 
-func SetupRoutes(router *gin.Engine, taskHandler *handlers.TaskHandler, userHandler *handlers.UserHandler) {
+func SetupRoutes(router *gin.Engine, taskHandler *handlers.TaskHandler, userHandler *handlers.UserHandler, labelHandler *handlers.LabelHandler, attachmentHandler *handlers.AttachmentHandler, commentHandler *handlers.CommentHandler, filterHandler *handlers.FilterHandler, webhookHandler *handlers.WebhookHandler, streamHandler *handlers.StreamHandler, accountHandler *handlers.AccountHandler, meHandler *handlers.MeHandler, mfaHandler *handlers.MFAHandler, securityHandler *handlers.SecurityHandler, accessTokenHandler *handlers.AccessTokenHandler, oidcHandler *handlers.OIDCHandler, oauthHandler *handlers.OAuthHandler, organizationHandler *handlers.OrganizationHandler, invitationHandler *handlers.InvitationHandler, trashHandler *handlers.TrashHandler, batchHandler *handlers.BatchHandler, sessions middleware.SessionChecker, tokens middleware.TokenAuthenticator, oauth middleware.OAuthTokenChecker, members middleware.TenantMemberLookup) {
+	// OAuth authorization server metadata (RFC 8414)
+	router.GET("/.well-known/oauth-authorization-server", oauthHandler.Metadata)
+
 	v1 := router.Group("/api/v1")
 	{
 		// Public routes
 		users := v1.Group("/users")
 		{
 			users.POST("/register", userHandler.RegisterUser)
+			users.POST("/invitations/accept", invitationHandler.AcceptInvitation)
 			users.POST("/login", userHandler.Login)
+			users.POST("/login/mfa", mfaHandler.Login)
+			users.POST("/login/mfa/enroll", mfaHandler.StartLoginEnrollment)
+			users.POST("/login/mfa/enroll/confirm", mfaHandler.CompleteLoginEnrollment)
+			users.GET("/oidc/login", oidcHandler.Login)
+			users.GET("/oidc/callback", oidcHandler.Callback)
+
+			users.POST("/verify-email", accountHandler.VerifyEmail)
+			users.POST("/verify-email/resend", accountHandler.ResendVerification)
+			users.POST("/password/forgot", accountHandler.ForgotPassword)
+			users.POST("/password/reset", accountHandler.ResetPassword)
+		}
+
+		// OAuth protocol endpoints; apps authenticate with their own credentials
+		oauthProtocol := v1.Group("/oauth")
+		{
+			oauthProtocol.POST("/token", oauthHandler.Token)
+			oauthProtocol.POST("/introspect", oauthHandler.Introspect)
+			oauthProtocol.POST("/revoke", oauthHandler.Revoke)
+		}
+
+		// Real-time task events, over SSE or WebSocket
+		stream := v1.Group("/stream")
+		stream.Use(middleware.TokenFromQuery(), middleware.AuthMiddleware(sessions, tokens, oauth))
+		{
+			stream.GET("", streamHandler.Stream)
 		}
 
 		// Protected routes
 		authenticated := v1.Group("/")
-		authenticated.Use(middleware.AuthMiddleware())
+		authenticated.Use(middleware.AuthMiddleware(sessions, tokens, oauth))
 		{
-			// User routes
+			// The current user's own account
+			me := authenticated.Group("/me")
+			{
+				me.GET("", meHandler.GetProfile)
+				me.PUT("", meHandler.UpdateProfile)
+				me.DELETE("", meHandler.DeleteAccount)
+				me.POST("/cancel-deletion", meHandler.CancelDeletion)
+				me.PUT("/password", meHandler.ChangePassword)
+				me.PUT("/email", meHandler.ChangeEmail)
+
+				me.GET("/sessions", meHandler.ListSessions)
+				me.DELETE("/sessions", meHandler.RevokeOtherSessions)
+				me.DELETE("/sessions/:sessionId", meHandler.RevokeSession)
+
+				me.GET("/mfa", mfaHandler.GetStatus)
+				me.DELETE("/mfa", mfaHandler.Disable)
+				me.POST("/mfa/totp", mfaHandler.StartEnrollment)
+				me.POST("/mfa/totp/confirm", mfaHandler.ConfirmEnrollment)
+				me.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+				me.GET("/tokens", accessTokenHandler.ListTokens)
+				me.POST("/tokens", accessTokenHandler.CreateToken)
+				me.DELETE("/tokens/:tokenId", accessTokenHandler.RevokeToken)
+
+				me.GET("/authorizations", oauthHandler.ListAuthorizations)
+				me.DELETE("/authorizations/:clientId", oauthHandler.RevokeAuthorization)
+			}
+
+			// User routes, confined to the current organisation; changing
+			// other accounts is for organisation admins only
 			users := authenticated.Group("/users")
 			{
+				orgAdmin := middleware.RequireOrgAdmin()
 				users.GET("", userHandler.ListUsers)
-				users.GET("/:id", userHandler.GetUser)
-				users.PUT("/:id", userHandler.UpdateUser)
-				users.DELETE("/:id", userHandler.DeleteUser)
+				users.GET("/:id", middleware.TenantUser(members), userHandler.GetUser)
+				users.PUT("/:id", orgAdmin, middleware.ManagedUser(members), userHandler.UpdateUser)
+				users.DELETE("/:id", orgAdmin, middleware.ManagedUser(members), userHandler.DeleteUser)
+				users.DELETE("/:id/mfa", orgAdmin, middleware.ManagedUser(members), mfaHandler.ResetUser)
+				users.POST("/:id/unlock", orgAdmin, middleware.ManagedUser(members), securityHandler.UnlockUser)
+				users.GET("/:id/tokens", orgAdmin, middleware.TenantUser(members), accessTokenHandler.ListUserTokens)
+				users.DELETE("/:id/tokens/:tokenId", orgAdmin, middleware.ManagedUser(members), accessTokenHandler.RevokeUserToken)
+			}
+
+			// The current user's organisation, its members and teams
+			org := authenticated.Group("/org")
+			{
+				orgAdmin := middleware.RequireOrgAdmin()
+				org.GET("", organizationHandler.GetCurrent)
+				org.PUT("", orgAdmin, organizationHandler.UpdateCurrent)
+				org.POST("/members", orgAdmin, organizationHandler.CreateMember)
+				org.PUT("/members/:id/role", orgAdmin, organizationHandler.SetMemberRole)
+
+				org.GET("/invitations", orgAdmin, invitationHandler.ListInvitations)
+				org.POST("/invitations", orgAdmin, invitationHandler.CreateInvitation)
+				org.DELETE("/invitations/:id", orgAdmin, invitationHandler.RevokeInvitation)
+
+				org.GET("/teams", organizationHandler.ListTeams)
+				org.POST("/teams", orgAdmin, organizationHandler.CreateTeam)
+				org.GET("/teams/:id", organizationHandler.GetTeam)
+				org.PUT("/teams/:id", orgAdmin, organizationHandler.UpdateTeam)
+				org.DELETE("/teams/:id", orgAdmin, organizationHandler.DeleteTeam)
+				org.GET("/teams/:id/members", organizationHandler.ListTeamMembers)
+				org.PUT("/teams/:id/members/:userId", orgAdmin, organizationHandler.AddTeamMember)
+				org.DELETE("/teams/:id/members/:userId", orgAdmin, organizationHandler.RemoveTeamMember)
+			}
+
+			// Deleted tasks and users of the current organisation, until
+			// they are purged
+			trash := authenticated.Group("/trash")
+			{
+				orgAdmin := middleware.RequireOrgAdmin()
+				trash.GET("", trashHandler.GetTrash)
+				trash.POST("/tasks/:id/restore", trashHandler.RestoreTask)
+				trash.DELETE("/tasks/:id", orgAdmin, trashHandler.PurgeTask)
+				trash.POST("/users/:id/restore", orgAdmin, trashHandler.RestoreUser)
+				trash.DELETE("/users/:id", orgAdmin, trashHandler.PurgeUser)
+			}
+
+			// Every organisation, for platform admins
+			organizations := authenticated.Group("/organizations")
+			organizations.Use(middleware.RequireRole(models.UserRoleAdmin))
+			{
+				organizations.GET("", organizationHandler.ListOrganizations)
+				organizations.POST("", organizationHandler.CreateOrganization)
+				organizations.GET("/:id", organizationHandler.GetOrganization)
+				organizations.PUT("/:id", organizationHandler.UpdateOrganization)
+				organizations.POST("/:id/members", organizationHandler.CreateOrganizationMember)
+			}
+
+			// Addresses blocked after repeated failed logins
+			security := authenticated.Group("/security")
+			security.Use(middleware.RequireRole(models.UserRoleAdmin))
+			{
+				security.GET("/blocked-ips", securityHandler.ListBlockedIPs)
+				security.DELETE("/blocked-ips/:ip", securityHandler.UnblockIP)
+			}
+
+			// Two-factor policy: which roles must use it
+			mfa := authenticated.Group("/mfa")
+			mfa.Use(middleware.RequireRole(models.UserRoleAdmin))
+			{
+				mfa.GET("/policy", mfaHandler.GetPolicy)
+				mfa.PUT("/policy", mfaHandler.UpdatePolicy)
+			}
+
+			// OAuth consent, behind the frontend's consent page, and app registration
+			oauthApps := authenticated.Group("/oauth")
+			{
+				oauthApps.GET("/authorize", oauthHandler.GetAuthorization)
+				oauthApps.POST("/authorize", oauthHandler.Authorize)
+
+				oauthApps.GET("/clients", oauthHandler.ListClients)
+				oauthApps.POST("/clients", oauthHandler.CreateClient)
+				oauthApps.GET("/clients/:id", oauthHandler.GetClient)
+				oauthApps.PUT("/clients/:id", oauthHandler.UpdateClient)
+				oauthApps.DELETE("/clients/:id", oauthHandler.DeleteClient)
+				oauthApps.POST("/clients/:id/secret", oauthHandler.RotateSecret)
 			}
 
 			// Task routes
@@ -35,9 +175,86 @@ func SetupRoutes(router *gin.Engine, taskHandler *handlers.TaskHandler, userHand
 				tasks.GET("", taskHandler.GetAllTasks)
 				tasks.GET("/:id", taskHandler.GetTaskByID)
 				tasks.POST("", taskHandler.CreateTask)
+				tasks.POST("/bulk", taskHandler.BulkTasks)
+				tasks.GET("/export", taskHandler.ExportTasks)
+				tasks.POST("/import", taskHandler.ImportTasks)
 				tasks.PUT("/:id", taskHandler.UpdateTask)
 				tasks.DELETE("/:id", taskHandler.DeleteTask)
+				tasks.POST("/:id/archive", taskHandler.ArchiveTask)
+				tasks.POST("/:id/unarchive", taskHandler.UnarchiveTask)
+				tasks.GET("/:id/history", taskHandler.GetTaskHistory)
+				tasks.POST("/:id/revisions/:revision/revert", taskHandler.RevertTask)
+
+				tasks.GET("/:id/children", taskHandler.GetSubtasks)
+				tasks.GET("/:id/tree", taskHandler.GetTaskTree)
+				tasks.GET("/:id/plan", taskHandler.GetTaskPlan)
+
+				tasks.GET("/:id/dependencies", taskHandler.GetDependencies)
+				tasks.POST("/:id/dependencies", taskHandler.AddDependency)
+				tasks.DELETE("/:id/dependencies/:blockerId", taskHandler.RemoveDependency)
+
+				tasks.GET("/:id/labels", labelHandler.GetTaskLabels)
+				tasks.POST("/:id/labels", labelHandler.AttachLabel)
+				tasks.DELETE("/:id/labels/:labelId", labelHandler.DetachLabel)
+
+				tasks.GET("/:id/attachments", attachmentHandler.ListAttachments)
+				tasks.POST("/:id/attachments", attachmentHandler.UploadAttachment)
+				tasks.GET("/:id/attachments/:attachmentId", attachmentHandler.DownloadAttachment)
+				tasks.DELETE("/:id/attachments/:attachmentId", attachmentHandler.DeleteAttachment)
+
+				tasks.GET("/:id/comments", commentHandler.ListComments)
+				tasks.POST("/:id/comments", commentHandler.AddComment)
+				tasks.DELETE("/:id/comments/:commentId", commentHandler.DeleteComment)
+			}
+
+			// Search routes
+			authenticated.GET("/search", taskHandler.SearchTasks)
+
+			// Several requests in one round trip, each authenticated again
+			authenticated.POST("/batch", batchHandler.Batch)
+
+			// Saved filter routes
+			filters := authenticated.Group("/filters")
+			{
+				filters.GET("", filterHandler.ListFilters)
+				filters.GET("/:id", filterHandler.GetFilter)
+				filters.GET("/:id/tasks", filterHandler.RunFilter)
+				filters.POST("", filterHandler.CreateFilter)
+				filters.PUT("/:id", filterHandler.UpdateFilter)
+				filters.DELETE("/:id", filterHandler.DeleteFilter)
+			}
+
+			// Label routes
+			labels := authenticated.Group("/labels")
+			{
+				labels.GET("", labelHandler.ListLabels)
+				labels.GET("/:id", labelHandler.GetLabel)
+				labels.POST("", labelHandler.CreateLabel)
+
+				// Labels belong to the organisation, so its admins manage them
+				admin := labels.Group("")
+				admin.Use(middleware.RequireOrgAdmin())
+				{
+					admin.PUT("/:id", labelHandler.UpdateLabel)
+					admin.DELETE("/:id", labelHandler.DeleteLabel)
+					admin.POST("/:id/merge", labelHandler.MergeLabel)
+				}
+			}
+
+			// Webhook routes
+			webhooks := authenticated.Group("/webhooks")
+			webhooks.Use(middleware.RequireRole(models.UserRoleAdmin))
+			{
+				webhooks.GET("", webhookHandler.ListWebhooks)
+				webhooks.GET("/:id", webhookHandler.GetWebhook)
+				webhooks.POST("", webhookHandler.CreateWebhook)
+				webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+				webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+
+				webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+				webhooks.GET("/:id/deliveries/:deliveryId", webhookHandler.GetDelivery)
+				webhooks.POST("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
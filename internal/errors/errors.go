@@ -30,4 +30,39 @@ func NewInternalServerError(message string) *APIError {
 		StatusCode: http.StatusInternalServerError,
 		Message:    message,
 	}
-}
\ No newline at end of file
+}
+
+func NewConflictError(message string) *APIError {
+	return &APIError{
+		StatusCode: http.StatusConflict,
+		Message:    message,
+	}
+}
+
+func NewPayloadTooLargeError(message string) *APIError {
+	return &APIError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Message:    message,
+	}
+}
+
+func NewUnsupportedMediaTypeError(message string) *APIError {
+	return &APIError{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Message:    message,
+	}
+}
+
+func NewForbiddenError(message string) *APIError {
+	return &APIError{
+		StatusCode: http.StatusForbidden,
+		Message:    message,
+	}
+}
+
+func NewUnauthorizedError(message string) *APIError {
+	return &APIError{
+		StatusCode: http.StatusUnauthorized,
+		Message:    message,
+	}
+}
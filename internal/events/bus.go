@@ -0,0 +1,105 @@
+package events
+
+import "sync"
+
+// Message is an event as delivered by the Bus, numbered in publish order so
+// that subscribers can resume after a disconnect
+type Message struct {
+	Seq   uint64
+	Event Event
+}
+
+// Bus is an in-process Publisher that fans events out to live subscribers.
+// It keeps the most recent events so a subscriber can pick up where it left
+// off. Publishing never blocks: a subscriber that falls a full buffer behind
+// is dropped, and is expected to reconnect and resume.
+type Bus struct {
+	mu           sync.Mutex
+	seq          uint64
+	backlog      []Message
+	backlogSize  int
+	clientBuffer int
+	subscribers  map[*Subscription]struct{}
+}
+
+// Subscription receives messages from a Bus on C until it is closed, either
+// by the subscriber or by the bus when the subscriber falls behind
+type Subscription struct {
+	C   <-chan Message
+	c   chan Message
+	bus *Bus
+}
+
+// NewBus creates a Bus that remembers the last backlogSize events and buffers
+// up to clientBuffer undelivered messages per subscriber
+func NewBus(backlogSize, clientBuffer int) *Bus {
+	return &Bus{
+		backlogSize:  backlogSize,
+		clientBuffer: clientBuffer,
+		subscribers:  make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Publish(event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	msg := Message{Seq: b.seq, Event: event}
+	b.backlog = append(b.backlog, msg)
+	if len(b.backlog) > b.backlogSize {
+		b.backlog = b.backlog[len(b.backlog)-b.backlogSize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.c <- msg:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.c)
+		}
+	}
+	return nil
+}
+
+// Subscribe starts receiving events. When resume is set, the events
+// published after lastSeq are replayed first; ok is false if some of them
+// are no longer in the backlog (or lastSeq is from before a restart), in
+// which case nothing is replayed and the subscriber should reload its state.
+func (b *Bus) Subscribe(lastSeq uint64, resume bool) (sub *Subscription, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Message
+	ok = true
+	if resume {
+		oldest := b.seq + 1
+		if len(b.backlog) > 0 {
+			oldest = b.backlog[0].Seq
+		}
+		if lastSeq > b.seq || lastSeq+1 < oldest {
+			ok = false
+		} else {
+			replay = b.backlog[len(b.backlog)-int(b.seq-lastSeq):]
+		}
+	}
+
+	c := make(chan Message, b.clientBuffer+len(replay))
+	for _, msg := range replay {
+		c <- msg
+	}
+	sub = &Subscription{C: c, c: c, bus: b}
+	b.subscribers[sub] = struct{}{}
+	return sub, ok
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.c)
+	}
+}
@@ -0,0 +1,200 @@
+// Package events defines the domain events emitted by the services when
+// tasks and users change, and the publishers they are handed to.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+const (
+	TaskCreated       = "task.created"
+	TaskUpdated       = "task.updated"
+	TaskStatusChanged = "task.status_changed"
+	TaskDeleted       = "task.deleted"
+	TaskRestored      = "task.restored"
+	TaskArchived      = "task.archived"
+	TaskUnarchived    = "task.unarchived"
+	UserCreated       = "user.created"
+	UserUpdated       = "user.updated"
+	UserDeactivated   = "user.deactivated"
+	UserDeleted       = "user.deleted"
+	UserRestored      = "user.restored"
+
+	// Security events, kept as an audit trail of two-factor authentication
+	// and failed logins
+	UserMFAEnabled                = "user.mfa_enabled"
+	UserMFADisabled               = "user.mfa_disabled"
+	UserMFAFailed                 = "user.mfa_failed"
+	UserMFARecoveryCodeUsed       = "user.mfa_recovery_code_used"
+	UserMFARecoveryCodesGenerated = "user.mfa_recovery_codes_generated"
+	UserLoginFailed               = "user.login_failed"
+	UserLocked                    = "user.locked"
+	UserUnlocked                  = "user.unlocked"
+	IPBlocked                     = "security.ip_blocked"
+	IPUnblocked                   = "security.ip_unblocked"
+	UserTokenCreated              = "user.token_created"
+	UserTokenRevoked              = "user.token_revoked"
+	UserIdentityLinked            = "user.identity_linked"
+	UserAppAuthorized             = "user.app_authorized"
+	UserAppRevoked                = "user.app_revoked"
+)
+
+// Types lists every event type that can be emitted
+var Types = []string{
+	TaskCreated, TaskUpdated, TaskStatusChanged, TaskDeleted, TaskRestored, TaskArchived, TaskUnarchived,
+	UserCreated, UserUpdated, UserDeactivated, UserDeleted, UserRestored,
+	UserMFAEnabled, UserMFADisabled, UserMFAFailed, UserMFARecoveryCodeUsed, UserMFARecoveryCodesGenerated,
+	UserLoginFailed, UserLocked, UserUnlocked, IPBlocked, IPUnblocked,
+	UserTokenCreated, UserTokenRevoked, UserIdentityLinked, UserAppAuthorized, UserAppRevoked,
+}
+
+// Event is something that happened to a task or a user
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// New creates an event of the given type with a random ID
+func New(eventType string, data interface{}) Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return Event{
+		ID:         hex.EncodeToString(id),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// Publisher receives events relayed from the outbox. An error means the
+// event was not accepted and will be offered again later, so publishers
+// must tolerate seeing the same event (same ID) more than once.
+type Publisher interface {
+	Publish(event Event) error
+}
+
+// Aggregate returns the kind and ID of the task or user the event is about.
+// Events about the same aggregate are published in the order they happened.
+func (e Event) Aggregate() (kind string, id int) {
+	switch data := e.Data.(type) {
+	case *models.Task:
+		return "task", data.ID
+	case StatusChangedData:
+		return "task", data.Task.ID
+	case TaskDeletedData:
+		return "task", data.ID
+	case *models.User:
+		return "user", data.ID
+	case UserDeletedData:
+		return "user", data.ID
+	case SecurityData:
+		if data.UserID == 0 {
+			// About an address, or a username that matches no account
+			return "", 0
+		}
+		return "user", data.UserID
+	default:
+		return "", 0
+	}
+}
+
+// Unmarshal decodes a JSON-encoded event, restoring the payload type that
+// matches its event type
+func Unmarshal(payload []byte) (Event, error) {
+	var raw struct {
+		ID         string          `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Data       json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return Event{}, fmt.Errorf("error decoding event: %v", err)
+	}
+
+	event := Event{ID: raw.ID, Type: raw.Type, OccurredAt: raw.OccurredAt}
+	var err error
+	switch raw.Type {
+	case TaskCreated, TaskUpdated, TaskRestored, TaskArchived, TaskUnarchived:
+		data := &models.Task{}
+		err = json.Unmarshal(raw.Data, data)
+		event.Data = data
+	case TaskStatusChanged:
+		var data StatusChangedData
+		err = json.Unmarshal(raw.Data, &data)
+		event.Data = data
+	case TaskDeleted:
+		var data TaskDeletedData
+		err = json.Unmarshal(raw.Data, &data)
+		event.Data = data
+	case UserCreated, UserUpdated, UserDeactivated, UserRestored:
+		data := &models.User{}
+		err = json.Unmarshal(raw.Data, data)
+		event.Data = data
+	case UserDeleted:
+		var data UserDeletedData
+		err = json.Unmarshal(raw.Data, &data)
+		event.Data = data
+	case UserMFAEnabled, UserMFADisabled, UserMFAFailed, UserMFARecoveryCodeUsed, UserMFARecoveryCodesGenerated,
+		UserLoginFailed, UserLocked, UserUnlocked, IPBlocked, IPUnblocked,
+		UserTokenCreated, UserTokenRevoked, UserIdentityLinked, UserAppAuthorized, UserAppRevoked:
+		var data SecurityData
+		err = json.Unmarshal(raw.Data, &data)
+		event.Data = data
+	default:
+		event.Data = raw.Data
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("error decoding %s event: %v", raw.Type, err)
+	}
+	return event, nil
+}
+
+// TaskDeletedData is the payload of a task.deleted event
+type TaskDeletedData struct {
+	ID     int  `json:"id"`
+	OrgID  int  `json:"org_id"`
+	UserID *int `json:"user_id"`
+}
+
+// StatusChangedData is the payload of a task.status_changed event
+type StatusChangedData struct {
+	Task      *models.Task `json:"task"`
+	OldStatus string       `json:"old_status"`
+	NewStatus string       `json:"new_status"`
+}
+
+// UserDeletedData is the payload of a user.deleted event
+type UserDeletedData struct {
+	ID int `json:"id"`
+}
+
+// SecurityData is the payload of a security event about a user's account or
+// a client address
+type SecurityData struct {
+	// UserID is 0 when the event is not about an existing account
+	UserID   int    `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	// ActorID is the user who made the change, when it was not the user
+	// themselves, such as an admin resetting two-factor authentication
+	ActorID   *int   `json:"actor_id,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	// Method is how the user authenticated: "totp" or "recovery_code"
+	Method string `json:"method,omitempty"`
+	// Failures is the number of failed attempts in a row
+	Failures    int        `json:"failures,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	// TokenID is the personal access token the event is about
+	TokenID int `json:"token_id,omitempty"`
+	// Issuer is the identity provider an account was linked to
+	Issuer string `json:"issuer,omitempty"`
+	// ClientID is the OAuth app a user authorized, or stopped authorizing
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
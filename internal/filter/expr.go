@@ -0,0 +1,222 @@
+package filter
+
+import (
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+// Record is what an expression is evaluated against in memory: a task and
+// the names of its labels
+type Record struct {
+	Task   *models.Task
+	Labels []string
+}
+
+// Expr is a parsed filter expression
+type Expr interface {
+	// SQL returns a condition on the tasks table and its arguments
+	SQL() (string, []interface{})
+	// Eval reports whether the record matches the expression
+	Eval(r Record) bool
+}
+
+// UsesLabels reports whether evaluating the expression needs the labels of
+// the record, so callers can skip looking them up otherwise
+func UsesLabels(e Expr) bool {
+	switch e := e.(type) {
+	case *logical:
+		return UsesLabels(e.left) || UsesLabels(e.right)
+	case *negation:
+		return UsesLabels(e.expr)
+	case *comparison:
+		return e.field.kind == kindLabel
+	}
+	return false
+}
+
+type logical struct {
+	op          string
+	left, right Expr
+}
+
+func (e *logical) SQL() (string, []interface{}) {
+	left, leftArgs := e.left.SQL()
+	right, rightArgs := e.right.SQL()
+	return "(" + left + " " + e.op + " " + right + ")", append(leftArgs, rightArgs...)
+}
+
+func (e *logical) Eval(r Record) bool {
+	if e.op == "AND" {
+		return e.left.Eval(r) && e.right.Eval(r)
+	}
+	return e.left.Eval(r) || e.right.Eval(r)
+}
+
+type negation struct {
+	expr Expr
+}
+
+func (e *negation) SQL() (string, []interface{}) {
+	sql, args := e.expr.SQL()
+	return "NOT (" + sql + ")", args
+}
+
+func (e *negation) Eval(r Record) bool {
+	return !e.expr.Eval(r)
+}
+
+type comparison struct {
+	field *field
+	op    string
+	value interface{}
+}
+
+var sqlOperators = map[string]string{"=": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+const dbTimeFormat = "2006-01-02 15:04:05"
+
+func (c *comparison) SQL() (string, []interface{}) {
+	f := c.field
+	if c.value == nil {
+		if c.op == "=" {
+			return f.column + " IS NULL", nil
+		}
+		return f.column + " IS NOT NULL", nil
+	}
+
+	switch f.kind {
+	case kindLabel:
+		sql := "id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE l.name = ?)"
+		if c.op == "!=" {
+			sql = "NOT " + sql
+		}
+		return sql, []interface{}{c.value}
+	case kindText:
+		switch c.op {
+		case "~":
+			return f.column + " LIKE ?", []interface{}{"%" + escapeLike(c.value.(string)) + "%"}
+		case "!~":
+			return f.column + " NOT LIKE ?", []interface{}{"%" + escapeLike(c.value.(string)) + "%"}
+		}
+	case kindEnum:
+		if f.ordered && c.op != "=" && c.op != "!=" {
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(f.values)), ", ")
+			args := make([]interface{}, 0, len(f.values)+1)
+			for _, v := range f.values {
+				args = append(args, v)
+			}
+			return "FIELD(" + f.column + ", " + placeholders + ") " + sqlOperators[c.op] + " ?", append(args, f.rank(c.value.(string))+1)
+		}
+	}
+
+	var arg interface{} = c.value
+	if t, ok := c.value.(time.Time); ok {
+		arg = t.UTC().Format(dbTimeFormat)
+	}
+	sql := f.column + " " + sqlOperators[c.op] + " ?"
+	if f.nullable {
+		// Make comparisons with a NULL column false rather than unknown, so
+		// NOT behaves the same as in Eval
+		sql = "(" + f.column + " IS NOT NULL AND " + sql + ")"
+	}
+	return sql, []interface{}{arg}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (c *comparison) Eval(r Record) bool {
+	t := r.Task
+	switch c.field.name {
+	case "label":
+		// Label names compare case-insensitively, as in the database
+		has := false
+		for _, name := range r.Labels {
+			if strings.EqualFold(name, c.value.(string)) {
+				has = true
+				break
+			}
+		}
+		return has == (c.op == "=")
+	case "title":
+		return compareText(t.Title, c.op, c.value.(string))
+	case "description":
+		return compareText(t.Description, c.op, c.value.(string))
+	case "status":
+		return compareInts(c.field.rank(string(t.Status)), c.op, c.field.rank(c.value.(string)))
+	case "priority":
+		priority := t.Priority
+		if priority == "" {
+			priority = models.TaskPriorityMedium
+		}
+		return compareInts(c.field.rank(string(priority)), c.op, c.field.rank(c.value.(string)))
+	case "id":
+		return compareInts(t.ID, c.op, c.value.(int))
+	case "parent":
+		return c.compareNullableInt(t.ParentID)
+	case "user":
+		return c.compareNullableInt(t.UserID)
+	case "due":
+		if c.value == nil {
+			return (t.DueDate == nil) == (c.op == "=")
+		}
+		return t.DueDate != nil && compareTimes(*t.DueDate, c.op, c.value.(time.Time))
+	case "created":
+		return compareTimes(t.CreatedAt, c.op, c.value.(time.Time))
+	case "updated":
+		return compareTimes(t.UpdatedAt, c.op, c.value.(time.Time))
+	}
+	return false
+}
+
+// compareNullableInt handles null like SQL: it only matches "= null", and
+// any other comparison with a null value is false
+func (c *comparison) compareNullableInt(v *int) bool {
+	if c.value == nil {
+		return (v == nil) == (c.op == "=")
+	}
+	return v != nil && compareInts(*v, c.op, c.value.(int))
+}
+
+func compareText(v, op, value string) bool {
+	switch op {
+	case "=":
+		return strings.EqualFold(v, value)
+	case "!=":
+		return !strings.EqualFold(v, value)
+	case "~":
+		return strings.Contains(strings.ToLower(v), strings.ToLower(value))
+	default:
+		return !strings.Contains(strings.ToLower(v), strings.ToLower(value))
+	}
+}
+
+func compareInts(v int, op string, value int) bool {
+	switch op {
+	case "=":
+		return v == value
+	case "!=":
+		return v != value
+	case "<":
+		return v < value
+	case "<=":
+		return v <= value
+	case ">":
+		return v > value
+	default:
+		return v >= value
+	}
+}
+
+func compareTimes(v time.Time, op string, value time.Time) bool {
+	switch {
+	case v.Before(value):
+		return compareInts(-1, op, 0)
+	case v.After(value):
+		return compareInts(1, op, 0)
+	default:
+		return compareInts(0, op, 0)
+	}
+}
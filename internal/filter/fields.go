@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+	"task-management-api/internal/models"
+)
+
+type fieldKind int
+
+const (
+	kindEnum fieldKind = iota
+	kindText
+	kindTime
+	kindInt
+	kindLabel
+)
+
+type field struct {
+	name     string
+	column   string
+	kind     fieldKind
+	nullable bool
+	// values lists the valid values of an enum field, in ascending order
+	values []string
+	// ordered enums support < <= > >= in the order of values
+	ordered bool
+}
+
+var fields = map[string]*field{
+	"id":          {name: "id", column: "id", kind: kindInt},
+	"title":       {name: "title", column: "title", kind: kindText},
+	"description": {name: "description", column: "description", kind: kindText},
+	"status": {name: "status", column: "status", kind: kindEnum,
+		values: []string{string(models.TaskStatusTodo), string(models.TaskStatusInProgress), string(models.TaskStatusDone)}},
+	"priority": {name: "priority", column: "priority", kind: kindEnum, values: priorityValues(), ordered: true},
+	"due":      {name: "due", column: "due_date", kind: kindTime, nullable: true},
+	"created":  {name: "created", column: "created_at", kind: kindTime},
+	"updated":  {name: "updated", column: "updated_at", kind: kindTime},
+	"parent":   {name: "parent", column: "parent_id", kind: kindInt, nullable: true},
+	"user":     {name: "user", column: "user_id", kind: kindInt, nullable: true},
+	"label":    {name: "label", kind: kindLabel},
+}
+
+func priorityValues() []string {
+	values := make([]string, len(models.TaskPriorities))
+	for i, p := range models.TaskPriorities {
+		values[i] = string(p)
+	}
+	return values
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// allows reports whether the operator can be applied to the field
+func (f *field) allows(op string) bool {
+	switch f.kind {
+	case kindText:
+		return op == "=" || op == "!=" || op == "~" || op == "!~"
+	case kindLabel:
+		return op == "=" || op == "!="
+	case kindEnum:
+		if op == "~" || op == "!~" {
+			return false
+		}
+		return f.ordered || op == "=" || op == "!="
+	default:
+		return op != "~" && op != "!~"
+	}
+}
+
+// rank returns the position of an enum value, or -1 if it is not valid
+func (f *field) rank(value string) int {
+	for i, v := range f.values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
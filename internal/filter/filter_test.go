@@ -0,0 +1,156 @@
+package filter
+
+import (
+	"reflect"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+var testEnv = Env{Now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), UserID: 7}
+
+func TestParseCompilesToSQL(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"status = done", "status = ?", []interface{}{"DONE"}},
+		{"status != DONE AND priority >= high",
+			"(status <> ? AND FIELD(priority, ?, ?, ?) >= ?)", []interface{}{"DONE", "LOW", "MEDIUM", "HIGH", 3}},
+		{"title ~ '50%_off'", `title LIKE ?`, []interface{}{`%50\%\_off%`}},
+		{"label = bug OR NOT label = \"won't fix\"",
+			"(id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE l.name = ?) OR " +
+				"NOT (id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id WHERE l.name = ?)))",
+			[]interface{}{"bug", "won't fix"}},
+		{"user = me", "(user_id IS NOT NULL AND user_id = ?)", []interface{}{7}},
+		{"parent = null", "parent_id IS NULL", nil},
+		{"due < now+7d", "(due_date IS NOT NULL AND due_date < ?)", []interface{}{"2024-05-08 12:00:00"}},
+		{"created >= 2024-04-01", "created_at >= ?", []interface{}{"2024-04-01 00:00:00"}},
+		// AND binds tighter than OR
+		{"id = 1 OR id = 2 AND id = 3", "(id = ? OR (id = ? AND id = ?))", []interface{}{1, 2, 3}},
+		{"(id = 1 OR id = 2) and id = 3", "((id = ? OR id = ?) AND id = ?)", []interface{}{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.input, testEnv)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		sql, args := expr.SQL()
+		if sql != tt.wantSQL {
+			t.Errorf("Parse(%q).SQL() = %s, want %s", tt.input, sql, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("Parse(%q) args = %#v, want %#v", tt.input, args, tt.wantArgs)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"", 0},
+		{"colour = red", 0},
+		{"status", 6},
+		{"status =", 8},
+		{"status = LATE", 9},
+		{"title < 'a'", 6},
+		{"label ~ bug", 6},
+		{"id = one", 5},
+		{"status = null", 9},
+		{"due > null", 6},
+		{"due < now+7y", 6},
+		{"(id = 1", 7},
+		{"id = 1 id = 2", 7},
+		{"title = 'open", 8},
+		{"id ! 1", 3},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input, testEnv)
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Parse(%q) = %v, want a ParseError", tt.input, err)
+			continue
+		}
+		if parseErr.Pos != tt.pos {
+			t.Errorf("Parse(%q) failed at %d (%s), want %d", tt.input, parseErr.Pos, parseErr.Message, tt.pos)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	due := testEnv.Now.Add(48 * time.Hour)
+	task := &models.Task{
+		ID:        3,
+		Title:     "Fix the Login page",
+		Status:    models.TaskStatusInProgress,
+		Priority:  models.TaskPriorityHigh,
+		DueDate:   &due,
+		UserID:    intPtr(7),
+		CreatedAt: testEnv.Now.Add(-time.Hour),
+		UpdatedAt: testEnv.Now,
+	}
+	record := Record{Task: task, Labels: []string{"Bug", "frontend"}}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"status = in_progress", true},
+		{"status != IN_PROGRESS", false},
+		{"priority > medium", true},
+		{"title ~ login", true},
+		{"title !~ LOGIN", false},
+		{"title = 'fix the login page'", true},
+		{"description = ''", true},
+		// Labels match regardless of case, like the SQL condition
+		{"label = bug", true},
+		{"label = BUG AND label = Frontend", true},
+		{"label != bug", false},
+		{"label = backend", false},
+		{"user = me", true},
+		{"parent = null", true},
+		{"parent != null", false},
+		// Comparisons with a null column are false either way
+		{"parent > 1", false},
+		{"NOT parent > 1", true},
+		{"due < now+7d AND due > now", true},
+		{"created < now", true},
+		{"id = 3 OR id = 4", true},
+		{"NOT (id = 3 OR id = 4)", false},
+	}
+	for _, tt := range tests {
+		expr, err := Parse(tt.input, testEnv)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := expr.Eval(record); got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestUsesLabels(t *testing.T) {
+	tests := map[string]bool{
+		"status = DONE":                  false,
+		"status = DONE OR NOT label = x": true,
+		"(id = 1 AND label != y)":        true,
+	}
+	for input, want := range tests {
+		expr, err := Parse(input, testEnv)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", input, err)
+		}
+		if got := UsesLabels(expr); got != want {
+			t.Errorf("UsesLabels(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
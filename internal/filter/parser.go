@@ -0,0 +1,249 @@
+// Package filter implements the task filter language, a small boolean
+// expression language over task fields such as
+//
+//	status != DONE AND (priority = HIGH OR label = bug) AND due < now+7d
+//
+// Comparisons take the form <field> <operator> <value> and can be combined
+// with AND, OR, NOT and parentheses. A parsed expression can be compiled to a
+// parameterised SQL condition or evaluated against a task in memory.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError describes a syntax or validation error and where it occurred
+type ParseError struct {
+	// Pos is the zero-based character offset of the offending token
+	Pos     int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+// Env holds the values that relative expressions are resolved against
+type Env struct {
+	// Now is the reference time for now, now+7d and the like
+	Now time.Time
+	// UserID is the user that "me" refers to
+	UserID int
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	env    Env
+}
+
+// Parse parses and validates a filter expression
+func Parse(input string, env Env) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if env.Now.IsZero() {
+		env.Now = time.Now()
+	}
+
+	p := &parser{tokens: tokens, env: env}
+	if p.peek().kind == tokenEOF {
+		return nil, &ParseError{Pos: 0, Message: "empty filter"}
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &ParseError{Pos: tok.pos, Message: fmt.Sprintf("unexpected %s, expected AND, OR or end of query", tok)}
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &negation{expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &ParseError{Pos: closing.pos, Message: fmt.Sprintf("unexpected %s, expected ')'", closing)}
+		}
+		return expr, nil
+	case tokenWord:
+		return p.parseComparison(tok)
+	default:
+		return nil, &ParseError{Pos: tok.pos, Message: fmt.Sprintf("unexpected %s, expected a field name or '('", tok)}
+	}
+}
+
+func (p *parser) parseComparison(fieldTok token) (Expr, error) {
+	f, ok := fields[strings.ToLower(fieldTok.text)]
+	if !ok {
+		return nil, &ParseError{Pos: fieldTok.pos, Message: fmt.Sprintf("unknown field '%s', expected one of %s", fieldTok.text, fieldNames())}
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokenOperator {
+		return nil, &ParseError{Pos: opTok.pos, Message: fmt.Sprintf("unexpected %s, expected an operator after '%s'", opTok, fieldTok.text)}
+	}
+	if !f.allows(opTok.text) {
+		return nil, &ParseError{Pos: opTok.pos, Message: fmt.Sprintf("operator '%s' cannot be used with field '%s'", opTok.text, f.name)}
+	}
+
+	valueTok := p.next()
+	if valueTok.kind != tokenWord && valueTok.kind != tokenString {
+		return nil, &ParseError{Pos: valueTok.pos, Message: fmt.Sprintf("unexpected %s, expected a value", valueTok)}
+	}
+
+	value, err := p.parseValue(f, opTok.text, valueTok)
+	if err != nil {
+		return nil, err
+	}
+	return &comparison{field: f, op: opTok.text, value: value}, nil
+}
+
+// parseValue converts a value token into the Go value matching the field kind:
+// a string, an int, a time.Time, or nil for null
+func (p *parser) parseValue(f *field, op string, tok token) (interface{}, error) {
+	if tok.kind == tokenWord && strings.EqualFold(tok.text, "null") {
+		if !f.nullable {
+			return nil, &ParseError{Pos: tok.pos, Message: fmt.Sprintf("field '%s' is never null", f.name)}
+		}
+		if op != "=" && op != "!=" {
+			return nil, &ParseError{Pos: tok.pos, Message: "null can only be compared with = or !="}
+		}
+		return nil, nil
+	}
+
+	switch f.kind {
+	case kindEnum:
+		value := strings.ToUpper(tok.text)
+		if f.rank(value) < 0 {
+			return nil, &ParseError{Pos: tok.pos, Message: fmt.Sprintf("invalid %s '%s', expected one of %s", f.name, tok.text, strings.Join(f.values, ", "))}
+		}
+		return value, nil
+	case kindInt:
+		if f.name == "user" && strings.EqualFold(tok.text, "me") {
+			return p.env.UserID, nil
+		}
+		value, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, &ParseError{Pos: tok.pos, Message: fmt.Sprintf("invalid %s '%s', expected a number", f.name, tok.text)}
+		}
+		return value, nil
+	case kindTime:
+		value, err := parseTime(tok.text, p.env.Now)
+		if err != nil {
+			return nil, &ParseError{Pos: tok.pos, Message: err.Error()}
+		}
+		return value, nil
+	default:
+		return tok.text, nil
+	}
+}
+
+// parseTime accepts RFC 3339 timestamps, plain dates, "now" and offsets from
+// now such as now+7d, now-12h or now+2w
+func parseTime(text string, now time.Time) (time.Time, error) {
+	lower := strings.ToLower(text)
+	if lower == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(lower, "now+") || strings.HasPrefix(lower, "now-") {
+		if len(lower) < 6 {
+			return time.Time{}, fmt.Errorf("invalid relative time '%s', expected e.g. now+7d", text)
+		}
+		amount, unit := lower[4:len(lower)-1], lower[len(lower)-1]
+		n, err := strconv.Atoi(amount)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time '%s', expected e.g. now+7d", text)
+		}
+		var d time.Duration
+		switch unit {
+		case 'm':
+			d = time.Minute
+		case 'h':
+			d = time.Hour
+		case 'd':
+			d = 24 * time.Hour
+		case 'w':
+			d = 7 * 24 * time.Hour
+		default:
+			return time.Time{}, fmt.Errorf("invalid time unit '%c' in '%s', expected m, h, d or w", unit, text)
+		}
+		if lower[3] == '-' {
+			n = -n
+		}
+		return now.Add(time.Duration(n) * d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", text); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected a date like 2024-05-31, a timestamp or now+7d", text)
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// TokenPrefix starts every personal access token, telling them apart from JWTs
+const TokenPrefix = "pat_"
+
+// Token scopes: each area of the API has a read scope, for GET requests,
+// and a write scope, which includes read
+var TokenScopes = []string{
+	"tasks:read", "tasks:write",
+	"labels:read", "labels:write",
+	"filters:read", "filters:write",
+	"users:read", "users:write",
+	"webhooks:read", "webhooks:write",
+}
+
+// PersonalAccessToken lets scripts call the API as a user without their
+// password. Only a hash of the token is stored; it is shown once, when
+// created.
+type PersonalAccessToken struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"user_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Prefix is the start of the token, to help recognise it
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Token is the token itself, set only in the response that creates it
+	Token string `json:"token,omitempty"`
+}
+
+// NewPersonalAccessToken represents the data needed to create a token.
+// Without ExpiresAt the token expires after the configured maximum lifetime,
+// if there is one.
+type NewPersonalAccessToken struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
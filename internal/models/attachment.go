@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded to a task. The contents live in blob storage
+// under their SHA-256 checksum, so identical uploads share a single blob.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	UploadedBy  *int      `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
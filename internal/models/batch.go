@@ -0,0 +1,33 @@
+package models
+
+import "encoding/json"
+
+// BatchRequest is a list of API requests run one after another in a single
+// round trip
+type BatchRequest struct {
+	Requests []*BatchItem `json:"requests" binding:"required,min=1,dive"`
+}
+
+// BatchItem is one request of a batch. Its path and body may refer to the
+// response of an earlier request as {{id.field}}, where field is a
+// dot-separated path into the JSON body, such as {{task.id}} or
+// {{tasks.0.title}}.
+type BatchItem struct {
+	// ID names the request so that later ones can refer to it; it is made
+	// of letters, digits, "_" and "-"
+	ID     string `json:"id" binding:"omitempty,max=50"`
+	Method string `json:"method" binding:"required,oneof=GET POST PUT PATCH DELETE"`
+	// Path is relative to /api/v1 and may include a query string
+	Path string          `json:"path" binding:"required,startswith=/"`
+	Body json.RawMessage `json:"body"`
+	// DependsOn names earlier requests that have to succeed first, besides
+	// the ones referred to
+	DependsOn []string `json:"depends_on"`
+}
+
+// BatchItemResult is the response to one request of a batch
+type BatchItemResult struct {
+	ID     string      `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+// Bulk modes: in atomic mode one failed operation rolls all of them back,
+// in partial mode every operation succeeds or fails on its own
+const (
+	BulkModeAtomic  = "atomic"
+	BulkModePartial = "partial"
+)
+
+// Bulk operations
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkRequest is a list of task operations run in one transaction
+type BulkRequest struct {
+	Mode       string           `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Operations []*BulkOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BulkOperation creates, updates or deletes one task. Updates only touch
+// the fields that are set.
+type BulkOperation struct {
+	Op string `json:"op" binding:"required,oneof=create update delete"`
+	// ID is the task to update or delete
+	ID int `json:"id"`
+	// Task is the task to create
+	Task     *Task         `json:"task"`
+	Status   *TaskStatus   `json:"status" binding:"omitempty,oneof=TODO IN_PROGRESS DONE"`
+	Priority *TaskPriority `json:"priority" binding:"omitempty,oneof=LOW MEDIUM HIGH"`
+	// UserID assigns the task to a user; Unassign leaves it to nobody
+	UserID       *int  `json:"user_id"`
+	Unassign     bool  `json:"unassign"`
+	AddLabels    []int `json:"add_labels"`
+	RemoveLabels []int `json:"remove_labels"`
+}
+
+// BulkItemResult is the outcome of one operation, with the HTTP status it
+// would have had on its own
+type BulkItemResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Task   *Task  `json:"task,omitempty"`
+}
+
+// BulkResult reports what a bulk request did. Committed is false when an
+// operation failed in atomic mode and everything was rolled back.
+type BulkResult struct {
+	Mode      string            `json:"mode"`
+	Committed bool              `json:"committed"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []*BulkItemResult `json:"results"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Comment is a note left on a task by one of its users
+type Comment struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	UserID    *int      `json:"user_id"`
+	Body      string    `json:"body" binding:"required,max=10000"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// ImportReport describes what an import of tasks did, or would do on a dry
+// run. Nothing is imported unless every row is valid.
+type ImportReport struct {
+	DryRun    bool `json:"dry_run"`
+	Committed bool `json:"committed"`
+	Rows      int  `json:"rows"`
+	// Imported is the number of tasks created, or that would be created on
+	// a dry run
+	Imported int               `json:"imported"`
+	TaskIDs  []int             `json:"task_ids,omitempty"`
+	Errors   []*ImportRowError `json:"errors"`
+}
+
+// ImportRowError lists the problems with one row of an imported file, by
+// field, like the validation errors of the API
+type ImportRowError struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
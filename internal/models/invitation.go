@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Registration modes, which decide who may sign up through public registration
+const (
+	RegistrationOpen            = "open"
+	RegistrationInviteOnly      = "invite_only"
+	RegistrationDomainAllowlist = "domain_allowlist"
+)
+
+// Invitation lets someone create an account in an organisation, with a role
+// and team chosen by the admin who invited them. It is sent by email as a
+// single-use, expiring token, of which only a hash is stored.
+type Invitation struct {
+	ID         int        `json:"id"`
+	OrgID      int        `json:"org_id"`
+	Email      string     `json:"email"`
+	Role       UserRole   `json:"role"`
+	OrgRole    OrgRole    `json:"org_role"`
+	TeamID     *int       `json:"team_id"`
+	InvitedBy  *int       `json:"invited_by"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// NewInvitation represents the data needed to invite someone
+type NewInvitation struct {
+	Email   string   `json:"email" binding:"required,email"`
+	Role    UserRole `json:"role" binding:"omitempty,oneof=USER ADMIN"`
+	OrgRole OrgRole  `json:"org_role" binding:"omitempty,oneof=MEMBER ORG_ADMIN"`
+	TeamID  *int     `json:"team_id"`
+}
+
+// InvitationAcceptance is the body of a request creating an account from an invitation
+type InvitationAcceptance struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password" binding:"required"`
+	FullName string `json:"full_name" binding:"max=100"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Label is a tag that can be attached to tasks. Labels without a ProjectID are
+// global; the others can only be used on the tasks of that project, i.e. the
+// top-level task with that ID and its subtasks.
+type Label struct {
+	ID         int       `json:"id"`
+	OrgID      int       `json:"org_id"`
+	Name       string    `json:"name" binding:"required,min=1,max=50"`
+	Color      string    `json:"color" binding:"omitempty,hexcolor"`
+	ProjectID  *int      `json:"project_id"`
+	UsageCount int       `json:"usage_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UpdateLabel represents the data that can be updated for a label
+type UpdateLabel struct {
+	Name  *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Color *string `json:"color" binding:"omitempty,hexcolor"`
+}
+
+// MergeLabels names the label that absorbs another one
+type MergeLabels struct {
+	TargetID int `json:"target_id" binding:"required"`
+}
+
+// TaskLabel names a label to attach to a task
+type TaskLabel struct {
+	LabelID int `json:"label_id" binding:"required"`
+}
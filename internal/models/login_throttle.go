@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ThrottleScope is what failed logins are counted against
+type ThrottleScope string
+
+const (
+	// ThrottleScopeAccount counts failures per username, whether or not an
+	// account with that name exists
+	ThrottleScopeAccount ThrottleScope = "ACCOUNT"
+	// ThrottleScopeIP counts failures per client address
+	ThrottleScopeIP ThrottleScope = "IP"
+)
+
+// LoginThrottle tracks recent failed logins for a username or an address.
+// Further attempts are refused until LockedUntil, which is set first for a
+// short, growing delay and then for a lockout.
+type LoginThrottle struct {
+	Scope         ThrottleScope `json:"scope"`
+	Key           string        `json:"key"`
+	Failures      int           `json:"failures"`
+	FirstFailedAt time.Time     `json:"first_failed_at"`
+	LastFailedAt  time.Time     `json:"last_failed_at"`
+	LockedUntil   *time.Time    `json:"locked_until"`
+}
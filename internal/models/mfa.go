@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// MFAStatus describes a user's two-factor authentication setup
+type MFAStatus struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// Required is set when the user's role must use two-factor authentication
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator
+// app. The provisioning URI is usually shown as a QR code.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAPolicy lists the roles that must use two-factor authentication
+type MFAPolicy struct {
+	RequiredRoles []UserRole `json:"required_roles" binding:"dive,oneof=USER ADMIN"`
+}
+
+// LoginResult is the outcome of a login. When a second factor is needed,
+// it carries a challenge token instead of an access token.
+type LoginResult struct {
+	User  *User  `json:"user"`
+	Token string `json:"token,omitempty"`
+	// MFARequired asks for a code from the user's authenticator app
+	MFARequired bool `json:"mfa_required,omitempty"`
+	// MFAEnrollmentRequired asks the user to enrol an authenticator app,
+	// which their role requires, before they can log in
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+	// RecoveryCodes are shown once, when enrolment completes during login
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// MFALogin is the body of the second login step. Code is either a code from
+// the authenticator app or one of the recovery codes.
+type MFALogin struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFATokenRequest is the body of a request that only carries a challenge token
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFACode is the body of a request confirmed with a two-factor code
+type MFACode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFADisable is the body of a request to turn off two-factor authentication
+type MFADisable struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
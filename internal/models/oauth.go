@@ -0,0 +1,160 @@
+package models
+
+import "time"
+
+// OAuth grant types a client may use
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// OAuthClient is a third-party app registered to call the API on behalf of
+// users. Apps with a secret are confidential; those without, such as
+// single-page or mobile apps, are public and must use PKCE.
+type OAuthClient struct {
+	ID       int    `json:"id"`
+	ClientID string `json:"client_id"`
+	// OwnerID is the user who registered the app; client credentials
+	// tokens act on their behalf
+	OwnerID      int      `json:"owner_id"`
+	Name         string   `json:"name"`
+	SecretHash   string   `json:"-"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// Scopes are the most the app may be granted
+	Scopes     []string  `json:"scopes"`
+	GrantTypes []string  `json:"grant_types"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// ClientSecret is set only in the responses that create it
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// NewOAuthClient represents the data needed to register an app
+type NewOAuthClient struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris" binding:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1"`
+	GrantTypes   []string `json:"grant_types" binding:"required,min=1,dive,oneof=authorization_code client_credentials"`
+}
+
+// UpdateOAuthClient represents the data that can be updated for an app
+type UpdateOAuthClient struct {
+	Name         *string  `json:"name" binding:"omitempty,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes" binding:"omitempty,min=1"`
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens
+type OAuthCode struct {
+	CodeHash      string
+	ClientID      int
+	UserID        int
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// OAuthToken records an access token issued to a client, and the refresh
+// token issued with it, so that both can be revoked
+type OAuthToken struct {
+	ID       int
+	TokenID  string
+	ClientID int
+	UserID   int
+	Scopes   []string
+	// RefreshHash is empty for grants without a refresh token
+	RefreshHash      string
+	ExpiresAt        time.Time
+	RefreshExpiresAt *time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+}
+
+// OAuthConsent records the scopes a user allowed an app, so that they are
+// not asked again for them
+type OAuthConsent struct {
+	UserID    int       `json:"-"`
+	Client    ClientRef `json:"client"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ClientRef names an app in consent listings and prompts
+type ClientRef struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+// AuthorizationRequest is the authorization request an app sends the user
+// with, passed on by the frontend's consent page
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// AuthorizationPrompt describes what the consent page should ask
+type AuthorizationPrompt struct {
+	Client ClientRef `json:"client"`
+	Scopes []string  `json:"scopes"`
+	// ConsentRequired is false if the user already allowed these scopes
+	ConsentRequired bool   `json:"consent_required"`
+	RedirectURI     string `json:"redirect_uri"`
+}
+
+// AuthorizationDecision is the user's answer on the consent page
+type AuthorizationDecision struct {
+	AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// TokenRequest is a request to the token endpoint, whichever the grant
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenResponse is the token endpoint's answer
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// TokenIntrospection is the introspection endpoint's answer; only Active is
+// set for tokens that are not active
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// TokenLookup is a request to introspect or revoke a token
+type TokenLookup struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
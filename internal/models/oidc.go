@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// OIDCLoginState is a single sign-on login in progress, between sending
+// the user to the identity provider and their return. It is looked up by a
+// hash of the state parameter and can only be used once.
+type OIDCLoginState struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// UserIdentity links a user to their account at an identity provider,
+// which is identified by its issuer and the account by its subject
+type UserIdentity struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OIDCCallback is what the identity provider sends back to the callback
+type OIDCCallback struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
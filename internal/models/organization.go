@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// DefaultOrganizationID is the organisation that existed before there were
+// several; public registration and single sign-on create users in it
+const DefaultOrganizationID = 1
+
+// OrgRole is a user's role within their organisation, as opposed to Role,
+// which applies to the whole platform
+type OrgRole string
+
+const (
+	OrgRoleMember OrgRole = "MEMBER"
+	// OrgRoleAdmin manages the organisation's members and teams
+	OrgRoleAdmin OrgRole = "ORG_ADMIN"
+)
+
+// Organization is a tenant: its users and tasks are invisible to every other
+// organisation
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewOrganization represents the data needed to create an organisation
+type NewOrganization struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,min=2,max=50"`
+}
+
+// UpdateOrganization represents the data that can be updated for an organisation
+type UpdateOrganization struct {
+	Name *string `json:"name" binding:"omitempty,max=100"`
+}
+
+// OrgRoleChange is the body of a request to change a member's role in the organisation
+type OrgRoleChange struct {
+	OrgRole OrgRole `json:"org_role" binding:"required,oneof=MEMBER ORG_ADMIN"`
+}
+
+// Team groups members of an organisation
+type Team struct {
+	ID          int       `json:"id"`
+	OrgID       int       `json:"org_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewTeam represents the data needed to create a team
+type NewTeam struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// UpdateTeam represents the data that can be updated for a team
+type UpdateTeam struct {
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+}
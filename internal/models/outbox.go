@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEntry is a domain event recorded in the same transaction as the
+// change that raised it, waiting to be relayed to the event sinks
+type OutboxEntry struct {
+	ID            int64           `json:"id"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int             `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	// DeliveredTo names the sinks that have already accepted the entry
+	DeliveredTo []string   `json:"delivered_to"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at"`
+	// DeadAt is set when the entry ran out of attempts and is no longer
+	// offered to the sinks
+	DeadAt *time.Time `json:"dead_at"`
+}
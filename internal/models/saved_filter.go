@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SavedFilter is a named task filter expression kept by a user and optionally
+// shared with other users
+type SavedFilter struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Name       string    `json:"name" binding:"required,min=1,max=100"`
+	Query      string    `json:"query" binding:"required,max=1000"`
+	SharedWith []int     `json:"shared_with"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// SearchResult is a task matching a search query, with its relevance score and
+// snippets of the matching fields where the matched terms are wrapped in <mark>
+type SearchResult struct {
+	Task       *Task             `json:"task"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
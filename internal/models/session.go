@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is a login session. Each token issued at login belongs to one, and
+// revoking the session invalidates the token.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session making the request
+	Current bool `json:"current"`
+}
+
+// ClientInfo describes the client a user logs in from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
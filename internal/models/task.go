@@ -10,11 +10,77 @@ const (
 	TaskStatusDone       TaskStatus = "DONE"
 )
 
+type TaskPriority string
+
+const (
+	TaskPriorityLow    TaskPriority = "LOW"
+	TaskPriorityMedium TaskPriority = "MEDIUM"
+	TaskPriorityHigh   TaskPriority = "HIGH"
+)
+
+// TaskPriorities lists the priorities from lowest to highest
+var TaskPriorities = []TaskPriority{TaskPriorityLow, TaskPriorityMedium, TaskPriorityHigh}
+
 type Task struct {
-	ID          int        `json:"id"`
-	Title       string     `json:"title" binding:"required,min=1,max=100"`
-	Description string     `json:"description" binding:"max=500"`
-	Status      TaskStatus `json:"status" binding:"required,oneof=TODO IN_PROGRESS DONE"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-}
\ No newline at end of file
+	ID          int          `json:"id"`
+	OrgID       int          `json:"org_id"`
+	ParentID    *int         `json:"parent_id"`
+	UserID      *int         `json:"user_id"`
+	Title       string       `json:"title" binding:"required,min=1,max=100"`
+	Description string       `json:"description" binding:"max=500"`
+	Status      TaskStatus   `json:"status" binding:"required,oneof=TODO IN_PROGRESS DONE"`
+	Priority    TaskPriority `json:"priority" binding:"omitempty,oneof=LOW MEDIUM HIGH"`
+	DueDate     *time.Time   `json:"due_date"`
+	// DeletedAt is when the task was moved to the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// ArchivedAt is when the task was archived, which keeps it out of the
+	// default lists and search
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// KeepParent makes an update leave the parent as it is, whatever
+	// ParentID says. It is set for requests that leave out parent_id.
+	KeepParent bool `json:"-"`
+}
+
+// TaskNode is a task together with its subtasks, used to render a task hierarchy
+type TaskNode struct {
+	*Task
+	Progress float64     `json:"progress"`
+	Children []*TaskNode `json:"children"`
+}
+
+// TaskDependency records that BlockerID has to be finished before BlockedID can progress
+type TaskDependency struct {
+	BlockerID int       `json:"blocker_id" binding:"required"`
+	BlockedID int       `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskDependencies lists the tasks blocking a task and the tasks it blocks
+type TaskDependencies struct {
+	BlockedBy []*Task `json:"blocked_by"`
+	Blocks    []*Task `json:"blocks"`
+}
+
+// TaskPlan orders a set of tasks so that every blocker comes before the tasks it blocks
+type TaskPlan struct {
+	Order        []*Task `json:"order"`
+	CriticalPath []*Task `json:"critical_path"`
+}
+
+// TaskFilter narrows down the tasks returned by a task listing
+type TaskFilter struct {
+	// Labels keeps tasks carrying any of these label names, or all of them when MatchAllLabels is set
+	Labels         []string
+	MatchAllLabels bool
+	// Condition is an extra condition, typically a parsed filter expression
+	Condition SQLCondition
+	// IncludeArchived adds the archived tasks to the live ones
+	IncludeArchived bool
+}
+
+// SQLCondition is a condition on the tasks table rendered as parameterised SQL
+type SQLCondition interface {
+	SQL() (string, []interface{})
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TaskRevision is the state of a task after a change, kept so that its
+// history can be shown and an earlier state brought back
+type TaskRevision struct {
+	ID       int `json:"id"`
+	TaskID   int `json:"task_id"`
+	OrgID    int `json:"org_id"`
+	Revision int `json:"revision"`
+	// ActorID is the user who made the change, or nil for the state a task
+	// was in when its history started being recorded
+	ActorID *int  `json:"actor_id"`
+	Task    *Task `json:"task"`
+	// Changes lists the fields that differ from the previous revision
+	Changes   []FieldChange `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// FieldChange is a field of a task changed by a revision
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// Trash holds the deleted tasks and users that can still be restored. Users
+// are only listed for organisation admins.
+type Trash struct {
+	Tasks []*Task `json:"tasks"`
+	Users []*User `json:"users,omitempty"`
+}
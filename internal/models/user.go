@@ -12,32 +12,66 @@ const (
 	UserRoleAdmin UserRole = "ADMIN"
 )
 
+// Actor is the user a change is made on behalf of, with their roles
+type Actor struct {
+	UserID  int
+	Role    UserRole
+	OrgRole OrgRole
+}
+
+// IsAdmin reports whether the actor administers their organisation, which
+// platform admins do too
+func (a Actor) IsAdmin() bool {
+	return a.OrgRole == OrgRoleAdmin || a.Role == UserRoleAdmin
+}
+
 // User represents a user in the system
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username" binding:"required,min=3,max=50"`
-	Email        string    `json:"email" binding:"required,email"`
-	PasswordHash string    `json:"-"` // The "-" tag means this field won't be included in JSON output
-	FullName     string    `json:"full_name" binding:"max=100"`
-	Role         UserRole  `json:"role" binding:"required,oneof=USER ADMIN"`
-	IsActive     bool      `json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int      `json:"id"`
+	OrgID        int      `json:"org_id"` // usernames are unique within the organisation
+	OrgRole      OrgRole  `json:"org_role"`
+	Username     string   `json:"username" binding:"required,min=3,max=50"`
+	Email        string   `json:"email" binding:"required,email"`
+	PasswordHash string   `json:"-"` // The "-" tag means this field won't be included in JSON output
+	FullName     string   `json:"full_name" binding:"max=100"`
+	Role         UserRole `json:"role" binding:"required,oneof=USER ADMIN"`
+	IsActive     bool     `json:"is_active"`
+	// EmailVerifiedAt is when the user proved they own their email address
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// PendingEmail is a new address waiting to be verified before it replaces Email
+	PendingEmail *string `json:"pending_email,omitempty"`
+	// DeletionScheduledAt is when the account will be deleted, if the user asked for it
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	// MFASecret is the TOTP secret of the user's authenticator app, set once
+	// enrolment starts; MFAEnabledAt is set once it is confirmed
+	MFASecret    string     `json:"-"`
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at,omitempty"`
+	// DeletedAt is when the user was moved to the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 // UserCredentials represents the data needed for user authentication
 type UserCredentials struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Password string `json:"password" binding:"required,min=6"`
+	// Organization is the slug of the user's organisation; it can be left
+	// out for the default one
+	Organization string `json:"organization"`
+	Username     string `json:"username" binding:"required,min=3,max=50"`
+	Password     string `json:"password" binding:"required"`
 }
 
-// NewUser represents the data needed to create a new user
+// NewUser represents the data needed to create a new user. Role is USER
+// unless given, and public registration always uses USER.
 type NewUser struct {
 	Username string   `json:"username" binding:"required,min=3,max=50"`
 	Email    string   `json:"email" binding:"required,email"`
-	Password string   `json:"password" binding:"required,min=6"`
+	Password string   `json:"password" binding:"required"`
 	FullName string   `json:"full_name" binding:"max=100"`
-	Role     UserRole `json:"role" binding:"required,oneof=USER ADMIN"`
+	Role     UserRole `json:"role" binding:"omitempty,oneof=USER ADMIN"`
+	// OrgRole is the role in the organisation the user is created in,
+	// MEMBER unless given
+	OrgRole OrgRole `json:"org_role" binding:"omitempty,oneof=MEMBER ORG_ADMIN"`
 }
 
 // UpdateUser represents the data that can be updated for a user
@@ -46,4 +80,44 @@ type UpdateUser struct {
 	FullName *string   `json:"full_name" binding:"omitempty,max=100"`
 	Role     *UserRole `json:"role" binding:"omitempty,oneof=USER ADMIN"`
 	IsActive *bool     `json:"is_active"`
-}
\ No newline at end of file
+}
+
+// EmailVerification is the body of a request to verify an email address
+type EmailVerification struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailRequest is the body of a request that only names an email address,
+// such as asking for a password reset
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PasswordReset is the body of a request to set a new password with a reset token
+type PasswordReset struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateProfile represents the data users can change about themselves
+type UpdateProfile struct {
+	FullName *string `json:"full_name" binding:"omitempty,max=100"`
+}
+
+// PasswordChange is the body of a request to change one's own password
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// EmailChange is the body of a request to change one's own email address
+type EmailChange struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// PasswordConfirmation is the body of a sensitive request that must be
+// confirmed with the user's password
+type PasswordConfirmation struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// TokenPurpose is what a single-use user token may be redeemed for
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "EMAIL_VERIFICATION"
+	TokenPurposePasswordReset     TokenPurpose = "PASSWORD_RESET"
+	// TokenPurposeEmailChange confirms a user's new, pending email address
+	TokenPurposeEmailChange TokenPurpose = "EMAIL_CHANGE"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only
+// the SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID        int
+	UserID    int
+	Purpose   TokenPurpose
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookDeliveryStatus is the state of a webhook delivery in the outbox
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	// WebhookDeliveryDead marks a delivery that ran out of retries
+	WebhookDeliveryDead WebhookDeliveryStatus = "DEAD"
+)
+
+// Webhook is a subscription that has events POSTed to a URL, signed with the
+// secret. The secret is only shown once, when the webhook is created.
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	CreatedBy  *int      `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewWebhook represents the data needed to create a webhook. Without a
+// Secret one is generated.
+type NewWebhook struct {
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16,max=255"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,required"`
+}
+
+// CreatedWebhook is the response to creating a webhook, the only one that
+// includes its secret
+type CreatedWebhook struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhook represents the data that can be updated for a webhook
+type UpdateWebhook struct {
+	URL        *string  `json:"url" binding:"omitempty,url,max=2048"`
+	Secret     *string  `json:"secret" binding:"omitempty,min=16,max=255"`
+	EventTypes []string `json:"event_types" binding:"omitempty,min=1,dive,required"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// WebhookDelivery is one event queued for one webhook
+type WebhookDelivery struct {
+	ID             int                   `json:"id"`
+	WebhookID      int                   `json:"webhook_id"`
+	EventID        string                `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at"`
+	LastStatusCode *int                  `json:"last_status_code"`
+	LastError      string                `json:"last_error"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	AttemptLog     []*WebhookAttempt     `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt logs a single attempt at sending a delivery
+type WebhookAttempt struct {
+	ID           int       `json:"id"`
+	DeliveryID   int       `json:"delivery_id"`
+	StatusCode   *int      `json:"status_code"`
+	Error        string    `json:"error"`
+	ResponseBody string    `json:"response_body"`
+	DurationMs   int       `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"task-management-api/internal/events"
+	"time"
+)
+
+// KafkaRESTSink produces events to a Kafka topic through a Kafka REST Proxy
+// compatible endpoint (Confluent REST Proxy v2, Redpanda's HTTP proxy).
+// Records are keyed by aggregate so that all events about one task or user
+// land on the same partition, in order.
+type KafkaRESTSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewKafkaRESTSink(proxyURL, topic string, timeout time.Duration) *KafkaRESTSink {
+	return &KafkaRESTSink{
+		endpoint: strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type kafkaRecord struct {
+	Key   *string      `json:"key"`
+	Value events.Event `json:"value"`
+}
+
+func (s *KafkaRESTSink) Publish(event events.Event) error {
+	record := kafkaRecord{Value: event}
+	if kind, id := event.Aggregate(); kind != "" {
+		key := fmt.Sprintf("%s:%d", kind, id)
+		record.Key = &key
+	}
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": {record}})
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error producing to Kafka: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Kafka REST proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// The proxy reports per-record failures in an otherwise successful response
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(respBody, &result); err == nil {
+		for _, offset := range result.Offsets {
+			if offset.ErrorCode != nil {
+				return fmt.Errorf("Kafka rejected the record: %s (code %d)", offset.Error, *offset.ErrorCode)
+			}
+		}
+	}
+	return nil
+}
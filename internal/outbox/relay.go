@@ -0,0 +1,185 @@
+// Package outbox relays the domain events recorded in the outbox table to
+// the configured sinks, and provides the sinks that live outside the process.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"time"
+)
+
+// Sink is a named destination for events. The name records which sinks have
+// accepted an entry, so it must not change between restarts.
+type Sink struct {
+	Name string
+	events.Publisher
+}
+
+// Relay publishes outbox entries to its sinks. Delivery is at least once:
+// each sink's acceptance of an entry is recorded, an entry is marked
+// published once every sink has accepted it, and an entry that some sinks
+// failed to take is offered again on the next run to those sinks only.
+// Entries about the same aggregate are published in the order they were
+// written; one that fails holds back the later entries for its aggregate but
+// not for others. An entry that fails MaxAttempts times is set aside as dead
+// and stops holding its aggregate back.
+type Relay struct {
+	repo        repository.OutboxRepository
+	sinks       []Sink
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	retention   time.Duration
+}
+
+func NewRelay(repo repository.OutboxRepository, sinks []Sink, cfg config.OutboxConfig) *Relay {
+	return &Relay{
+		repo:        repo,
+		sinks:       sinks,
+		interval:    cfg.PollInterval,
+		batchSize:   cfg.BatchSize,
+		maxAttempts: cfg.MaxAttempts,
+		retention:   cfg.Retention,
+	}
+}
+
+// Start relays pending entries every poll interval until ctx is done
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.relayPending(ctx)
+			}
+		}
+	}()
+}
+
+func (r *Relay) relayPending(ctx context.Context) {
+	release, acquired, err := r.repo.TryLock(ctx)
+	if err != nil {
+		log.Printf("outbox: %v", err)
+		return
+	}
+	if !acquired {
+		// Another instance is relaying
+		return
+	}
+	defer release()
+
+	// Each run pages through the pending entries once, past the ones held
+	// back, so that a failing aggregate cannot keep the others waiting
+	blocked := make(map[string]bool)
+	var after int64
+	for {
+		entries, err := r.repo.ListPending(after, r.batchSize)
+		if err != nil {
+			log.Printf("outbox: %v", err)
+			return
+		}
+
+		for _, entry := range entries {
+			after = entry.ID
+			aggregate := fmt.Sprintf("%s:%d", entry.AggregateType, entry.AggregateID)
+			if blocked[aggregate] {
+				continue
+			}
+
+			err := r.publish(entry)
+			if err != nil {
+				log.Printf("outbox: failed to publish %s event %s: %v", entry.EventType, entry.EventID, err)
+				if r.maxAttempts > 0 && entry.Attempts+1 >= r.maxAttempts {
+					log.Printf("outbox: giving up on %s event %s after %d attempts", entry.EventType, entry.EventID,
+						entry.Attempts+1)
+					if err := r.repo.MarkDead(entry.ID, err.Error()); err != nil {
+						log.Printf("outbox: %v", err)
+						blocked[aggregate] = true
+					}
+					continue
+				}
+				blocked[aggregate] = true
+				if err := r.repo.MarkFailed(entry.ID, err.Error()); err != nil {
+					log.Printf("outbox: %v", err)
+				}
+				continue
+			}
+
+			if err := r.repo.MarkPublished(entry.ID); err != nil {
+				log.Printf("outbox: %v", err)
+				return
+			}
+		}
+
+		if len(entries) < r.batchSize {
+			break
+		}
+	}
+
+	if r.retention > 0 {
+		if err := r.repo.DeletePublishedBefore(time.Now().Add(-r.retention)); err != nil {
+			log.Printf("outbox: %v", err)
+		}
+	}
+}
+
+// publish offers an entry to the sinks that have not accepted it yet,
+// recording each one that does
+func (r *Relay) publish(entry *models.OutboxEntry) error {
+	event, err := events.Unmarshal(entry.Payload)
+	if err != nil {
+		return err
+	}
+
+	delivered := make(map[string]bool, len(entry.DeliveredTo))
+	for _, name := range entry.DeliveredTo {
+		delivered[name] = true
+	}
+	var errs []error
+	for _, sink := range r.sinks {
+		if delivered[sink.Name] {
+			continue
+		}
+		if err := sink.Publish(event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", sink.Name, err))
+			continue
+		}
+		if err := r.repo.MarkDelivered(entry.ID, sink.Name); err != nil {
+			// The sink will be offered the entry again
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewSinks creates the external sinks enabled in the configuration
+func NewSinks(cfg config.OutboxSinksConfig) ([]Sink, error) {
+	var sinks []Sink
+	if cfg.File.Path != "" {
+		sink, err := NewFileSink(cfg.File.Path)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, Sink{Name: "file", Publisher: sink})
+	}
+	if cfg.NATS.URL != "" {
+		sink, err := NewNATSSink(cfg.NATS.URL, cfg.NATS.SubjectPrefix, cfg.NATS.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, Sink{Name: "nats", Publisher: sink})
+	}
+	if cfg.Kafka.RestProxyURL != "" {
+		sinks = append(sinks, Sink{Name: "kafka", Publisher: NewKafkaRESTSink(cfg.Kafka.RestProxyURL, cfg.Kafka.Topic, cfg.Kafka.Timeout)})
+	}
+	return sinks, nil
+}
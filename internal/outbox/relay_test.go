@@ -0,0 +1,211 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+type fakeOutboxRepo struct {
+	repository.OutboxRepository
+	entries []*models.OutboxEntry
+}
+
+func (r *fakeOutboxRepo) append(t *testing.T, event events.Event) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, id := event.Aggregate()
+	r.entries = append(r.entries, &models.OutboxEntry{ID: int64(len(r.entries) + 1), EventID: event.ID,
+		EventType: event.Type, AggregateType: kind, AggregateID: id, Payload: payload})
+}
+
+func (r *fakeOutboxRepo) TryLock(ctx context.Context) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (r *fakeOutboxRepo) ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error) {
+	var pending []*models.OutboxEntry
+	for _, entry := range r.entries {
+		if entry.ID > afterID && entry.PublishedAt == nil && entry.DeadAt == nil && len(pending) < limit {
+			copied := *entry
+			copied.DeliveredTo = append([]string(nil), entry.DeliveredTo...)
+			pending = append(pending, &copied)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeOutboxRepo) MarkDelivered(id int64, sink string) error {
+	entry := r.entries[id-1]
+	entry.DeliveredTo = append(entry.DeliveredTo, sink)
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkPublished(id int64) error {
+	now := time.Now()
+	r.entries[id-1].PublishedAt = &now
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkFailed(id int64, lastError string) error {
+	r.entries[id-1].Attempts++
+	r.entries[id-1].LastError = lastError
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkDead(id int64, lastError string) error {
+	now := time.Now()
+	r.MarkFailed(id, lastError)
+	r.entries[id-1].DeadAt = &now
+	return nil
+}
+
+func (r *fakeOutboxRepo) WithTx(tx *sql.Tx) repository.OutboxRepository { return r }
+
+// recordingSink remembers the IDs of the events it accepted, and refuses
+// every event while failing is set
+type recordingSink struct {
+	received []string
+	failing  bool
+}
+
+func (s *recordingSink) Publish(event events.Event) error {
+	if s.failing {
+		return fmt.Errorf("sink is down")
+	}
+	s.received = append(s.received, event.ID)
+	return nil
+}
+
+func TestRelayRetriesOnlyFailedSinks(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	task := &models.Task{ID: 1}
+	first, second := events.New(events.TaskCreated, task), events.New(events.TaskUpdated, task)
+	other := events.New(events.TaskCreated, &models.Task{ID: 2})
+	repo.append(t, first)
+	repo.append(t, second)
+	repo.append(t, other)
+
+	bus, kafka := &recordingSink{}, &recordingSink{failing: true}
+	relay := NewRelay(repo, []Sink{{Name: "bus", Publisher: bus}, {Name: "kafka", Publisher: kafka}},
+		config.OutboxConfig{BatchSize: 10})
+
+	relay.relayPending(context.Background())
+	// The later event about task 1 waits for the first one, other tasks go ahead
+	if want := []string{first.ID, other.ID}; fmt.Sprint(bus.received) != fmt.Sprint(want) {
+		t.Fatalf("bus received %v, want %v", bus.received, want)
+	}
+	for _, entry := range repo.entries {
+		if entry.PublishedAt != nil || entry.Attempts != map[int64]int{1: 1, 2: 0, 3: 1}[entry.ID] {
+			t.Errorf("entry %d: published %v after %d failures", entry.ID, entry.PublishedAt != nil, entry.Attempts)
+		}
+	}
+
+	kafka.failing = false
+	relay.relayPending(context.Background())
+	if want := []string{first.ID, other.ID, second.ID}; fmt.Sprint(bus.received) != fmt.Sprint(want) {
+		t.Errorf("bus received %v, want every event once", bus.received)
+	}
+	if want := []string{first.ID, second.ID, other.ID}; fmt.Sprint(kafka.received) != fmt.Sprint(want) {
+		t.Errorf("kafka received %v, want %v", kafka.received, want)
+	}
+	for _, entry := range repo.entries {
+		if entry.PublishedAt == nil {
+			t.Errorf("entry %d is still pending", entry.ID)
+		}
+	}
+}
+
+func TestRelayKeepsBusSequenceWhenASinkFails(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	repo.append(t, events.New(events.TaskCreated, &models.Task{ID: 1}))
+
+	bus := events.NewBus(10, 10)
+	webhooks := &recordingSink{failing: true}
+	relay := NewRelay(repo, []Sink{{Name: "bus", Publisher: bus}, {Name: "webhooks", Publisher: webhooks}},
+		config.OutboxConfig{BatchSize: 10})
+
+	for i := 0; i < 3; i++ {
+		relay.relayPending(context.Background())
+	}
+	webhooks.failing = false
+	relay.relayPending(context.Background())
+
+	sub, ok := bus.Subscribe(0, true)
+	if !ok {
+		t.Fatal("could not replay the bus")
+	}
+	defer sub.Close()
+	if len(sub.C) != 1 {
+		t.Errorf("bus broadcast the event %d times, want once", len(sub.C))
+	}
+	if len(webhooks.received) != 1 {
+		t.Errorf("webhooks received the event %d times, want once", len(webhooks.received))
+	}
+}
+
+func TestRelayPagesPastFailingAggregates(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	// Task 1's first entry cannot be read, and it and the entries held back
+	// behind it fill a batch
+	for i := 0; i < 3; i++ {
+		repo.append(t, events.New(events.TaskUpdated, &models.Task{ID: 1}))
+	}
+	repo.entries[0].Payload = json.RawMessage(`{"type": 1}`)
+	other := events.New(events.TaskCreated, &models.Task{ID: 2})
+	repo.append(t, other)
+
+	bus := &recordingSink{}
+	relay := NewRelay(repo, []Sink{{Name: "bus", Publisher: bus}}, config.OutboxConfig{BatchSize: 2})
+	for run := 0; run < 2; run++ {
+		relay.relayPending(context.Background())
+	}
+	if want := []string{other.ID}; fmt.Sprint(bus.received) != fmt.Sprint(want) {
+		t.Errorf("bus received %v, want only the event about task 2", bus.received)
+	}
+	if repo.entries[0].Attempts != 2 {
+		t.Errorf("the failing entry was tried %d times, want once a run", repo.entries[0].Attempts)
+	}
+}
+
+func TestRelaySetsAsideDeadEntries(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	task := &models.Task{ID: 1}
+	repo.append(t, events.New(events.TaskCreated, task))
+	repo.entries[0].Payload = json.RawMessage(`not json`)
+	next := events.New(events.TaskUpdated, task)
+	repo.append(t, next)
+
+	bus := &recordingSink{}
+	relay := NewRelay(repo, []Sink{{Name: "bus", Publisher: bus}}, config.OutboxConfig{BatchSize: 10, MaxAttempts: 3})
+	for run := 0; run < 2; run++ {
+		relay.relayPending(context.Background())
+	}
+	if len(bus.received) != 0 || repo.entries[0].DeadAt != nil {
+		t.Fatalf("after two runs: received %v, dead %v; want the entry still retried", bus.received,
+			repo.entries[0].DeadAt != nil)
+	}
+
+	// The last attempt fails too, and the later event goes ahead without it
+	relay.relayPending(context.Background())
+	if dead := repo.entries[0]; dead.DeadAt == nil || dead.Attempts != 3 || dead.LastError == "" {
+		t.Errorf("entry 1 = %+v, want it dead after 3 attempts", dead)
+	}
+	if want := []string{next.ID}; fmt.Sprint(bus.received) != fmt.Sprint(want) {
+		t.Errorf("bus received %v, want %v", bus.received, want)
+	}
+	relay.relayPending(context.Background())
+	if repo.entries[0].Attempts != 3 {
+		t.Error("a dead entry was offered again")
+	}
+}
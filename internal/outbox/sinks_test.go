@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+func TestFileSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "log.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink: %v", err)
+	}
+	first, second := events.New(events.TaskCreated, &models.Task{ID: 1}), events.New(events.TaskDeleted, nil)
+	for _, event := range []events.Event{first, second} {
+		if err := sink.Publish(event); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for i, want := range []string{first.ID, second.ID} {
+		event, err := events.Unmarshal([]byte(lines[i]))
+		if err != nil || event.ID != want {
+			t.Errorf("line %d = %s, want event %s", i+1, lines[i], want)
+		}
+	}
+}
+
+// fakeNATS speaks enough of the NATS client protocol to accept publishes,
+// sending every message it receives on msgs
+func fakeNATS(t *testing.T, info string, msgs chan<- string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				fmt.Fprintf(conn, "INFO %s\r\n", info)
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					switch {
+					case len(fields) == 0:
+					case fields[0] == "CONNECT":
+						msgs <- strings.TrimSpace(line)
+					case fields[0] == "PING":
+						conn.Write([]byte("PONG\r\n"))
+					case fields[0] == "PUB" && len(fields) == 3:
+						var size int
+						fmt.Sscan(fields[2], &size)
+						payload := make([]byte, size+2)
+						if _, err := io.ReadFull(r, payload); err != nil {
+							return
+						}
+						if fields[1] == "events.rejected" {
+							conn.Write([]byte("-ERR 'Permissions Violation'\r\n"))
+							continue
+						}
+						msgs <- fields[1] + " " + string(payload[:size])
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestNATSSinkPublishes(t *testing.T) {
+	msgs := make(chan string, 10)
+	addr := fakeNATS(t, `{"server_id":"fake"}`, msgs)
+
+	sink, err := NewNATSSink("nats://user:secret@"+addr, "events", time.Second)
+	if err != nil {
+		t.Fatalf("NewNATSSink: %v", err)
+	}
+	event := events.New(events.TaskCreated, &models.Task{ID: 1})
+	if err := sink.Publish(event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var connect struct{ User, Pass string }
+	json.Unmarshal([]byte(strings.TrimPrefix(<-msgs, "CONNECT ")), &connect)
+	if connect.User != "user" || connect.Pass != "secret" {
+		t.Errorf("connected as %+v, want the credentials from the URL", connect)
+	}
+	msg := <-msgs
+	if !strings.HasPrefix(msg, "events.task.created ") {
+		t.Fatalf("published %q, want subject events.task.created", msg)
+	}
+	if published, err := events.Unmarshal([]byte(strings.SplitN(msg, " ", 2)[1])); err != nil || published.ID != event.ID {
+		t.Errorf("payload = %s, want the event", msg)
+	}
+
+	rejected := &NATSSink{addr: addr, prefix: "events", timeout: time.Second}
+	if err := rejected.Publish(events.Event{Type: "rejected"}); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Publish = %v, want the server error", err)
+	}
+}
+
+func TestNATSSinkRefusesTLSServers(t *testing.T) {
+	addr := fakeNATS(t, `{"tls_required":true}`, make(chan string, 10))
+
+	sink, _ := NewNATSSink("nats://"+addr, "", time.Second)
+	if err := sink.Publish(events.New(events.TaskCreated, nil)); err == nil || !strings.Contains(err.Error(), "TLS") {
+		t.Errorf("Publish = %v, want TLS to be refused", err)
+	}
+}
+
+func TestKafkaRESTSink(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   *string         `json:"key"`
+			Value json.RawMessage `json:"value"`
+		} `json:"records"`
+	}
+	response := `{"offsets":[{"partition":0,"offset":1}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/topics/task%20events" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	sink := NewKafkaRESTSink(server.URL+"/", "task events", time.Second)
+	event := events.New(events.TaskCreated, &models.Task{ID: 5})
+	if err := sink.Publish(event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(body.Records) != 1 || body.Records[0].Key == nil || *body.Records[0].Key != "task:5" {
+		t.Fatalf("records = %+v, want one keyed by its task", body.Records)
+	}
+	if published, err := events.Unmarshal(body.Records[0].Value); err != nil || published.ID != event.ID {
+		t.Errorf("value = %s, want the event", body.Records[0].Value)
+	}
+
+	response = `{"offsets":[{"error_code":40403,"error":"topic not found"}]}`
+	if err := sink.Publish(event); err == nil || !strings.Contains(err.Error(), "topic not found") {
+		t.Errorf("Publish = %v, want the record error", err)
+	}
+}
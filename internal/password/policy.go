@@ -0,0 +1,108 @@
+// Package password decides whether a new password is acceptable.
+package password
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"task-management-api/config"
+	"task-management-api/pkg/breach"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PolicyError lists the rules a password breaks
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Policy holds the rules new passwords must meet
+type Policy struct {
+	cfg      config.PasswordsConfig
+	breached breach.Checker
+}
+
+// NewPolicy creates a Policy. breached may be nil to skip the check against
+// breached passwords.
+func NewPolicy(cfg config.PasswordsConfig, breached breach.Checker) *Policy {
+	return &Policy{cfg: cfg, breached: breached}
+}
+
+// Check returns a *PolicyError if the password breaks any rule. The username
+// and email are those of the account the password is for.
+func (p *Policy) Check(password, username, email string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength))
+	}
+	if p.cfg.MaxLength > 0 && len(password) > p.cfg.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.cfg.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		violations = append(violations, "must contain an upper-case letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		violations = append(violations, "must contain a lower-case letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if p.cfg.DisallowUserInfo && containsUserInfo(password, username, email) {
+		violations = append(violations, "must not contain your username or email address")
+	}
+
+	if len(violations) == 0 && p.breached != nil {
+		breached, err := p.breached.Breached(password)
+		if err != nil {
+			// An incomplete dataset should not stop people from setting
+			// passwords; the other rules still apply
+			log.Printf("Error checking breached passwords: %v", err)
+		} else if breached {
+			violations = append(violations, "has appeared in a data breach; choose a different one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo reports whether the password contains the username, the
+// email address or its local part, ignoring case. Very short values are
+// skipped, since they would match too much.
+func containsUserInfo(password, username, email string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, info := range []string{username, email, local} {
+		info = strings.ToLower(info)
+		if len(info) >= 3 && strings.Contains(password, info) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"task-management-api/config"
+	"testing"
+)
+
+type fakeChecker struct {
+	breached map[string]bool
+	err      error
+	calls    int
+}
+
+func (c *fakeChecker) Breached(password string) (bool, error) {
+	c.calls++
+	return c.breached[password], c.err
+}
+
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("err = %v, want a *PolicyError", err)
+	}
+	return policyErr.Violations
+}
+
+func TestCheck(t *testing.T) {
+	cfg := config.PasswordsConfig{
+		MinLength:        8,
+		MaxLength:        72,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		DisallowUserInfo: true,
+	}
+	policy := NewPolicy(cfg, nil)
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"Corr3ct horse", nil},
+		{"Ab1!", []string{"must be at least 8 characters long"}},
+		{"Ab1!" + strings.Repeat("x", 69), []string{"must be at most 72 bytes long"}},
+		{"correct horse 1", []string{"must contain an upper-case letter"}},
+		{"CORRECT HORSE 1", []string{"must contain a lower-case letter"}},
+		{"Correct horse!", []string{"must contain a digit"}},
+		{"Correcthorse1", []string{"must contain a symbol"}},
+		{"Annabel 2024!", []string{"must not contain your username or email address"}},
+		{"x", []string{
+			"must be at least 8 characters long",
+			"must contain an upper-case letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+	}
+	for _, tt := range tests {
+		got := violations(t, policy.Check(tt.password, "annabel", "ann.b@example.com"))
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Check(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCountsCharactersForMinimumLength(t *testing.T) {
+	policy := NewPolicy(config.PasswordsConfig{MinLength: 8}, nil)
+	if err := policy.Check("ééééééé", "", ""); err == nil {
+		t.Error("7 characters accepted")
+	}
+	if err := policy.Check("éééééééé", "", ""); err != nil {
+		t.Errorf("8 characters refused: %v", err)
+	}
+}
+
+func TestContainsUserInfo(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"my-ANNABEL-pass", true},
+		{"ann.b@example.com!", true},
+		{"hello ann.b", true},
+		{"nothing to see", false},
+	}
+	for _, tt := range tests {
+		if got := containsUserInfo(tt.password, "annabel", "ann.b@example.com"); got != tt.want {
+			t.Errorf("containsUserInfo(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+	// Short values would match almost anything
+	if containsUserInfo("joanna", "jo", "an@example.com") {
+		t.Error("matched a username shorter than 3 characters")
+	}
+}
+
+func TestCheckBreached(t *testing.T) {
+	checker := &fakeChecker{breached: map[string]bool{"password123": true}}
+	policy := NewPolicy(config.PasswordsConfig{MinLength: 8}, checker)
+
+	if got := violations(t, policy.Check("password123", "", "")); len(got) != 1 || !strings.Contains(got[0], "breach") {
+		t.Errorf("violations = %q, want the breach", got)
+	}
+	if err := policy.Check("unbreached words", "", ""); err != nil {
+		t.Errorf("Check = %v", err)
+	}
+
+	// Passwords that already break a rule are not looked up
+	calls := checker.calls
+	policy.Check("short", "", "")
+	if checker.calls != calls {
+		t.Error("looked up a password that was already refused")
+	}
+
+	// A broken dataset does not block password changes
+	checker.err = errors.New("disk on fire")
+	if err := policy.Check("password123", "", ""); err != nil {
+		t.Errorf("Check with a failing checker = %v, want no error", err)
+	}
+}
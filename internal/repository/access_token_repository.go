@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type AccessTokenRepository interface {
+	CreateAccessToken(token *models.PersonalAccessToken) error
+	GetAccessTokenByHash(hash string) (*models.PersonalAccessToken, error)
+	// ListAccessTokens returns the user's tokens that are not revoked,
+	// including expired ones, newest first
+	ListAccessTokens(userID int) ([]*models.PersonalAccessToken, error)
+	RevokeAccessToken(userID, id int) error
+	// TouchAccessToken records use of a token, if it was last used before since
+	TouchAccessToken(id int, ip string, since time.Time) error
+}
+
+type accessTokenRepository struct {
+	db DBTX
+}
+
+func NewAccessTokenRepository(db *sql.DB) AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+const accessTokenColumns = `id, user_id, name, scopes, token_prefix, token_hash, expires_at, last_used_at,
+			  last_used_ip, created_at, revoked_at`
+
+func scanAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	token := &models.PersonalAccessToken{}
+	var scopes string
+	var lastUsedIP sql.NullString
+	var expiresAt, lastUsedAt, createdAt, revokedAt []uint8
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.Prefix, &token.TokenHash,
+		&expiresAt, &lastUsedAt, &lastUsedIP, &createdAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Split(scopes, ",")
+	token.LastUsedIP = lastUsedIP.String
+	token.ExpiresAt, err = parseNullableTime(expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+	token.LastUsedAt, err = parseNullableTime(lastUsedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_used_at: %v", err)
+	}
+	token.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	token.RevokedAt, err = parseNullableTime(revokedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing revoked_at: %v", err)
+	}
+
+	return token, nil
+}
+
+func (r *accessTokenRepository) CreateAccessToken(token *models.PersonalAccessToken) error {
+	query := `INSERT INTO personal_access_tokens (user_id, name, scopes, token_prefix, token_hash, expires_at)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, token.UserID, token.Name, strings.Join(token.Scopes, ","), token.Prefix,
+		token.TokenHash, nullableTimeValue(token.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating access token: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	token.ID = int(id)
+	token.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
+
+func (r *accessTokenRepository) GetAccessTokenByHash(hash string) (*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens WHERE token_hash = ?`
+	token, err := scanAccessToken(r.db.QueryRow(query, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("token not found")
+		}
+		return nil, fmt.Errorf("error getting access token: %v", err)
+	}
+	return token, nil
+}
+
+func (r *accessTokenRepository) ListAccessTokens(userID int) ([]*models.PersonalAccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM personal_access_tokens
+			  WHERE user_id = ? AND revoked_at IS NULL ORDER BY id DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing access tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []*models.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning access token row: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return tokens, nil
+}
+
+func (r *accessTokenRepository) RevokeAccessToken(userID, id int) error {
+	now := time.Now()
+	query := `UPDATE personal_access_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, nullableTimeValue(&now), id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking access token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("token not found")
+	}
+
+	return nil
+}
+
+func (r *accessTokenRepository) TouchAccessToken(id int, ip string, since time.Time) error {
+	now := time.Now()
+	query := `UPDATE personal_access_tokens SET last_used_at = ?, last_used_ip = ?
+			  WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+	if _, err := r.db.Exec(query, nullableTimeValue(&now), ip, id, nullableTimeValue(&since)); err != nil {
+		return fmt.Errorf("error updating access token: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type AttachmentRepository interface {
+	CreateAttachment(attachment *models.Attachment) error
+	GetAttachmentByID(id int) (*models.Attachment, error)
+	ListAttachments(taskID int) ([]*models.Attachment, error)
+	DeleteAttachment(id int) error
+	CountByChecksum(checksum string) (int, error)
+	GetChecksumsForTasks(taskIDs []int) ([]string, error)
+	// LockBlob locks the row of a blob until the end of the transaction,
+	// creating it if needed
+	LockBlob(checksum string) error
+	DeleteBlob(checksum string) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) AttachmentRepository
+}
+
+type attachmentRepository struct {
+	db DBTX
+}
+
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) WithTx(tx *sql.Tx) AttachmentRepository {
+	return &attachmentRepository{db: tx}
+}
+
+const attachmentColumns = `id, task_id, file_name, content_type, size, checksum, uploaded_by, created_at`
+
+func scanAttachment(row rowScanner) (*models.Attachment, error) {
+	attachment := &models.Attachment{}
+	var uploadedBy sql.NullInt64
+	var createdAt []uint8
+	err := row.Scan(&attachment.ID, &attachment.TaskID, &attachment.FileName, &attachment.ContentType,
+		&attachment.Size, &attachment.Checksum, &uploadedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadedBy.Valid {
+		id := int(uploadedBy.Int64)
+		attachment.UploadedBy = &id
+	}
+
+	attachment.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+
+	return attachment, nil
+}
+
+func (r *attachmentRepository) CreateAttachment(attachment *models.Attachment) error {
+	query := `INSERT INTO attachments (task_id, file_name, content_type, size, checksum, uploaded_by)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, attachment.TaskID, attachment.FileName, attachment.ContentType,
+		attachment.Size, attachment.Checksum, attachment.UploadedBy)
+	if err != nil {
+		return fmt.Errorf("error creating attachment: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	created, err := r.GetAttachmentByID(int(id))
+	if err != nil {
+		return err
+	}
+	*attachment = *created
+	return nil
+}
+
+func (r *attachmentRepository) GetAttachmentByID(id int) (*models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = ?`
+	attachment, err := scanAttachment(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("attachment not found")
+		}
+		return nil, fmt.Errorf("error getting attachment: %v", err)
+	}
+	return attachment, nil
+}
+
+func (r *attachmentRepository) ListAttachments(taskID int) ([]*models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments WHERE task_id = ? ORDER BY created_at, id`
+	rows, err := r.db.Query(query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing attachments: %v", err)
+	}
+	defer rows.Close()
+
+	var attachments []*models.Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning attachment row: %v", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) DeleteAttachment(id int) error {
+	result, err := r.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting attachment: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("attachment not found")
+	}
+
+	return nil
+}
+
+// CountByChecksum returns how many attachments share the blob with the given checksum
+func (r *attachmentRepository) CountByChecksum(checksum string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM attachments WHERE checksum = ?`, checksum).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting attachments: %v", err)
+	}
+	return count, nil
+}
+
+// GetChecksumsForTasks returns the distinct blob checksums used by the given tasks
+func (r *attachmentRepository) GetChecksumsForTasks(taskIDs []int) ([]string, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(taskIDs)), ", ")
+	args := make([]interface{}, 0, len(taskIDs))
+	for _, id := range taskIDs {
+		args = append(args, id)
+	}
+
+	rows, err := r.db.Query(`SELECT DISTINCT checksum FROM attachments WHERE task_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying attachment checksums: %v", err)
+	}
+	defer rows.Close()
+
+	var checksums []string
+	for rows.Next() {
+		var checksum string
+		if err := rows.Scan(&checksum); err != nil {
+			return nil, fmt.Errorf("error scanning checksum row: %v", err)
+		}
+		checksums = append(checksums, checksum)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return checksums, nil
+}
+
+func (r *attachmentRepository) LockBlob(checksum string) error {
+	// Unlike INSERT IGNORE, a duplicate key update takes an exclusive lock
+	// on the existing row right away
+	query := `INSERT INTO blobs (checksum) VALUES (?) ON DUPLICATE KEY UPDATE checksum = checksum`
+	if _, err := r.db.Exec(query, checksum); err != nil {
+		return fmt.Errorf("error locking blob: %v", err)
+	}
+	return nil
+}
+
+func (r *attachmentRepository) DeleteBlob(checksum string) error {
+	if _, err := r.db.Exec(`DELETE FROM blobs WHERE checksum = ?`, checksum); err != nil {
+		return fmt.Errorf("error deleting blob: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type CommentRepository interface {
+	CreateComment(comment *models.Comment) error
+	GetCommentByID(id int) (*models.Comment, error)
+	ListComments(taskID int) ([]*models.Comment, error)
+	// GetAllComments returns the comments of every task, for filling an
+	// in-process search index
+	GetAllComments() ([]*models.Comment, error)
+	DeleteComment(id int) error
+}
+
+type commentRepository struct {
+	db *sql.DB
+}
+
+func NewCommentRepository(db *sql.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+const commentColumns = `id, task_id, user_id, body, created_at`
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	comment := &models.Comment{}
+	var userID sql.NullInt64
+	var createdAt []uint8
+	if err := row.Scan(&comment.ID, &comment.TaskID, &userID, &comment.Body, &createdAt); err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		id := int(userID.Int64)
+		comment.UserID = &id
+	}
+
+	var err error
+	comment.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+
+	return comment, nil
+}
+
+func (r *commentRepository) CreateComment(comment *models.Comment) error {
+	result, err := r.db.Exec(`INSERT INTO task_comments (task_id, user_id, body) VALUES (?, ?, ?)`,
+		comment.TaskID, comment.UserID, comment.Body)
+	if err != nil {
+		return fmt.Errorf("error creating comment: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	created, err := r.GetCommentByID(int(id))
+	if err != nil {
+		return err
+	}
+	*comment = *created
+	return nil
+}
+
+func (r *commentRepository) GetCommentByID(id int) (*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM task_comments WHERE id = ?`
+	comment, err := scanComment(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("comment not found")
+		}
+		return nil, fmt.Errorf("error getting comment: %v", err)
+	}
+	return comment, nil
+}
+
+func (r *commentRepository) ListComments(taskID int) ([]*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM task_comments WHERE task_id = ? ORDER BY created_at, id`
+	return r.queryComments(query, taskID)
+}
+
+func (r *commentRepository) GetAllComments() ([]*models.Comment, error) {
+	return r.queryComments(`SELECT ` + commentColumns + ` FROM task_comments ORDER BY task_id, created_at, id`)
+}
+
+func (r *commentRepository) queryComments(query string, args ...interface{}) ([]*models.Comment, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing comments: %v", err)
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning comment row: %v", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return comments, nil
+}
+
+func (r *commentRepository) DeleteComment(id int) error {
+	result, err := r.db.Exec(`DELETE FROM task_comments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting comment: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("comment not found")
+	}
+
+	return nil
+}
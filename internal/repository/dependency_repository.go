@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type DependencyRepository interface {
+	AddDependency(blockerID, blockedID int) error
+	RemoveDependency(blockerID, blockedID int) error
+	GetBlockers(taskID int) ([]*models.Task, error)
+	GetBlockedTasks(taskID int) ([]*models.Task, error)
+	GetDependenciesAmong(taskIDs []int) ([]*models.TaskDependency, error)
+	PathExists(fromID, toID int) (bool, error)
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) DependencyRepository
+}
+
+type dependencyRepository struct {
+	db DBTX
+}
+
+func NewDependencyRepository(db *sql.DB) DependencyRepository {
+	return &dependencyRepository{db: db}
+}
+
+func (r *dependencyRepository) WithTx(tx *sql.Tx) DependencyRepository {
+	return &dependencyRepository{db: tx}
+}
+
+func (r *dependencyRepository) AddDependency(blockerID, blockedID int) error {
+	query := `INSERT INTO task_dependencies (blocker_id, blocked_id) VALUES (?, ?)`
+	if _, err := r.db.Exec(query, blockerID, blockedID); err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("dependency already exists")
+		}
+		return fmt.Errorf("error adding dependency: %v", err)
+	}
+	return nil
+}
+
+func (r *dependencyRepository) RemoveDependency(blockerID, blockedID int) error {
+	query := `DELETE FROM task_dependencies WHERE blocker_id = ? AND blocked_id = ?`
+	result, err := r.db.Exec(query, blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("error removing dependency: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("dependency not found")
+	}
+
+	return nil
+}
+
+// GetBlockers returns the tasks that block the given task
+func (r *dependencyRepository) GetBlockers(taskID int) ([]*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks
+			  WHERE id IN (SELECT blocker_id FROM task_dependencies WHERE blocked_id = ?) AND deleted_at IS NULL
+			  ORDER BY id`
+	return queryTasks(r.db, query, taskID)
+}
+
+// GetBlockedTasks returns the tasks blocked by the given task
+func (r *dependencyRepository) GetBlockedTasks(taskID int) ([]*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks
+			  WHERE id IN (SELECT blocked_id FROM task_dependencies WHERE blocker_id = ?) AND deleted_at IS NULL
+			  ORDER BY id`
+	return queryTasks(r.db, query, taskID)
+}
+
+// GetDependenciesAmong returns the dependencies whose both ends are in taskIDs
+func (r *dependencyRepository) GetDependenciesAmong(taskIDs []int) ([]*models.TaskDependency, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(taskIDs)), ", ")
+	args := make([]interface{}, 0, 2*len(taskIDs))
+	for _, id := range taskIDs {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+
+	query := `SELECT blocker_id, blocked_id, created_at FROM task_dependencies
+			  WHERE blocker_id IN (` + placeholders + `) AND blocked_id IN (` + placeholders + `)`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying dependencies: %v", err)
+	}
+	defer rows.Close()
+
+	var deps []*models.TaskDependency
+	for rows.Next() {
+		dep := &models.TaskDependency{}
+		var createdAt []uint8
+		if err := rows.Scan(&dep.BlockerID, &dep.BlockedID, &createdAt); err != nil {
+			return nil, fmt.Errorf("error scanning dependency row: %v", err)
+		}
+		dep.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created_at: %v", err)
+		}
+		deps = append(deps, dep)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return deps, nil
+}
+
+// PathExists reports whether toID can be reached from fromID by following
+// blocker -> blocked edges
+func (r *dependencyRepository) PathExists(fromID, toID int) (bool, error) {
+	query := `WITH RECURSIVE reachable (id) AS (
+				  SELECT blocked_id FROM task_dependencies WHERE blocker_id = ?
+				  UNION
+				  SELECT d.blocked_id FROM task_dependencies d JOIN reachable r ON d.blocker_id = r.id
+			  )
+			  SELECT COUNT(*) FROM reachable WHERE id = ?`
+	var count int
+	if err := r.db.QueryRow(query, fromID, toID).Scan(&count); err != nil {
+		return false, fmt.Errorf("error checking dependency path: %v", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type InvitationRepository interface {
+	CreateInvitation(invitation *models.Invitation) error
+	GetInvitationByID(id int) (*models.Invitation, error)
+	GetInvitationByHash(hash string) (*models.Invitation, error)
+	// ListPendingInvitations returns the invitations not accepted yet,
+	// including expired ones
+	ListPendingInvitations() ([]*models.Invitation, error)
+	// DeletePendingInvitations deletes the invitations to the email address
+	// that were not accepted yet
+	DeletePendingInvitations(email string) error
+	DeleteInvitation(id int) error
+	// MarkAccepted records that an invitation was accepted; it fails if it
+	// already was
+	MarkAccepted(id int) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) InvitationRepository
+	// ForTenant returns a copy of the repository that only sees and creates
+	// the invitations of the given organisation
+	ForTenant(orgID int) InvitationRepository
+	// AllTenants returns a copy of the repository that sees the invitations of
+	// every organisation
+	AllTenants() InvitationRepository
+}
+
+type invitationRepository struct {
+	db     DBTX
+	tenant tenant
+}
+
+func NewInvitationRepository(db *sql.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) WithTx(tx *sql.Tx) InvitationRepository {
+	return &invitationRepository{db: tx, tenant: r.tenant}
+}
+
+func (r *invitationRepository) ForTenant(orgID int) InvitationRepository {
+	return &invitationRepository{db: r.db, tenant: tenant{orgID: orgID}}
+}
+
+func (r *invitationRepository) AllTenants() InvitationRepository {
+	return &invitationRepository{db: r.db, tenant: allTenants}
+}
+
+const invitationColumns = `id, org_id, email, role, org_role, team_id, invited_by, token_hash, expires_at, accepted_at, created_at`
+
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	var invitation models.Invitation
+	var teamID, invitedBy sql.NullInt64
+	var expiresAt, acceptedAt, createdAt []uint8
+	err := row.Scan(&invitation.ID, &invitation.OrgID, &invitation.Email, &invitation.Role, &invitation.OrgRole,
+		&teamID, &invitedBy, &invitation.TokenHash, &expiresAt, &acceptedAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if teamID.Valid {
+		id := int(teamID.Int64)
+		invitation.TeamID = &id
+	}
+	if invitedBy.Valid {
+		id := int(invitedBy.Int64)
+		invitation.InvitedBy = &id
+	}
+	invitation.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+	invitation.AcceptedAt, err = parseNullableTime(acceptedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing accepted_at: %v", err)
+	}
+	invitation.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) CreateInvitation(invitation *models.Invitation) error {
+	orgID, err := r.tenant.orgFor(invitation.OrgID)
+	if err != nil {
+		return err
+	}
+	invitation.OrgID = orgID
+	query := `INSERT INTO invitations (org_id, email, role, org_role, team_id, invited_by, token_hash, expires_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, invitation.OrgID, invitation.Email, invitation.Role, invitation.OrgRole,
+		invitation.TeamID, invitation.InvitedBy, invitation.TokenHash, nullableTimeValue(&invitation.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating invitation: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	created, err := r.GetInvitationByID(int(id))
+	if err != nil {
+		return err
+	}
+	*invitation = *created
+	return nil
+}
+
+func (r *invitationRepository) getInvitation(where string, arg interface{}) (*models.Invitation, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + invitationColumns + ` FROM invitations WHERE ` + where + ` AND ` + tenant
+	invitation, err := scanInvitation(r.db.QueryRow(query, append([]interface{}{arg}, tenantArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invitation not found")
+		}
+		return nil, fmt.Errorf("error getting invitation: %v", err)
+	}
+	return invitation, nil
+}
+
+func (r *invitationRepository) GetInvitationByID(id int) (*models.Invitation, error) {
+	return r.getInvitation(`id = ?`, id)
+}
+
+func (r *invitationRepository) GetInvitationByHash(hash string) (*models.Invitation, error) {
+	return r.getInvitation(`token_hash = ?`, hash)
+}
+
+func (r *invitationRepository) ListPendingInvitations() ([]*models.Invitation, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + invitationColumns + ` FROM invitations
+			  WHERE accepted_at IS NULL AND ` + tenant + ` ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing invitations: %v", err)
+	}
+	defer rows.Close()
+
+	var invitations []*models.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning invitation row: %v", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return invitations, nil
+}
+
+func (r *invitationRepository) DeletePendingInvitations(email string) error {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `DELETE FROM invitations WHERE email = ? AND accepted_at IS NULL AND ` + tenant
+	if _, err := r.db.Exec(query, append([]interface{}{email}, args...)...); err != nil {
+		return fmt.Errorf("error deleting invitations: %v", err)
+	}
+	return nil
+}
+
+func (r *invitationRepository) DeleteInvitation(id int) error {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`DELETE FROM invitations WHERE id = ? AND `+tenant, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error deleting invitation: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return nil
+}
+
+func (r *invitationRepository) MarkAccepted(id int) error {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	query := `UPDATE invitations SET accepted_at = ? WHERE id = ? AND accepted_at IS NULL AND ` + tenant
+	result, err := r.db.Exec(query, append([]interface{}{nullableTimeValue(&now), id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error accepting invitation: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("invitation already accepted")
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type LabelRepository interface {
+	CreateLabel(label *models.Label) error
+	GetLabelByID(id int) (*models.Label, error)
+	GetLabelByName(name string, projectID *int) (*models.Label, error)
+	ListLabels() ([]*models.Label, error)
+	UpdateLabel(id int, updates *models.UpdateLabel) error
+	DeleteLabel(id int) error
+	MergeLabels(sourceID, targetID int) error
+	GetTaskLabels(taskID int) ([]*models.Label, error)
+	AttachLabel(taskID, labelID int) error
+	DetachLabel(taskID, labelID int) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) LabelRepository
+	// ForTenant returns a copy of the repository that only sees and creates
+	// the labels of the given organisation
+	ForTenant(orgID int) LabelRepository
+	// AllTenants returns a copy of the repository that sees the labels of
+	// every organisation
+	AllTenants() LabelRepository
+}
+
+type labelRepository struct {
+	db     DBTX
+	tenant tenant
+}
+
+func NewLabelRepository(db *sql.DB) LabelRepository {
+	return &labelRepository{db: db}
+}
+
+func (r *labelRepository) WithTx(tx *sql.Tx) LabelRepository {
+	return &labelRepository{db: tx, tenant: r.tenant}
+}
+
+func (r *labelRepository) ForTenant(orgID int) LabelRepository {
+	return &labelRepository{db: r.db, tenant: tenant{orgID: orgID}}
+}
+
+func (r *labelRepository) AllTenants() LabelRepository {
+	return &labelRepository{db: r.db, tenant: allTenants}
+}
+
+// The usage count only takes the tasks of the label's own organisation
+// into account, archived ones included
+const labelColumns = `l.id, l.org_id, l.name, l.color, l.project_id,
+			  (SELECT COUNT(*) FROM task_labels tl WHERE tl.label_id = l.id AND tl.task_id IN (
+			      SELECT id FROM tasks WHERE org_id = l.org_id
+			      UNION ALL SELECT id FROM archived_tasks WHERE org_id = l.org_id)),
+			  l.created_at, l.updated_at`
+
+func scanLabel(row rowScanner) (*models.Label, error) {
+	label := &models.Label{}
+	var projectID sql.NullInt64
+	var createdAt, updatedAt []uint8
+	err := row.Scan(&label.ID, &label.OrgID, &label.Name, &label.Color, &projectID, &label.UsageCount, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectID.Valid {
+		id := int(projectID.Int64)
+		label.ProjectID = &id
+	}
+
+	label.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	label.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+
+	return label, nil
+}
+
+func (r *labelRepository) queryLabels(query string, args ...interface{}) ([]*models.Label, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing labels: %v", err)
+	}
+	defer rows.Close()
+
+	var labels []*models.Label
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning label row: %v", err)
+		}
+		labels = append(labels, label)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return labels, nil
+}
+
+func (r *labelRepository) CreateLabel(label *models.Label) error {
+	orgID, err := r.tenant.orgFor(label.OrgID)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO labels (org_id, name, color, project_id) VALUES (?, ?, ?, ?)`
+	result, err := r.db.Exec(query, orgID, label.Name, label.Color, label.ProjectID)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("label already exists")
+		}
+		return fmt.Errorf("error creating label: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	created, err := r.GetLabelByID(int(id))
+	if err != nil {
+		return err
+	}
+	*label = *created
+	return nil
+}
+
+func (r *labelRepository) GetLabelByID(id int) (*models.Label, error) {
+	tenant, tenantArgs, err := r.tenant.clause("l.org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + labelColumns + ` FROM labels l WHERE l.id = ? AND ` + tenant
+	label, err := scanLabel(r.db.QueryRow(query, append([]interface{}{id}, tenantArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("label not found")
+		}
+		return nil, fmt.Errorf("error getting label: %v", err)
+	}
+	return label, nil
+}
+
+// GetLabelByName looks a label up by name within a scope; a nil projectID
+// means the global scope
+func (r *labelRepository) GetLabelByName(name string, projectID *int) (*models.Label, error) {
+	tenant, tenantArgs, err := r.tenant.clause("l.org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + labelColumns + ` FROM labels l WHERE l.name = ? AND l.project_id <=> ? AND ` + tenant
+	label, err := scanLabel(r.db.QueryRow(query, append([]interface{}{name, projectID}, tenantArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("label not found")
+		}
+		return nil, fmt.Errorf("error getting label: %v", err)
+	}
+	return label, nil
+}
+
+func (r *labelRepository) ListLabels() ([]*models.Label, error) {
+	tenant, args, err := r.tenant.clause("l.org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + labelColumns + ` FROM labels l WHERE ` + tenant + ` ORDER BY l.project_id, l.name`
+	return r.queryLabels(query, args...)
+}
+
+func (r *labelRepository) UpdateLabel(id int, updates *models.UpdateLabel) error {
+	query := `UPDATE labels SET `
+	args := []interface{}{}
+
+	if updates.Name != nil {
+		query += `name = ?, `
+		args = append(args, *updates.Name)
+	}
+	if updates.Color != nil {
+		query += `color = ?, `
+		args = append(args, *updates.Color)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query = query[:len(query)-2] + ` WHERE id = ? AND ` + tenant
+	args = append(append(args, id), tenantArgs...)
+
+	if _, err := r.db.Exec(query, args...); err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("label already exists")
+		}
+		return fmt.Errorf("error updating label: %v", err)
+	}
+
+	return nil
+}
+
+func (r *labelRepository) DeleteLabel(id int) error {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`DELETE FROM labels WHERE id = ? AND `+tenant, append([]interface{}{id}, tenantArgs...)...)
+	if err != nil {
+		return fmt.Errorf("error deleting label: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("label not found")
+	}
+
+	return nil
+}
+
+// MergeLabels moves every use of the source label over to the target label
+// and deletes the source label
+func (r *labelRepository) MergeLabels(sourceID, targetID int) error {
+	return inTx(r.db, func(tx DBTX) error {
+		scoped := &labelRepository{db: tx, tenant: r.tenant}
+		for _, id := range []int{sourceID, targetID} {
+			if _, err := scoped.GetLabelByID(id); err != nil {
+				return err
+			}
+		}
+
+		query := `INSERT IGNORE INTO task_labels (task_id, label_id)
+				  SELECT task_id, ? FROM task_labels WHERE label_id = ?`
+		if _, err := tx.Exec(query, targetID, sourceID); err != nil {
+			return fmt.Errorf("error moving task labels: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM labels WHERE id = ?`, sourceID); err != nil {
+			return fmt.Errorf("error deleting merged label: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *labelRepository) GetTaskLabels(taskID int) ([]*models.Label, error) {
+	tenant, tenantArgs, err := r.tenant.clause("l.org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + labelColumns + ` FROM labels l
+			  JOIN task_labels tl ON tl.label_id = l.id
+			  WHERE tl.task_id = ? AND ` + tenant + ` ORDER BY l.name`
+	return r.queryLabels(query, append([]interface{}{taskID}, tenantArgs...)...)
+}
+
+// AttachLabel only attaches the labels of the repository's organisation;
+// checking the task is up to the caller
+func (r *labelRepository) AttachLabel(taskID, labelID int) error {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `INSERT IGNORE INTO task_labels (task_id, label_id) SELECT ?, id FROM labels WHERE id = ? AND ` + tenant
+	result, err := r.db.Exec(query, append([]interface{}{taskID, labelID}, tenantArgs...)...)
+	if err != nil {
+		return fmt.Errorf("error attaching label: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		// Already attached, or the label is not there
+		_, err := r.GetLabelByID(labelID)
+		return err
+	}
+
+	return nil
+}
+
+func (r *labelRepository) DetachLabel(taskID, labelID int) error {
+	tenant, tenantArgs, err := r.tenant.clause("l.org_id")
+	if err != nil {
+		return err
+	}
+	query := `DELETE tl FROM task_labels tl JOIN labels l ON l.id = tl.label_id
+			  WHERE tl.task_id = ? AND tl.label_id = ? AND ` + tenant
+	result, err := r.db.Exec(query, append([]interface{}{taskID, labelID}, tenantArgs...)...)
+	if err != nil {
+		return fmt.Errorf("error detaching label: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("label not attached to task")
+	}
+
+	return nil
+}
+
+// labelFilterClause builds the SQL condition restricting tasks to those
+// carrying the labels named in the filter
+func labelFilterClause(filter models.TaskFilter) (string, []interface{}) {
+	if len(filter.Labels) == 0 {
+		return "", nil
+	}
+
+	// The names are compared by the column's case-insensitive collation, so
+	// names differing only in case count once
+	args := make([]interface{}, 0, len(filter.Labels)+1)
+	seen := map[string]bool{}
+	for _, name := range filter.Labels {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			args = append(args, name)
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+
+	clause := `id IN (SELECT tl.task_id FROM task_labels tl JOIN labels l ON l.id = tl.label_id
+			   WHERE l.name IN (` + placeholders + `)`
+	if filter.MatchAllLabels {
+		clause += ` GROUP BY tl.task_id HAVING COUNT(DISTINCT l.name) = ?`
+		args = append(args, len(args))
+	}
+	return clause + `)`, args
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"strings"
+	"task-management-api/internal/models"
+	"testing"
+)
+
+func TestLabelFilterClause(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    models.TaskFilter
+		wantArgs  []interface{}
+		wantCount bool
+	}{
+		{"no labels", models.TaskFilter{}, nil, false},
+		{"any", models.TaskFilter{Labels: []string{"bug", "ui"}}, []interface{}{"bug", "ui"}, false},
+		{"all", models.TaskFilter{Labels: []string{"bug", "ui"}, MatchAllLabels: true}, []interface{}{"bug", "ui", 2}, true},
+		{"all with repeats", models.TaskFilter{Labels: []string{"bug", "bug", "Bug", "ui"}, MatchAllLabels: true},
+			[]interface{}{"bug", "ui", 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := labelFilterClause(tt.filter)
+			if len(tt.filter.Labels) == 0 {
+				if clause != "" || args != nil {
+					t.Fatalf("got %q %v, want no condition", clause, args)
+				}
+				return
+			}
+
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+				}
+			}
+			if placeholders := strings.Count(clause, "?"); placeholders != len(args) {
+				t.Errorf("clause has %d placeholders for %d args: %s", placeholders, len(args), clause)
+			}
+			if got := strings.Contains(clause, "HAVING"); got != tt.wantCount {
+				t.Errorf("HAVING in clause = %v, want %v", got, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type LoginThrottleRepository interface {
+	GetThrottle(scope models.ThrottleScope, key string) (*models.LoginThrottle, error)
+	// RecordFailure counts a failed login and returns the updated throttle.
+	// Failures older than the window are forgotten.
+	RecordFailure(scope models.ThrottleScope, key string, window time.Duration) (*models.LoginThrottle, error)
+	SetLockedUntil(scope models.ThrottleScope, key string, until time.Time) error
+	// DeleteThrottle forgets the failures, lifting any lock
+	DeleteThrottle(scope models.ThrottleScope, key string) error
+	ListLocked(scope models.ThrottleScope, now time.Time) ([]*models.LoginThrottle, error)
+	// DeleteStale removes throttles whose last failure and lock are both older than before
+	DeleteStale(before time.Time) error
+}
+
+type loginThrottleRepository struct {
+	db DBTX
+}
+
+func NewLoginThrottleRepository(db *sql.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+const loginThrottleColumns = `scope, throttle_key, failures, first_failed_at, last_failed_at, locked_until`
+
+func scanLoginThrottle(row rowScanner) (*models.LoginThrottle, error) {
+	throttle := &models.LoginThrottle{}
+	var firstFailedAt, lastFailedAt, lockedUntil []uint8
+	err := row.Scan(&throttle.Scope, &throttle.Key, &throttle.Failures, &firstFailedAt, &lastFailedAt, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	throttle.FirstFailedAt, err = time.Parse("2006-01-02 15:04:05", string(firstFailedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing first_failed_at: %v", err)
+	}
+	throttle.LastFailedAt, err = time.Parse("2006-01-02 15:04:05", string(lastFailedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_failed_at: %v", err)
+	}
+	throttle.LockedUntil, err = parseNullableTime(lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing locked_until: %v", err)
+	}
+
+	return throttle, nil
+}
+
+func (r *loginThrottleRepository) GetThrottle(scope models.ThrottleScope, key string) (*models.LoginThrottle, error) {
+	query := `SELECT ` + loginThrottleColumns + ` FROM login_throttles WHERE scope = ? AND throttle_key = ?`
+	throttle, err := scanLoginThrottle(r.db.QueryRow(query, scope, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("throttle not found")
+		}
+		return nil, fmt.Errorf("error getting throttle: %v", err)
+	}
+	return throttle, nil
+}
+
+func (r *loginThrottleRepository) RecordFailure(scope models.ThrottleScope, key string, window time.Duration) (*models.LoginThrottle, error) {
+	now := time.Now()
+	windowStart := now.Add(-window)
+
+	// The assignments run in order, so last_failed_at still holds the
+	// previous failure until the last one
+	query := `INSERT INTO login_throttles (scope, throttle_key, failures, first_failed_at, last_failed_at)
+			  VALUES (?, ?, 1, ?, ?)
+			  ON DUPLICATE KEY UPDATE
+				failures = IF(last_failed_at < ?, 1, failures + 1),
+				first_failed_at = IF(last_failed_at < ?, VALUES(first_failed_at), first_failed_at),
+				last_failed_at = VALUES(last_failed_at)`
+	_, err := r.db.Exec(query, scope, key, nullableTimeValue(&now), nullableTimeValue(&now),
+		nullableTimeValue(&windowStart), nullableTimeValue(&windowStart))
+	if err != nil {
+		return nil, fmt.Errorf("error recording failed login: %v", err)
+	}
+
+	return r.GetThrottle(scope, key)
+}
+
+func (r *loginThrottleRepository) SetLockedUntil(scope models.ThrottleScope, key string, until time.Time) error {
+	query := `UPDATE login_throttles SET locked_until = ? WHERE scope = ? AND throttle_key = ?`
+	if _, err := r.db.Exec(query, nullableTimeValue(&until), scope, key); err != nil {
+		return fmt.Errorf("error locking logins: %v", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) DeleteThrottle(scope models.ThrottleScope, key string) error {
+	query := `DELETE FROM login_throttles WHERE scope = ? AND throttle_key = ?`
+	if _, err := r.db.Exec(query, scope, key); err != nil {
+		return fmt.Errorf("error deleting throttle: %v", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) ListLocked(scope models.ThrottleScope, now time.Time) ([]*models.LoginThrottle, error) {
+	query := `SELECT ` + loginThrottleColumns + ` FROM login_throttles
+			  WHERE scope = ? AND locked_until > ? ORDER BY locked_until DESC`
+	rows, err := r.db.Query(query, scope, nullableTimeValue(&now))
+	if err != nil {
+		return nil, fmt.Errorf("error listing throttles: %v", err)
+	}
+	defer rows.Close()
+
+	throttles := []*models.LoginThrottle{}
+	for rows.Next() {
+		throttle, err := scanLoginThrottle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning throttle: %v", err)
+		}
+		throttles = append(throttles, throttle)
+	}
+	return throttles, rows.Err()
+}
+
+func (r *loginThrottleRepository) DeleteStale(before time.Time) error {
+	query := `DELETE FROM login_throttles WHERE last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)`
+	if _, err := r.db.Exec(query, nullableTimeValue(&before), nullableTimeValue(&before)); err != nil {
+		return fmt.Errorf("error deleting stale throttles: %v", err)
+	}
+	return nil
+}
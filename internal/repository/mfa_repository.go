@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type MFARepository interface {
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores new
+	// ones, given as hashes
+	ReplaceRecoveryCodes(userID int, hashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used; it fails with
+	// "recovery code not found" if there is none with that hash
+	UseRecoveryCode(userID int, hash string) error
+	CountRecoveryCodes(userID int) (int, error)
+	DeleteRecoveryCodes(userID int) error
+	GetRequiredRoles() ([]models.UserRole, error)
+	SetRequiredRoles(roles []models.UserRole) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) MFARepository
+}
+
+type mfaRepository struct {
+	db DBTX
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) WithTx(tx *sql.Tx) MFARepository {
+	return &mfaRepository{db: tx}
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	if err := r.DeleteRecoveryCodes(userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		_, err := r.db.Exec(`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash)
+		if err != nil {
+			return fmt.Errorf("error creating recovery code: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID int, hash string) error {
+	now := time.Now()
+	query := `UPDATE mfa_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	result, err := r.db.Exec(query, nullableTimeValue(&now), userID, hash)
+	if err != nil {
+		return fmt.Errorf("error using recovery code: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("recovery code not found")
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	if err := r.db.QueryRow(query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %v", err)
+	}
+	return count, nil
+}
+
+func (r *mfaRepository) DeleteRecoveryCodes(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %v", err)
+	}
+	return nil
+}
+
+func (r *mfaRepository) GetRequiredRoles() ([]models.UserRole, error) {
+	rows, err := r.db.Query(`SELECT role FROM mfa_required_roles ORDER BY role`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting MFA policy: %v", err)
+	}
+	defer rows.Close()
+
+	roles := []models.UserRole{}
+	for rows.Next() {
+		var role models.UserRole
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("error scanning MFA policy: %v", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *mfaRepository) SetRequiredRoles(roles []models.UserRole) error {
+	if _, err := r.db.Exec(`DELETE FROM mfa_required_roles`); err != nil {
+		return fmt.Errorf("error updating MFA policy: %v", err)
+	}
+	for _, role := range roles {
+		if _, err := r.db.Exec(`INSERT IGNORE INTO mfa_required_roles (role) VALUES (?)`, role); err != nil {
+			return fmt.Errorf("error updating MFA policy: %v", err)
+		}
+	}
+	return nil
+}
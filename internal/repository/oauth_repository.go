@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type OAuthRepository interface {
+	CreateClient(client *models.OAuthClient) error
+	GetClient(id int) (*models.OAuthClient, error)
+	// GetClientByClientID fails with "client not found" if there is no such app
+	GetClientByClientID(clientID string) (*models.OAuthClient, error)
+	// ListClients returns the apps registered by the owner, or every app
+	// when ownerID is 0
+	ListClients(ownerID int) ([]*models.OAuthClient, error)
+	UpdateClient(client *models.OAuthClient) error
+	UpdateClientSecret(id int, secretHash string) error
+	DeleteClient(id int) error
+
+	CreateCode(code *models.OAuthCode) error
+	// TakeCode returns an authorization code and deletes it, so that it
+	// cannot be used again; it fails with "code not found" if there is none
+	TakeCode(hash string) (*models.OAuthCode, error)
+
+	CreateToken(token *models.OAuthToken) error
+	// GetToken and GetTokenByRefreshHash fail with "token not found"
+	GetToken(tokenID string) (*models.OAuthToken, error)
+	GetTokenByRefreshHash(hash string) (*models.OAuthToken, error)
+	RevokeToken(id int) error
+	// RevokeTokens revokes every token the user granted the app
+	RevokeTokens(userID, clientID int) error
+
+	// GetConsent fails with "consent not found" if the user never allowed the app
+	GetConsent(userID, clientID int) (*models.OAuthConsent, error)
+	SaveConsent(userID, clientID int, scopes []string) error
+	ListConsents(userID int) ([]*models.OAuthConsent, error)
+	DeleteConsent(userID, clientID int) error
+
+	// DeleteExpired deletes codes and tokens that can no longer be used
+	DeleteExpired(now time.Time) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) OAuthRepository
+}
+
+type oauthRepository struct {
+	db DBTX
+}
+
+func NewOAuthRepository(db *sql.DB) OAuthRepository {
+	return &oauthRepository{db: db}
+}
+
+func (r *oauthRepository) WithTx(tx *sql.Tx) OAuthRepository {
+	return &oauthRepository{db: tx}
+}
+
+// splitList splits a stored list, which is empty for an empty string
+func splitList(s, sep string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, sep)
+}
+
+const oauthClientColumns = `id, client_id, owner_id, name, secret_hash, redirect_uris, scopes, grant_types,
+			  created_at, updated_at`
+
+func scanOAuthClient(row rowScanner) (*models.OAuthClient, error) {
+	client := &models.OAuthClient{}
+	var secretHash sql.NullString
+	var redirectURIs, scopes, grantTypes string
+	var createdAt, updatedAt []uint8
+	err := row.Scan(&client.ID, &client.ClientID, &client.OwnerID, &client.Name, &secretHash, &redirectURIs,
+		&scopes, &grantTypes, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SecretHash = secretHash.String
+	client.Confidential = secretHash.Valid
+	client.RedirectURIs = splitList(redirectURIs, "\n")
+	client.Scopes = splitList(scopes, ",")
+	client.GrantTypes = splitList(grantTypes, ",")
+	client.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	client.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+
+	return client, nil
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (r *oauthRepository) CreateClient(client *models.OAuthClient) error {
+	query := `INSERT INTO oauth_clients (client_id, owner_id, name, secret_hash, redirect_uris, scopes, grant_types)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, client.ClientID, client.OwnerID, client.Name, nullableString(client.SecretHash),
+		strings.Join(client.RedirectURIs, "\n"), strings.Join(client.Scopes, ","), strings.Join(client.GrantTypes, ","))
+	if err != nil {
+		return fmt.Errorf("error creating OAuth client: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	created, err := r.GetClient(int(id))
+	if err != nil {
+		return err
+	}
+	*client = *created
+	return nil
+}
+
+func (r *oauthRepository) getClient(where string, arg interface{}) (*models.OAuthClient, error) {
+	client, err := scanOAuthClient(r.db.QueryRow(`SELECT `+oauthClientColumns+` FROM oauth_clients WHERE `+where, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("client not found")
+		}
+		return nil, fmt.Errorf("error getting OAuth client: %v", err)
+	}
+	return client, nil
+}
+
+func (r *oauthRepository) GetClient(id int) (*models.OAuthClient, error) {
+	return r.getClient(`id = ?`, id)
+}
+
+func (r *oauthRepository) GetClientByClientID(clientID string) (*models.OAuthClient, error) {
+	return r.getClient(`client_id = ?`, clientID)
+}
+
+func (r *oauthRepository) ListClients(ownerID int) ([]*models.OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients`
+	args := []interface{}{}
+	if ownerID != 0 {
+		query += ` WHERE owner_id = ?`
+		args = append(args, ownerID)
+	}
+	query += ` ORDER BY id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing OAuth clients: %v", err)
+	}
+	defer rows.Close()
+
+	clients := []*models.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning OAuth client: %v", err)
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating OAuth clients: %v", err)
+	}
+	return clients, nil
+}
+
+func (r *oauthRepository) UpdateClient(client *models.OAuthClient) error {
+	query := `UPDATE oauth_clients SET name = ?, redirect_uris = ?, scopes = ? WHERE id = ?`
+	_, err := r.db.Exec(query, client.Name, strings.Join(client.RedirectURIs, "\n"), strings.Join(client.Scopes, ","), client.ID)
+	if err != nil {
+		return fmt.Errorf("error updating OAuth client: %v", err)
+	}
+	return nil
+}
+
+func (r *oauthRepository) UpdateClientSecret(id int, secretHash string) error {
+	if _, err := r.db.Exec(`UPDATE oauth_clients SET secret_hash = ? WHERE id = ?`, secretHash, id); err != nil {
+		return fmt.Errorf("error updating OAuth client secret: %v", err)
+	}
+	return nil
+}
+
+func (r *oauthRepository) DeleteClient(id int) error {
+	result, err := r.db.Exec(`DELETE FROM oauth_clients WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting OAuth client: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("client not found")
+	}
+	return nil
+}
+
+func (r *oauthRepository) CreateCode(code *models.OAuthCode) error {
+	query := `INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI,
+		strings.Join(code.Scopes, ","), code.CodeChallenge, nullableTimeValue(&code.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating authorization code: %v", err)
+	}
+	return nil
+}
+
+func (r *oauthRepository) TakeCode(hash string) (*models.OAuthCode, error) {
+	code := &models.OAuthCode{}
+	var scopes string
+	var expiresAt []uint8
+	query := `SELECT code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at
+			  FROM oauth_codes WHERE code_hash = ?`
+	err := r.db.QueryRow(query, hash).Scan(&code.CodeHash, &code.ClientID, &code.UserID, &code.RedirectURI,
+		&scopes, &code.CodeChallenge, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("code not found")
+		}
+		return nil, fmt.Errorf("error getting authorization code: %v", err)
+	}
+	code.Scopes = splitList(scopes, ",")
+	code.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+
+	// Only the request that deletes the code gets to use it
+	result, err := r.db.Exec(`DELETE FROM oauth_codes WHERE code_hash = ?`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting authorization code: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, errors.New("code not found")
+	}
+
+	return code, nil
+}
+
+const oauthTokenColumns = `id, token_id, client_id, user_id, scopes, refresh_hash, expires_at, refresh_expires_at,
+			  revoked_at, created_at`
+
+func scanOAuthToken(row rowScanner) (*models.OAuthToken, error) {
+	token := &models.OAuthToken{}
+	var scopes string
+	var refreshHash sql.NullString
+	var expiresAt, refreshExpiresAt, revokedAt, createdAt []uint8
+	err := row.Scan(&token.ID, &token.TokenID, &token.ClientID, &token.UserID, &scopes, &refreshHash,
+		&expiresAt, &refreshExpiresAt, &revokedAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = splitList(scopes, ",")
+	token.RefreshHash = refreshHash.String
+	token.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+	token.RefreshExpiresAt, err = parseNullableTime(refreshExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing refresh_expires_at: %v", err)
+	}
+	token.RevokedAt, err = parseNullableTime(revokedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing revoked_at: %v", err)
+	}
+	token.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+
+	return token, nil
+}
+
+func (r *oauthRepository) CreateToken(token *models.OAuthToken) error {
+	query := `INSERT INTO oauth_tokens (token_id, client_id, user_id, scopes, refresh_hash, expires_at, refresh_expires_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, token.TokenID, token.ClientID, token.UserID, strings.Join(token.Scopes, ","),
+		nullableString(token.RefreshHash), nullableTimeValue(&token.ExpiresAt), nullableTimeValue(token.RefreshExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating OAuth token: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	token.ID = int(id)
+	return nil
+}
+
+func (r *oauthRepository) getToken(where string, arg interface{}) (*models.OAuthToken, error) {
+	token, err := scanOAuthToken(r.db.QueryRow(`SELECT `+oauthTokenColumns+` FROM oauth_tokens WHERE `+where, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("token not found")
+		}
+		return nil, fmt.Errorf("error getting OAuth token: %v", err)
+	}
+	return token, nil
+}
+
+func (r *oauthRepository) GetToken(tokenID string) (*models.OAuthToken, error) {
+	return r.getToken(`token_id = ?`, tokenID)
+}
+
+func (r *oauthRepository) GetTokenByRefreshHash(hash string) (*models.OAuthToken, error) {
+	return r.getToken(`refresh_hash = ?`, hash)
+}
+
+func (r *oauthRepository) RevokeToken(id int) error {
+	now := time.Now()
+	query := `UPDATE oauth_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, nullableTimeValue(&now), id)
+	if err != nil {
+		return fmt.Errorf("error revoking OAuth token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("token not found")
+	}
+	return nil
+}
+
+func (r *oauthRepository) RevokeTokens(userID, clientID int) error {
+	now := time.Now()
+	query := `UPDATE oauth_tokens SET revoked_at = ? WHERE user_id = ? AND client_id = ? AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, nullableTimeValue(&now), userID, clientID); err != nil {
+		return fmt.Errorf("error revoking OAuth tokens: %v", err)
+	}
+	return nil
+}
+
+func (r *oauthRepository) GetConsent(userID, clientID int) (*models.OAuthConsent, error) {
+	consents, err := r.queryConsents(`c.user_id = ? AND c.client_id = ?`, userID, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if len(consents) == 0 {
+		return nil, errors.New("consent not found")
+	}
+	return consents[0], nil
+}
+
+func (r *oauthRepository) SaveConsent(userID, clientID int, scopes []string) error {
+	query := `INSERT INTO oauth_consents (user_id, client_id, scopes) VALUES (?, ?, ?)
+			  ON DUPLICATE KEY UPDATE scopes = VALUES(scopes)`
+	if _, err := r.db.Exec(query, userID, clientID, strings.Join(scopes, ",")); err != nil {
+		return fmt.Errorf("error saving consent: %v", err)
+	}
+	return nil
+}
+
+func (r *oauthRepository) ListConsents(userID int) ([]*models.OAuthConsent, error) {
+	return r.queryConsents(`c.user_id = ?`, userID)
+}
+
+func (r *oauthRepository) queryConsents(where string, args ...interface{}) ([]*models.OAuthConsent, error) {
+	query := `SELECT c.user_id, o.client_id, o.name, c.scopes, c.created_at, c.updated_at
+			  FROM oauth_consents c JOIN oauth_clients o ON o.id = c.client_id
+			  WHERE ` + where + ` ORDER BY o.name`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing consents: %v", err)
+	}
+	defer rows.Close()
+
+	consents := []*models.OAuthConsent{}
+	for rows.Next() {
+		consent := &models.OAuthConsent{}
+		var scopes string
+		var createdAt, updatedAt []uint8
+		err := rows.Scan(&consent.UserID, &consent.Client.ClientID, &consent.Client.Name, &scopes, &createdAt, &updatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning consent: %v", err)
+		}
+		consent.Scopes = splitList(scopes, ",")
+		consent.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created_at: %v", err)
+		}
+		consent.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing updated_at: %v", err)
+		}
+		consents = append(consents, consent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating consents: %v", err)
+	}
+	return consents, nil
+}
+
+func (r *oauthRepository) DeleteConsent(userID, clientID int) error {
+	result, err := r.db.Exec(`DELETE FROM oauth_consents WHERE user_id = ? AND client_id = ?`, userID, clientID)
+	if err != nil {
+		return fmt.Errorf("error deleting consent: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("consent not found")
+	}
+	return nil
+}
+
+func (r *oauthRepository) DeleteExpired(now time.Time) error {
+	cutoff := nullableTimeValue(&now)
+	if _, err := r.db.Exec(`DELETE FROM oauth_codes WHERE expires_at < ?`, cutoff); err != nil {
+		return fmt.Errorf("error deleting expired authorization codes: %v", err)
+	}
+	query := `DELETE FROM oauth_tokens WHERE expires_at < ? AND (refresh_expires_at IS NULL OR refresh_expires_at < ?)`
+	if _, err := r.db.Exec(query, cutoff, cutoff); err != nil {
+		return fmt.Errorf("error deleting expired OAuth tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type OIDCRepository interface {
+	CreateLoginState(state *models.OIDCLoginState) error
+	// TakeLoginState returns a login state and deletes it, so that it cannot
+	// be used again; it fails with "state not found" if there is none
+	TakeLoginState(hash string) (*models.OIDCLoginState, error)
+	DeleteExpiredLoginStates(now time.Time) error
+	// GetIdentity fails with "identity not found" if the provider account is
+	// not linked to a user
+	GetIdentity(issuer, subject string) (*models.UserIdentity, error)
+	CreateIdentity(identity *models.UserIdentity) error
+	TouchIdentity(id int) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) OIDCRepository
+}
+
+type oidcRepository struct {
+	db DBTX
+}
+
+func NewOIDCRepository(db *sql.DB) OIDCRepository {
+	return &oidcRepository{db: db}
+}
+
+func (r *oidcRepository) WithTx(tx *sql.Tx) OIDCRepository {
+	return &oidcRepository{db: tx}
+}
+
+func (r *oidcRepository) CreateLoginState(state *models.OIDCLoginState) error {
+	query := `INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, expires_at) VALUES (?, ?, ?, ?)`
+	_, err := r.db.Exec(query, state.StateHash, state.Nonce, state.CodeVerifier, nullableTimeValue(&state.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating login state: %v", err)
+	}
+	return nil
+}
+
+func (r *oidcRepository) TakeLoginState(hash string) (*models.OIDCLoginState, error) {
+	state := &models.OIDCLoginState{}
+	var expiresAt []uint8
+	query := `SELECT state_hash, nonce, code_verifier, expires_at FROM oidc_login_states WHERE state_hash = ?`
+	err := r.db.QueryRow(query, hash).Scan(&state.StateHash, &state.Nonce, &state.CodeVerifier, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("state not found")
+		}
+		return nil, fmt.Errorf("error getting login state: %v", err)
+	}
+	state.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+
+	// Only the request that deletes the state gets to use it
+	result, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE state_hash = ?`, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting login state: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, errors.New("state not found")
+	}
+
+	return state, nil
+}
+
+func (r *oidcRepository) DeleteExpiredLoginStates(now time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM oidc_login_states WHERE expires_at < ?`, nullableTimeValue(&now)); err != nil {
+		return fmt.Errorf("error deleting expired login states: %v", err)
+	}
+	return nil
+}
+
+func (r *oidcRepository) GetIdentity(issuer, subject string) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{}
+	var createdAt, lastLoginAt []uint8
+	query := `SELECT id, user_id, issuer, subject, created_at, last_login_at FROM user_identities
+			  WHERE issuer = ? AND subject = ?`
+	err := r.db.QueryRow(query, issuer, subject).Scan(&identity.ID, &identity.UserID, &identity.Issuer,
+		&identity.Subject, &createdAt, &lastLoginAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("identity not found")
+		}
+		return nil, fmt.Errorf("error getting identity: %v", err)
+	}
+
+	identity.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	identity.LastLoginAt, err = parseNullableTime(lastLoginAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_login_at: %v", err)
+	}
+
+	return identity, nil
+}
+
+func (r *oidcRepository) CreateIdentity(identity *models.UserIdentity) error {
+	now := time.Now()
+	query := `INSERT INTO user_identities (user_id, issuer, subject, last_login_at) VALUES (?, ?, ?, ?)`
+	result, err := r.db.Exec(query, identity.UserID, identity.Issuer, identity.Subject, nullableTimeValue(&now))
+	if err != nil {
+		return fmt.Errorf("error creating identity: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	identity.ID = int(id)
+	identity.CreatedAt = now
+	identity.LastLoginAt = &now
+	return nil
+}
+
+func (r *oidcRepository) TouchIdentity(id int) error {
+	now := time.Now()
+	if _, err := r.db.Exec(`UPDATE user_identities SET last_login_at = ? WHERE id = ?`, nullableTimeValue(&now), id); err != nil {
+		return fmt.Errorf("error updating identity: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+// tenant is the organisation a repository is restricted to. The zero value
+// is restricted to nothing and refuses every query, so that a repository
+// nobody called ForTenant on cannot leak another organisation's rows;
+// repositories that work across organisations ask for allTenants.
+type tenant struct {
+	orgID int
+	all   bool
+}
+
+// allTenants lets a repository see and change the rows of every organisation
+var allTenants = tenant{all: true}
+
+// errNoTenant is returned by the repositories that were restricted to no
+// organisation, which ForTenant(0) also does
+var errNoTenant = errors.New("repository is not restricted to an organisation")
+
+// clause returns the SQL condition restricting rows to the organisation
+// through the given column, and its arguments
+func (t tenant) clause(column string) (string, []interface{}, error) {
+	switch {
+	case t.orgID != 0:
+		return column + ` = ?`, []interface{}{t.orgID}, nil
+	case t.all:
+		return `TRUE`, nil, nil
+	}
+	return "", nil, errNoTenant
+}
+
+// orgFor returns the organisation new rows go to: the repository's own, or
+// for repositories working across organisations the given one, falling back
+// to the default organisation
+func (t tenant) orgFor(orgID int) (int, error) {
+	switch {
+	case t.orgID != 0:
+		return t.orgID, nil
+	case !t.all:
+		return 0, errNoTenant
+	case orgID != 0:
+		return orgID, nil
+	}
+	return models.DefaultOrganizationID, nil
+}
+
+type OrganizationRepository interface {
+	CreateOrganization(org *models.NewOrganization) (*models.Organization, error)
+	GetOrganizationByID(id int) (*models.Organization, error)
+	GetOrganizationBySlug(slug string) (*models.Organization, error)
+	ListOrganizations() ([]*models.Organization, error)
+	UpdateOrganization(id int, updates *models.UpdateOrganization) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) OrganizationRepository
+}
+
+type organizationRepository struct {
+	db DBTX
+}
+
+func NewOrganizationRepository(db *sql.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+func (r *organizationRepository) WithTx(tx *sql.Tx) OrganizationRepository {
+	return &organizationRepository{db: tx}
+}
+
+const organizationColumns = `id, name, slug, created_at`
+
+func scanOrganization(row rowScanner) (*models.Organization, error) {
+	var org models.Organization
+	var createdAt []uint8
+	if err := row.Scan(&org.ID, &org.Name, &org.Slug, &createdAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	org.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) CreateOrganization(org *models.NewOrganization) (*models.Organization, error) {
+	result, err := r.db.Exec(`INSERT INTO organizations (name, slug) VALUES (?, ?)`, org.Name, org.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("error creating organization: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	return r.GetOrganizationByID(int(id))
+}
+
+func (r *organizationRepository) getOrganization(where string, arg interface{}) (*models.Organization, error) {
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE ` + where
+	org, err := scanOrganization(r.db.QueryRow(query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("organization not found")
+		}
+		return nil, fmt.Errorf("error getting organization: %v", err)
+	}
+	return org, nil
+}
+
+func (r *organizationRepository) GetOrganizationByID(id int) (*models.Organization, error) {
+	return r.getOrganization(`id = ?`, id)
+}
+
+func (r *organizationRepository) GetOrganizationBySlug(slug string) (*models.Organization, error) {
+	return r.getOrganization(`slug = ?`, slug)
+}
+
+func (r *organizationRepository) ListOrganizations() ([]*models.Organization, error) {
+	rows, err := r.db.Query(`SELECT ` + organizationColumns + ` FROM organizations ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing organizations: %v", err)
+	}
+	defer rows.Close()
+
+	var orgs []*models.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning organization row: %v", err)
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return orgs, nil
+}
+
+func (r *organizationRepository) UpdateOrganization(id int, updates *models.UpdateOrganization) error {
+	if updates.Name == nil {
+		return nil
+	}
+	result, err := r.db.Exec(`UPDATE organizations SET name = ? WHERE id = ?`, *updates.Name, id)
+	if err != nil {
+		return fmt.Errorf("error updating organization: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("organization not found")
+	}
+
+	return nil
+}
+
+type TeamRepository interface {
+	CreateTeam(team *models.NewTeam) (*models.Team, error)
+	GetTeamByID(id int) (*models.Team, error)
+	ListTeams() ([]*models.Team, error)
+	UpdateTeam(id int, updates *models.UpdateTeam) error
+	DeleteTeam(id int) error
+	ListTeamMembers(teamID int) ([]*models.User, error)
+	// AddTeamMember adds a user to a team; adding a member twice is not an error
+	AddTeamMember(teamID, userID int) error
+	RemoveTeamMember(teamID, userID int) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) TeamRepository
+	// ForTenant returns a copy of the repository that only sees and creates
+	// the teams of the given organisation
+	ForTenant(orgID int) TeamRepository
+	// AllTenants returns a copy of the repository that sees the teams of
+	// every organisation
+	AllTenants() TeamRepository
+}
+
+type teamRepository struct {
+	db     DBTX
+	tenant tenant
+}
+
+func NewTeamRepository(db *sql.DB) TeamRepository {
+	return &teamRepository{db: db}
+}
+
+func (r *teamRepository) WithTx(tx *sql.Tx) TeamRepository {
+	return &teamRepository{db: tx, tenant: r.tenant}
+}
+
+func (r *teamRepository) ForTenant(orgID int) TeamRepository {
+	return &teamRepository{db: r.db, tenant: tenant{orgID: orgID}}
+}
+
+func (r *teamRepository) AllTenants() TeamRepository {
+	return &teamRepository{db: r.db, tenant: allTenants}
+}
+
+const teamColumns = `id, org_id, name, description, created_at, updated_at`
+
+func scanTeam(row rowScanner) (*models.Team, error) {
+	var team models.Team
+	var createdAt, updatedAt []uint8
+	if err := row.Scan(&team.ID, &team.OrgID, &team.Name, &team.Description, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	team.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	team.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+	return &team, nil
+}
+
+func (r *teamRepository) CreateTeam(team *models.NewTeam) (*models.Team, error) {
+	orgID, err := r.tenant.orgFor(0)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.db.Exec(`INSERT INTO teams (org_id, name, description) VALUES (?, ?, ?)`, orgID, team.Name, team.Description)
+	if err != nil {
+		return nil, fmt.Errorf("error creating team: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	return r.GetTeamByID(int(id))
+}
+
+func (r *teamRepository) GetTeamByID(id int) (*models.Team, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + teamColumns + ` FROM teams WHERE id = ? AND ` + tenant
+	team, err := scanTeam(r.db.QueryRow(query, append([]interface{}{id}, tenantArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("team not found")
+		}
+		return nil, fmt.Errorf("error getting team: %v", err)
+	}
+	return team, nil
+}
+
+func (r *teamRepository) ListTeams() ([]*models.Team, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(`SELECT `+teamColumns+` FROM teams WHERE `+tenant+` ORDER BY name, id`, tenantArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing teams: %v", err)
+	}
+	defer rows.Close()
+
+	var teams []*models.Team
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning team row: %v", err)
+		}
+		teams = append(teams, team)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return teams, nil
+}
+
+func (r *teamRepository) UpdateTeam(id int, updates *models.UpdateTeam) error {
+	var sets []string
+	var args []interface{}
+	if updates.Name != nil {
+		sets = append(sets, `name = ?`)
+		args = append(args, *updates.Name)
+	}
+	if updates.Description != nil {
+		sets = append(sets, `description = ?`)
+		args = append(args, *updates.Description)
+	}
+	if len(sets) == 0 {
+		_, err := r.GetTeamByID(id)
+		return err
+	}
+
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `UPDATE teams SET ` + strings.Join(sets, `, `) + ` WHERE id = ? AND ` + tenant
+	args = append(append(args, id), tenantArgs...)
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating team: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		// Nothing changed, or the team is not there
+		_, err := r.GetTeamByID(id)
+		return err
+	}
+
+	return nil
+}
+
+func (r *teamRepository) DeleteTeam(id int) error {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`DELETE FROM teams WHERE id = ? AND `+tenant, append([]interface{}{id}, tenantArgs...)...)
+	if err != nil {
+		return fmt.Errorf("error deleting team: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("team not found")
+	}
+
+	return nil
+}
+
+func (r *teamRepository) ListTeamMembers(teamID int) ([]*models.User, error) {
+	if _, err := r.GetTeamByID(teamID); err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users
+			  WHERE id IN (SELECT user_id FROM team_members WHERE team_id = ?) AND deleted_at IS NULL
+			  ORDER BY username, id`
+	return queryUsers(r.db, query, teamID)
+}
+
+// AddTeamMember only adds users of the team's own organisation
+func (r *teamRepository) AddTeamMember(teamID, userID int) error {
+	team, err := r.GetTeamByID(teamID)
+	if err != nil {
+		return err
+	}
+	if _, err := (&userRepository{db: r.db, tenant: tenant{orgID: team.OrgID}}).GetUserByID(userID); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(`INSERT IGNORE INTO team_members (team_id, user_id) VALUES (?, ?)`, teamID, userID); err != nil {
+		return fmt.Errorf("error adding team member: %v", err)
+	}
+	return nil
+}
+
+func (r *teamRepository) RemoveTeamMember(teamID, userID int) error {
+	if _, err := r.GetTeamByID(teamID); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("error removing team member: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("team member not found")
+	}
+
+	return nil
+}
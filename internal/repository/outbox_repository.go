@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"time"
+)
+
+// outboxRelayLock is the name of the advisory lock held by the relay, so
+// that only one instance publishes at a time and per-aggregate order holds
+const outboxRelayLock = "task_management_outbox_relay"
+
+type OutboxRepository interface {
+	// Append records events; call it on a repository bound to the
+	// transaction that makes the change the events describe
+	Append(evts ...events.Event) error
+	// ListPending returns up to limit entries after the one with ID afterID
+	// that are neither published nor dead, in the order they were written
+	ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error)
+	// MarkDelivered records that the named sink accepted an entry
+	MarkDelivered(id int64, sink string) error
+	MarkPublished(id int64) error
+	MarkFailed(id int64, lastError string) error
+	// MarkDead records a last failure and sets the entry aside for good
+	MarkDead(id int64, lastError string) error
+	DeletePublishedBefore(t time.Time) error
+	// TryLock takes the relay lock if no other instance holds it. The
+	// returned function releases it.
+	TryLock(ctx context.Context) (release func(), acquired bool, err error)
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) OutboxRepository
+}
+
+type outboxRepository struct {
+	db   DBTX
+	pool *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db, pool: db}
+}
+
+func (r *outboxRepository) WithTx(tx *sql.Tx) OutboxRepository {
+	return &outboxRepository{db: tx, pool: r.pool}
+}
+
+func (r *outboxRepository) Append(evts ...events.Event) error {
+	query := `INSERT INTO outbox (event_id, event_type, aggregate_type, aggregate_id, payload) VALUES (?, ?, ?, ?, ?)`
+	for _, event := range evts {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error encoding %s event: %v", event.Type, err)
+		}
+		kind, id := event.Aggregate()
+		if _, err := r.db.Exec(query, event.ID, event.Type, kind, id, string(payload)); err != nil {
+			return fmt.Errorf("error writing event to outbox: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListPending returns pending entries with the sinks that have accepted them
+// so far
+func (r *outboxRepository) ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error) {
+	query := `SELECT id, event_id, event_type, aggregate_type, aggregate_id, payload, attempts, last_error, created_at,
+			  (SELECT GROUP_CONCAT(sink ORDER BY sink) FROM outbox_deliveries WHERE outbox_id = outbox.id)
+			  FROM outbox WHERE published_at IS NULL AND dead_at IS NULL AND id > ? ORDER BY id LIMIT ?`
+	rows, err := r.db.Query(query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing outbox: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		entry := &models.OutboxEntry{}
+		var payload []byte
+		var lastError, deliveredTo sql.NullString
+		var createdAt []uint8
+		err := rows.Scan(&entry.ID, &entry.EventID, &entry.EventType, &entry.AggregateType, &entry.AggregateID,
+			&payload, &entry.Attempts, &lastError, &createdAt, &deliveredTo)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning outbox row: %v", err)
+		}
+		entry.Payload = payload
+		entry.LastError = lastError.String
+		if deliveredTo.Valid {
+			entry.DeliveredTo = strings.Split(deliveredTo.String, ",")
+		}
+		entry.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing created_at: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return entries, nil
+}
+
+func (r *outboxRepository) MarkDelivered(id int64, sink string) error {
+	_, err := r.db.Exec(`INSERT IGNORE INTO outbox_deliveries (outbox_id, sink) VALUES (?, ?)`, id, sink)
+	if err != nil {
+		return fmt.Errorf("error recording outbox delivery: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkPublished(id int64) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE outbox SET published_at = ? WHERE id = ?`, nullableTimeValue(&now), id)
+	if err != nil {
+		return fmt.Errorf("error marking outbox entry published: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(id int64, lastError string) error {
+	_, err := r.db.Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ?`, lastError, id)
+	if err != nil {
+		return fmt.Errorf("error recording outbox failure: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkDead(id int64, lastError string) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = ?, dead_at = ? WHERE id = ?`,
+		lastError, nullableTimeValue(&now), id)
+	if err != nil {
+		return fmt.Errorf("error setting outbox entry aside: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) DeletePublishedBefore(t time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < ?`, nullableTimeValue(&t))
+	if err != nil {
+		return fmt.Errorf("error pruning outbox: %v", err)
+	}
+	return nil
+}
+
+func (r *outboxRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	// Advisory locks belong to a connection, so hold on to one until release
+	conn, err := r.pool.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting connection: %v", err)
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 0)`, outboxRelayLock).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("error taking relay lock: %v", err)
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, outboxRelayLock)
+		conn.Close()
+	}
+	return release, true, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type SavedFilterRepository interface {
+	CreateFilter(f *models.SavedFilter) error
+	GetFilterByID(id int) (*models.SavedFilter, error)
+	ListFiltersForUser(userID int) ([]*models.SavedFilter, error)
+	UpdateFilter(f *models.SavedFilter) error
+	DeleteFilter(id int) error
+}
+
+type savedFilterRepository struct {
+	db *sql.DB
+}
+
+func NewSavedFilterRepository(db *sql.DB) SavedFilterRepository {
+	return &savedFilterRepository{db: db}
+}
+
+func (r *savedFilterRepository) CreateFilter(f *models.SavedFilter) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO saved_filters (user_id, name, query) VALUES (?, ?, ?)`, f.UserID, f.Name, f.Query)
+	if err != nil {
+		return fmt.Errorf("error creating filter: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	if err := replaceShares(tx, int(id), f.SharedWith); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing filter: %v", err)
+	}
+
+	created, err := r.GetFilterByID(int(id))
+	if err != nil {
+		return err
+	}
+	*f = *created
+	return nil
+}
+
+func (r *savedFilterRepository) GetFilterByID(id int) (*models.SavedFilter, error) {
+	query := `SELECT id, user_id, name, query, created_at, updated_at FROM saved_filters WHERE id = ?`
+	f, err := scanFilter(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("filter not found")
+		}
+		return nil, fmt.Errorf("error getting filter: %v", err)
+	}
+
+	if err := r.loadShares([]*models.SavedFilter{f}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ListFiltersForUser returns the filters owned by the user and those shared with them
+func (r *savedFilterRepository) ListFiltersForUser(userID int) ([]*models.SavedFilter, error) {
+	query := `SELECT id, user_id, name, query, created_at, updated_at FROM saved_filters
+			  WHERE user_id = ? OR id IN (SELECT filter_id FROM saved_filter_shares WHERE user_id = ?)
+			  ORDER BY name, id`
+	rows, err := r.db.Query(query, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing filters: %v", err)
+	}
+	defer rows.Close()
+
+	var filters []*models.SavedFilter
+	for rows.Next() {
+		f, err := scanFilter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning filter row: %v", err)
+		}
+		filters = append(filters, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	if err := r.loadShares(filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+func (r *savedFilterRepository) UpdateFilter(f *models.SavedFilter) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE saved_filters SET name = ?, query = ? WHERE id = ?`, f.Name, f.Query, f.ID); err != nil {
+		return fmt.Errorf("error updating filter: %v", err)
+	}
+	if err := replaceShares(tx, f.ID, f.SharedWith); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *savedFilterRepository) DeleteFilter(id int) error {
+	result, err := r.db.Exec(`DELETE FROM saved_filters WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting filter: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("filter not found")
+	}
+
+	return nil
+}
+
+func scanFilter(row rowScanner) (*models.SavedFilter, error) {
+	f := &models.SavedFilter{SharedWith: []int{}}
+	var createdAt, updatedAt []uint8
+	if err := row.Scan(&f.ID, &f.UserID, &f.Name, &f.Query, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	f.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	f.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+
+	return f, nil
+}
+
+// loadShares fills in SharedWith for the given filters
+func (r *savedFilterRepository) loadShares(filters []*models.SavedFilter) error {
+	for _, f := range filters {
+		rows, err := r.db.Query(`SELECT user_id FROM saved_filter_shares WHERE filter_id = ? ORDER BY user_id`, f.ID)
+		if err != nil {
+			return fmt.Errorf("error loading filter shares: %v", err)
+		}
+		for rows.Next() {
+			var userID int
+			if err := rows.Scan(&userID); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning filter share row: %v", err)
+			}
+			f.SharedWith = append(f.SharedWith, userID)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error after scanning all rows: %v", err)
+		}
+	}
+	return nil
+}
+
+func replaceShares(tx *sql.Tx, filterID int, userIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM saved_filter_shares WHERE filter_id = ?`, filterID); err != nil {
+		return fmt.Errorf("error clearing filter shares: %v", err)
+	}
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(`INSERT IGNORE INTO saved_filter_shares (filter_id, user_id) VALUES (?, ?)`, filterID, userID); err != nil {
+			return fmt.Errorf("error sharing filter: %v", err)
+		}
+	}
+	return nil
+}
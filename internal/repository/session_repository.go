@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type SessionRepository interface {
+	CreateSession(session *models.Session) error
+	GetSession(id string) (*models.Session, error)
+	// ListActiveSessions returns the user's sessions that are neither revoked
+	// nor expired, most recently used first
+	ListActiveSessions(userID int) ([]*models.Session, error)
+	// TouchSession records activity on a session, if it was last seen before since
+	TouchSession(id string, since time.Time) error
+	RevokeSession(userID int, id string) error
+	// RevokeSessions revokes all of the user's sessions except the given one,
+	// which may be empty
+	RevokeSessions(userID int, exceptID string) error
+}
+
+type sessionRepository struct {
+	db DBTX
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const sessionColumns = `id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	var createdAt, lastSeenAt, expiresAt, revokedAt []uint8
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+		&createdAt, &lastSeenAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	session.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	session.LastSeenAt, err = time.Parse("2006-01-02 15:04:05", string(lastSeenAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_seen_at: %v", err)
+	}
+	session.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+	session.RevokedAt, err = parseNullableTime(revokedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing revoked_at: %v", err)
+	}
+
+	return session, nil
+}
+
+func (r *sessionRepository) CreateSession(session *models.Session) error {
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip_address, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, session.ID, session.UserID, session.UserAgent, session.IPAddress,
+		nullableTimeValue(&session.LastSeenAt), nullableTimeValue(&session.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating session: %v", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetSession(id string) (*models.Session, error) {
+	session, err := scanSession(r.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("session not found")
+		}
+		return nil, fmt.Errorf("error getting session: %v", err)
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) ListActiveSessions(userID int) ([]*models.Session, error) {
+	now := time.Now()
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+			  WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC`
+	rows, err := r.db.Query(query, userID, nullableTimeValue(&now))
+	if err != nil {
+		return nil, fmt.Errorf("error listing sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning session row: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchSession(id string, since time.Time) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?`,
+		nullableTimeValue(&now), id, nullableTimeValue(&since))
+	if err != nil {
+		return fmt.Errorf("error updating session: %v", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeSession(userID int, id string) error {
+	now := time.Now()
+	result, err := r.db.Exec(`UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		nullableTimeValue(&now), id, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("session not found")
+	}
+
+	return nil
+}
+
+func (r *sessionRepository) RevokeSessions(userID int, exceptID string) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id <> ? AND revoked_at IS NULL`,
+		nullableTimeValue(&now), userID, exceptID)
+	if err != nil {
+		return fmt.Errorf("error revoking sessions: %v", err)
+	}
+	return nil
+}
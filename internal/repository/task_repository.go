@@ -3,29 +3,232 @@ package repository
 import (
 	"database/sql"
 	"fmt"
-	"time"
+	"strings"
 	"task-management-api/internal/models"
+	"time"
+)
+
+// ParentDeletePolicy controls what DeleteTask does with the subtasks of the deleted task
+type ParentDeletePolicy string
+
+const (
+	// ParentDeleteCascade deletes every descendant together with the task
+	ParentDeleteCascade ParentDeletePolicy = "cascade"
+	// ParentDeleteOrphan detaches the direct children, turning them into top-level tasks
+	ParentDeleteOrphan ParentDeletePolicy = "orphan"
+	// ParentDeleteBlock refuses to delete a task that still has subtasks
+	ParentDeleteBlock ParentDeletePolicy = "block"
 )
 
 type TaskRepository interface {
 	CreateTask(task *models.Task) error
 	GetTaskByID(id int) (*models.Task, error)
-	GetAllTasks() ([]*models.Task, error)
+	// LockTask returns a task like GetTaskByID, locking its row until the
+	// end of the transaction the repository runs in
+	LockTask(id int) (*models.Task, error)
+	GetAllTasks(filter models.TaskFilter) ([]*models.Task, error)
+	// EachTask calls fn with the tasks GetAllTasks would return, one at a
+	// time as they are read, stopping at the first error fn returns
+	EachTask(filter models.TaskFilter, fn func(task *models.Task) error) error
+	GetChildren(parentID int) ([]*models.Task, error)
+	GetDescendants(id int) ([]*models.Task, error)
+	SearchTasks(terms []string, cond models.SQLCondition, includeArchived bool, limit int) ([]*models.SearchResult, error)
 	UpdateTask(task *models.Task) error
+	// AssignTask changes the user a task belongs to; nil leaves it to nobody
+	AssignTask(id int, userID *int) error
+	// DeleteTask moves a task to the trash, handling its subtasks according
+	// to the configured ParentDeletePolicy. Tasks in the trash are left out
+	// of every other query.
 	DeleteTask(id int) error
+	ListDeletedTasks() ([]*models.Task, error)
+	GetDeletedTask(id int) (*models.Task, error)
+	GetDeletedDescendants(id int) ([]*models.Task, error)
+	// ListTasksDeletedBefore returns the tasks moved to the trash before the given time
+	ListTasksDeletedBefore(before time.Time) ([]*models.Task, error)
+	// RestoreTask takes a task out of the trash, together with the subtasks
+	// deleted along with it. It fails with "parent task is deleted" while
+	// the task's parent is in the trash.
+	RestoreTask(id int) error
+	// PurgeTask permanently deletes a task in the trash and its subtasks
+	PurgeTask(id int) error
+	// ArchiveTask moves a task and its subtasks to the archived_tasks table,
+	// which only GetArchivedTask and the queries asking for archived tasks
+	// look at. It fails with "task is not completed" unless they are all DONE.
+	ArchiveTask(id int) error
+	GetArchivedTask(id int) (*models.Task, error)
+	// UnarchiveTask moves a task back from the archive, together with the
+	// subtasks archived along with it. It fails with "parent task is
+	// archived" or "parent task is deleted" while the task's parent is.
+	UnarchiveTask(id int) error
+	// ListTasksToArchive returns the top-level tasks that are DONE and were
+	// last changed before the given time
+	ListTasksToArchive(before time.Time) ([]*models.Task, error)
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) TaskRepository
+	// ForTenant returns a copy of the repository that only sees and creates
+	// the tasks of the given organisation. Repositories restricted to no
+	// organisation, like the one NewTaskRepository returns, refuse to run
+	// any query.
+	ForTenant(orgID int) TaskRepository
+	// AllTenants returns a copy of the repository that sees the tasks of
+	// every organisation, for the jobs that work across them
+	AllTenants() TaskRepository
 }
 
 type taskRepository struct {
-	db *sql.DB
+	db             DBTX
+	onParentDelete ParentDeletePolicy
+	tenant         tenant
+}
+
+func NewTaskRepository(db *sql.DB, onParentDelete ParentDeletePolicy) TaskRepository {
+	if onParentDelete == "" {
+		onParentDelete = ParentDeleteBlock
+	}
+	return &taskRepository{db: db, onParentDelete: onParentDelete}
 }
 
-func NewTaskRepository(db *sql.DB) TaskRepository {
-	return &taskRepository{db: db}
+func (r *taskRepository) WithTx(tx *sql.Tx) TaskRepository {
+	return &taskRepository{db: tx, onParentDelete: r.onParentDelete, tenant: r.tenant}
+}
+
+func (r *taskRepository) ForTenant(orgID int) TaskRepository {
+	return &taskRepository{db: r.db, onParentDelete: r.onParentDelete, tenant: tenant{orgID: orgID}}
+}
+
+func (r *taskRepository) AllTenants() TaskRepository {
+	return &taskRepository{db: r.db, onParentDelete: r.onParentDelete, tenant: allTenants}
+}
+
+const taskColumns = `id, org_id, parent_id, user_id, title, description, status, priority, due_date, deleted_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask scans a row made of taskColumns, followed by any extra columns
+// which are scanned into extra
+func scanTask(row rowScanner, extra ...interface{}) (*models.Task, error) {
+	task := &models.Task{}
+	var parentID, userID sql.NullInt64
+	var dueDate, deletedAt, createdAt, updatedAt []uint8
+	dest := []interface{}{&task.ID, &task.OrgID, &parentID, &userID, &task.Title, &task.Description, &task.Status,
+		&task.Priority, &dueDate, &deletedAt, &createdAt, &updatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		task.ParentID = &id
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		task.UserID = &id
+	}
+
+	// Parse the timestamps
+	if dueDate != nil {
+		due, err := time.Parse("2006-01-02 15:04:05", string(dueDate))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing due_date: %v", err)
+		}
+		task.DueDate = &due
+	}
+	task.DeletedAt, err = parseNullableTime(deletedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deleted_at: %v", err)
+	}
+	task.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	task.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+
+	return task, nil
+}
+
+// nullableTimeValue converts an optional time to the UTC DATETIME value stored in the database
+func nullableTimeValue(due *time.Time) interface{} {
+	if due == nil {
+		return nil
+	}
+	return due.UTC().Format("2006-01-02 15:04:05")
+}
+
+// scanArchivableTask scans a row made of taskColumns and archived_at,
+// which is NULL for the tasks that are not archived
+func scanArchivableTask(row rowScanner, extra ...interface{}) (*models.Task, error) {
+	var archivedAt []uint8
+	task, err := scanTask(row, append([]interface{}{&archivedAt}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	task.ArchivedAt, err = parseNullableTime(archivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing archived_at: %v", err)
+	}
+	return task, nil
+}
+
+func queryTasks(db DBTX, query string, args ...interface{}) ([]*models.Task, error) {
+	return queryTaskRows(db, scanTask, query, args...)
+}
+
+func queryTaskRows(db DBTX, scan func(rowScanner, ...interface{}) (*models.Task, error), query string,
+	args ...interface{}) ([]*models.Task, error) {
+	var tasks []*models.Task
+	err := eachTaskRow(db, scan, func(task *models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// eachTaskRow runs a query and calls fn with each task as it is scanned
+func eachTaskRow(db DBTX, scan func(rowScanner, ...interface{}) (*models.Task, error), fn func(*models.Task) error,
+	query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("error querying database: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scan(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning row: %v", err)
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error after scanning all rows: %v", err)
+	}
+	return nil
 }
 
 func (r *taskRepository) CreateTask(task *models.Task) error {
-	query := `INSERT INTO tasks (title, description, status) VALUES (?, ?, ?)`
-	result, err := r.db.Exec(query, task.Title, task.Description, task.Status)
+	if task.Priority == "" {
+		task.Priority = models.TaskPriorityMedium
+	}
+	orgID, err := r.tenant.orgFor(task.OrgID)
+	if err != nil {
+		return err
+	}
+	task.OrgID = orgID
+	query := `INSERT INTO tasks (org_id, parent_id, user_id, title, description, status, priority, due_date) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, task.OrgID, task.ParentID, task.UserID, task.Title, task.Description, task.Status,
+		task.Priority, nullableTimeValue(task.DueDate))
 	if err != nil {
 		return err
 	}
@@ -38,72 +241,187 @@ func (r *taskRepository) CreateTask(task *models.Task) error {
 }
 
 func (r *taskRepository) GetTaskByID(id int) (*models.Task, error) {
-	query := `SELECT id, title, description, status, created_at, updated_at FROM tasks WHERE id = ?`
-	row := r.db.QueryRow(query, id)
+	return r.getTask(id, `deleted_at IS NULL`, "")
+}
 
-	task := &models.Task{}
-	var createdAt, updatedAt []uint8
-	err := row.Scan(&task.ID, &task.Title, &task.Description, &task.Status, &createdAt, &updatedAt)
+func (r *taskRepository) LockTask(id int) (*models.Task, error) {
+	return r.getTask(id, `deleted_at IS NULL`, ` FOR UPDATE`)
+}
+
+func (r *taskRepository) GetDeletedTask(id int) (*models.Task, error) {
+	return r.getTask(id, `deleted_at IS NOT NULL`, "")
+}
+
+func (r *taskRepository) getTask(id int, state, lock string) (*models.Task, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = ? AND ` + state + ` AND ` + tenant + lock
+	task, err := scanTask(r.db.QueryRow(query, append([]interface{}{id}, tenantArgs...)...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("task not found")
 		}
 		return nil, fmt.Errorf("error scanning row: %v", err)
 	}
-	
-	// Parse the timestamps
-	task.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+
+	return task, nil
+}
+
+func (r *taskRepository) GetAllTasks(filter models.TaskFilter) ([]*models.Task, error) {
+	var tasks []*models.Task
+	err := r.EachTask(filter, func(task *models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error parsing created_at: %v", err)
+		return nil, err
 	}
-	task.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	return tasks, nil
+}
+
+func (r *taskRepository) EachTask(filter models.TaskFilter, fn func(task *models.Task) error) error {
+	tenant, args, err := r.tenant.clause("org_id")
 	if err != nil {
-		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+		return err
+	}
+	clauses := []string{`deleted_at IS NULL`, tenant}
+	if clause, clauseArgs := labelFilterClause(filter); clause != "" {
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
+	}
+	if filter.Condition != nil {
+		clause, clauseArgs := filter.Condition.SQL()
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
 	}
 
-	return task, nil
+	where := strings.Join(clauses, ` AND `)
+	if !filter.IncludeArchived {
+		query := `SELECT ` + taskColumns + ` FROM tasks WHERE ` + where + ` ORDER BY created_at DESC`
+		return eachTaskRow(r.db, scanTask, fn, query, args...)
+	}
+
+	query := `SELECT ` + taskColumns + `, NULL AS archived_at FROM tasks WHERE ` + where + `
+			  UNION ALL
+			  SELECT ` + taskColumns + `, archived_at FROM archived_tasks WHERE ` + where + `
+			  ORDER BY created_at DESC`
+	return eachTaskRow(r.db, scanArchivableTask, fn, query, append(args, args...)...)
+}
+
+// GetChildren returns the direct subtasks of the given task
+func (r *taskRepository) GetChildren(parentID int) ([]*models.Task, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE parent_id = ? AND deleted_at IS NULL AND ` + tenant + `
+			  ORDER BY created_at, id`
+	return queryTasks(r.db, query, append([]interface{}{parentID}, tenantArgs...)...)
 }
 
-func (r *taskRepository) GetAllTasks() ([]*models.Task, error) {
-	query := `SELECT id, title, description, status, created_at, updated_at FROM tasks ORDER BY created_at DESC`
-	rows, err := r.db.Query(query)
+// GetDescendants returns every task below the given task in the hierarchy,
+// at any depth. The task itself is not included.
+func (r *taskRepository) GetDescendants(id int) ([]*models.Task, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
 	if err != nil {
-		return nil, fmt.Errorf("error querying database: %v", err)
+		return nil, err
+	}
+	query := `WITH RECURSIVE subtree (id) AS (
+				  SELECT id FROM tasks WHERE parent_id = ?
+				  UNION
+				  SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+			  )
+			  SELECT ` + taskColumns + ` FROM tasks WHERE id IN (SELECT id FROM subtree) AND deleted_at IS NULL AND ` + tenant + `
+			  ORDER BY created_at, id`
+	return queryTasks(r.db, query, append([]interface{}{id}, tenantArgs...)...)
+}
+
+// SearchTasks runs a FULLTEXT search over title, description and comments
+// for tasks containing every term, each matched as a word prefix in any of
+// them, that match the optional extra condition
+func (r *taskRepository) SearchTasks(terms []string, cond models.SQLCondition, includeArchived bool,
+	limit int) ([]*models.SearchResult, error) {
+	// Any term counts towards the score, the WHERE clause requires them all
+	var anyTerm strings.Builder
+	for _, term := range terms {
+		anyTerm.WriteString(term + "* ")
+	}
+
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+
+	// Both tables have the same columns and FULLTEXT index
+	search := func(table, archivedAt string) (string, []interface{}) {
+		args := []interface{}{anyTerm.String(), anyTerm.String()}
+		query := `SELECT ` + taskColumns + `, ` + archivedAt + ` AS archived_at,
+				  MATCH (title, description) AGAINST (? IN BOOLEAN MODE)
+				  + COALESCE((SELECT SUM(MATCH (body) AGAINST (? IN BOOLEAN MODE)) FROM task_comments
+				              WHERE task_comments.task_id = ` + table + `.id), 0) AS score
+				  FROM ` + table + `
+				  WHERE deleted_at IS NULL`
+		for _, term := range terms {
+			query += ` AND (MATCH (title, description) AGAINST (? IN BOOLEAN MODE)
+				  OR id IN (SELECT task_id FROM task_comments WHERE MATCH (body) AGAINST (? IN BOOLEAN MODE)))`
+			args = append(args, "+"+term+"*", "+"+term+"*")
+		}
+		query += ` AND ` + tenant
+		args = append(args, tenantArgs...)
+		if cond != nil {
+			clause, condArgs := cond.SQL()
+			query += ` AND ` + clause
+			args = append(args, condArgs...)
+		}
+		return query, args
+	}
+	query, args := search("tasks", "NULL")
+	if includeArchived {
+		archivedQuery, archivedArgs := search("archived_tasks", "archived_at")
+		query += ` UNION ALL ` + archivedQuery
+		args = append(args, archivedArgs...)
+	}
+	query += ` ORDER BY score DESC, id DESC LIMIT ?`
+	rows, err := r.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("error searching tasks: %v", err)
 	}
 	defer rows.Close()
 
-	var tasks []*models.Task
+	var results []*models.SearchResult
 	for rows.Next() {
-		task := &models.Task{}
-		var createdAt, updatedAt []uint8
-		err := rows.Scan(&task.ID, &task.Title, &task.Description, &task.Status, &createdAt, &updatedAt)
+		result := &models.SearchResult{}
+		var score float64
+		result.Task, err = scanArchivableTask(rows, &score)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning row: %v", err)
 		}
-		
-		// Parse the timestamps
-		task.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing created_at: %v", err)
-		}
-		task.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing updated_at: %v", err)
-		}
-
-		tasks = append(tasks, task)
+		result.Score = score
+		results = append(results, result)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error after scanning all rows: %v", err)
 	}
 
-	return tasks, nil
+	return results, nil
 }
 
 func (r *taskRepository) UpdateTask(task *models.Task) error {
-	query := `UPDATE tasks SET title = ?, description = ?, status = ? WHERE id = ?`
-	result, err := r.db.Exec(query, task.Title, task.Description, task.Status, task.ID)
+	if task.Priority == "" {
+		task.Priority = models.TaskPriorityMedium
+	}
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `UPDATE tasks SET parent_id = ?, title = ?, description = ?, status = ?, priority = ?, due_date = ?
+			  WHERE id = ? AND deleted_at IS NULL AND ` + tenant
+	args := []interface{}{task.ParentID, task.Title, task.Description, task.Status,
+		task.Priority, nullableTimeValue(task.DueDate), task.ID}
+	result, err := r.db.Exec(query, append(args, tenantArgs...)...)
 	if err != nil {
 		return fmt.Errorf("error updating task: %v", err)
 	}
@@ -114,15 +432,86 @@ func (r *taskRepository) UpdateTask(task *models.Task) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("task not found")
+		// MariaDB does not count rows left unchanged
+		if _, err := r.GetTaskByID(task.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *taskRepository) AssignTask(id int, userID *int) error {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `UPDATE tasks SET user_id = ? WHERE id = ? AND deleted_at IS NULL AND ` + tenant
+	result, err := r.db.Exec(query, append([]interface{}{userID, id}, tenantArgs...)...)
+	if err != nil {
+		return fmt.Errorf("error assigning task: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		// MariaDB does not count rows left unchanged
+		if _, err := r.GetTaskByID(id); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func (r *taskRepository) DeleteTask(id int) error {
-	query := `DELETE FROM tasks WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+	return inTx(r.db, func(tx DBTX) error {
+		return r.deleteTask(tx, id)
+	})
+}
+
+func (r *taskRepository) deleteTask(tx DBTX, id int) error {
+	// Subtasks are always in the same organisation as their parent, so
+	// checking the task itself keeps the whole deletion within the tenant
+	scoped := &taskRepository{db: tx, tenant: r.tenant}
+	if _, err := scoped.GetTaskByID(id); err != nil {
+		return err
+	}
+
+	ids := []interface{}{id}
+	switch r.onParentDelete {
+	case ParentDeleteCascade:
+		descendants, err := scoped.GetDescendants(id)
+		if err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			ids = append(ids, d.ID)
+		}
+	case ParentDeleteOrphan:
+		// Subtasks already in the trash stay with the task
+		if _, err := tx.Exec(`UPDATE tasks SET parent_id = NULL WHERE parent_id = ? AND deleted_at IS NULL`, id); err != nil {
+			return fmt.Errorf("error detaching subtasks: %v", err)
+		}
+	default:
+		var children int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE parent_id = ? AND deleted_at IS NULL`, id).Scan(&children); err != nil {
+			return fmt.Errorf("error counting subtasks: %v", err)
+		}
+		if children > 0 {
+			return fmt.Errorf("task has subtasks")
+		}
+	}
+
+	// Everything deleted together gets the same time, which is how
+	// RestoreTask finds it again
+	now := time.Now()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	result, err := tx.Exec(`UPDATE tasks SET deleted_at = ? WHERE id IN (`+placeholders+`) AND deleted_at IS NULL`,
+		append([]interface{}{nullableTimeValue(&now)}, ids...)...)
 	if err != nil {
 		return fmt.Errorf("error deleting task: %v", err)
 	}
@@ -137,4 +526,242 @@ func (r *taskRepository) DeleteTask(id int) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (r *taskRepository) ListDeletedTasks() ([]*models.Task, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE deleted_at IS NOT NULL AND ` + tenant + `
+			  ORDER BY deleted_at DESC, id DESC`
+	return queryTasks(r.db, query, args...)
+}
+
+func (r *taskRepository) ListTasksDeletedBefore(before time.Time) ([]*models.Task, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE deleted_at < ? AND ` + tenant + ` ORDER BY deleted_at, id`
+	return queryTasks(r.db, query, append([]interface{}{nullableTimeValue(&before)}, args...)...)
+}
+
+// GetDeletedDescendants returns the subtasks in the trash below a task in
+// the trash, at any depth. The task itself is not included.
+func (r *taskRepository) GetDeletedDescendants(id int) ([]*models.Task, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `WITH RECURSIVE subtree (id) AS (
+				  SELECT id FROM tasks WHERE parent_id = ? AND deleted_at IS NOT NULL
+				  UNION
+				  SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id WHERE t.deleted_at IS NOT NULL
+			  )
+			  SELECT ` + taskColumns + ` FROM tasks WHERE id IN (SELECT id FROM subtree) AND ` + tenant + `
+			  ORDER BY created_at, id`
+	return queryTasks(r.db, query, append([]interface{}{id}, tenantArgs...)...)
+}
+
+// trashedSubtree returns a task in the trash, with its ID and the IDs of the
+// subtasks in the trash below it
+func (r *taskRepository) trashedSubtree(tx DBTX, id int) (*models.Task, []interface{}, error) {
+	scoped := &taskRepository{db: tx, tenant: r.tenant}
+	task, err := scoped.GetDeletedTask(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	descendants, err := scoped.GetDeletedDescendants(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := []interface{}{id}
+	for _, d := range descendants {
+		ids = append(ids, d.ID)
+	}
+	return task, ids, nil
+}
+
+func (r *taskRepository) RestoreTask(id int) error {
+	return inTx(r.db, func(tx DBTX) error {
+		task, ids, err := r.trashedSubtree(tx, id)
+		if err != nil {
+			return err
+		}
+		if task.ParentID != nil {
+			if _, err := (&taskRepository{db: tx, tenant: r.tenant}).GetDeletedTask(*task.ParentID); err == nil {
+				return fmt.Errorf("parent task is deleted")
+			}
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		query := `UPDATE tasks SET deleted_at = NULL WHERE id IN (` + placeholders + `) AND deleted_at = ?`
+		if _, err := tx.Exec(query, append(ids, nullableTimeValue(task.DeletedAt))...); err != nil {
+			return fmt.Errorf("error restoring task: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *taskRepository) PurgeTask(id int) error {
+	return inTx(r.db, func(tx DBTX) error {
+		_, ids, err := r.trashedSubtree(tx, id)
+		if err != nil {
+			return err
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+		// The rows belonging to a task are kept while it moves to and from
+		// the archive, so they have no foreign key to cascade the delete
+		related := []string{
+			`DELETE FROM task_labels WHERE task_id IN (` + placeholders + `)`,
+			`DELETE FROM attachments WHERE task_id IN (` + placeholders + `)`,
+			`DELETE FROM labels WHERE project_id IN (` + placeholders + `)`,
+			`DELETE FROM task_dependencies WHERE blocker_id IN (` + placeholders + `)`,
+			`DELETE FROM task_dependencies WHERE blocked_id IN (` + placeholders + `)`,
+			`DELETE FROM task_revisions WHERE task_id IN (` + placeholders + `)`,
+			`DELETE FROM task_comments WHERE task_id IN (` + placeholders + `)`,
+			`DELETE FROM tasks WHERE id IN (` + placeholders + `)`,
+		}
+		for _, query := range related {
+			if _, err := tx.Exec(query, ids...); err != nil {
+				return fmt.Errorf("error purging task: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// subtreeIDs returns the IDs of a task and of every task below it, at any
+// depth, including the ones in the trash. Parents come before their subtasks.
+func subtreeIDs(tx DBTX, table string, id int, extra string, args ...interface{}) ([]interface{}, error) {
+	query := `WITH RECURSIVE subtree (id, depth) AS (
+				  SELECT id, 0 FROM ` + table + ` WHERE id = ?
+				  UNION
+				  SELECT t.id, s.depth + 1 FROM ` + table + ` t JOIN subtree s ON t.parent_id = s.id WHERE ` + extra + `
+			  )
+			  SELECT id FROM subtree ORDER BY depth, id`
+	rows, err := tx.Query(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing subtasks: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []interface{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *taskRepository) ArchiveTask(id int) error {
+	return inTx(r.db, func(tx DBTX) error {
+		scoped := &taskRepository{db: tx, tenant: r.tenant}
+		task, err := scoped.GetTaskByID(id)
+		if err != nil {
+			return err
+		}
+		descendants, err := scoped.GetDescendants(id)
+		if err != nil {
+			return err
+		}
+		for _, t := range append([]*models.Task{task}, descendants...) {
+			if t.Status != models.TaskStatusDone {
+				return fmt.Errorf("task is not completed")
+			}
+		}
+
+		// Subtasks in the trash go too, so that they are still below the
+		// task if it comes back
+		ids, err := subtreeIDs(tx, "tasks", id, `TRUE`)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		query := `INSERT INTO archived_tasks (` + taskColumns + `, archived_at)
+				  SELECT ` + taskColumns + `, ? FROM tasks WHERE id IN (` + placeholders + `)`
+		if _, err := tx.Exec(query, append([]interface{}{nullableTimeValue(&now)}, ids...)...); err != nil {
+			return fmt.Errorf("error archiving task: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM tasks WHERE id IN (`+placeholders+`)`, ids...); err != nil {
+			return fmt.Errorf("error archiving task: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *taskRepository) GetArchivedTask(id int) (*models.Task, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + `, archived_at FROM archived_tasks WHERE id = ? AND ` + tenant
+	task, err := scanArchivableTask(r.db.QueryRow(query, append([]interface{}{id}, tenantArgs...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("task not found")
+		}
+		return nil, fmt.Errorf("error scanning row: %v", err)
+	}
+
+	return task, nil
+}
+
+func (r *taskRepository) UnarchiveTask(id int) error {
+	return inTx(r.db, func(tx DBTX) error {
+		task, err := (&taskRepository{db: tx, tenant: r.tenant}).GetArchivedTask(id)
+		if err != nil {
+			return err
+		}
+		if task.ParentID != nil {
+			// Parents are always in the same organisation as their subtasks
+			scoped := &taskRepository{db: tx, tenant: r.tenant}
+			if _, err := scoped.GetArchivedTask(*task.ParentID); err == nil {
+				return fmt.Errorf("parent task is archived")
+			}
+			if _, err := scoped.GetDeletedTask(*task.ParentID); err == nil {
+				return fmt.Errorf("parent task is deleted")
+			}
+			if _, err := scoped.GetTaskByID(*task.ParentID); err != nil {
+				// The parent has been purged in the meantime
+				if _, err := tx.Exec(`UPDATE archived_tasks SET parent_id = NULL WHERE id = ?`, id); err != nil {
+					return fmt.Errorf("error detaching task: %v", err)
+				}
+			}
+		}
+
+		ids, err := subtreeIDs(tx, "archived_tasks", id, `t.archived_at = ?`, nullableTimeValue(task.ArchivedAt))
+		if err != nil {
+			return err
+		}
+		// One at a time, so that every parent is back before its subtasks
+		for _, taskID := range ids {
+			query := `INSERT INTO tasks (` + taskColumns + `) SELECT ` + taskColumns + ` FROM archived_tasks WHERE id = ?`
+			if _, err := tx.Exec(query, taskID); err != nil {
+				return fmt.Errorf("error unarchiving task: %v", err)
+			}
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		if _, err := tx.Exec(`DELETE FROM archived_tasks WHERE id IN (`+placeholders+`)`, ids...); err != nil {
+			return fmt.Errorf("error unarchiving task: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *taskRepository) ListTasksToArchive(before time.Time) ([]*models.Task, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks
+			  WHERE parent_id IS NULL AND status = ? AND deleted_at IS NULL AND updated_at < ? AND ` + tenant + `
+			  ORDER BY id`
+	return queryTasks(r.db, query, append([]interface{}{models.TaskStatusDone, nullableTimeValue(&before)}, args...)...)
+}
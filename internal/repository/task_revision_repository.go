@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type TaskRevisionRepository interface {
+	// CreateRevision records the next revision of a task, numbered after the
+	// last one. CreatedAt defaults to the current time.
+	CreateRevision(revision *models.TaskRevision) error
+	// HasRevisions reports whether any revision of the task was recorded
+	HasRevisions(taskID int) (bool, error)
+	// ListRevisions returns the revisions of a task, oldest first
+	ListRevisions(taskID int) ([]*models.TaskRevision, error)
+	GetRevision(taskID, revision int) (*models.TaskRevision, error)
+	// GetRevisionAsOf returns the last revision of a task recorded at or
+	// before the given time
+	GetRevisionAsOf(taskID int, at time.Time) (*models.TaskRevision, error)
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) TaskRevisionRepository
+	// ForTenant returns a copy of the repository that only sees the
+	// revisions of the given organisation's tasks
+	ForTenant(orgID int) TaskRevisionRepository
+	// AllTenants returns a copy of the repository that sees the revisions of
+	// every organisation
+	AllTenants() TaskRevisionRepository
+}
+
+type taskRevisionRepository struct {
+	db     DBTX
+	tenant tenant
+}
+
+func NewTaskRevisionRepository(db *sql.DB) TaskRevisionRepository {
+	return &taskRevisionRepository{db: db}
+}
+
+func (r *taskRevisionRepository) WithTx(tx *sql.Tx) TaskRevisionRepository {
+	return &taskRevisionRepository{db: tx, tenant: r.tenant}
+}
+
+func (r *taskRevisionRepository) ForTenant(orgID int) TaskRevisionRepository {
+	return &taskRevisionRepository{db: r.db, tenant: tenant{orgID: orgID}}
+}
+
+func (r *taskRevisionRepository) AllTenants() TaskRevisionRepository {
+	return &taskRevisionRepository{db: r.db, tenant: allTenants}
+}
+
+const taskRevisionColumns = `id, task_id, org_id, revision, actor_id, snapshot, created_at`
+
+func scanTaskRevision(row rowScanner) (*models.TaskRevision, error) {
+	var revision models.TaskRevision
+	var actorID sql.NullInt64
+	var snapshot, createdAt []uint8
+	err := row.Scan(&revision.ID, &revision.TaskID, &revision.OrgID, &revision.Revision, &actorID, &snapshot, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		revision.ActorID = &id
+	}
+	revision.Task = &models.Task{}
+	if err := json.Unmarshal(snapshot, revision.Task); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %v", err)
+	}
+	revision.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	return &revision, nil
+}
+
+func (r *taskRevisionRepository) CreateRevision(revision *models.TaskRevision) error {
+	snapshot, err := json.Marshal(revision.Task)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %v", err)
+	}
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+	orgID, err := r.tenant.orgFor(revision.OrgID)
+	if err != nil {
+		return err
+	}
+	revision.OrgID = orgID
+
+	query := `INSERT INTO task_revisions (task_id, org_id, revision, actor_id, snapshot, created_at)
+			  SELECT ?, ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ? FROM task_revisions WHERE task_id = ?`
+	result, err := r.db.Exec(query, revision.TaskID, revision.OrgID, revision.ActorID, string(snapshot),
+		nullableTimeValue(&revision.CreatedAt), revision.TaskID)
+	if err != nil {
+		return fmt.Errorf("error creating revision: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting revision ID: %v", err)
+	}
+	revision.ID = int(id)
+
+	if err := r.db.QueryRow(`SELECT revision FROM task_revisions WHERE id = ?`, id).Scan(&revision.Revision); err != nil {
+		return fmt.Errorf("error reading revision number: %v", err)
+	}
+	return nil
+}
+
+func (r *taskRevisionRepository) HasRevisions(taskID int) (bool, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM task_revisions WHERE task_id = ? AND ` + tenant + `)`
+	if err := r.db.QueryRow(query, append([]interface{}{taskID}, args...)...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking revisions: %v", err)
+	}
+	return exists, nil
+}
+
+func (r *taskRevisionRepository) ListRevisions(taskID int) ([]*models.TaskRevision, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskRevisionColumns + ` FROM task_revisions WHERE task_id = ? AND ` + tenant + ` ORDER BY revision`
+	rows, err := r.db.Query(query, append([]interface{}{taskID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying revisions: %v", err)
+	}
+	defer rows.Close()
+
+	var revisions []*models.TaskRevision
+	for rows.Next() {
+		revision, err := scanTaskRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+	return revisions, nil
+}
+
+func (r *taskRevisionRepository) GetRevision(taskID, revision int) (*models.TaskRevision, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskRevisionColumns + ` FROM task_revisions WHERE task_id = ? AND revision = ? AND ` + tenant
+	return r.getRevision(query, append([]interface{}{taskID, revision}, args...)...)
+}
+
+func (r *taskRevisionRepository) GetRevisionAsOf(taskID int, at time.Time) (*models.TaskRevision, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + taskRevisionColumns + ` FROM task_revisions
+			  WHERE task_id = ? AND created_at <= ? AND ` + tenant + `
+			  ORDER BY revision DESC LIMIT 1`
+	return r.getRevision(query, append([]interface{}{taskID, nullableTimeValue(&at)}, args...)...)
+}
+
+func (r *taskRevisionRepository) getRevision(query string, args ...interface{}) (*models.TaskRevision, error) {
+	revision, err := scanTaskRevision(r.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("revision not found")
+		}
+		return nil, fmt.Errorf("error scanning row: %v", err)
+	}
+	return revision, nil
+}
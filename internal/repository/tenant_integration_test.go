@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// openTestDB connects to the database named by TEST_DATABASE_DSN, which has
+// to be set up with init.sql, and skips the test without one
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestOrganizations creates two organisations for a test, deleting
+// them and everything in them when it is over
+func createTestOrganizations(t *testing.T, db *sql.DB) (a, b *models.Organization) {
+	t.Helper()
+	suffix := time.Now().UnixNano()
+	orgs := NewOrganizationRepository(db)
+	var created []*models.Organization
+	for _, name := range []string{"a", "b"} {
+		org, err := orgs.CreateOrganization(&models.NewOrganization{
+			Name: "Tenant " + name,
+			Slug: fmt.Sprintf("tenant-%s-%d", name, suffix),
+		})
+		if err != nil {
+			t.Fatalf("CreateOrganization: %v", err)
+		}
+		created = append(created, org)
+	}
+	t.Cleanup(func() {
+		for _, org := range created {
+			for _, table := range []string{"tasks", "archived_tasks", "labels", "users", "organizations"} {
+				column := "org_id"
+				if table == "organizations" {
+					column = "id"
+				}
+				db.Exec(`DELETE FROM `+table+` WHERE `+column+` = ?`, org.ID)
+			}
+		}
+	})
+	return created[0], created[1]
+}
+
+func TestTaskRepositoryTenantIsolation(t *testing.T) {
+	db := openTestDB(t)
+	orgA, orgB := createTestOrganizations(t, db)
+	base := NewTaskRepository(db, ParentDeleteCascade)
+	tasksA, tasksB := base.ForTenant(orgA.ID), base.ForTenant(orgB.ID)
+
+	word := fmt.Sprintf("isolation%d", time.Now().UnixNano())
+	mine := &models.Task{Title: word + " mine", Status: models.TaskStatusTodo}
+	theirs := &models.Task{Title: word + " theirs", Status: models.TaskStatusTodo}
+	if err := tasksA.CreateTask(mine); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	// Asking for another organisation does not get around the repository's
+	if err := tasksB.CreateTask(&models.Task{Title: word + " smuggled", OrgID: orgA.ID}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if err := tasksB.CreateTask(theirs); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if mine.OrgID != orgA.ID || theirs.OrgID != orgB.ID {
+		t.Fatalf("tasks created in organisations %d and %d", mine.OrgID, theirs.OrgID)
+	}
+
+	if _, err := tasksA.GetTaskByID(theirs.ID); err == nil || err.Error() != "task not found" {
+		t.Errorf("GetTaskByID of another organisation's task: %v", err)
+	}
+	all, err := tasksA.GetAllTasks(models.TaskFilter{IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("GetAllTasks: %v", err)
+	}
+	if len(all) != 1 || all[0].ID != mine.ID {
+		t.Errorf("GetAllTasks = %v, want only task %d", all, mine.ID)
+	}
+	results, err := tasksA.SearchTasks([]string{word}, nil, true, 10)
+	if err != nil {
+		t.Fatalf("SearchTasks: %v", err)
+	}
+	if len(results) != 1 || results[0].Task.ID != mine.ID {
+		t.Errorf("SearchTasks found %d tasks, want only task %d", len(results), mine.ID)
+	}
+
+	changed := *theirs
+	changed.Title = "changed"
+	if err := tasksA.UpdateTask(&changed); err == nil || err.Error() != "task not found" {
+		t.Errorf("UpdateTask of another organisation's task: %v", err)
+	}
+	if err := tasksA.AssignTask(theirs.ID, nil); err == nil {
+		t.Error("AssignTask of another organisation's task succeeded")
+	}
+	if err := tasksA.DeleteTask(theirs.ID); err == nil || err.Error() != "task not found" {
+		t.Errorf("DeleteTask of another organisation's task: %v", err)
+	}
+	if got, err := tasksB.GetTaskByID(theirs.ID); err != nil || got.Title != theirs.Title {
+		t.Fatalf("the other organisation's task is now %v, %v", got, err)
+	}
+
+	// In the trash
+	if err := tasksB.DeleteTask(theirs.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	deleted, err := tasksA.ListDeletedTasks()
+	if err != nil {
+		t.Fatalf("ListDeletedTasks: %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("ListDeletedTasks = %v, want none", deleted)
+	}
+	if err := tasksA.RestoreTask(theirs.ID); err == nil || err.Error() != "task not found" {
+		t.Errorf("RestoreTask of another organisation's task: %v", err)
+	}
+	if err := tasksA.PurgeTask(theirs.ID); err == nil || err.Error() != "task not found" {
+		t.Errorf("PurgeTask of another organisation's task: %v", err)
+	}
+	if _, err := tasksB.GetDeletedTask(theirs.ID); err != nil {
+		t.Errorf("the other organisation's task left the trash: %v", err)
+	}
+}
+
+func TestUserRepositoryTenantIsolation(t *testing.T) {
+	db := openTestDB(t)
+	orgA, orgB := createTestOrganizations(t, db)
+	base := NewUserRepository(db)
+	usersA, usersB := base.ForTenant(orgA.ID), base.ForTenant(orgB.ID)
+
+	suffix := time.Now().UnixNano()
+	newUser := func(repo UserRepository, name string) *models.User {
+		t.Helper()
+		user, err := repo.CreateUser(&models.NewUser{
+			Username: name,
+			Email:    fmt.Sprintf("%s-%d@example.com", name, suffix),
+			Password: "hash",
+			Role:     models.UserRoleUser,
+		})
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		return user
+	}
+	mine := newUser(usersA, "ann")
+	// Usernames are only unique within an organisation
+	theirs := newUser(usersB, "ann")
+
+	if _, err := usersA.GetUserByID(theirs.ID); err == nil || err.Error() != "user not found" {
+		t.Errorf("GetUserByID of another organisation's user: %v", err)
+	}
+	if got, err := usersA.GetUserByUsername("ann"); err != nil || got.ID != mine.ID {
+		t.Errorf("GetUserByUsername = %v, %v; want user %d", got, err, mine.ID)
+	}
+	if _, err := usersA.GetUserByEmail(theirs.Email); err == nil {
+		t.Error("GetUserByEmail found another organisation's user")
+	}
+	list, err := usersA.ListUsers(0, 100)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != mine.ID {
+		t.Errorf("ListUsers = %v, want only user %d", list, mine.ID)
+	}
+
+	name := "changed"
+	if err := usersA.UpdateUser(theirs.ID, &models.UpdateUser{FullName: &name}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := usersA.DeleteUser(theirs.ID); err == nil || err.Error() != "user not found" {
+		t.Errorf("DeleteUser of another organisation's user: %v", err)
+	}
+	if got, err := usersB.GetUserByID(theirs.ID); err != nil || got.FullName == name {
+		t.Fatalf("the other organisation's user is now %v, %v", got, err)
+	}
+
+	// In the trash
+	if err := usersB.DeleteUser(theirs.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	// The deleted user keeps their username and email until purged
+	_, err = usersB.CreateUser(&models.NewUser{Username: "ann", Email: fmt.Sprintf("other-%d@example.com", suffix),
+		Password: "hash", Role: models.UserRoleUser})
+	if err == nil || err.Error() != "username belongs to a deleted user" {
+		t.Errorf("CreateUser with the username of a deleted user: %v", err)
+	}
+	deleted, err := usersA.ListDeletedUsers()
+	if err != nil {
+		t.Fatalf("ListDeletedUsers: %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("ListDeletedUsers = %v, want none", deleted)
+	}
+	if err := usersA.RestoreUser(theirs.ID); err == nil || err.Error() != "user not found" {
+		t.Errorf("RestoreUser of another organisation's user: %v", err)
+	}
+	if err := usersA.PurgeUser(theirs.ID); err == nil || err.Error() != "user not found" {
+		t.Errorf("PurgeUser of another organisation's user: %v", err)
+	}
+	if _, err := usersB.GetDeletedUser(theirs.ID); err != nil {
+		t.Errorf("the other organisation's user left the trash: %v", err)
+	}
+}
+
+func TestLabelRepositoryTenantIsolation(t *testing.T) {
+	db := openTestDB(t)
+	orgA, orgB := createTestOrganizations(t, db)
+	tasks := NewTaskRepository(db, ParentDeleteCascade)
+	labels := NewLabelRepository(db)
+	labelsA, labelsB := labels.ForTenant(orgA.ID), labels.ForTenant(orgB.ID)
+
+	// Both organisations have a label of the same name on one of their tasks
+	var tasksOf, labelsOf [2]int
+	for i, org := range []*models.Organization{orgA, orgB} {
+		task := &models.Task{Title: "labelled", Status: models.TaskStatusTodo}
+		if err := tasks.ForTenant(org.ID).CreateTask(task); err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		label := &models.Label{Name: "bug", Color: "#ff0000"}
+		if err := labels.ForTenant(org.ID).CreateLabel(label); err != nil {
+			t.Fatalf("CreateLabel: %v", err)
+		}
+		if err := labels.ForTenant(org.ID).AttachLabel(task.ID, label.ID); err != nil {
+			t.Fatalf("AttachLabel: %v", err)
+		}
+		tasksOf[i], labelsOf[i] = task.ID, label.ID
+	}
+
+	// Within an organisation the name is unique whatever its case
+	if err := labelsA.CreateLabel(&models.Label{Name: "BUG", Color: "#ff0000"}); err == nil ||
+		err.Error() != "label already exists" {
+		t.Errorf("CreateLabel of a duplicate name: %v", err)
+	}
+
+	list, err := labelsA.ListLabels()
+	if err != nil {
+		t.Fatalf("ListLabels: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != labelsOf[0] || list[0].UsageCount != 1 {
+		t.Errorf("ListLabels = %v, want only label %d used once", list, labelsOf[0])
+	}
+	if _, err := labelsA.GetLabelByID(labelsOf[1]); err == nil || err.Error() != "label not found" {
+		t.Errorf("GetLabelByID of another organisation's label: %v", err)
+	}
+	if got, err := labelsA.GetLabelByName("bug", nil); err != nil || got.ID != labelsOf[0] {
+		t.Errorf("GetLabelByName = %v, %v; want label %d", got, err, labelsOf[0])
+	}
+	if err := labelsA.AttachLabel(tasksOf[0], labelsOf[1]); err == nil || err.Error() != "label not found" {
+		t.Errorf("AttachLabel of another organisation's label: %v", err)
+	}
+	if err := labelsA.DetachLabel(tasksOf[1], labelsOf[1]); err == nil {
+		t.Error("DetachLabel of another organisation's label succeeded")
+	}
+	if err := labelsA.MergeLabels(labelsOf[1], labelsOf[0]); err == nil || err.Error() != "label not found" {
+		t.Errorf("MergeLabels of another organisation's label: %v", err)
+	}
+	if err := labelsA.DeleteLabel(labelsOf[1]); err == nil || err.Error() != "label not found" {
+		t.Errorf("DeleteLabel of another organisation's label: %v", err)
+	}
+	if got, err := labelsB.GetTaskLabels(tasksOf[1]); err != nil || len(got) != 1 {
+		t.Errorf("the other organisation's task now has labels %v, %v", got, err)
+	}
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+// recordingDriver is a database that fails every statement, recording it,
+// so tests can check that a repository never reached the database
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.statements = append(c.d.statements, query)
+	return nil, errors.New("recordingDriver: no statements allowed")
+}
+
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{}, nil }
+
+type recordingTx struct{}
+
+func (recordingTx) Commit() error   { return nil }
+func (recordingTx) Rollback() error { return nil }
+
+func openRecordingDB(t *testing.T) (*sql.DB, *recordingDriver) {
+	t.Helper()
+	d := &recordingDriver{}
+	name := fmt.Sprintf("recording-%s-%d", t.Name(), time.Now().UnixNano())
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func TestTenantClause(t *testing.T) {
+	if _, _, err := (tenant{}).clause("org_id"); err != errNoTenant {
+		t.Errorf("zero tenant: err = %v, want errNoTenant", err)
+	}
+	clause, args, err := tenant{orgID: 7}.clause("t.org_id")
+	if err != nil || clause != "t.org_id = ?" || len(args) != 1 || args[0] != 7 {
+		t.Errorf("tenant 7 = %q %v %v", clause, args, err)
+	}
+	clause, args, err = allTenants.clause("org_id")
+	if err != nil || clause != "TRUE" || args != nil {
+		t.Errorf("all tenants = %q %v %v", clause, args, err)
+	}
+}
+
+func TestTenantOrgFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenant  tenant
+		asked   int
+		want    int
+		wantErr bool
+	}{
+		{"zero", tenant{}, 3, 0, true},
+		{"own organisation wins", tenant{orgID: 2}, 3, 2, false},
+		{"all tenants, asked", allTenants, 3, 3, false},
+		{"all tenants, default", allTenants, 0, models.DefaultOrganizationID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tenant.orgFor(tt.asked)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("orgFor(%d) = %d, %v; want %d, error %v", tt.asked, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestUnscopedRepositoriesRefuse checks that repositories nobody restricted
+// to an organisation fail without running a single statement
+func TestUnscopedRepositoriesRefuse(t *testing.T) {
+	db, d := openRecordingDB(t)
+	base := NewTaskRepository(db, ParentDeleteCascade)
+	users := NewUserRepository(db)
+	labels := NewLabelRepository(db)
+
+	for name, tasks := range map[string]TaskRepository{"new": base, "ForTenant(0)": base.AllTenants().ForTenant(0)} {
+		calls := map[string]func() error{
+			"CreateTask":  func() error { return tasks.CreateTask(&models.Task{Title: "t", OrgID: 2}) },
+			"GetTaskByID": func() error { _, err := tasks.GetTaskByID(1); return err },
+			"GetAllTasks": func() error { _, err := tasks.GetAllTasks(models.TaskFilter{}); return err },
+			"SearchTasks": func() error {
+				_, err := tasks.SearchTasks([]string{"x"}, nil, true, 10)
+				return err
+			},
+			"UpdateTask":       func() error { return tasks.UpdateTask(&models.Task{ID: 1, Title: "t"}) },
+			"DeleteTask":       func() error { return tasks.DeleteTask(1) },
+			"ListDeletedTasks": func() error { _, err := tasks.ListDeletedTasks(); return err },
+			"RestoreTask":      func() error { return tasks.RestoreTask(1) },
+			"PurgeTask":        func() error { return tasks.PurgeTask(1) },
+			"ArchiveTask":      func() error { return tasks.ArchiveTask(1) },
+		}
+		for call, fn := range calls {
+			if err := fn(); err != errNoTenant {
+				t.Errorf("%s %s: err = %v, want errNoTenant", name, call, err)
+			}
+		}
+	}
+
+	userCalls := map[string]func() error{
+		"CreateUser": func() error {
+			_, err := users.CreateUser(&models.NewUser{Username: "u", Email: "u@example.com"})
+			return err
+		},
+		"GetUserByID":       func() error { _, err := users.GetUserByID(1); return err },
+		"GetUserByUsername": func() error { _, err := users.ForTenant(0).GetUserByUsername("u"); return err },
+		"ListUsers":         func() error { _, err := users.ListUsers(0, 10); return err },
+		"UpdateUser":        func() error { name := "n"; return users.UpdateUser(1, &models.UpdateUser{FullName: &name}) },
+		"DeleteUser":        func() error { return users.DeleteUser(1) },
+		"RestoreUser":       func() error { return users.RestoreUser(1) },
+		"PurgeUser":         func() error { return users.PurgeUser(1) },
+	}
+	for call, fn := range userCalls {
+		if err := fn(); err != errNoTenant {
+			t.Errorf("%s: err = %v, want errNoTenant", call, err)
+		}
+	}
+
+	labelCalls := map[string]func() error{
+		"CreateLabel":   func() error { return labels.CreateLabel(&models.Label{Name: "l", OrgID: 2}) },
+		"ListLabels":    func() error { _, err := labels.ListLabels(); return err },
+		"GetLabelByID":  func() error { _, err := labels.GetLabelByID(1); return err },
+		"DeleteLabel":   func() error { return labels.DeleteLabel(1) },
+		"MergeLabels":   func() error { return labels.MergeLabels(1, 2) },
+		"GetTaskLabels": func() error { _, err := labels.GetTaskLabels(1); return err },
+		"AttachLabel":   func() error { return labels.AttachLabel(1, 1) },
+		"DetachLabel":   func() error { return labels.DetachLabel(1, 1) },
+	}
+	for call, fn := range labelCalls {
+		if err := fn(); err != errNoTenant {
+			t.Errorf("%s: err = %v, want errNoTenant", call, err)
+		}
+	}
+
+	if len(d.statements) != 0 {
+		t.Errorf("ran %d statements, the first %q", len(d.statements), d.statements[0])
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx used by the repositories, so that
+// a repository can run on its own or inside a caller's transaction
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// TxRunner runs a function inside a database transaction, committing it if
+// the function succeeds and rolling it back otherwise. Repositories join the
+// transaction through their WithTx method.
+type TxRunner interface {
+	RunInTx(fn func(tx *sql.Tx) error) error
+}
+
+type txRunner struct {
+	db *sql.DB
+}
+
+func NewTxRunner(db *sql.DB) TxRunner {
+	return &txRunner{db: db}
+}
+
+func (r *txRunner) RunInTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx runs fn in db if it is already a transaction, or else in a new
+// transaction on db
+func inTx(db DBTX, fn func(tx DBTX) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	return NewTxRunner(db.(*sql.DB)).RunInTx(func(tx *sql.Tx) error {
+		return fn(tx)
+	})
+}
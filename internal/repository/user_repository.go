@@ -4,8 +4,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"time"
+	"strings"
 	"task-management-api/internal/models"
+	"time"
 )
 
 type UserRepository interface {
@@ -14,52 +15,123 @@ type UserRepository interface {
 	GetUserByUsername(username string) (*models.User, error)
 	GetUserByEmail(email string) (*models.User, error)
 	UpdateUser(id int, updates *models.UpdateUser) error
+	UpdatePassword(id int, passwordHash string) error
+	// AddPasswordHistory remembers a password hash of the user, keeping only
+	// the most recent keep hashes
+	AddPasswordHistory(id int, passwordHash string, keep int) error
+	// ListPasswordHistory returns the user's most recent password hashes
+	ListPasswordHistory(id int, limit int) ([]string, error)
+	MarkEmailVerified(id int) error
+	SetPendingEmail(id int, email string) error
+	// ConfirmEmailChange replaces the user's email with the pending one,
+	// which is thereby verified
+	ConfirmEmailChange(id int) error
+	// ScheduleDeletion sets when the user's account will be deleted; nil cancels it
+	ScheduleDeletion(id int, at *time.Time) error
+	ListUsersDueForDeletion(now time.Time) ([]*models.User, error)
+	// SetMFASecret starts enrolment of an authenticator app, replacing any
+	// earlier secret that was never confirmed
+	SetMFASecret(id int, secret string) error
+	EnableMFA(id int) error
+	DisableMFA(id int) error
+	// UseMFAStep records the time step of an accepted TOTP code. It fails
+	// with "code already used" unless the step is later than the last one
+	// used, so that a code cannot be replayed.
+	UseMFAStep(id int, step int64) error
+	// DeleteUser moves a user to the trash. Users in the trash are left out
+	// of every other query, so they can no longer log in.
 	DeleteUser(id int) error
+	ListDeletedUsers() ([]*models.User, error)
+	GetDeletedUser(id int) (*models.User, error)
+	// ListUsersDeletedBefore returns the users moved to the trash before the given time
+	ListUsersDeletedBefore(before time.Time) ([]*models.User, error)
+	RestoreUser(id int) error
+	// PurgeUser permanently deletes a user, whether or not they are in the
+	// trash; their tasks are kept without an owner
+	PurgeUser(id int) error
 	ListUsers(offset, limit int) ([]*models.User, error)
+	SetOrgRole(id int, role models.OrgRole) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) UserRepository
+	// ForTenant returns a copy of the repository that only sees and creates
+	// the users of the given organisation. Repositories restricted to no
+	// organisation, like the one NewUserRepository returns, refuse to run
+	// any query.
+	ForTenant(orgID int) UserRepository
+	// AllTenants returns a copy of the repository that sees the users of
+	// every organisation, for sign-in and the jobs that work across them.
+	// Usernames are only unique within an organisation, so it looks them
+	// up in the default organisation.
+	AllTenants() UserRepository
 }
 
 type userRepository struct {
-	db *sql.DB
+	db     DBTX
+	tenant tenant
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(newUser *models.NewUser) (*models.User, error) {
-	query := `INSERT INTO users (username, email, password_hash, full_name, role, created_at, updated_at) 
-			  VALUES (?, ?, ?, ?, ?, NOW(), NOW())`
-	
-	result, err := r.db.Exec(query, newUser.Username, newUser.Email, newUser.Password, newUser.FullName, newUser.Role)
-	if err != nil {
-		return nil, fmt.Errorf("error creating user: %v", err)
-	}
+func (r *userRepository) WithTx(tx *sql.Tx) UserRepository {
+	return &userRepository{db: tx, tenant: r.tenant}
+}
 
-	id, err := result.LastInsertId()
+func (r *userRepository) ForTenant(orgID int) UserRepository {
+	return &userRepository{db: r.db, tenant: tenant{orgID: orgID}}
+}
+
+func (r *userRepository) AllTenants() UserRepository {
+	return &userRepository{db: r.db, tenant: allTenants}
+}
+
+// scoped restricts a query, which has to end with its WHERE clause, to the
+// users of the repository's organisation that are not in the trash
+func (r *userRepository) scoped(query string, args ...interface{}) (string, []interface{}, error) {
+	tenant, tenantArgs, err := r.tenant.clause("org_id")
 	if err != nil {
-		return nil, fmt.Errorf("error getting last insert ID: %v", err)
+		return "", nil, err
 	}
-
-	return r.GetUserByID(int(id))
+	return query + ` AND deleted_at IS NULL AND ` + tenant, append(args, tenantArgs...), nil
 }
 
-func (r *userRepository) GetUserByID(id int) (*models.User, error) {
-	query := `SELECT id, username, email, password_hash, full_name, role, is_active, created_at, updated_at 
-			  FROM users WHERE id = ?`
-	
+const userColumns = `id, org_id, org_role, username, email, password_hash, full_name, role, is_active, email_verified_at,
+			  pending_email, deletion_scheduled_at, mfa_secret, mfa_enabled_at, deleted_at, created_at, updated_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
 	var user models.User
-	var createdAt, updatedAt []uint8
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, 
-		&user.IsActive, &createdAt, &updatedAt,
+	var pendingEmail, mfaSecret sql.NullString
+	var emailVerifiedAt, deletionScheduledAt, mfaEnabledAt, deletedAt, createdAt, updatedAt []uint8
+	err := row.Scan(
+		&user.ID, &user.OrgID, &user.OrgRole, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role,
+		&user.IsActive, &emailVerifiedAt, &pendingEmail, &deletionScheduledAt, &mfaSecret, &mfaEnabledAt,
+		&deletedAt, &createdAt, &updatedAt,
 	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, fmt.Errorf("error getting user: %v", err)
+		return nil, err
 	}
 
+	if pendingEmail.Valid {
+		user.PendingEmail = &pendingEmail.String
+	}
+	user.MFASecret = mfaSecret.String
+	user.MFAEnabledAt, err = parseNullableTime(mfaEnabledAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mfa_enabled_at: %v", err)
+	}
+	user.DeletionScheduledAt, err = parseNullableTime(deletionScheduledAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deletion_scheduled_at: %v", err)
+	}
+	user.DeletedAt, err = parseNullableTime(deletedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deleted_at: %v", err)
+	}
+	user.EmailVerifiedAt, err = parseNullableTime(emailVerifiedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing email_verified_at: %v", err)
+	}
 	user.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
 	if err != nil {
 		return nil, fmt.Errorf("error parsing created_at: %v", err)
@@ -72,62 +144,76 @@ func (r *userRepository) GetUserByID(id int) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) GetUserByUsername(username string) (*models.User, error) {
-	query := `SELECT id, username, email, password_hash, full_name, role, is_active, created_at, updated_at 
-			  FROM users WHERE username = ?`
-	
-	var user models.User
-	var createdAt, updatedAt []uint8
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, 
-		&user.IsActive, &createdAt, &updatedAt,
-	)
+func (r *userRepository) getUser(where string, arg interface{}) (*models.User, error) {
+	query, args, err := r.scoped(`SELECT `+userColumns+` FROM users WHERE `+where, arg)
+	if err != nil {
+		return nil, err
+	}
+	user, err := scanUser(r.db.QueryRow(query, args...))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
 		return nil, fmt.Errorf("error getting user: %v", err)
 	}
+	return user, nil
+}
 
-	user.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+func (r *userRepository) CreateUser(newUser *models.NewUser) (*models.User, error) {
+	orgID, err := r.tenant.orgFor(0)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing created_at: %v", err)
+		return nil, err
 	}
-	user.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
-	if err != nil {
-		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	orgRole := newUser.OrgRole
+	if orgRole == "" {
+		orgRole = models.OrgRoleMember
 	}
+	query := `INSERT INTO users (org_id, org_role, username, email, password_hash, full_name, role, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
 
-	return &user, nil
-}
-
-func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
-	query := `SELECT id, username, email, password_hash, full_name, role, is_active, created_at, updated_at 
-			  FROM users WHERE email = ?`
-	
-	var user models.User
-	var createdAt, updatedAt []uint8
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, 
-		&user.IsActive, &createdAt, &updatedAt,
-	)
+	result, err := r.db.Exec(query, orgID, orgRole, newUser.Username, newUser.Email, newUser.Password, newUser.FullName, newUser.Role)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			if strings.Contains(err.Error(), "email") {
+				return nil, r.duplicateError("email", `email = ?`, newUser.Email)
+			}
+			return nil, r.duplicateError("username", `org_id = ? AND username = ?`, orgID, newUser.Username)
 		}
-		return nil, fmt.Errorf("error getting user: %v", err)
+		return nil, fmt.Errorf("error creating user: %v", err)
 	}
 
-	user.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	id, err := result.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("error parsing created_at: %v", err)
+		return nil, fmt.Errorf("error getting last insert ID: %v", err)
 	}
-	user.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
-	if err != nil {
-		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+
+	return r.GetUserByID(int(id))
+}
+
+// duplicateError tells apart a username or email already taken by another
+// user from one held by a user in the trash, who keeps it until purged
+func (r *userRepository) duplicateError(field, where string, args ...interface{}) error {
+	var deleted bool
+	err := r.db.QueryRow(`SELECT deleted_at IS NOT NULL FROM users WHERE `+where, args...).Scan(&deleted)
+	if err == nil && deleted {
+		return errors.New(field + " belongs to a deleted user")
 	}
+	return errors.New(field + " already exists")
+}
 
-	return &user, nil
+func (r *userRepository) GetUserByID(id int) (*models.User, error) {
+	return r.getUser(`id = ?`, id)
+}
+
+func (r *userRepository) GetUserByUsername(username string) (*models.User, error) {
+	if r.tenant.all {
+		return (&userRepository{db: r.db, tenant: tenant{orgID: models.DefaultOrganizationID}}).GetUserByUsername(username)
+	}
+	return r.getUser(`username = ?`, username)
+}
+
+func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
+	return r.getUser(`email = ?`, email)
 }
 
 func (r *userRepository) UpdateUser(id int, updates *models.UpdateUser) error {
@@ -135,8 +221,9 @@ func (r *userRepository) UpdateUser(id int, updates *models.UpdateUser) error {
 	args := []interface{}{}
 
 	if updates.Email != nil {
-		query += `email = ?, `
-		args = append(args, *updates.Email)
+		// A new address has to be verified again
+		query += `email_verified_at = IF(email = ?, email_verified_at, NULL), email = ?, `
+		args = append(args, *updates.Email, *updates.Email)
 	}
 	if updates.FullName != nil {
 		query += `full_name = ?, `
@@ -151,21 +238,220 @@ func (r *userRepository) UpdateUser(id int, updates *models.UpdateUser) error {
 		args = append(args, *updates.IsActive)
 	}
 
-	query = query[:len(query)-2] + ` WHERE id = ?`
-	args = append(args, id)
+	query, args, err := r.scoped(query[:len(query)-2]+` WHERE id = ?`, append(args, id)...)
+	if err != nil {
+		return err
+	}
 
-	_, err := r.db.Exec(query, args...)
+	_, err = r.db.Exec(query, args...)
 	if err != nil {
+		if updates.Email != nil && strings.Contains(err.Error(), "Duplicate entry") {
+			return r.duplicateError("email", `email = ?`, *updates.Email)
+		}
 		return fmt.Errorf("error updating user: %v", err)
 	}
 
 	return nil
 }
 
+func (r *userRepository) UpdatePassword(id int, passwordHash string) error {
+	query, args, err := r.scoped(`UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating password: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) AddPasswordHistory(id int, passwordHash string, keep int) error {
+	_, err := r.db.Exec(`INSERT INTO password_history (user_id, password_hash) VALUES (?, ?)`, id, passwordHash)
+	if err != nil {
+		return fmt.Errorf("error adding password history: %v", err)
+	}
+
+	// MariaDB does not allow LIMIT in an IN subquery, hence the derived table
+	query := `DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+				SELECT id FROM (
+					SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+				) AS recent
+			  )`
+	if _, err := r.db.Exec(query, id, id, keep); err != nil {
+		return fmt.Errorf("error pruning password history: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ListPasswordHistory(id int, limit int) ([]string, error) {
+	rows, err := r.db.Query(`SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing password history: %v", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("error scanning password history: %v", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// MarkEmailVerified records that the user proved they own their email
+// address, keeping the original time if it was already verified
+func (r *userRepository) MarkEmailVerified(id int) error {
+	now := time.Now()
+	query, args, err := r.scoped(`UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?`,
+		nullableTimeValue(&now), id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error verifying email: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) SetPendingEmail(id int, email string) error {
+	query, args, err := r.scoped(`UPDATE users SET pending_email = ? WHERE id = ?`, email, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error setting pending email: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ConfirmEmailChange(id int) error {
+	now := time.Now()
+	query := `UPDATE users SET email = pending_email, pending_email = NULL, email_verified_at = ?
+			  WHERE id = ? AND pending_email IS NOT NULL`
+	query, args, err := r.scoped(query, nullableTimeValue(&now), id)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error changing email: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("no pending email change")
+	}
+
+	return nil
+}
+
+func (r *userRepository) ScheduleDeletion(id int, at *time.Time) error {
+	query, args, err := r.scoped(`UPDATE users SET deletion_scheduled_at = ? WHERE id = ?`, nullableTimeValue(at), id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error scheduling deletion: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) ListUsersDueForDeletion(now time.Time) ([]*models.User, error) {
+	query, args, err := r.scoped(`SELECT `+userColumns+` FROM users WHERE deletion_scheduled_at <= ?`, nullableTimeValue(&now))
+	if err != nil {
+		return nil, err
+	}
+	return queryUsers(r.db, query, args...)
+}
+
+func (r *userRepository) SetMFASecret(id int, secret string) error {
+	query, args, err := r.scoped(`UPDATE users SET mfa_secret = ?, mfa_enabled_at = NULL, mfa_last_step = NULL WHERE id = ?`, secret, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error setting MFA secret: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) EnableMFA(id int) error {
+	now := time.Now()
+	query, args, err := r.scoped(`UPDATE users SET mfa_enabled_at = ? WHERE id = ? AND mfa_secret IS NOT NULL`, nullableTimeValue(&now), id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error enabling MFA: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) DisableMFA(id int) error {
+	query, args, err := r.scoped(`UPDATE users SET mfa_secret = NULL, mfa_enabled_at = NULL, mfa_last_step = NULL WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error disabling MFA: %v", err)
+	}
+	return nil
+}
+
+func (r *userRepository) UseMFAStep(id int, step int64) error {
+	query := `UPDATE users SET mfa_last_step = ? WHERE id = ? AND (mfa_last_step IS NULL OR mfa_last_step < ?)`
+	query, args, err := r.scoped(query, step, id, step)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error recording MFA code: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("code already used")
+	}
+
+	return nil
+}
+
 func (r *userRepository) DeleteUser(id int) error {
-	query := `DELETE FROM users WHERE id = ?`
-	
-	result, err := r.db.Exec(query, id)
+	now := time.Now()
+	query, args, err := r.scoped(`UPDATE users SET deleted_at = ? WHERE id = ?`, nullableTimeValue(&now), id)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("error deleting user: %v", err)
 	}
@@ -183,10 +469,120 @@ func (r *userRepository) DeleteUser(id int) error {
 }
 
 func (r *userRepository) ListUsers(offset, limit int) ([]*models.User, error) {
-	query := `SELECT id, username, email, password_hash, full_name, role, is_active, created_at, updated_at 
-			  FROM users LIMIT ? OFFSET ?`
-	
-	rows, err := r.db.Query(query, limit, offset)
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL AND ` + tenant + ` ORDER BY id LIMIT ? OFFSET ?`
+	return queryUsers(r.db, query, append(args, limit, offset)...)
+}
+
+func (r *userRepository) ListDeletedUsers() ([]*models.User, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NOT NULL AND ` + tenant + `
+			  ORDER BY deleted_at DESC, id DESC`
+	return queryUsers(r.db, query, args...)
+}
+
+func (r *userRepository) GetDeletedUser(id int) (*models.User, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ? AND deleted_at IS NOT NULL AND ` + tenant
+	user, err := scanUser(r.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("error getting user: %v", err)
+	}
+	return user, nil
+}
+
+func (r *userRepository) ListUsersDeletedBefore(before time.Time) ([]*models.User, error) {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at < ? AND ` + tenant + ` ORDER BY deleted_at, id`
+	return queryUsers(r.db, query, append([]interface{}{nullableTimeValue(&before)}, args...)...)
+}
+
+func (r *userRepository) RestoreUser(id int) error {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	query := `UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND ` + tenant
+	result, err := r.db.Exec(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error restoring user: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) PurgeUser(id int) error {
+	tenant, args, err := r.tenant.clause("org_id")
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`DELETE FROM users WHERE id = ? AND `+tenant, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("error purging user: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+func (r *userRepository) SetOrgRole(id int, role models.OrgRole) error {
+	query, args, err := r.scoped(`UPDATE users SET org_role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error setting organization role: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		// Nothing changed, or the user is not there
+		_, err := r.GetUserByID(id)
+		return err
+	}
+
+	return nil
+}
+
+func queryUsers(db DBTX, query string, args ...interface{}) ([]*models.User, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error listing users: %v", err)
 	}
@@ -194,26 +590,11 @@ func (r *userRepository) ListUsers(offset, limit int) ([]*models.User, error) {
 
 	var users []*models.User
 	for rows.Next() {
-		var user models.User
-		var createdAt, updatedAt []uint8
-		err := rows.Scan(
-			&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.FullName, &user.Role, 
-			&user.IsActive, &createdAt, &updatedAt,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning user row: %v", err)
 		}
-		
-		user.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing created_at: %v", err)
-		}
-		user.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
-		if err != nil {
-			return nil, fmt.Errorf("error parsing updated_at: %v", err)
-		}
-
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -221,4 +602,4 @@ func (r *userRepository) ListUsers(offset, limit int) ([]*models.User, error) {
 	}
 
 	return users, nil
-}
\ No newline at end of file
+}
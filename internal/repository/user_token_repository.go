@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type UserTokenRepository interface {
+	CreateToken(token *models.UserToken) error
+	GetTokenByHash(hash string) (*models.UserToken, error)
+	// UseToken marks a token as used; it fails if the token was already used
+	UseToken(id int) error
+	// RevokeTokens marks every unused token of the user for the purpose as used
+	RevokeTokens(userID int, purpose models.TokenPurpose) error
+	// WithTx returns a copy of the repository that runs inside tx
+	WithTx(tx *sql.Tx) UserTokenRepository
+}
+
+type userTokenRepository struct {
+	db DBTX
+}
+
+func NewUserTokenRepository(db *sql.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) WithTx(tx *sql.Tx) UserTokenRepository {
+	return &userTokenRepository{db: tx}
+}
+
+func (r *userTokenRepository) CreateToken(token *models.UserToken) error {
+	query := `INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES (?, ?, ?, ?)`
+	result, err := r.db.Exec(query, token.UserID, token.Purpose, token.TokenHash, nullableTimeValue(&token.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("error creating token: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	token.ID = int(id)
+	return nil
+}
+
+func (r *userTokenRepository) GetTokenByHash(hash string) (*models.UserToken, error) {
+	query := `SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+			  FROM user_tokens WHERE token_hash = ?`
+
+	token := &models.UserToken{}
+	var expiresAt, usedAt, createdAt []uint8
+	err := r.db.QueryRow(query, hash).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash,
+		&expiresAt, &usedAt, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("token not found")
+		}
+		return nil, fmt.Errorf("error getting token: %v", err)
+	}
+
+	token.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing expires_at: %v", err)
+	}
+	token.UsedAt, err = parseNullableTime(usedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing used_at: %v", err)
+	}
+	token.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+
+	return token, nil
+}
+
+func (r *userTokenRepository) UseToken(id int) error {
+	now := time.Now()
+	result, err := r.db.Exec(`UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		nullableTimeValue(&now), id)
+	if err != nil {
+		return fmt.Errorf("error using token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("token already used")
+	}
+
+	return nil
+}
+
+func (r *userTokenRepository) RevokeTokens(userID int, purpose models.TokenPurpose) error {
+	now := time.Now()
+	_, err := r.db.Exec(`UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		nullableTimeValue(&now), userID, purpose)
+	if err != nil {
+		return fmt.Errorf("error revoking tokens: %v", err)
+	}
+	return nil
+}
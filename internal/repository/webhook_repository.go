@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"task-management-api/internal/models"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(webhook *models.Webhook) error
+	GetWebhookByID(id int) (*models.Webhook, error)
+	ListWebhooks() ([]*models.Webhook, error)
+	ListActiveWebhooks() ([]*models.Webhook, error)
+	UpdateWebhook(webhook *models.Webhook) error
+	DeleteWebhook(id int) error
+
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	GetDelivery(id int) (*models.WebhookDelivery, error)
+	ListDeliveries(webhookID, limit int) ([]*models.WebhookDelivery, error)
+	GetDueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error
+	ResetDelivery(id int, now time.Time) error
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+const webhookColumns = `id, url, secret, event_types, is_active, created_by, created_at, updated_at`
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var eventTypes string
+	var createdBy sql.NullInt64
+	var createdAt, updatedAt []uint8
+	err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &eventTypes, &webhook.IsActive,
+		&createdBy, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.EventTypes = strings.Split(eventTypes, ",")
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		webhook.CreatedBy = &id
+	}
+
+	webhook.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+	webhook.UpdatedAt, err = time.Parse("2006-01-02 15:04:05", string(updatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing updated_at: %v", err)
+	}
+
+	return webhook, nil
+}
+
+func (r *webhookRepository) queryWebhooks(query string, args ...interface{}) ([]*models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %v", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook row: %v", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	query := `INSERT INTO webhooks (url, secret, event_types, is_active, created_by) VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, webhook.URL, webhook.Secret, strings.Join(webhook.EventTypes, ","),
+		webhook.IsActive, webhook.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+
+	created, err := r.GetWebhookByID(int(id))
+	if err != nil {
+		return err
+	}
+	*webhook = *created
+	return nil
+}
+
+func (r *webhookRepository) GetWebhookByID(id int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	webhook, err := scanWebhook(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("webhook not found")
+		}
+		return nil, fmt.Errorf("error getting webhook: %v", err)
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepository) ListWebhooks() ([]*models.Webhook, error) {
+	return r.queryWebhooks(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+}
+
+func (r *webhookRepository) ListActiveWebhooks() ([]*models.Webhook, error) {
+	return r.queryWebhooks(`SELECT ` + webhookColumns + ` FROM webhooks WHERE is_active ORDER BY id`)
+}
+
+func (r *webhookRepository) UpdateWebhook(webhook *models.Webhook) error {
+	query := `UPDATE webhooks SET url = ?, secret = ?, event_types = ?, is_active = ? WHERE id = ?`
+	_, err := r.db.Exec(query, webhook.URL, webhook.Secret, strings.Join(webhook.EventTypes, ","),
+		webhook.IsActive, webhook.ID)
+	if err != nil {
+		return fmt.Errorf("error updating webhook: %v", err)
+	}
+	return nil
+}
+
+func (r *webhookRepository) DeleteWebhook(id int) error {
+	result, err := r.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("webhook not found")
+	}
+
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			  last_status_code, last_error, created_at, delivered_at`
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var payload []byte
+	var lastStatusCode sql.NullInt64
+	var lastError sql.NullString
+	var nextAttemptAt, createdAt, deliveredAt []uint8
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&nextAttemptAt, &lastStatusCode, &lastError, &createdAt, &deliveredAt)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = payload
+	d.LastError = lastError.String
+	if lastStatusCode.Valid {
+		code := int(lastStatusCode.Int64)
+		d.LastStatusCode = &code
+	}
+
+	if d.NextAttemptAt, err = parseNullableTime(nextAttemptAt); err != nil {
+		return nil, fmt.Errorf("error parsing next_attempt_at: %v", err)
+	}
+	if d.DeliveredAt, err = parseNullableTime(deliveredAt); err != nil {
+		return nil, fmt.Errorf("error parsing delivered_at: %v", err)
+	}
+	d.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing created_at: %v", err)
+	}
+
+	return d, nil
+}
+
+// parseNullableTime parses a DATETIME column that may be NULL
+func parseNullableTime(value []uint8) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", string(value))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *webhookRepository) queryDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning delivery row: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// CreateDelivery queues a delivery, unless the event is already queued for
+// the webhook
+func (r *webhookRepository) CreateDelivery(d *models.WebhookDelivery) error {
+	query := `INSERT IGNORE INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, d.WebhookID, d.EventID, d.EventType, string(d.Payload), d.Status,
+		nullableTimeValue(d.NextAttemptAt))
+	if err != nil {
+		return fmt.Errorf("error creating delivery: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert ID: %v", err)
+	}
+	d.ID = int(id)
+	return nil
+}
+
+// GetDelivery returns a delivery together with the log of its attempts
+func (r *webhookRepository) GetDelivery(id int) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = ?`
+	d, err := scanDelivery(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("delivery not found")
+		}
+		return nil, fmt.Errorf("error getting delivery: %v", err)
+	}
+
+	rows, err := r.db.Query(`SELECT id, delivery_id, status_code, error, response_body, duration_ms, attempted_at
+							 FROM webhook_delivery_attempts WHERE delivery_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error listing delivery attempts: %v", err)
+	}
+	defer rows.Close()
+
+	d.AttemptLog = []*models.WebhookAttempt{}
+	for rows.Next() {
+		a := &models.WebhookAttempt{}
+		var statusCode sql.NullInt64
+		var attemptError, responseBody sql.NullString
+		var attemptedAt []uint8
+		err := rows.Scan(&a.ID, &a.DeliveryID, &statusCode, &attemptError, &responseBody, &a.DurationMs, &attemptedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning attempt row: %v", err)
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			a.StatusCode = &code
+		}
+		a.Error = attemptError.String
+		a.ResponseBody = responseBody.String
+		a.AttemptedAt, err = time.Parse("2006-01-02 15:04:05", string(attemptedAt))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing attempted_at: %v", err)
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after scanning all rows: %v", err)
+	}
+
+	return d, nil
+}
+
+func (r *webhookRepository) ListDeliveries(webhookID, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`
+	return r.queryDeliveries(query, webhookID, limit)
+}
+
+// GetDueDeliveries returns pending deliveries whose next attempt is due, oldest first
+func (r *webhookRepository) GetDueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+			  WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`
+	return r.queryDeliveries(query, models.WebhookDeliveryPending, nullableTimeValue(&now), limit)
+}
+
+// RecordAttempt logs an attempt and stores the resulting state of the delivery
+func (r *webhookRepository) RecordAttempt(d *models.WebhookDelivery, a *models.WebhookAttempt) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, response_body, duration_ms)
+			  VALUES (?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, d.ID, a.StatusCode, a.Error, a.ResponseBody, a.DurationMs); err != nil {
+		return fmt.Errorf("error logging delivery attempt: %v", err)
+	}
+
+	query = `UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?,
+			 last_error = ?, delivered_at = ? WHERE id = ?`
+	_, err = tx.Exec(query, d.Status, d.Attempts, nullableTimeValue(d.NextAttemptAt), d.LastStatusCode,
+		d.LastError, nullableTimeValue(d.DeliveredAt), d.ID)
+	if err != nil {
+		return fmt.Errorf("error updating delivery: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// ResetDelivery puts a delivery back in the queue to be sent right away
+func (r *webhookRepository) ResetDelivery(id int, now time.Time) error {
+	query := `UPDATE webhook_deliveries SET status = ?, next_attempt_at = ?, delivered_at = NULL WHERE id = ?`
+	result, err := r.db.Exec(query, models.WebhookDeliveryPending, nullableTimeValue(&now), id)
+	if err != nil {
+		return fmt.Errorf("error resetting delivery: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("delivery not found")
+	}
+
+	return nil
+}
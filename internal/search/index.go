@@ -0,0 +1,128 @@
+package search
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"unicode"
+)
+
+// Index finds tasks matching free-text queries. Every query term has to
+// match, as a prefix of a word in the title, the description or one of the
+// task's comments.
+type Index interface {
+	// Index adds a task to the index or refreshes it after a change
+	Index(task *models.Task)
+	// IndexComments replaces the comments indexed for a task
+	IndexComments(taskID int, comments []*models.Comment)
+	// Remove drops a deleted task from the index
+	Remove(taskID int)
+	// Search returns up to limit tasks of the organisation matching the
+	// optional filter expression, best match first. Archived tasks are left
+	// out unless includeArchived is set.
+	Search(query string, cond filter.Expr, orgID int, includeArchived bool, limit int) ([]*models.SearchResult, error)
+}
+
+// New creates the index selected by backend: "mariadb" searches the tasks and
+// comments tables through their FULLTEXT indexes, "memory" keeps an inverted
+// index in process that has to be filled with Rebuild at startup
+func New(backend string, taskRepo repository.TaskRepository, labelRepo repository.LabelRepository,
+	commentRepo repository.CommentRepository) (Index, error) {
+	switch backend {
+	case "", "mariadb":
+		return NewSQLIndex(taskRepo, commentRepo), nil
+	case "memory":
+		return NewMemoryIndex(labelRepo), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", backend)
+	}
+}
+
+// Tokenize splits text into lower-cased words made of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+const snippetLength = 160
+
+// Highlight returns the part of text around the first word starting with one
+// of the terms, HTML-escaped and with every matching word wrapped in <mark>.
+// It returns an empty string when nothing matches.
+func Highlight(text string, terms []string) string {
+	type span struct{ start, end int }
+	var matches []span
+
+	start := -1
+	runes := []rune(text)
+	for i := 0; i <= len(runes); i++ {
+		inWord := i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]))
+		if inWord && start < 0 {
+			start = i
+		}
+		if !inWord && start >= 0 {
+			word := strings.ToLower(string(runes[start:i]))
+			for _, term := range terms {
+				if strings.HasPrefix(word, term) {
+					matches = append(matches, span{start, i})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	// Centre the snippet on the first match
+	from := matches[0].start - snippetLength/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetLength
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[m.start:m.end])) + "</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// highlightResult fills in the highlights of a search result, taking the
+// comment highlight from the first of the task's comments that matches
+func highlightResult(result *models.SearchResult, terms []string, comments []string) {
+	result.Highlights = map[string]string{}
+	if snippet := Highlight(result.Task.Title, terms); snippet != "" {
+		result.Highlights["title"] = snippet
+	}
+	if snippet := Highlight(result.Task.Description, terms); snippet != "" {
+		result.Highlights["description"] = snippet
+	}
+	for _, comment := range comments {
+		if snippet := Highlight(comment, terms); snippet != "" {
+			result.Highlights["comment"] = snippet
+			break
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+)
+
+// titleBoost weighs a match in the title above one in the description
+const titleBoost = 2.0
+
+// MemoryIndex is an inverted index held in process, scoring matches with
+// TF-IDF. Filter expressions are evaluated in memory against the matches,
+// looking up their labels only when the expression needs them.
+type MemoryIndex struct {
+	labelRepo repository.LabelRepository
+
+	mu       sync.RWMutex
+	tasks    map[int]*models.Task
+	comments map[int][]string           // task ID -> comment bodies
+	postings map[string]map[int]float64 // term -> task ID -> weighted term frequency
+	terms    []string                   // sorted keys of postings, for prefix lookups
+	dirty    bool
+}
+
+func NewMemoryIndex(labelRepo repository.LabelRepository) *MemoryIndex {
+	return &MemoryIndex{
+		labelRepo: labelRepo,
+		tasks:     make(map[int]*models.Task),
+		comments:  make(map[int][]string),
+		postings:  make(map[string]map[int]float64),
+	}
+}
+
+// Rebuild replaces the contents of the index with the given tasks and
+// comments
+func (i *MemoryIndex) Rebuild(tasks []*models.Task, comments []*models.Comment) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.tasks = make(map[int]*models.Task, len(tasks))
+	i.comments = make(map[int][]string)
+	i.postings = make(map[string]map[int]float64)
+	for _, comment := range comments {
+		i.comments[comment.TaskID] = append(i.comments[comment.TaskID], comment.Body)
+	}
+	for _, task := range tasks {
+		i.add(task)
+	}
+}
+
+func (i *MemoryIndex) Index(task *models.Task) {
+	copied := *task
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(task.ID)
+	i.add(&copied)
+}
+
+func (i *MemoryIndex) IndexComments(taskID int, comments []*models.Comment) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	task, indexed := i.tasks[taskID]
+	if indexed {
+		i.remove(taskID)
+	}
+	delete(i.comments, taskID)
+	for _, comment := range comments {
+		i.comments[taskID] = append(i.comments[taskID], comment.Body)
+	}
+	if indexed {
+		i.add(task)
+	}
+}
+
+// Remove drops a task from the index. Its comments are kept, so that the
+// task is found by them again once it is restored.
+func (i *MemoryIndex) Remove(taskID int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(taskID)
+}
+
+func (i *MemoryIndex) add(task *models.Task) {
+	i.tasks[task.ID] = task
+	for _, term := range Tokenize(task.Title) {
+		i.post(term, task.ID, titleBoost)
+	}
+	for _, term := range Tokenize(task.Description) {
+		i.post(term, task.ID, 1)
+	}
+	for _, comment := range i.comments[task.ID] {
+		for _, term := range Tokenize(comment) {
+			i.post(term, task.ID, 1)
+		}
+	}
+}
+
+func (i *MemoryIndex) post(term string, taskID int, weight float64) {
+	postings, ok := i.postings[term]
+	if !ok {
+		postings = make(map[int]float64)
+		i.postings[term] = postings
+		i.dirty = true
+	}
+	postings[taskID] += weight
+}
+
+func (i *MemoryIndex) remove(taskID int) {
+	task, ok := i.tasks[taskID]
+	if !ok {
+		return
+	}
+	delete(i.tasks, taskID)
+
+	terms := append(Tokenize(task.Title), Tokenize(task.Description)...)
+	for _, comment := range i.comments[taskID] {
+		terms = append(terms, Tokenize(comment)...)
+	}
+	for _, term := range terms {
+		if postings, ok := i.postings[term]; ok {
+			delete(postings, taskID)
+			if len(postings) == 0 {
+				delete(i.postings, term)
+				i.dirty = true
+			}
+		}
+	}
+}
+
+// expand returns the indexed terms starting with prefix. It must be called
+// with the write lock held since it may re-sort the term list.
+func (i *MemoryIndex) expand(prefix string) []string {
+	if i.dirty {
+		i.terms = i.terms[:0]
+		for term := range i.postings {
+			i.terms = append(i.terms, term)
+		}
+		sort.Strings(i.terms)
+		i.dirty = false
+	}
+
+	var matches []string
+	for j := sort.SearchStrings(i.terms, prefix); j < len(i.terms) && strings.HasPrefix(i.terms[j], prefix); j++ {
+		matches = append(matches, i.terms[j])
+	}
+	return matches
+}
+
+func (i *MemoryIndex) Search(query string, cond filter.Expr, orgID int, includeArchived bool,
+	limit int) ([]*models.SearchResult, error) {
+	results := i.match(query, orgID, includeArchived)
+
+	if cond != nil {
+		matching := results[:0]
+		for _, result := range results {
+			record := filter.Record{Task: result.Task}
+			if filter.UsesLabels(cond) {
+				labels, err := i.labelRepo.GetTaskLabels(result.Task.ID)
+				if err != nil {
+					return nil, err
+				}
+				for _, label := range labels {
+					record.Labels = append(record.Labels, label.Name)
+				}
+			}
+			if cond.Eval(record) {
+				matching = append(matching, result)
+			}
+		}
+		results = matching
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Task.ID > results[b].Task.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	terms := Tokenize(query)
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, result := range results {
+		highlightResult(result, terms, i.comments[result.Task.ID])
+	}
+	return results, nil
+}
+
+// match returns the organisation's tasks matching every query term, unsorted
+func (i *MemoryIndex) match(query string, orgID int, includeArchived bool) []*models.SearchResult {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var scores map[int]float64
+	for _, term := range terms {
+		// Score of every task matching this query term through any expansion
+		termScores := make(map[int]float64)
+		for _, expanded := range i.expand(term) {
+			postings := i.postings[expanded]
+			idf := math.Log(1 + float64(len(i.tasks))/float64(len(postings)))
+			for taskID, tf := range postings {
+				termScores[taskID] += tf * idf
+			}
+		}
+
+		// Every term has to match
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for taskID := range scores {
+			if score, ok := termScores[taskID]; ok {
+				scores[taskID] += score
+			} else {
+				delete(scores, taskID)
+			}
+		}
+	}
+
+	var results []*models.SearchResult
+	for taskID, score := range scores {
+		task := i.tasks[taskID]
+		if task.OrgID != orgID || (task.ArchivedAt != nil && !includeArchived) {
+			continue
+		}
+		copied := *task
+		results = append(results, &models.SearchResult{Task: &copied, Score: score})
+	}
+	return results
+}
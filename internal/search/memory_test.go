@@ -0,0 +1,158 @@
+package search
+
+import (
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+func searchIDs(t *testing.T, index *MemoryIndex, query string) []int {
+	t.Helper()
+	results, err := index.Search(query, nil, 1, false, 0)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.Task.ID
+	}
+	return ids
+}
+
+func sameIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestIndex() *MemoryIndex {
+	index := NewMemoryIndex(nil)
+	index.Rebuild([]*models.Task{
+		{ID: 1, OrgID: 1, Title: "Fix the login page", Description: "Users cannot sign in"},
+		{ID: 2, OrgID: 1, Title: "Write release notes"},
+		{ID: 3, OrgID: 2, Title: "Login audit", Description: "Another organisation"},
+	}, []*models.Comment{
+		{TaskID: 2, Body: "Mention the login fix in the notes"},
+		{TaskID: 3, Body: "Release blocker"},
+	})
+	return index
+}
+
+func TestMemoryIndexSearchesComments(t *testing.T) {
+	index := newTestIndex()
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		// The title match of 1 outweighs the comment match of 2
+		{"login", []int{1, 2}},
+		{"mention", []int{2}},
+		// Terms may match in different fields
+		{"release mention", []int{2}},
+		{"rel", []int{2}},
+		{"login release blocker", nil},
+	}
+	for _, tt := range tests {
+		if got := searchIDs(t, index, tt.query); !sameIDs(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryIndexHighlightsComments(t *testing.T) {
+	index := newTestIndex()
+
+	results, _ := index.Search("mention", nil, 1, false, 0)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if got, want := results[0].Highlights["comment"], "<mark>Mention</mark> the login fix in the notes"; got != want {
+		t.Errorf("comment highlight = %q, want %q", got, want)
+	}
+	if _, ok := results[0].Highlights["title"]; ok {
+		t.Error("highlighted a title that does not match")
+	}
+}
+
+func TestMemoryIndexReplacesComments(t *testing.T) {
+	index := newTestIndex()
+
+	index.IndexComments(2, []*models.Comment{{TaskID: 2, Body: "Ship it on friday"}})
+	if got := searchIDs(t, index, "mention"); len(got) != 0 {
+		t.Errorf("still found %v by a deleted comment", got)
+	}
+	if got := searchIDs(t, index, "friday"); !sameIDs(got, []int{2}) {
+		t.Errorf("Search(friday) = %v, want [2]", got)
+	}
+
+	index.IndexComments(2, nil)
+	if got := searchIDs(t, index, "friday"); len(got) != 0 {
+		t.Errorf("still found %v after its comments were removed", got)
+	}
+}
+
+func TestMemoryIndexKeepsCommentsOfRemovedTasks(t *testing.T) {
+	index := newTestIndex()
+
+	index.Remove(2)
+	if got := searchIDs(t, index, "mention"); len(got) != 0 {
+		t.Errorf("found removed task: %v", got)
+	}
+
+	// Restored from the trash
+	index.Index(&models.Task{ID: 2, OrgID: 1, Title: "Write release notes"})
+	if got := searchIDs(t, index, "mention"); !sameIDs(got, []int{2}) {
+		t.Errorf("Search(mention) = %v after restoring, want [2]", got)
+	}
+}
+
+func TestMemoryIndexHidesArchivedTasks(t *testing.T) {
+	index := newTestIndex()
+	archivedAt := time.Now()
+	index.Index(&models.Task{ID: 1, OrgID: 1, Title: "Fix the login page", ArchivedAt: &archivedAt})
+
+	if got := searchIDs(t, index, "login"); !sameIDs(got, []int{2}) {
+		t.Errorf("Search(login) = %v, want only the live task 2", got)
+	}
+	results, err := index.Search("login", nil, 1, true, 0)
+	if err != nil || len(results) != 2 || results[0].Task.ID != 1 {
+		t.Errorf("Search(login) including archived = %v, %v; want 1 and 2", results, err)
+	}
+}
+
+func TestMemoryIndexFindsTasksOfEveryMember(t *testing.T) {
+	index := newTestIndex()
+	ann, bob := 7, 8
+	index.Index(&models.Task{ID: 1, OrgID: 1, UserID: &ann, Title: "Fix the login page"})
+	index.Index(&models.Task{ID: 2, OrgID: 1, UserID: &bob, Title: "Write release notes"})
+
+	// Like the task list, search is bounded by the organisation only
+	if got := searchIDs(t, index, "login"); !sameIDs(got, []int{1, 2}) {
+		t.Errorf("Search(login) = %v, want the tasks of both members", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Fix the login page", []string{"log"}, "Fix the <mark>login</mark> page"},
+		{"Fix the login page", []string{"page", "fix"}, "<mark>Fix</mark> the login <mark>page</mark>"},
+		{"<b>bold</b> claims", []string{"bold"}, "&lt;b&gt;<mark>bold</mark>&lt;/b&gt; claims"},
+		{"Fix the login page", []string{"ogin"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.text, tt.terms); got != tt.want {
+			t.Errorf("Highlight(%q, %v) = %q, want %q", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"log"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+)
+
+// SQLIndex searches through the FULLTEXT indexes of the tasks,
+// archived_tasks and task_comments tables, which the database keeps up to
+// date by itself
+type SQLIndex struct {
+	taskRepo    repository.TaskRepository
+	commentRepo repository.CommentRepository
+}
+
+func NewSQLIndex(taskRepo repository.TaskRepository, commentRepo repository.CommentRepository) *SQLIndex {
+	return &SQLIndex{taskRepo: taskRepo, commentRepo: commentRepo}
+}
+
+func (i *SQLIndex) Index(task *models.Task) {}
+
+func (i *SQLIndex) IndexComments(taskID int, comments []*models.Comment) {}
+
+func (i *SQLIndex) Remove(taskID int) {}
+
+func (i *SQLIndex) Search(query string, cond filter.Expr, orgID int, includeArchived bool,
+	limit int) ([]*models.SearchResult, error) {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var sqlCond models.SQLCondition
+	if cond != nil {
+		sqlCond = cond
+	}
+	results, err := i.taskRepo.ForTenant(orgID).SearchTasks(terms, sqlCond, includeArchived, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		comments, err := i.commentRepo.ListComments(result.Task.ID)
+		if err != nil {
+			// The match stands, only its comment highlight is missing
+			log.Printf("Error loading comments of task %d: %v", result.Task.ID, err)
+		}
+		highlightResult(result, terms, commentBodies(comments))
+	}
+	return results, nil
+}
+
+func commentBodies(comments []*models.Comment) []string {
+	bodies := make([]string, len(comments))
+	for i, comment := range comments {
+		bodies[i] = comment.Body
+	}
+	return bodies
+}
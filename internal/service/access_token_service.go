@@ -0,0 +1,163 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"time"
+)
+
+// tokenTouchInterval limits how often a token's last use is written
+const tokenTouchInterval = time.Minute
+
+// AccessTokenService manages personal access tokens, which scripts use to
+// call the API as a user with a limited set of scopes
+type AccessTokenService interface {
+	// CreateToken returns the new token with its secret, which is not
+	// shown again
+	CreateToken(userID int, newToken *models.NewPersonalAccessToken) (*models.PersonalAccessToken, error)
+	ListTokens(userID int) ([]*models.PersonalAccessToken, error)
+	// RevokeToken revokes one of the user's tokens; actorID is the owner or an admin
+	RevokeToken(actorID, userID, id int) error
+	// AuthenticateToken checks a token presented with a request and returns
+	// it along with its user
+	AuthenticateToken(token, ip string) (*models.PersonalAccessToken, *models.User, error)
+}
+
+type accessTokenService struct {
+	repo     repository.AccessTokenRepository
+	userRepo repository.UserRepository
+	outbox   repository.OutboxRepository
+	cfg      config.AccessTokensConfig
+}
+
+// NewAccessTokenService creates a new AccessTokenService
+func NewAccessTokenService(repo repository.AccessTokenRepository, userRepo repository.UserRepository,
+	outbox repository.OutboxRepository, cfg config.AccessTokensConfig) AccessTokenService {
+	return &accessTokenService{repo: repo, userRepo: userRepo, outbox: outbox, cfg: cfg}
+}
+
+func (s *accessTokenService) CreateToken(userID int, newToken *models.NewPersonalAccessToken) (*models.PersonalAccessToken, error) {
+	scopes, err := validateScopes(newToken.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := newToken.ExpiresAt
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, apierrors.NewBadRequestError("expires_at must be in the future")
+	}
+	if s.cfg.MaxLifetime > 0 {
+		latest := now.Add(s.cfg.MaxLifetime)
+		if expiresAt == nil {
+			expiresAt = &latest
+		} else if expiresAt.After(latest) {
+			return nil, apierrors.NewBadRequestError(fmt.Sprintf("tokens cannot be valid for longer than %s", humanDuration(s.cfg.MaxLifetime)))
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("error generating token: %v", err)
+	}
+	secret := models.TokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      newToken.Name,
+		Scopes:    scopes,
+		Prefix:    secret[:len(models.TokenPrefix)+8],
+		TokenHash: hashToken(secret),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.CreateAccessToken(token); err != nil {
+		return nil, err
+	}
+	token.Token = secret
+
+	audit(s.outbox, events.UserTokenCreated, events.SecurityData{UserID: userID, TokenID: token.ID})
+	return token, nil
+}
+
+// validateScopes checks that every scope is known, dropping duplicates
+func validateScopes(scopes []string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, scope := range models.TokenScopes {
+		known[scope] = true
+	}
+
+	seen := make(map[string]bool)
+	var valid []string
+	for _, scope := range scopes {
+		if !known[scope] {
+			return nil, apierrors.NewBadRequestError(fmt.Sprintf("unknown scope %q; valid scopes are: %s",
+				scope, strings.Join(models.TokenScopes, ", ")))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			valid = append(valid, scope)
+		}
+	}
+	return valid, nil
+}
+
+func (s *accessTokenService) ListTokens(userID int) ([]*models.PersonalAccessToken, error) {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return nil, apierrors.NewNotFoundError(err.Error())
+	}
+	return s.repo.ListAccessTokens(userID)
+}
+
+func (s *accessTokenService) RevokeToken(actorID, userID, id int) error {
+	if err := s.repo.RevokeAccessToken(userID, id); err != nil {
+		if err.Error() == "token not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+
+	data := events.SecurityData{UserID: userID, TokenID: id}
+	if actorID != userID {
+		data.ActorID = &actorID
+	}
+	audit(s.outbox, events.UserTokenRevoked, data)
+	return nil
+}
+
+func (s *accessTokenService) AuthenticateToken(secret, ip string) (*models.PersonalAccessToken, *models.User, error) {
+	invalid := errors.New("invalid access token")
+
+	token, err := s.repo.GetAccessTokenByHash(hashToken(secret))
+	if err != nil {
+		if err.Error() == "token not found" {
+			return nil, nil, invalid
+		}
+		return nil, nil, err
+	}
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+		return nil, nil, invalid
+	}
+
+	// Tokens stop working with their account, and while it is scheduled for deletion
+	user, err := s.userRepo.GetUserByID(token.UserID)
+	if err != nil {
+		return nil, nil, invalid
+	}
+	if !user.IsActive || user.DeletionScheduledAt != nil {
+		return nil, nil, invalid
+	}
+
+	if err := s.repo.TouchAccessToken(token.ID, ip, time.Now().Add(-tokenTouchInterval)); err != nil {
+		log.Printf("Error recording use of access token %d: %v", token.ID, err)
+	}
+	return token, user, nil
+}
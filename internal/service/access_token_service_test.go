@@ -0,0 +1,158 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+type fakeAccessTokenRepo struct {
+	repository.AccessTokenRepository
+	tokens  []*models.PersonalAccessToken
+	touched map[int]string
+}
+
+func (r *fakeAccessTokenRepo) CreateAccessToken(token *models.PersonalAccessToken) error {
+	token.ID = len(r.tokens) + 1
+	stored := *token
+	r.tokens = append(r.tokens, &stored)
+	return nil
+}
+
+func (r *fakeAccessTokenRepo) GetAccessTokenByHash(hash string) (*models.PersonalAccessToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			stored := *token
+			return &stored, nil
+		}
+	}
+	return nil, errors.New("token not found")
+}
+
+func (r *fakeAccessTokenRepo) RevokeAccessToken(userID, id int) error {
+	for _, token := range r.tokens {
+		if token.ID == id && token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return nil
+		}
+	}
+	return errors.New("token not found")
+}
+
+func (r *fakeAccessTokenRepo) TouchAccessToken(id int, ip string, since time.Time) error {
+	r.touched[id] = ip
+	return nil
+}
+
+type testAccessTokenService struct {
+	AccessTokenService
+	repo   *fakeAccessTokenRepo
+	users  *fakeUserRepo
+	outbox *fakeOutbox
+}
+
+func newTestAccessTokenService(maxLifetime time.Duration) *testAccessTokenService {
+	f := &testAccessTokenService{
+		repo:   &fakeAccessTokenRepo{touched: map[int]string{}},
+		users:  newFakeUserRepo(&models.User{ID: 1, Username: "ann", IsActive: true}),
+		outbox: &fakeOutbox{},
+	}
+	f.AccessTokenService = NewAccessTokenService(f.repo, f.users, f.outbox, config.AccessTokensConfig{MaxLifetime: maxLifetime})
+	return f
+}
+
+func TestAccessTokenLifecycle(t *testing.T) {
+	s := newTestAccessTokenService(0)
+
+	token, err := s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read", "tasks:read"}})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if !strings.HasPrefix(token.Token, models.TokenPrefix) || !strings.HasPrefix(token.Token, token.Prefix) {
+		t.Errorf("token %q with prefix %q", token.Token, token.Prefix)
+	}
+	if len(token.Scopes) != 1 {
+		t.Errorf("scopes = %v, want duplicates dropped", token.Scopes)
+	}
+	if stored := s.repo.tokens[0]; stored.Token != "" || stored.TokenHash != hashToken(token.Token) {
+		t.Error("the token must only be stored hashed")
+	}
+	if len(s.outbox.events) != 1 || s.outbox.events[0].Type != events.UserTokenCreated {
+		t.Errorf("events = %v, want %s", s.outbox.events, events.UserTokenCreated)
+	}
+
+	got, user, err := s.AuthenticateToken(token.Token, "192.0.2.1")
+	if err != nil || got.ID != token.ID || user.ID != 1 {
+		t.Fatalf("AuthenticateToken = %v, %v, %v", got, user, err)
+	}
+	if s.repo.touched[token.ID] != "192.0.2.1" {
+		t.Error("last use was not recorded")
+	}
+
+	if _, _, err := s.AuthenticateToken(token.Token+"x", ""); err == nil {
+		t.Error("accepted an unknown token")
+	}
+
+	if err := s.RevokeToken(1, 1, token.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, _, err := s.AuthenticateToken(token.Token, ""); err == nil {
+		t.Error("accepted a revoked token")
+	}
+	wantNotFound(t, s.RevokeToken(1, 1, token.ID))
+}
+
+func TestAuthenticateTokenRefusesInactiveAccounts(t *testing.T) {
+	now := time.Now()
+	for name, update := range map[string]func(*models.User){
+		"deactivated":            func(u *models.User) { u.IsActive = false },
+		"scheduled for deletion": func(u *models.User) { u.DeletionScheduledAt = &now },
+		"deleted":                func(u *models.User) { u.DeletedAt = &now },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestAccessTokenService(0)
+			token, _ := s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read"}})
+			update(s.users.users[1])
+			if _, _, err := s.AuthenticateToken(token.Token, ""); err == nil {
+				t.Error("accepted a token of an inactive account")
+			}
+		})
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	s := newTestAccessTokenService(30 * 24 * time.Hour)
+
+	token, err := s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read"}})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if token.ExpiresAt == nil || token.ExpiresAt.After(time.Now().Add(30*24*time.Hour)) {
+		t.Errorf("expires at %v, want the maximum lifetime", token.ExpiresAt)
+	}
+
+	tooLate := time.Now().Add(31 * 24 * time.Hour)
+	_, err = s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read"}, ExpiresAt: &tooLate})
+	wantBadRequest(t, err)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read"}, ExpiresAt: &past})
+	wantBadRequest(t, err)
+
+	s.repo.tokens[0].ExpiresAt = &past
+	if _, _, err := s.AuthenticateToken(token.Token, ""); err == nil {
+		t.Error("accepted an expired token")
+	}
+}
+
+func TestCreateTokenRejectsUnknownScopes(t *testing.T) {
+	s := newTestAccessTokenService(0)
+	_, err := s.CreateToken(1, &models.NewPersonalAccessToken{Name: "ci", Scopes: []string{"tasks:read", "admin"}})
+	wantBadRequest(t, err)
+}
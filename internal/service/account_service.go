@@ -0,0 +1,268 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/mail"
+	"time"
+)
+
+// AccountService handles the email-based account flows: verifying the email
+// address, changing it, and resetting a forgotten password. They work with
+// single-use, expiring tokens sent by email, of which only a hash is stored.
+type AccountService interface {
+	SendVerification(user *models.User) error
+	ResendVerification(email string) error
+	// VerifyEmail redeems a verification token, or the token confirming a
+	// change of address
+	VerifyEmail(token string) error
+	// RequestEmailChange asks the user to confirm their new address, which
+	// replaces the current one once confirmed
+	RequestEmailChange(userID int, change *models.EmailChange) error
+	ForgotPassword(email string) error
+	ResetPassword(token, password string) error
+}
+
+type accountService struct {
+	userRepo  repository.UserRepository
+	tokenRepo repository.UserTokenRepository
+	tx        repository.TxRunner
+	outbox    repository.OutboxRepository
+	passwords PasswordService
+	sessions  SessionService
+	mailer    mail.Sender
+	cfg       config.AccountsConfig
+}
+
+// NewAccountService creates a new AccountService
+func NewAccountService(userRepo repository.UserRepository, tokenRepo repository.UserTokenRepository,
+	tx repository.TxRunner, outbox repository.OutboxRepository, passwords PasswordService, sessions SessionService,
+	mailer mail.Sender, cfg config.AccountsConfig) AccountService {
+	return &accountService{
+		userRepo: userRepo, tokenRepo: tokenRepo, tx: tx, outbox: outbox, passwords: passwords, sessions: sessions,
+		mailer: mailer, cfg: cfg,
+	}
+}
+
+// SendVerification emails the user a link to verify their address,
+// invalidating any link sent before
+func (s *accountService) SendVerification(user *models.User) error {
+	token, err := s.issueToken(user.ID, models.TokenPurposeEmailVerification, s.cfg.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.send("verify_email", user, s.cfg.VerifyURL, token, s.cfg.VerificationTTL)
+}
+
+// ResendVerification sends a new verification link. To avoid revealing which
+// addresses have accounts it succeeds whether or not one exists.
+func (s *accountService) ResendVerification(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || !user.IsActive || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	if err := s.SendVerification(user); err != nil {
+		log.Printf("Error resending verification to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (s *accountService) VerifyEmail(token string) error {
+	return s.tx.RunInTx(func(tx *sql.Tx) error {
+		t, err := s.redeem(tx, token, models.TokenPurposeEmailVerification, models.TokenPurposeEmailChange)
+		if err != nil {
+			return err
+		}
+
+		users := s.userRepo.WithTx(tx)
+		if t.Purpose == models.TokenPurposeEmailVerification {
+			return users.MarkEmailVerified(t.UserID)
+		}
+
+		// The new address may have been taken since the change was requested
+		user, err := users.GetUserByID(t.UserID)
+		if err != nil {
+			return err
+		}
+		if user.PendingEmail == nil {
+			return apierrors.NewBadRequestError("invalid or expired token")
+		}
+		if other, err := users.GetUserByEmail(*user.PendingEmail); err == nil && other.ID != user.ID {
+			return apierrors.NewConflictError("email already exists")
+		}
+		if err := users.ConfirmEmailChange(t.UserID); err != nil {
+			return err
+		}
+		if user, err = users.GetUserByID(t.UserID); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserUpdated, user))
+	})
+}
+
+func (s *accountService) RequestEmailChange(userID int, change *models.EmailChange) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, change.Password); err != nil {
+		return err
+	}
+	if change.Email == user.Email {
+		return apierrors.NewBadRequestError("that is already your email address")
+	}
+	if _, err := s.userRepo.GetUserByEmail(change.Email); err == nil {
+		return apierrors.NewConflictError("email already exists")
+	}
+
+	if err := s.userRepo.SetPendingEmail(userID, change.Email); err != nil {
+		return err
+	}
+	token, err := s.issueToken(userID, models.TokenPurposeEmailChange, s.cfg.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	// The link goes to the new address, proving the user can read it
+	recipient := *user
+	recipient.Email = change.Email
+	return s.send("confirm_email_change", &recipient, s.cfg.VerifyURL, token, s.cfg.VerificationTTL)
+}
+
+// ForgotPassword emails a password reset link. To avoid revealing which
+// addresses have accounts it succeeds whether or not one exists.
+func (s *accountService) ForgotPassword(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil || !user.IsActive {
+		return nil
+	}
+
+	token, err := s.issueToken(user.ID, models.TokenPurposePasswordReset, s.cfg.ResetTTL)
+	if err == nil {
+		err = s.send("password_reset", user, s.cfg.ResetURL, token, s.cfg.ResetTTL)
+	}
+	if err != nil {
+		log.Printf("Error sending password reset to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out
+// everywhere, in case someone else knew the old password. Since the token
+// was delivered by email, redeeming it also verifies the address.
+func (s *accountService) ResetPassword(token, password string) error {
+	var userID int
+	// A password the policy rejects rolls back, leaving the token usable
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		t, err := s.redeem(tx, token, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		users := s.userRepo.WithTx(tx)
+		user, err := users.GetUserByID(t.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.passwords.Set(tx, user, password); err != nil {
+			return err
+		}
+		if err := s.tokenRepo.WithTx(tx).RevokeTokens(t.UserID, models.TokenPurposePasswordReset); err != nil {
+			return err
+		}
+		userID = t.UserID
+		return users.MarkEmailVerified(t.UserID)
+	})
+	if err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllSessions(userID)
+}
+
+// issueToken creates a new token for the purpose, revoking the user's
+// earlier ones, and returns it in the form sent to the user
+func (s *accountService) issueToken(userID int, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		tokens := s.tokenRepo.WithTx(tx)
+		if err := tokens.RevokeTokens(userID, purpose); err != nil {
+			return err
+		}
+		return tokens.CreateToken(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeem checks that a token is valid for one of the purposes and marks it
+// used, so that it works only once
+func (s *accountService) redeem(tx *sql.Tx, token string, purposes ...models.TokenPurpose) (*models.UserToken, error) {
+	invalid := apierrors.NewBadRequestError("invalid or expired token")
+	tokens := s.tokenRepo.WithTx(tx)
+
+	t, err := tokens.GetTokenByHash(hashToken(token))
+	if err != nil {
+		if err.Error() == "token not found" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	allowed := false
+	for _, purpose := range purposes {
+		allowed = allowed || t.Purpose == purpose
+	}
+	if !allowed || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, invalid
+	}
+	if err := tokens.UseToken(t.ID); err != nil {
+		if err.Error() == "token already used" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *accountService) send(template string, user *models.User, link, token string, ttl time.Duration) error {
+	name := user.FullName
+	if name == "" {
+		name = user.Username
+	}
+	msg, err := renderEmail(template, user.Email, emailData{
+		Name:      name,
+		Link:      strings.ReplaceAll(link, "{token}", url.QueryEscape(token)),
+		ExpiresIn: humanDuration(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(msg)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/mail"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeTokenRepo struct {
+	repository.UserTokenRepository
+	tokens []*models.UserToken
+}
+
+func (r *fakeTokenRepo) WithTx(tx *sql.Tx) repository.UserTokenRepository { return r }
+
+func (r *fakeTokenRepo) CreateToken(token *models.UserToken) error {
+	token.ID = len(r.tokens) + 1
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakeTokenRepo) GetTokenByHash(hash string) (*models.UserToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			stored := *token
+			return &stored, nil
+		}
+	}
+	return nil, fmt.Errorf("token not found")
+}
+
+func (r *fakeTokenRepo) UseToken(id int) error {
+	token := r.tokens[id-1]
+	if token.UsedAt != nil {
+		return fmt.Errorf("token already used")
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return nil
+}
+
+func (r *fakeTokenRepo) RevokeTokens(userID int, purpose models.TokenPurpose) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeMailer struct {
+	sent []*mail.Message
+}
+
+func (m *fakeMailer) Send(msg *mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// token returns the token in the link of the last email sent
+func (m *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := regexp.MustCompile(`token=([^\s"&]+)`).FindStringSubmatch(m.sent[len(m.sent)-1].Text)
+	if match == nil {
+		t.Fatalf("no link in %q", m.sent[len(m.sent)-1].Text)
+	}
+	token, _ := url.QueryUnescape(match[1])
+	return token
+}
+
+// fakePasswords stores passwords as they are, refusing short ones
+type fakePasswords struct {
+	PasswordService
+	users *fakeUserRepo
+}
+
+func (p *fakePasswords) Set(tx *sql.Tx, user *models.User, newPassword string) error {
+	if len(newPassword) < 8 {
+		return apierrors.NewBadRequestError("password is too short")
+	}
+	return p.users.UpdatePassword(user.ID, newPassword)
+}
+
+type testAccountService struct {
+	*accountService
+	users    *fakeUserRepo
+	tokens   *fakeTokenRepo
+	sessions *fakeSessions
+	mailer   *fakeMailer
+}
+
+func newTestAccountService() *testAccountService {
+	users := newFakeUserRepo(&models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true})
+	f := &testAccountService{users: users, tokens: &fakeTokenRepo{}, sessions: &fakeSessions{}, mailer: &fakeMailer{}}
+	cfg := config.AccountsConfig{
+		VerificationTTL: 48 * time.Hour,
+		ResetTTL:        time.Hour,
+		VerifyURL:       "https://app.example.com/verify?token={token}",
+		ResetURL:        "https://app.example.com/reset?token={token}",
+	}
+	f.accountService = NewAccountService(users, f.tokens, &fakeTx{}, &fakeOutbox{}, &fakePasswords{users: users},
+		f.sessions, f.mailer, cfg).(*accountService)
+	return f
+}
+
+func wantBadRequest(t *testing.T, err error) {
+	t.Helper()
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != 400 {
+		t.Fatalf("err = %v, want a bad request", err)
+	}
+}
+
+func wantNotFound(t *testing.T, err error) {
+	t.Helper()
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != 404 {
+		t.Fatalf("err = %v, want not found", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestAccountService()
+
+	if err := s.ForgotPassword("ann@example.com"); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	msg := s.mailer.sent[0]
+	if msg.To != "ann@example.com" {
+		t.Errorf("sent to %s", msg.To)
+	}
+	token := s.mailer.token(t)
+	if s.tokens.tokens[0].TokenHash == token || s.tokens.tokens[0].TokenHash != hashToken(token) {
+		t.Error("the token must be stored hashed")
+	}
+
+	if err := s.ResetPassword(token, "new password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	user := s.users.users[1]
+	if user.PasswordHash != "new password" || user.EmailVerifiedAt == nil {
+		t.Errorf("password %q, verified %v; want the new password and the address verified", user.PasswordHash, user.EmailVerifiedAt)
+	}
+	if len(s.sessions.revoked) != 1 || s.sessions.revoked[0] != 1 {
+		t.Errorf("revoked sessions of %v, want the user logged out everywhere", s.sessions.revoked)
+	}
+
+	wantBadRequest(t, s.ResetPassword(token, "another password"))
+}
+
+func TestForgotPasswordRevealsNothingAboutUnknownAddresses(t *testing.T) {
+	s := newTestAccountService()
+
+	if err := s.ForgotPassword("nobody@example.com"); err != nil {
+		t.Errorf("ForgotPassword = %v, want no error", err)
+	}
+	if len(s.mailer.sent) != 0 {
+		t.Error("sent an email for an unknown address")
+	}
+}
+
+func TestPasswordResetTokens(t *testing.T) {
+	t.Run("a new request replaces the old link", func(t *testing.T) {
+		s := newTestAccountService()
+		s.ForgotPassword("ann@example.com")
+		first := s.mailer.token(t)
+		s.ForgotPassword("ann@example.com")
+
+		wantBadRequest(t, s.ResetPassword(first, "new password"))
+		if err := s.ResetPassword(s.mailer.token(t), "new password"); err != nil {
+			t.Errorf("ResetPassword with the new link: %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		s := newTestAccountService()
+		s.ForgotPassword("ann@example.com")
+		s.tokens.tokens[0].ExpiresAt = time.Now().Add(-time.Second)
+
+		wantBadRequest(t, s.ResetPassword(s.mailer.token(t), "new password"))
+	})
+
+	t.Run("made for another purpose", func(t *testing.T) {
+		s := newTestAccountService()
+		s.SendVerification(s.users.users[1])
+
+		wantBadRequest(t, s.ResetPassword(s.mailer.token(t), "new password"))
+		if s.users.users[1].PasswordHash != "" {
+			t.Error("a verification link reset the password")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		s := newTestAccountService()
+		wantBadRequest(t, s.ResetPassword("made-up", "new password"))
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s := newTestAccountService()
+
+	if err := s.SendVerification(s.users.users[1]); err != nil {
+		t.Fatalf("SendVerification: %v", err)
+	}
+	if err := s.VerifyEmail(s.mailer.token(t)); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if s.users.users[1].EmailVerifiedAt == nil {
+		t.Error("address not verified")
+	}
+	wantBadRequest(t, s.VerifyEmail(s.mailer.token(t)))
+}
+
+func TestChangeEmailNeedsTheNewAddressConfirmed(t *testing.T) {
+	s := newTestAccountService()
+	s.users.users[1].PasswordHash = mustHash(t, "old password")
+
+	change := &models.EmailChange{Email: "ann@example.org", Password: "old password"}
+	if err := s.RequestEmailChange(1, change); err != nil {
+		t.Fatalf("RequestEmailChange: %v", err)
+	}
+	if to := s.mailer.sent[0].To; to != "ann@example.org" {
+		t.Errorf("confirmation sent to %s, want the new address", to)
+	}
+	if s.users.users[1].Email != "ann@example.com" {
+		t.Fatal("address changed before it was confirmed")
+	}
+
+	if err := s.VerifyEmail(s.mailer.token(t)); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if s.users.users[1].Email != "ann@example.org" {
+		t.Errorf("email = %s, want the new address", s.users.users[1].Email)
+	}
+}
+
+func mustHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
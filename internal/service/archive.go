@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"log"
+	"task-management-api/config"
+	"time"
+)
+
+// StartAutoArchiver archives the top-level tasks that have been DONE, with
+// all of their subtasks, for longer than cfg.AutoArchiveAfter, checking
+// every interval until ctx is done. It does nothing when AutoArchiveAfter is
+// zero. The service must not be confined to a tenant.
+func StartAutoArchiver(ctx context.Context, tasks TaskService, cfg config.ArchiveConfig) {
+	if cfg.AutoArchiveAfter <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := tasks.ArchiveCompletedTasks(time.Now().Add(-cfg.AutoArchiveAfter)); err != nil {
+					log.Printf("Error archiving completed tasks: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+// done is a DONE task last updated at the given time
+func done(id int, parentID *int, updatedAt time.Time) *models.Task {
+	task := todo(id, parentID)
+	task.Status, task.UpdatedAt = models.TaskStatusDone, updatedAt
+	return task
+}
+
+func TestArchiveTask(t *testing.T) {
+	now := time.Now()
+	f := newTestTaskService(t, done(1, nil, now), done(2, intPtr(1), now), todo(3, nil), done(4, nil, now), todo(5, intPtr(4)))
+
+	_, err := f.ArchiveTask(3)
+	wantStatus(t, err, 409)
+	// Nor with a subtask still open
+	_, err = f.ArchiveTask(4)
+	wantStatus(t, err, 409)
+	_, err = f.ArchiveTask(9)
+	wantStatus(t, err, 404)
+	if len(f.outbox.events) != 0 {
+		t.Errorf("events = %v, want none", eventTypes(f.outbox.events))
+	}
+
+	archived, err := f.ArchiveTask(1)
+	if err != nil {
+		t.Fatalf("ArchiveTask: %v", err)
+	}
+	if archived.ID != 1 || archived.ArchivedAt == nil {
+		t.Errorf("ArchiveTask = %+v, want task 1 archived", archived)
+	}
+	if got := eventTypes(f.outbox.events); len(got) != 2 || got[0] != events.TaskArchived || got[1] != events.TaskArchived {
+		t.Errorf("events = %v, want the task and its subtask archived", got)
+	}
+	if _, err := f.GetTaskByID(2); err == nil {
+		t.Error("the archived subtask is still among the live tasks")
+	}
+	// Archived tasks stay searchable
+	if indexed := f.index.indexed[2]; indexed == nil || indexed.ArchivedAt == nil {
+		t.Errorf("indexed subtask = %+v, want it archived", indexed)
+	}
+}
+
+func TestUnarchiveTask(t *testing.T) {
+	now := time.Now()
+	f := newTestTaskService(t, done(1, nil, now), done(2, intPtr(1), now), todo(3, nil))
+	if _, err := f.ArchiveTask(1); err != nil {
+		t.Fatalf("ArchiveTask: %v", err)
+	}
+	f.outbox.events = nil
+
+	// The subtask cannot come back without its parent
+	_, err := f.UnarchiveTask(2)
+	wantStatus(t, err, 409)
+	_, err = f.UnarchiveTask(3)
+	wantStatus(t, err, 404)
+
+	unarchived, err := f.UnarchiveTask(1)
+	if err != nil || unarchived.ID != 1 || unarchived.ArchivedAt != nil {
+		t.Fatalf("UnarchiveTask = %+v, %v", unarchived, err)
+	}
+	if got := eventTypes(f.outbox.events); len(got) != 2 || got[0] != events.TaskUnarchived {
+		t.Errorf("events = %v, want the task and its subtask unarchived", got)
+	}
+	if _, err := f.GetTaskByID(2); err != nil {
+		t.Errorf("the subtask was not unarchived: %v", err)
+	}
+}
+
+func TestArchiveCompletedTasks(t *testing.T) {
+	old, recent := time.Now().Add(-48*time.Hour), time.Now()
+	f := newTestTaskService(t,
+		// Done long ago, with a subtask done long ago
+		done(1, nil, old), done(2, intPtr(1), old),
+		// Done long ago, but a subtask was finished recently
+		done(3, nil, old), done(4, intPtr(3), recent),
+		// Done long ago, with a subtask still open
+		done(5, nil, old), todo(6, intPtr(5)),
+		// Done recently
+		done(7, nil, recent),
+	)
+
+	if err := f.ArchiveCompletedTasks(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("ArchiveCompletedTasks: %v", err)
+	}
+	for id := 1; id <= 7; id++ {
+		_, err := f.repo.GetArchivedTask(id)
+		if archived, want := err == nil, id <= 2; archived != want {
+			t.Errorf("task %d archived = %v, want %v", id, archived, want)
+		}
+	}
+}
+
+func TestAutoArchiver(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	f := newTestTaskService(t, done(1, nil, old), done(2, nil, time.Now()))
+
+	mu := &sync.Mutex{}
+	archived := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartAutoArchiver(ctx, lockedArchiver{f.taskService, mu, archived},
+		config.ArchiveConfig{AutoArchiveAfter: 24 * time.Hour, Interval: time.Millisecond})
+	select {
+	case <-archived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no tasks were ever archived")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := f.repo.GetArchivedTask(1); err != nil {
+		t.Errorf("task 1 was not archived: %v", err)
+	}
+	if _, err := f.repo.GetTaskByID(2); err != nil {
+		t.Errorf("the recently completed task 2 was archived: %v", err)
+	}
+}
+
+func TestAutoArchiverDisabled(t *testing.T) {
+	// Without AutoArchiveAfter the service is never called
+	StartAutoArchiver(context.Background(), nil, config.ArchiveConfig{Interval: time.Millisecond})
+	time.Sleep(10 * time.Millisecond)
+}
+
+// lockedArchiver archives under a lock, so that a test can look at the fakes
+// while the archiver is running
+type lockedArchiver struct {
+	TaskService
+	mu *sync.Mutex
+	// archived is signalled after each round
+	archived chan struct{}
+}
+
+func (s lockedArchiver) ArchiveCompletedTasks(before time.Time) error {
+	s.mu.Lock()
+	err := s.TaskService.ArchiveCompletedTasks(before)
+	s.mu.Unlock()
+	select {
+	case s.archived <- struct{}{}:
+	default:
+	}
+	return err
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/storage"
+)
+
+// AttachmentService defines the interface for task file attachments
+type AttachmentService interface {
+	Upload(taskID int, fileName string, file io.ReadSeeker, size int64, uploadedBy int) (*models.Attachment, error)
+	ListAttachments(taskID int) ([]*models.Attachment, error)
+	GetAttachment(taskID, id int) (*models.Attachment, error)
+	OpenAttachment(attachment *models.Attachment) io.ReadSeekCloser
+	DeleteAttachment(taskID, id int) error
+	TaskChecksums(taskIDs []int) ([]string, error)
+	ReleaseBlobs(checksums []string)
+	// ForTenant returns a copy of the service confined to the tasks of the
+	// given organisation
+	ForTenant(orgID int) AttachmentService
+}
+
+type attachmentService struct {
+	attachmentRepo repository.AttachmentRepository
+	taskRepo       repository.TaskRepository
+	blobs          storage.Storage
+	tx             repository.TxRunner
+	maxSize        int64
+	allowedTypes   map[string]bool
+}
+
+// NewAttachmentService creates a new AttachmentService. An empty allowedTypes
+// accepts any content type.
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, taskRepo repository.TaskRepository,
+	blobs storage.Storage, tx repository.TxRunner, maxSize int64, allowedTypes []string) AttachmentService {
+	allowed := make(map[string]bool, len(allowedTypes))
+	for _, t := range allowedTypes {
+		allowed[t] = true
+	}
+	return &attachmentService{
+		attachmentRepo: attachmentRepo,
+		taskRepo:       taskRepo,
+		blobs:          blobs,
+		tx:             tx,
+		maxSize:        maxSize,
+		allowedTypes:   allowed,
+	}
+}
+
+func (s *attachmentService) ForTenant(orgID int) AttachmentService {
+	scoped := *s
+	scoped.taskRepo = s.taskRepo.ForTenant(orgID)
+	return &scoped
+}
+
+func (s *attachmentService) Upload(taskID int, fileName string, file io.ReadSeeker, size int64, uploadedBy int) (*models.Attachment, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	if s.maxSize > 0 && size > s.maxSize {
+		return nil, apierrors.NewPayloadTooLargeError(fmt.Sprintf("file exceeds the maximum size of %d bytes", s.maxSize))
+	}
+
+	// Detect the type from the contents rather than trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("error reading upload: %v", err)
+	}
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+	if contentType == "text/plain" {
+		if byName := textTypeByExtension(fileName); byName != "" {
+			contentType = byName
+		}
+	}
+	if len(s.allowedTypes) > 0 && !s.allowedTypes[contentType] {
+		return nil, apierrors.NewUnsupportedMediaTypeError(fmt.Sprintf("file type %s is not allowed", contentType))
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error rewinding upload: %v", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("error hashing upload: %v", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	attachment := &models.Attachment{
+		TaskID:      taskID,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		Size:        size,
+		Checksum:    checksum,
+	}
+	if uploadedBy > 0 {
+		attachment.UploadedBy = &uploadedBy
+	}
+
+	stored := false
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.attachmentRepo.WithTx(tx)
+		// Holding the blob's row keeps ReleaseBlobs from deleting the blob
+		// between the check below and the attachment being recorded
+		if err := repo.LockBlob(checksum); err != nil {
+			return err
+		}
+
+		// Identical files are only stored once
+		exists, err := s.blobs.Exists(checksum)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("error rewinding upload: %v", err)
+			}
+			if err := s.blobs.Put(checksum, file, size, contentType); err != nil {
+				return err
+			}
+			stored = true
+		}
+		return repo.CreateAttachment(attachment)
+	})
+	if err != nil {
+		if stored {
+			s.ReleaseBlobs([]string{checksum})
+		}
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+func (s *attachmentService) ListAttachments(taskID int) ([]*models.Attachment, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	return s.attachmentRepo.ListAttachments(taskID)
+}
+
+func (s *attachmentService) GetAttachment(taskID, id int) (*models.Attachment, error) {
+	// Attachments are not scoped themselves, so the task is what keeps other
+	// organisations and the trash out
+	if _, err := s.taskRepo.GetTaskByID(taskID); err != nil {
+		if err.Error() == "task not found" {
+			return nil, apierrors.NewNotFoundError("attachment not found")
+		}
+		return nil, err
+	}
+	attachment, err := s.attachmentRepo.GetAttachmentByID(id)
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	if attachment.TaskID != taskID {
+		return nil, apierrors.NewNotFoundError("attachment not found")
+	}
+	return attachment, nil
+}
+
+// OpenAttachment returns a seekable reader over the attachment contents,
+// suitable for serving range requests
+func (s *attachmentService) OpenAttachment(attachment *models.Attachment) io.ReadSeekCloser {
+	return storage.NewReadSeeker(s.blobs, attachment.Checksum, attachment.Size)
+}
+
+func (s *attachmentService) DeleteAttachment(taskID, id int) error {
+	attachment, err := s.GetAttachment(taskID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentRepo.DeleteAttachment(attachment.ID); err != nil {
+		return err
+	}
+	s.ReleaseBlobs([]string{attachment.Checksum})
+	return nil
+}
+
+// TaskChecksums returns the blobs used by the attachments of the given tasks,
+// to be passed to ReleaseBlobs once the tasks are gone
+func (s *attachmentService) TaskChecksums(taskIDs []int) ([]string, error) {
+	return s.attachmentRepo.GetChecksumsForTasks(taskIDs)
+}
+
+// ReleaseBlobs deletes the blobs that are no longer referenced by any
+// attachment. Each blob is checked and deleted while its row is locked, so
+// that an upload of the same file waits instead of reusing a blob that is
+// going away. Failures are only logged: a leftover blob wastes space but
+// does not break anything.
+func (s *attachmentService) ReleaseBlobs(checksums []string) {
+	for _, checksum := range checksums {
+		err := s.tx.RunInTx(func(tx *sql.Tx) error {
+			repo := s.attachmentRepo.WithTx(tx)
+			if err := repo.LockBlob(checksum); err != nil {
+				return err
+			}
+			count, err := repo.CountByChecksum(checksum)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+			if err := s.blobs.Delete(checksum); err != nil {
+				return err
+			}
+			return repo.DeleteBlob(checksum)
+		})
+		if err != nil {
+			log.Printf("Error releasing blob %s: %v", checksum, err)
+		}
+	}
+}
+
+// textTypeByExtension returns the content type implied by the extension of a
+// text file. Sniffing the contents cannot tell CSV or JSON from plain text.
+// Types that browsers would render, like HTML, are never taken from the
+// name.
+func textTypeByExtension(fileName string) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == ".csv" {
+		// Not in Go's built-in table, and /etc/mime.types may be missing
+		return "text/csv"
+	}
+	contentType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return ""
+	}
+	switch {
+	case contentType == "text/html", contentType == "text/xml":
+		return ""
+	case strings.HasPrefix(contentType, "text/"), contentType == "application/json":
+		return contentType
+	}
+	return ""
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+)
+
+// attachmentLog records the blob locks, storage calls and attachment writes
+// of a test in the order they happen
+type attachmentLog []string
+
+func (l *attachmentLog) add(format string, args ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+type fakeAttachmentRepo struct {
+	repository.AttachmentRepository
+	log         *attachmentLog
+	attachments map[int]*models.Attachment
+	failCreate  bool
+}
+
+func (r *fakeAttachmentRepo) WithTx(tx *sql.Tx) repository.AttachmentRepository { return r }
+
+func (r *fakeAttachmentRepo) LockBlob(checksum string) error {
+	r.log.add("lock %s", checksum[:6])
+	return nil
+}
+
+func (r *fakeAttachmentRepo) DeleteBlob(checksum string) error { return nil }
+
+func (r *fakeAttachmentRepo) CreateAttachment(attachment *models.Attachment) error {
+	if r.failCreate {
+		return fmt.Errorf("database is down")
+	}
+	attachment.ID = len(r.attachments) + 1
+	r.attachments[attachment.ID] = attachment
+	r.log.add("create %d", attachment.ID)
+	return nil
+}
+
+func (r *fakeAttachmentRepo) DeleteAttachment(id int) error {
+	delete(r.attachments, id)
+	return nil
+}
+
+func (r *fakeAttachmentRepo) GetAttachmentByID(id int) (*models.Attachment, error) {
+	attachment, ok := r.attachments[id]
+	if !ok {
+		return nil, fmt.Errorf("attachment not found")
+	}
+	return attachment, nil
+}
+
+func (r *fakeAttachmentRepo) CountByChecksum(checksum string) (int, error) {
+	count := 0
+	for _, attachment := range r.attachments {
+		if attachment.Checksum == checksum {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type fakeBlobs struct {
+	log   *attachmentLog
+	blobs map[string][]byte
+}
+
+func (b *fakeBlobs) Put(key string, r io.Reader, size int64, contentType string) error {
+	data, _ := io.ReadAll(r)
+	b.blobs[key] = data
+	b.log.add("put %s", key[:6])
+	return nil
+}
+
+func (b *fakeBlobs) Open(key string, offset int64) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b.blobs[key][offset:])), nil
+}
+
+func (b *fakeBlobs) Exists(key string) (bool, error) {
+	b.log.add("exists %s", key[:6])
+	_, ok := b.blobs[key]
+	return ok, nil
+}
+
+func (b *fakeBlobs) Delete(key string) error {
+	delete(b.blobs, key)
+	b.log.add("delete %s", key[:6])
+	return nil
+}
+
+func newTestAttachmentService(allowedTypes ...string) (*attachmentService, *fakeAttachmentRepo, *fakeBlobs, *attachmentLog) {
+	log := &attachmentLog{}
+	repo := &fakeAttachmentRepo{log: log, attachments: map[int]*models.Attachment{}}
+	blobs := &fakeBlobs{log: log, blobs: map[string][]byte{}}
+	s := NewAttachmentService(repo, newFakeTaskRepo(todo(1, nil)), blobs, &fakeTx{}, 1<<20, allowedTypes)
+	return s.(*attachmentService), repo, blobs, log
+}
+
+func upload(t *testing.T, s AttachmentService, name, content string) (*models.Attachment, error) {
+	t.Helper()
+	return s.Upload(1, name, strings.NewReader(content), int64(len(content)), 7)
+}
+
+func TestUploadStoresIdenticalFilesOnce(t *testing.T) {
+	s, _, blobs, log := newTestAttachmentService()
+
+	first, err := upload(t, s, "a.txt", "same contents")
+	if err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+	second, err := upload(t, s, "b.txt", "same contents")
+	if err != nil {
+		t.Fatalf("second upload: %v", err)
+	}
+
+	if first.Checksum != second.Checksum || len(blobs.blobs) != 1 {
+		t.Errorf("stored %d blobs for identical files, want 1", len(blobs.blobs))
+	}
+	sum := first.Checksum[:6]
+	want := []string{"lock " + sum, "exists " + sum, "put " + sum, "create 1", "lock " + sum, "exists " + sum, "create 2"}
+	if strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want %v", *log, want)
+	}
+}
+
+func TestReleaseBlobsKeepsBlobsInUse(t *testing.T) {
+	s, _, blobs, log := newTestAttachmentService()
+	first, _ := upload(t, s, "a.txt", "same contents")
+	second, _ := upload(t, s, "b.txt", "same contents")
+
+	if err := s.DeleteAttachment(1, first.ID); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if len(blobs.blobs) != 1 {
+		t.Fatal("deleted a blob another attachment still uses")
+	}
+
+	*log = nil
+	if err := s.DeleteAttachment(1, second.ID); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("kept a blob no attachment uses")
+	}
+	sum := second.Checksum[:6]
+	if want := []string{"lock " + sum, "delete " + sum}; strings.Join(*log, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want the blob locked before it is deleted", *log)
+	}
+}
+
+func TestUploadReleasesNewBlobWhenRecordingFails(t *testing.T) {
+	s, repo, blobs, _ := newTestAttachmentService()
+	repo.failCreate = true
+
+	if _, err := upload(t, s, "a.txt", "contents"); err == nil {
+		t.Fatal("upload succeeded without its attachment being recorded")
+	}
+	if len(blobs.blobs) != 0 {
+		t.Error("left the blob of a failed upload behind")
+	}
+}
+
+func TestUploadContentTypes(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"notes.txt", "plain text", "text/plain"},
+		{"table.csv", "a,b\n1,2\n", "text/csv"},
+		{"data.json", `{"a": 1}`, "application/json"},
+		{"page.html", "just text", "text/plain"},
+		{"image.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+		// The name cannot turn binary data into text
+		{"fake.csv", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, _ := newTestAttachmentService()
+			attachment, err := upload(t, s, tt.name, tt.content)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if attachment.ContentType != tt.want {
+				t.Errorf("content type = %s, want %s", attachment.ContentType, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadRefusesTypesNotAllowed(t *testing.T) {
+	s, _, blobs, _ := newTestAttachmentService("text/csv")
+
+	if _, err := upload(t, s, "table.csv", "a,b\n"); err != nil {
+		t.Errorf("refused an allowed CSV file: %v", err)
+	}
+	_, err := upload(t, s, "notes.txt", "plain text")
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != 415 {
+		t.Errorf("Upload of text/plain = %v, want 415", err)
+	}
+	if len(blobs.blobs) != 1 {
+		t.Errorf("%d blobs stored, want only the allowed one", len(blobs.blobs))
+	}
+}
+
+// tenantTaskRepo is a task repository whose ForTenant copies only see the
+// tasks of their organisation
+type tenantTaskRepo struct {
+	*fakeTaskRepo
+	orgID int
+}
+
+func (r tenantTaskRepo) ForTenant(orgID int) repository.TaskRepository {
+	return tenantTaskRepo{r.fakeTaskRepo, orgID}
+}
+
+func (r tenantTaskRepo) GetTaskByID(id int) (*models.Task, error) {
+	task, err := r.fakeTaskRepo.GetTaskByID(id)
+	if err == nil && task.OrgID != r.orgID {
+		return nil, fmt.Errorf("task not found")
+	}
+	return task, err
+}
+
+func TestAttachmentsForTenant(t *testing.T) {
+	ours, theirs := todo(1, nil), todo(2, nil)
+	ours.OrgID, theirs.OrgID = 1, 2
+	tasks := newFakeTaskRepo(ours, theirs)
+	repo := &fakeAttachmentRepo{log: &attachmentLog{}, attachments: map[int]*models.Attachment{
+		1: {ID: 1, TaskID: 1, Checksum: "aaaaaa"},
+		2: {ID: 2, TaskID: 2, Checksum: "bbbbbb"},
+	}}
+	blobs := &fakeBlobs{log: repo.log, blobs: map[string][]byte{}}
+	s := NewAttachmentService(repo, tenantTaskRepo{tasks, 0}, blobs, &fakeTx{}, 1<<20, nil).ForTenant(1)
+
+	if _, err := s.GetAttachment(1, 1); err != nil {
+		t.Errorf("GetAttachment of our attachment: %v", err)
+	}
+	_, err := s.GetAttachment(2, 2)
+	wantStatus(t, err, 404)
+	wantStatus(t, s.DeleteAttachment(2, 2), 404)
+	if repo.attachments[2] == nil {
+		t.Error("deleted the attachment of another organisation")
+	}
+
+	// Nor are the attachments of a task in the trash reachable
+	if err := tasks.DeleteTask(1); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	_, err = s.GetAttachment(1, 1)
+	wantStatus(t, err, 404)
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"log"
+	"task-management-api/internal/events"
+	"task-management-api/internal/repository"
+)
+
+// audit records a security event that has no other change to go with.
+// Failing to record it is logged rather than failing the request.
+func audit(outbox repository.OutboxRepository, eventType string, data events.SecurityData) {
+	if err := outbox.Append(events.New(eventType, data)); err != nil {
+		log.Printf("Error recording %s event for user %d: %v", eventType, data.UserID, err)
+	}
+}
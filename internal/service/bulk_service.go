@@ -0,0 +1,265 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+)
+
+// BulkService runs many task operations in a single transaction
+type BulkService interface {
+	// Run applies the operations in order on behalf of the actor, checking
+	// for every task that the actor may change it
+	Run(req *models.BulkRequest, actor models.Actor) (*models.BulkResult, error)
+	// ForTenant returns a copy of the service confined to the tasks of the
+	// given organisation
+	ForTenant(orgID int) BulkService
+}
+
+type bulkService struct {
+	tasks     TxTaskService
+	labelRepo repository.LabelRepository
+	userRepo  repository.UserRepository
+	tx        repository.TxRunner
+	maxItems  int
+	orgID     int
+}
+
+// errRolledBack aborts the transaction of an atomic bulk request after one
+// of its operations failed
+var errRolledBack = errors.New("bulk request rolled back")
+
+// NewBulkService creates a new BulkService. The operations go through tasks,
+// so that they are checked and recorded like any other change.
+func NewBulkService(tasks TxTaskService, labelRepo repository.LabelRepository, userRepo repository.UserRepository,
+	tx repository.TxRunner, maxItems int) BulkService {
+	return &bulkService{tasks: tasks, labelRepo: labelRepo, userRepo: userRepo, tx: tx, maxItems: maxItems}
+}
+
+func (s *bulkService) ForTenant(orgID int) BulkService {
+	scoped := *s
+	scoped.tasks = s.tasks.ForTenantTx(orgID)
+	scoped.labelRepo = s.labelRepo.ForTenant(orgID)
+	scoped.orgID = orgID
+	return &scoped
+}
+
+func (s *bulkService) Run(req *models.BulkRequest, actor models.Actor) (*models.BulkResult, error) {
+	if s.maxItems > 0 && len(req.Operations) > s.maxItems {
+		return nil, apierrors.NewBadRequestError(fmt.Sprintf("a bulk request may contain at most %d operations", s.maxItems))
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = models.BulkModeAtomic
+	}
+
+	result := &models.BulkResult{Mode: mode, Results: []*models.BulkItemResult{}}
+	var refreshed []int
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		tasks := s.tasks.JoinTx(tx, &refreshed)
+		for i, op := range req.Operations {
+			// In partial mode a failed operation only undoes its own changes
+			if mode == models.BulkModePartial {
+				if _, err := tx.Exec(`SAVEPOINT bulk_operation`); err != nil {
+					return fmt.Errorf("error creating savepoint: %v", err)
+				}
+			}
+
+			item := &models.BulkItemResult{Index: i, Op: op.Op, ID: op.ID}
+			result.Results = append(result.Results, item)
+			task, err := s.apply(tx, tasks, op, actor)
+			if err == nil {
+				item.ID = task.ID
+				item.Status = http.StatusOK
+				if op.Op == models.BulkCreate {
+					item.Status = http.StatusCreated
+				}
+				if op.Op != models.BulkDelete {
+					item.Task = task
+				}
+				result.Succeeded++
+				if mode == models.BulkModePartial {
+					if _, err := tx.Exec(`RELEASE SAVEPOINT bulk_operation`); err != nil {
+						return fmt.Errorf("error releasing savepoint: %v", err)
+					}
+				}
+				continue
+			}
+
+			item.Status, item.Error = bulkError(err)
+			result.Failed++
+			if mode == models.BulkModeAtomic {
+				if item.Status >= http.StatusInternalServerError {
+					return err
+				}
+				return errRolledBack
+			}
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT bulk_operation`); err != nil {
+				return fmt.Errorf("error rolling back to savepoint: %v", err)
+			}
+		}
+		return nil
+	})
+
+	// Whatever was committed or rolled back, the index has to match it
+	for _, id := range refreshed {
+		s.tasks.Refresh(id)
+	}
+
+	if err == errRolledBack {
+		for _, item := range result.Results[:len(result.Results)-1] {
+			item.Status = http.StatusFailedDependency
+			item.Error = "rolled back"
+			item.Task = nil
+		}
+		result.Succeeded = 0
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Committed = true
+	return result, nil
+}
+
+// apply runs one operation and returns the task it created, changed or
+// deleted
+func (s *bulkService) apply(tx *sql.Tx, tasks TxTaskService, op *models.BulkOperation,
+	actor models.Actor) (*models.Task, error) {
+	labels := s.labelRepo.WithTx(tx)
+	if op.Op == models.BulkCreate {
+		if op.Task == nil {
+			return nil, apierrors.NewBadRequestError("task is required to create a task")
+		}
+		task := *op.Task
+		task.ID = 0
+		task.UserID = &actor.UserID
+		if err := tasks.CreateTask(&task); err != nil {
+			return nil, err
+		}
+		if err := s.changeLabels(labels, tasks, task.ID, op.AddLabels, op.RemoveLabels); err != nil {
+			return nil, err
+		}
+		return &task, nil
+	}
+
+	current, err := tasks.GetTaskByID(op.ID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	// Label changes do not go through UpdateTask, so the task is checked
+	// before anything is changed
+	if err := tasks.CheckChange(current, actor, nil); err != nil {
+		return nil, err
+	}
+
+	if op.Op == models.BulkDelete {
+		if err := tasks.DeleteTask(op.ID, actor); err != nil {
+			return nil, err
+		}
+		return current, nil
+	}
+
+	task := *current
+	task.KeepParent = true
+	changed := false
+	if op.Status != nil {
+		task.Status = *op.Status
+		changed = true
+	}
+	if op.Priority != nil {
+		task.Priority = *op.Priority
+		changed = true
+	}
+	assign := false
+	if op.Unassign {
+		task.UserID = nil
+		assign = true
+	} else if op.UserID != nil {
+		if err := tasks.CheckChange(current, actor, op.UserID); err != nil {
+			return nil, err
+		}
+		if err := s.checkAssignee(tx, *op.UserID); err != nil {
+			return nil, err
+		}
+		task.UserID = op.UserID
+		assign = true
+	}
+	if changed || assign {
+		if err := tasks.UpdateTaskAndAssignee(&task, actor, assign); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.changeLabels(labels, tasks, task.ID, op.AddLabels, op.RemoveLabels); err != nil {
+		return nil, err
+	}
+	return tasks.GetTaskByID(task.ID)
+}
+
+// checkAssignee refuses to give a task to a user outside the organisation
+func (s *bulkService) checkAssignee(tx *sql.Tx, userID int) error {
+	if _, err := s.userRepo.WithTx(tx).ForTenant(s.orgID).GetUserByID(userID); err != nil {
+		if err.Error() == "user not found" {
+			return apierrors.NewBadRequestError("assignee not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// changeLabels attaches and detaches labels, refusing labels of another
+// project like LabelService.AttachLabel does
+func (s *bulkService) changeLabels(labels repository.LabelRepository, tasks taskGetter, taskID int,
+	add, remove []int) error {
+	if len(add) > 0 {
+		projectID, err := projectOf(tasks, taskID)
+		if err != nil {
+			return err
+		}
+		for _, labelID := range add {
+			label, err := labels.GetLabelByID(labelID)
+			if err != nil {
+				if err.Error() == "label not found" {
+					return apierrors.NewBadRequestError(fmt.Sprintf("label %d not found", labelID))
+				}
+				return err
+			}
+			if label.ProjectID != nil && *label.ProjectID != projectID {
+				return apierrors.NewBadRequestError(fmt.Sprintf("label %d belongs to a different project", labelID))
+			}
+			if err := labels.AttachLabel(taskID, labelID); err != nil {
+				return err
+			}
+		}
+	}
+	for _, labelID := range remove {
+		if err := labels.DetachLabel(taskID, labelID); err != nil {
+			if err.Error() == "label not attached to task" {
+				return apierrors.NewNotFoundError(fmt.Sprintf("label %d not attached to task", labelID))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkError turns the error of a failed operation into the HTTP status and
+// message it would have had on its own
+func bulkError(err error) (int, string) {
+	if apiErr, ok := err.(*apierrors.APIError); ok {
+		return apiErr.StatusCode, apiErr.Message
+	}
+	if err.Error() == "task not found" {
+		return http.StatusNotFound, err.Error()
+	}
+	log.Printf("Error in bulk operation: %v", err)
+	return http.StatusInternalServerError, "internal error"
+}
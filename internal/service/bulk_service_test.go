@@ -0,0 +1,286 @@
+package service
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+// savepointDriver is a database whose statements do nothing but are passed
+// to exec, so that a test can play out savepoints on its fakes
+type savepointDriver struct {
+	exec func(query string)
+}
+
+func (d *savepointDriver) Open(name string) (driver.Conn, error) { return savepointConn{d}, nil }
+
+type savepointConn struct{ d *savepointDriver }
+
+func (c savepointConn) Prepare(query string) (driver.Stmt, error) {
+	return savepointStmt{c.d, query}, nil
+}
+func (c savepointConn) Close() error              { return nil }
+func (c savepointConn) Begin() (driver.Tx, error) { return savepointDriverTx{}, nil }
+
+type savepointStmt struct {
+	d     *savepointDriver
+	query string
+}
+
+func (s savepointStmt) Close() error  { return nil }
+func (s savepointStmt) NumInput() int { return -1 }
+
+func (s savepointStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.exec(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s savepointStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("savepointDriver: queries are not supported")
+}
+
+type savepointDriverTx struct{}
+
+func (savepointDriverTx) Commit() error   { return nil }
+func (savepointDriverTx) Rollback() error { return nil }
+
+// savepointTx runs functions in transactions of a savepointDriver, putting
+// the tasks of repo back as they were when a function fails or a savepoint
+// is rolled back to
+type savepointTx struct {
+	db         *sql.DB
+	repo       *fakeTaskRepo
+	saved      map[int]*models.Task
+	savedID    int
+	statements []string
+}
+
+func newSavepointTx(t *testing.T, repo *fakeTaskRepo) *savepointTx {
+	t.Helper()
+	runner := &savepointTx{repo: repo}
+	name := fmt.Sprintf("savepoint-%s-%d", t.Name(), time.Now().UnixNano())
+	sql.Register(name, &savepointDriver{exec: runner.exec})
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	runner.db = db
+	return runner
+}
+
+func (r *savepointTx) exec(query string) {
+	r.statements = append(r.statements, query)
+	switch query {
+	case "SAVEPOINT bulk_operation":
+		r.saved, r.savedID = r.repo.snapshot()
+	case "ROLLBACK TO SAVEPOINT bulk_operation":
+		r.repo.tasks, r.repo.nextID = r.saved, r.savedID
+	}
+}
+
+func (r *savepointTx) RunInTx(fn func(tx *sql.Tx) error) error {
+	tasks, nextID := r.repo.snapshot()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		r.repo.tasks, r.repo.nextID = tasks, nextID
+		return err
+	}
+	return tx.Commit()
+}
+
+type testBulkService struct {
+	BulkService
+	tasks  *testTaskService
+	labels *fakeLabelRepo
+	tx     *savepointTx
+}
+
+// newTestBulkService has tasks 1 and 2 owned by user 7 and task 3 by user 8;
+// label 10 belongs to project 1
+func newTestBulkService(t *testing.T) *testBulkService {
+	t.Helper()
+	tasks := []*models.Task{todo(1, nil), todo(2, nil), todo(3, nil)}
+	tasks[0].UserID, tasks[1].UserID, tasks[2].UserID = intPtr(7), intPtr(7), intPtr(8)
+	f := &testBulkService{
+		tasks:  newTestTaskService(t, tasks...),
+		labels: newFakeLabelRepo(&models.Label{ID: 10, Name: "of 1", ProjectID: intPtr(1)}),
+	}
+	f.tx = newSavepointTx(t, f.tasks.repo)
+	users := newFakeUserRepo(
+		&models.User{ID: 7, Username: "ann", Email: "ann@example.com", IsActive: true},
+		&models.User{ID: 8, Username: "bob", Email: "bob@example.com", IsActive: true},
+	)
+	f.BulkService = NewBulkService(f.tasks.taskService, f.labels, users, f.tx, 5)
+	return f
+}
+
+func statusPtr(status models.TaskStatus) *models.TaskStatus {
+	return &status
+}
+
+func itemStatuses(result *models.BulkResult) []int {
+	statuses := make([]int, len(result.Results))
+	for i, item := range result.Results {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBulkAtomic(t *testing.T) {
+	f := newTestBulkService(t)
+	result, err := f.Run(&models.BulkRequest{Operations: []*models.BulkOperation{
+		{Op: models.BulkCreate, Task: &models.Task{Title: "new", Status: models.TaskStatusTodo}},
+		{Op: models.BulkUpdate, ID: 1, Status: statusPtr(models.TaskStatusDone), AddLabels: []int{10}},
+		{Op: models.BulkDelete, ID: 2},
+	}}, models.Actor{UserID: 7})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Committed || result.Succeeded != 3 ||
+		!sameInts(itemStatuses(result), []int{http.StatusCreated, http.StatusOK, http.StatusOK}) {
+		t.Fatalf("result = %+v, statuses %v", result, itemStatuses(result))
+	}
+
+	created := result.Results[0].Task
+	if created == nil || created.ID != 4 || *created.UserID != 7 {
+		t.Errorf("created %+v, want task 4 owned by the actor", created)
+	}
+	if task, _ := f.tasks.repo.GetTaskByID(1); task.Status != models.TaskStatusDone {
+		t.Errorf("task 1 is %s, want DONE", task.Status)
+	}
+	if labels := f.labels.attached[1]; len(labels) != 1 || labels[0] != 10 {
+		t.Errorf("task 1 has labels %v, want [10]", labels)
+	}
+	if _, err := f.tasks.repo.GetDeletedTask(2); err != nil {
+		t.Errorf("task 2 is not in the trash: %v", err)
+	}
+	// The search index is brought up to date once the transaction is over
+	if f.tasks.index.indexed[4] == nil || f.tasks.index.indexed[1].Status != models.TaskStatusDone {
+		t.Error("the search index was not refreshed")
+	}
+}
+
+func TestBulkAtomicRollsBack(t *testing.T) {
+	f := newTestBulkService(t)
+	result, err := f.Run(&models.BulkRequest{Mode: models.BulkModeAtomic, Operations: []*models.BulkOperation{
+		{Op: models.BulkUpdate, ID: 1, Status: statusPtr(models.TaskStatusDone)},
+		{Op: models.BulkUpdate, ID: 99, Status: statusPtr(models.TaskStatusDone)},
+		{Op: models.BulkUpdate, ID: 2, Status: statusPtr(models.TaskStatusDone)},
+	}}, models.Actor{UserID: 7})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Committed || result.Succeeded != 0 || result.Failed != 1 ||
+		!sameInts(itemStatuses(result), []int{http.StatusFailedDependency, http.StatusNotFound}) {
+		t.Fatalf("result = %+v, statuses %v", result, itemStatuses(result))
+	}
+	if result.Results[0].Task != nil {
+		t.Error("a rolled back operation still reports its task")
+	}
+	if task, _ := f.tasks.repo.GetTaskByID(1); task.Status != models.TaskStatusTodo {
+		t.Errorf("task 1 is %s, want the change rolled back", task.Status)
+	}
+}
+
+func TestBulkPartial(t *testing.T) {
+	f := newTestBulkService(t)
+	result, err := f.Run(&models.BulkRequest{Mode: models.BulkModePartial, Operations: []*models.BulkOperation{
+		{Op: models.BulkUpdate, ID: 1, Status: statusPtr(models.TaskStatusDone)},
+		// The status is changed before the label of another project is
+		// refused, and has to be undone
+		{Op: models.BulkUpdate, ID: 2, Status: statusPtr(models.TaskStatusDone), AddLabels: []int{10}},
+		{Op: models.BulkUpdate, ID: 99, Status: statusPtr(models.TaskStatusDone)},
+	}}, models.Actor{UserID: 7})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Committed || result.Succeeded != 1 || result.Failed != 2 ||
+		!sameInts(itemStatuses(result), []int{http.StatusOK, http.StatusBadRequest, http.StatusNotFound}) {
+		t.Fatalf("result = %+v, statuses %v", result, itemStatuses(result))
+	}
+	if task, _ := f.tasks.repo.GetTaskByID(1); task.Status != models.TaskStatusDone {
+		t.Errorf("task 1 is %s, want DONE", task.Status)
+	}
+	if task, _ := f.tasks.repo.GetTaskByID(2); task.Status != models.TaskStatusTodo {
+		t.Errorf("task 2 is %s, want its failed change undone", task.Status)
+	}
+	rollbacks := 0
+	for _, statement := range f.tx.statements {
+		if statement == "ROLLBACK TO SAVEPOINT bulk_operation" {
+			rollbacks++
+		}
+	}
+	if rollbacks != 2 {
+		t.Errorf("rolled back to the savepoint %d times, want 2", rollbacks)
+	}
+}
+
+func TestBulkChecksPermissions(t *testing.T) {
+	user := models.Actor{UserID: 7, Role: models.UserRoleUser, OrgRole: models.OrgRoleMember}
+	orgAdmin := models.Actor{UserID: 7, Role: models.UserRoleUser, OrgRole: models.OrgRoleAdmin}
+	admin := models.Actor{UserID: 7, Role: models.UserRoleAdmin}
+	tests := []struct {
+		name  string
+		op    *models.BulkOperation
+		actor models.Actor
+		want  int
+	}{
+		{"another user's task", &models.BulkOperation{Op: models.BulkDelete, ID: 3}, user, http.StatusForbidden},
+		{"labels of another user's task", &models.BulkOperation{Op: models.BulkUpdate, ID: 3, AddLabels: []int{10}}, user,
+			http.StatusForbidden},
+		{"admin on another user's task", &models.BulkOperation{Op: models.BulkDelete, ID: 3}, admin, http.StatusOK},
+		{"organisation admin on another user's task", &models.BulkOperation{Op: models.BulkDelete, ID: 3}, orgAdmin,
+			http.StatusOK},
+		{"assigning to someone else", &models.BulkOperation{Op: models.BulkUpdate, ID: 1, UserID: intPtr(8)}, user,
+			http.StatusForbidden},
+		{"organisation admin assigning to someone else", &models.BulkOperation{Op: models.BulkUpdate, ID: 1, UserID: intPtr(8)},
+			orgAdmin, http.StatusOK},
+		{"taking a task", &models.BulkOperation{Op: models.BulkUpdate, ID: 1, UserID: intPtr(7)}, user, http.StatusOK},
+		{"admin assigning to nobody known", &models.BulkOperation{Op: models.BulkUpdate, ID: 1, UserID: intPtr(99)}, admin,
+			http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestBulkService(t)
+			result, err := f.Run(&models.BulkRequest{Operations: []*models.BulkOperation{tt.op}}, tt.actor)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := result.Results[0].Status; got != tt.want {
+				t.Errorf("status = %d (%s), want %d", got, result.Results[0].Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestBulkLimitsOperations(t *testing.T) {
+	f := newTestBulkService(t)
+	ops := make([]*models.BulkOperation, 6)
+	for i := range ops {
+		ops[i] = &models.BulkOperation{Op: models.BulkDelete, ID: 1}
+	}
+	_, err := f.Run(&models.BulkRequest{Operations: ops}, models.Actor{UserID: 7, Role: models.UserRoleAdmin})
+	wantStatus(t, err, 400)
+}
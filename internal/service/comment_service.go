@@ -0,0 +1,109 @@
+package service
+
+import (
+	"log"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/internal/search"
+)
+
+// CommentService defines the interface for comments on tasks
+type CommentService interface {
+	AddComment(taskID, userID int, body string) (*models.Comment, error)
+	ListComments(taskID int) ([]*models.Comment, error)
+	// DeleteComment removes a comment. Only its author may do so, unless the
+	// user is a moderator.
+	DeleteComment(taskID, id, userID int, moderator bool) error
+	// ForTenant returns a copy of the service confined to the tasks of the
+	// given organisation
+	ForTenant(orgID int) CommentService
+}
+
+type commentService struct {
+	commentRepo repository.CommentRepository
+	taskRepo    repository.TaskRepository
+	index       search.Index
+}
+
+// NewCommentService creates a new CommentService
+func NewCommentService(commentRepo repository.CommentRepository, taskRepo repository.TaskRepository,
+	index search.Index) CommentService {
+	return &commentService{commentRepo: commentRepo, taskRepo: taskRepo, index: index}
+}
+
+func (s *commentService) ForTenant(orgID int) CommentService {
+	scoped := *s
+	scoped.taskRepo = s.taskRepo.ForTenant(orgID)
+	return &scoped
+}
+
+func (s *commentService) AddComment(taskID, userID int, body string) (*models.Comment, error) {
+	if _, err := s.getTask(taskID); err != nil {
+		return nil, err
+	}
+
+	comment := &models.Comment{TaskID: taskID, Body: body}
+	if userID > 0 {
+		comment.UserID = &userID
+	}
+	if err := s.commentRepo.CreateComment(comment); err != nil {
+		return nil, err
+	}
+	s.reindex(taskID)
+	return comment, nil
+}
+
+func (s *commentService) ListComments(taskID int) ([]*models.Comment, error) {
+	if _, err := s.getTask(taskID); err != nil {
+		return nil, err
+	}
+	return s.commentRepo.ListComments(taskID)
+}
+
+func (s *commentService) DeleteComment(taskID, id, userID int, moderator bool) error {
+	if _, err := s.getTask(taskID); err != nil {
+		return err
+	}
+
+	comment, err := s.commentRepo.GetCommentByID(id)
+	if err != nil || comment.TaskID != taskID {
+		if err == nil || err.Error() == "comment not found" {
+			return apierrors.NewNotFoundError("comment not found")
+		}
+		return err
+	}
+	if !moderator && (comment.UserID == nil || *comment.UserID != userID) {
+		return apierrors.NewForbiddenError("only the author of a comment can delete it")
+	}
+
+	if err := s.commentRepo.DeleteComment(id); err != nil {
+		if err.Error() == "comment not found" {
+			return apierrors.NewNotFoundError("comment not found")
+		}
+		return err
+	}
+	s.reindex(taskID)
+	return nil
+}
+
+func (s *commentService) getTask(taskID int) (*models.Task, error) {
+	task, err := s.taskRepo.GetTaskByID(taskID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return nil, apierrors.NewNotFoundError("task not found")
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// reindex brings the comments of a task in the search index up to date
+func (s *commentService) reindex(taskID int) {
+	comments, err := s.commentRepo.ListComments(taskID)
+	if err != nil {
+		log.Printf("Error reloading comments of task %d: %v", taskID, err)
+		return
+	}
+	s.index.IndexComments(taskID, comments)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+)
+
+type fakeCommentRepo struct {
+	repository.CommentRepository
+	comments []*models.Comment
+}
+
+func (r *fakeCommentRepo) CreateComment(comment *models.Comment) error {
+	comment.ID = len(r.comments) + 1
+	r.comments = append(r.comments, comment)
+	return nil
+}
+
+func (r *fakeCommentRepo) GetCommentByID(id int) (*models.Comment, error) {
+	for _, comment := range r.comments {
+		if comment.ID == id {
+			return comment, nil
+		}
+	}
+	return nil, fmt.Errorf("comment not found")
+}
+
+func (r *fakeCommentRepo) ListComments(taskID int) ([]*models.Comment, error) {
+	var comments []*models.Comment
+	for _, comment := range r.comments {
+		if comment.TaskID == taskID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (r *fakeCommentRepo) DeleteComment(id int) error {
+	for i, comment := range r.comments {
+		if comment.ID == id {
+			r.comments = append(r.comments[:i], r.comments[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("comment not found")
+}
+
+func newTestCommentService(tasks ...*models.Task) (CommentService, *fakeIndex) {
+	index := &fakeIndex{indexed: map[int]*models.Task{}, comments: map[int][]*models.Comment{}}
+	return NewCommentService(&fakeCommentRepo{}, newFakeTaskRepo(tasks...), index), index
+}
+
+func TestAddCommentIndexesTheTaskComments(t *testing.T) {
+	s, index := newTestCommentService(todo(1, nil))
+
+	if _, err := s.AddComment(1, 7, "first"); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if _, err := s.AddComment(1, 8, "second"); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+
+	if got := index.comments[1]; len(got) != 2 || got[0].Body != "first" || got[1].Body != "second" {
+		t.Errorf("indexed comments = %v, want both", got)
+	}
+}
+
+func TestAddCommentToMissingTask(t *testing.T) {
+	s, _ := newTestCommentService(todo(1, nil))
+
+	_, err := s.AddComment(2, 7, "hello")
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != 404 {
+		t.Errorf("AddComment = %v, want not found", err)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	tests := []struct {
+		name      string
+		taskID    int
+		userID    int
+		moderator bool
+		want      int
+	}{
+		{"author", 1, 7, false, 0},
+		{"someone else", 1, 8, false, 403},
+		{"moderator", 1, 8, true, 0},
+		{"through another task", 2, 7, false, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, index := newTestCommentService(todo(1, nil), todo(2, nil))
+			comment, _ := s.AddComment(1, 7, "hello")
+
+			err := s.DeleteComment(tt.taskID, comment.ID, tt.userID, tt.moderator)
+			if tt.want == 0 {
+				if err != nil {
+					t.Fatalf("DeleteComment: %v", err)
+				}
+				if len(index.comments[1]) != 0 {
+					t.Error("deleted comment is still indexed")
+				}
+				return
+			}
+			if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != tt.want {
+				t.Fatalf("DeleteComment = %v, want %d", err, tt.want)
+			}
+			if comments, _ := s.ListComments(1); len(comments) != 1 {
+				t.Error("a refused delete removed the comment")
+			}
+		})
+	}
+}
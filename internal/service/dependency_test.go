@@ -0,0 +1,227 @@
+package service
+
+import (
+	"database/sql"
+	"sync"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestAddDependencyRefusesCycles(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil), todo(2, nil), todo(3, nil))
+	if err := s.AddDependency(1, 2); err != nil {
+		t.Fatalf("AddDependency(1, 2): %v", err)
+	}
+	if err := s.AddDependency(2, 3); err != nil {
+		t.Fatalf("AddDependency(2, 3): %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		blocker, blocked int
+		wantStatus       int
+	}{
+		{"itself", 1, 1, 400},
+		{"direct cycle", 2, 1, 409},
+		{"indirect cycle", 3, 1, 409},
+		{"duplicate", 1, 2, 409},
+		{"missing blocker", 99, 1, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.AddDependency(tt.blocker, tt.blocked)
+			apiErr, ok := err.(*apierrors.APIError)
+			if !ok || apiErr.StatusCode != tt.wantStatus {
+				t.Fatalf("AddDependency(%d, %d) = %v, want status %d", tt.blocker, tt.blocked, err, tt.wantStatus)
+			}
+		})
+	}
+	if len(s.deps.deps) != 2 {
+		t.Errorf("%d dependencies recorded, want 2", len(s.deps.deps))
+	}
+}
+
+// rowLocks stands in for the row locks of a database: LockTask waits until no
+// other transaction holds the task, and a transaction keeps its locks until
+// it ends. mu also guards the fakes the transactions share.
+type rowLocks struct {
+	mu      sync.Mutex
+	holders map[int]*sql.Tx
+	freed   *sync.Cond
+}
+
+func newRowLocks() *rowLocks {
+	l := &rowLocks{holders: map[int]*sql.Tx{}}
+	l.freed = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *rowLocks) lock(tx *sql.Tx, id int) {
+	for l.holders[id] != nil && l.holders[id] != tx {
+		l.freed.Wait()
+	}
+	l.holders[id] = tx
+}
+
+// RunInTx runs fn in a transaction of db and releases its locks at the end
+func (l *rowLocks) RunInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	l.mu.Lock()
+	for id, holder := range l.holders {
+		if holder == tx {
+			delete(l.holders, id)
+		}
+	}
+	l.mu.Unlock()
+	l.freed.Broadcast()
+	tx.Rollback()
+	return err
+}
+
+type lockingTx struct {
+	db    *sql.DB
+	locks *rowLocks
+}
+
+func (t lockingTx) RunInTx(fn func(tx *sql.Tx) error) error { return t.locks.RunInTx(t.db, fn) }
+
+// lockingTasks locks tasks with rowLocks in the transaction it was given
+type lockingTasks struct {
+	*fakeTaskRepo
+	locks *rowLocks
+	tx    *sql.Tx
+}
+
+func (r lockingTasks) WithTx(tx *sql.Tx) repository.TaskRepository {
+	return lockingTasks{r.fakeTaskRepo, r.locks, tx}
+}
+
+func (r lockingTasks) LockTask(id int) (*models.Task, error) {
+	r.locks.mu.Lock()
+	defer r.locks.mu.Unlock()
+	r.locks.lock(r.tx, id)
+	return r.fakeTaskRepo.LockTask(id)
+}
+
+// racingDeps is a dependency repository whose PathExists waits for a rival
+// request to have looked for a path too, which it only can when nothing
+// keeps the two apart
+type racingDeps struct {
+	*fakeDependencyRepo
+	locks *rowLocks
+	// arrived counts the requests that looked for a path; all is closed
+	// once both have
+	arrived *int
+	all     chan struct{}
+}
+
+func (r racingDeps) WithTx(tx *sql.Tx) repository.DependencyRepository { return r }
+
+func (r racingDeps) PathExists(fromID, toID int) (bool, error) {
+	r.locks.mu.Lock()
+	exists, err := r.fakeDependencyRepo.PathExists(fromID, toID)
+	*r.arrived++
+	if *r.arrived == 2 {
+		close(r.all)
+	}
+	r.locks.mu.Unlock()
+	select {
+	case <-r.all:
+	case <-time.After(100 * time.Millisecond):
+	}
+	return exists, err
+}
+
+func (r racingDeps) AddDependency(blockerID, blockedID int) error {
+	r.locks.mu.Lock()
+	defer r.locks.mu.Unlock()
+	return r.fakeDependencyRepo.AddDependency(blockerID, blockedID)
+}
+
+func TestAddDependencyRefusesConcurrentCycles(t *testing.T) {
+	tasks := newFakeTaskRepo(todo(1, nil), todo(2, nil))
+	locks := newRowLocks()
+	deps := racingDeps{&fakeDependencyRepo{tasks: tasks}, locks, new(int), make(chan struct{})}
+	tx := lockingTx{newSavepointTx(t, tasks).db, locks}
+	s := NewTaskService(lockingTasks{tasks, locks, nil}, deps, &fakeRevisionRepo{}, nil, &fakeIndex{}, tx, &fakeOutbox{})
+
+	errs := make(chan error, 2)
+	go func() { errs <- s.AddDependency(1, 2) }()
+	go func() { errs <- s.AddDependency(2, 1) }()
+	refused := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			wantStatus(t, err, 409)
+			refused++
+		}
+	}
+	if refused != 1 || len(deps.deps) != 1 {
+		t.Errorf("%d requests refused and %d dependencies stored, want one of each", refused, len(deps.deps))
+	}
+}
+
+func TestUpdateTaskFinishesTaskOnceBlockersAreDone(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil), todo(2, nil))
+	s.deps.AddDependency(1, 2)
+
+	done := todo(2, nil)
+	done.Status = "DONE"
+	if err := s.UpdateTask(done, models.Actor{UserID: 7}); err == nil {
+		t.Fatal("finished a task with an open blocker")
+	}
+
+	blocker := todo(1, nil)
+	blocker.Status = "DONE"
+	if err := s.UpdateTask(blocker, models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("finishing the blocker: %v", err)
+	}
+	if err := s.UpdateTask(done, models.Actor{UserID: 7}); err != nil {
+		t.Errorf("finishing the unblocked task: %v", err)
+	}
+}
+
+func TestGetTaskPlan(t *testing.T) {
+	// Subtasks 2..5 of 1, where 4 blocks 3, 3 blocks 2 and 5 blocks 2
+	s := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)), todo(3, intPtr(1)), todo(4, intPtr(1)), todo(5, intPtr(1)))
+	s.deps.AddDependency(4, 3)
+	s.deps.AddDependency(3, 2)
+	s.deps.AddDependency(5, 2)
+
+	plan, err := s.GetTaskPlan(1)
+	if err != nil {
+		t.Fatalf("GetTaskPlan: %v", err)
+	}
+
+	var order, critical []int
+	for _, task := range plan.Order {
+		order = append(order, task.ID)
+	}
+	for _, task := range plan.CriticalPath {
+		critical = append(critical, task.ID)
+	}
+	if want := []int{1, 4, 3, 5, 2}; !equalInts(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if want := []int{4, 3, 2}; !equalInts(critical, want) {
+		t.Errorf("critical path = %v, want %v", critical, want)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
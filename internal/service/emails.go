@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"task-management-api/pkg/mail"
+	texttemplate "text/template"
+	"time"
+)
+
+// Each email has a <name>.txt.tmpl template defining "subject" and "text",
+// and a <name>.html.tmpl template defining "html"
+//
+//go:embed templates/*.tmpl
+var emailTemplates embed.FS
+
+type emailData struct {
+	Name      string
+	Link      string
+	ExpiresIn string
+	// Organization and Inviter are set for invitations
+	Organization string
+	Inviter      string
+}
+
+// renderEmail builds the named email for the recipient
+func renderEmail(name, to string, data emailData) (*mail.Message, error) {
+	text, err := texttemplate.ParseFS(emailTemplates, "templates/"+name+".txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s email: %v", name, err)
+	}
+	html, err := htmltemplate.ParseFS(emailTemplates, "templates/"+name+".html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s email: %v", name, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s email: %v", name, err)
+	}
+	if err := text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s email: %v", name, err)
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "html", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s email: %v", name, err)
+	}
+
+	return &mail.Message{To: to, Subject: subject.String(), Text: textBody.String(), HTML: htmlBody.String()}, nil
+}
+
+// humanDuration formats a token lifetime for an email, e.g. "2 days" or "1 hour"
+func humanDuration(d time.Duration) string {
+	unit, n := "minute", int(d/time.Minute)
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		unit, n = "day", int(d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, n = "hour", int(d/time.Hour)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,696 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"task-management-api/internal/events"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+// The fakes below keep their data in memory. Each embeds the interface it
+// stands in for, so calling a method a test did not expect panics.
+
+type fakeTaskRepo struct {
+	repository.TaskRepository
+	tasks  map[int]*models.Task
+	nextID int
+	// locked lists the tasks passed to LockTask, in order
+	locked []int
+}
+
+func newFakeTaskRepo(tasks ...*models.Task) *fakeTaskRepo {
+	r := &fakeTaskRepo{tasks: map[int]*models.Task{}, nextID: 1}
+	for _, task := range tasks {
+		r.put(task)
+	}
+	return r
+}
+
+func (r *fakeTaskRepo) put(task *models.Task) {
+	stored := *task
+	if stored.Priority == "" {
+		stored.Priority = models.TaskPriorityMedium
+	}
+	stored.KeepParent = false
+	r.tasks[stored.ID] = &stored
+	if stored.ID >= r.nextID {
+		r.nextID = stored.ID + 1
+	}
+}
+
+func (r *fakeTaskRepo) WithTx(tx *sql.Tx) repository.TaskRepository { return r }
+
+func (r *fakeTaskRepo) ForTenant(orgID int) repository.TaskRepository { return r }
+func (r *fakeTaskRepo) AllTenants() repository.TaskRepository         { return r }
+
+func (r *fakeTaskRepo) CreateTask(task *models.Task) error {
+	task.ID = r.nextID
+	r.put(task)
+	return nil
+}
+
+func (r *fakeTaskRepo) GetTaskByID(id int) (*models.Task, error) {
+	task, ok := r.tasks[id]
+	if !ok || task.DeletedAt != nil || task.ArchivedAt != nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	stored := *task
+	return &stored, nil
+}
+
+func (r *fakeTaskRepo) LockTask(id int) (*models.Task, error) {
+	r.locked = append(r.locked, id)
+	return r.GetTaskByID(id)
+}
+
+func (r *fakeTaskRepo) GetArchivedTask(id int) (*models.Task, error) {
+	task, ok := r.tasks[id]
+	if !ok || task.ArchivedAt == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	stored := *task
+	return &stored, nil
+}
+
+func (r *fakeTaskRepo) GetChildren(parentID int) ([]*models.Task, error) {
+	var children []*models.Task
+	for _, id := range r.ids() {
+		if task, err := r.GetTaskByID(id); err == nil && task.ParentID != nil && *task.ParentID == parentID {
+			children = append(children, task)
+		}
+	}
+	return children, nil
+}
+
+func (r *fakeTaskRepo) GetDescendants(id int) ([]*models.Task, error) {
+	var descendants []*models.Task
+	children, _ := r.GetChildren(id)
+	for _, child := range children {
+		below, _ := r.GetDescendants(child.ID)
+		descendants = append(append(descendants, child), below...)
+	}
+	return descendants, nil
+}
+
+func (r *fakeTaskRepo) UpdateTask(task *models.Task) error {
+	stored, err := r.GetTaskByID(task.ID)
+	if err != nil {
+		return err
+	}
+	stored.ParentID, stored.Title, stored.Description = task.ParentID, task.Title, task.Description
+	stored.Status, stored.Priority, stored.DueDate = task.Status, task.Priority, task.DueDate
+	r.put(stored)
+	return nil
+}
+
+func (r *fakeTaskRepo) AssignTask(id int, userID *int) error {
+	stored, err := r.GetTaskByID(id)
+	if err != nil {
+		return err
+	}
+	stored.UserID = userID
+	r.put(stored)
+	return nil
+}
+
+func (r *fakeTaskRepo) DeleteTask(id int) error {
+	task, err := r.GetTaskByID(id)
+	if err != nil {
+		return err
+	}
+	descendants, _ := r.GetDescendants(id)
+	now := time.Now()
+	for _, t := range append([]*models.Task{task}, descendants...) {
+		r.tasks[t.ID].DeletedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeTaskRepo) GetDeletedTask(id int) (*models.Task, error) {
+	task, ok := r.tasks[id]
+	if !ok || task.DeletedAt == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	stored := *task
+	return &stored, nil
+}
+
+func (r *fakeTaskRepo) GetDeletedDescendants(id int) ([]*models.Task, error) {
+	var descendants []*models.Task
+	for _, childID := range r.ids() {
+		child := r.tasks[childID]
+		if child.DeletedAt != nil && child.ParentID != nil && *child.ParentID == id {
+			below, _ := r.GetDeletedDescendants(childID)
+			stored := *child
+			descendants = append(append(descendants, &stored), below...)
+		}
+	}
+	return descendants, nil
+}
+
+func (r *fakeTaskRepo) ListDeletedTasks() ([]*models.Task, error) {
+	return r.ListTasksDeletedBefore(time.Now().Add(time.Hour))
+}
+
+func (r *fakeTaskRepo) ListTasksDeletedBefore(before time.Time) ([]*models.Task, error) {
+	var deleted []*models.Task
+	for _, id := range r.ids() {
+		if task := r.tasks[id]; task.DeletedAt != nil && task.DeletedAt.Before(before) {
+			stored := *task
+			deleted = append(deleted, &stored)
+		}
+	}
+	return deleted, nil
+}
+
+// RestoreTask takes a task out of the trash with the subtasks deleted along
+// with it, which have the same deletion time
+func (r *fakeTaskRepo) RestoreTask(id int) error {
+	task, err := r.GetDeletedTask(id)
+	if err != nil {
+		return err
+	}
+	if task.ParentID != nil {
+		if _, err := r.GetDeletedTask(*task.ParentID); err == nil {
+			return fmt.Errorf("parent task is deleted")
+		}
+	}
+	descendants, _ := r.GetDeletedDescendants(id)
+	for _, t := range append([]*models.Task{task}, descendants...) {
+		if t.DeletedAt.Equal(*task.DeletedAt) {
+			r.tasks[t.ID].DeletedAt = nil
+		}
+	}
+	return nil
+}
+
+func (r *fakeTaskRepo) PurgeTask(id int) error {
+	if _, err := r.GetDeletedTask(id); err != nil {
+		return err
+	}
+	descendants, _ := r.GetDeletedDescendants(id)
+	for _, t := range descendants {
+		delete(r.tasks, t.ID)
+	}
+	delete(r.tasks, id)
+	return nil
+}
+
+// ArchiveTask archives a DONE task with its subtasks, which must all be DONE
+func (r *fakeTaskRepo) ArchiveTask(id int) error {
+	task, err := r.GetTaskByID(id)
+	if err != nil {
+		return err
+	}
+	descendants, _ := r.GetDescendants(id)
+	tasks := append([]*models.Task{task}, descendants...)
+	for _, t := range tasks {
+		if t.Status != models.TaskStatusDone {
+			return fmt.Errorf("task is not completed")
+		}
+	}
+	now := time.Now()
+	for _, t := range tasks {
+		r.tasks[t.ID].ArchivedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeTaskRepo) UnarchiveTask(id int) error {
+	task, err := r.GetArchivedTask(id)
+	if err != nil {
+		return err
+	}
+	if task.ParentID != nil {
+		if _, err := r.GetArchivedTask(*task.ParentID); err == nil {
+			return fmt.Errorf("parent task is archived")
+		}
+	}
+	r.unarchiveSubtree(id, *task.ArchivedAt)
+	return nil
+}
+
+// unarchiveSubtree unarchives a task and the subtasks archived with it
+func (r *fakeTaskRepo) unarchiveSubtree(id int, archivedAt time.Time) {
+	r.tasks[id].ArchivedAt = nil
+	for _, childID := range r.ids() {
+		child := r.tasks[childID]
+		if child.ParentID != nil && *child.ParentID == id && child.ArchivedAt != nil && child.ArchivedAt.Equal(archivedAt) {
+			r.unarchiveSubtree(childID, archivedAt)
+		}
+	}
+}
+
+func (r *fakeTaskRepo) ListTasksToArchive(before time.Time) ([]*models.Task, error) {
+	var tasks []*models.Task
+	for _, id := range r.ids() {
+		task, err := r.GetTaskByID(id)
+		if err == nil && task.ParentID == nil && task.Status == models.TaskStatusDone && task.UpdatedAt.Before(before) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (r *fakeTaskRepo) ids() []int {
+	ids := make([]int, 0, len(r.tasks))
+	for id := range r.tasks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// snapshot copies the tasks so that a rolled back transaction can restore them
+func (r *fakeTaskRepo) snapshot() (map[int]*models.Task, int) {
+	tasks := make(map[int]*models.Task, len(r.tasks))
+	for id, task := range r.tasks {
+		stored := *task
+		tasks[id] = &stored
+	}
+	return tasks, r.nextID
+}
+
+type fakeRevisionRepo struct {
+	repository.TaskRevisionRepository
+	revisions []*models.TaskRevision
+}
+
+func (r *fakeRevisionRepo) WithTx(tx *sql.Tx) repository.TaskRevisionRepository { return r }
+
+func (r *fakeRevisionRepo) ForTenant(orgID int) repository.TaskRevisionRepository { return r }
+func (r *fakeRevisionRepo) AllTenants() repository.TaskRevisionRepository         { return r }
+
+func (r *fakeRevisionRepo) CreateRevision(revision *models.TaskRevision) error {
+	revision.Revision = len(r.ListOf(revision.TaskID)) + 1
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+	r.revisions = append(r.revisions, revision)
+	return nil
+}
+
+func (r *fakeRevisionRepo) ListOf(taskID int) []*models.TaskRevision {
+	var revisions []*models.TaskRevision
+	for _, revision := range r.revisions {
+		if revision.TaskID == taskID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions
+}
+
+func (r *fakeRevisionRepo) HasRevisions(taskID int) (bool, error) {
+	return len(r.ListOf(taskID)) > 0, nil
+}
+
+func (r *fakeRevisionRepo) ListRevisions(taskID int) ([]*models.TaskRevision, error) {
+	return r.ListOf(taskID), nil
+}
+
+func (r *fakeRevisionRepo) GetRevision(taskID, revision int) (*models.TaskRevision, error) {
+	for _, recorded := range r.ListOf(taskID) {
+		if recorded.Revision == revision {
+			return recorded, nil
+		}
+	}
+	return nil, fmt.Errorf("revision not found")
+}
+
+// GetRevisionAsOf returns the last revision made at or before the given time
+func (r *fakeRevisionRepo) GetRevisionAsOf(taskID int, at time.Time) (*models.TaskRevision, error) {
+	var found *models.TaskRevision
+	for _, recorded := range r.ListOf(taskID) {
+		if !recorded.CreatedAt.After(at) {
+			found = recorded
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("revision not found")
+	}
+	return found, nil
+}
+
+type fakeDependencyRepo struct {
+	repository.DependencyRepository
+	tasks *fakeTaskRepo
+	deps  []*models.TaskDependency
+}
+
+func (r *fakeDependencyRepo) WithTx(tx *sql.Tx) repository.DependencyRepository { return r }
+
+func (r *fakeDependencyRepo) AddDependency(blockerID, blockedID int) error {
+	for _, dep := range r.deps {
+		if dep.BlockerID == blockerID && dep.BlockedID == blockedID {
+			return fmt.Errorf("dependency already exists")
+		}
+	}
+	r.deps = append(r.deps, &models.TaskDependency{BlockerID: blockerID, BlockedID: blockedID})
+	return nil
+}
+
+func (r *fakeDependencyRepo) GetBlockers(taskID int) ([]*models.Task, error) {
+	var blockers []*models.Task
+	for _, dep := range r.deps {
+		if dep.BlockedID == taskID {
+			if task, err := r.tasks.GetTaskByID(dep.BlockerID); err == nil {
+				blockers = append(blockers, task)
+			}
+		}
+	}
+	return blockers, nil
+}
+
+func (r *fakeDependencyRepo) GetDependenciesAmong(taskIDs []int) ([]*models.TaskDependency, error) {
+	among := map[int]bool{}
+	for _, id := range taskIDs {
+		among[id] = true
+	}
+	var deps []*models.TaskDependency
+	for _, dep := range r.deps {
+		if among[dep.BlockerID] && among[dep.BlockedID] {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+func (r *fakeDependencyRepo) PathExists(fromID, toID int) (bool, error) {
+	seen := map[int]bool{fromID: true}
+	queue := []int{fromID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == toID {
+			return true, nil
+		}
+		for _, dep := range r.deps {
+			if dep.BlockerID == current && !seen[dep.BlockedID] {
+				seen[dep.BlockedID] = true
+				queue = append(queue, dep.BlockedID)
+			}
+		}
+	}
+	return false, nil
+}
+
+type fakeOutbox struct {
+	repository.OutboxRepository
+	events []events.Event
+}
+
+func (o *fakeOutbox) WithTx(tx *sql.Tx) repository.OutboxRepository { return o }
+
+func (o *fakeOutbox) Append(evts ...events.Event) error {
+	o.events = append(o.events, evts...)
+	return nil
+}
+
+type fakeIndex struct {
+	indexed  map[int]*models.Task
+	comments map[int][]*models.Comment
+}
+
+func (i *fakeIndex) Index(task *models.Task) { i.indexed[task.ID] = task }
+
+func (i *fakeIndex) IndexComments(taskID int, comments []*models.Comment) {
+	i.comments[taskID] = comments
+}
+
+func (i *fakeIndex) Remove(taskID int) { delete(i.indexed, taskID) }
+
+func (i *fakeIndex) Search(query string, cond filter.Expr, orgID int, includeArchived bool,
+	limit int) ([]*models.SearchResult, error) {
+	return nil, nil
+}
+
+// fakeTx runs functions without a database, putting the tasks of repo, if
+// any, back as they were when a function fails
+type fakeTx struct {
+	repo *fakeTaskRepo
+	// runs counts the transactions that were started
+	runs int
+}
+
+func (t *fakeTx) RunInTx(fn func(tx *sql.Tx) error) error {
+	t.runs++
+	if t.repo == nil {
+		return fn(nil)
+	}
+	tasks, nextID := t.repo.snapshot()
+	if err := fn(nil); err != nil {
+		t.repo.tasks, t.repo.nextID = tasks, nextID
+		return err
+	}
+	return nil
+}
+
+// testTaskService bundles a task service with the fakes behind it
+type testTaskService struct {
+	*taskService
+	repo      *fakeTaskRepo
+	deps      *fakeDependencyRepo
+	revisions *fakeRevisionRepo
+	outbox    *fakeOutbox
+	index     *fakeIndex
+	tx        *fakeTx
+}
+
+func newTestTaskService(t *testing.T, tasks ...*models.Task) *testTaskService {
+	t.Helper()
+	repo := newFakeTaskRepo(tasks...)
+	f := &testTaskService{
+		repo:      repo,
+		deps:      &fakeDependencyRepo{tasks: repo},
+		revisions: &fakeRevisionRepo{},
+		outbox:    &fakeOutbox{},
+		index:     &fakeIndex{indexed: map[int]*models.Task{}, comments: map[int][]*models.Comment{}},
+		tx:        &fakeTx{repo: repo},
+	}
+	f.taskService = NewTaskService(f.repo, f.deps, f.revisions, nil, f.index, f.tx, f.outbox).(*taskService)
+	return f
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users   map[int]*models.User
+	history map[int][]string
+}
+
+func newFakeUserRepo(users ...*models.User) *fakeUserRepo {
+	r := &fakeUserRepo{users: map[int]*models.User{}, history: map[int][]string{}}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepo) WithTx(tx *sql.Tx) repository.UserRepository { return r }
+
+func (r *fakeUserRepo) ForTenant(orgID int) repository.UserRepository { return r }
+func (r *fakeUserRepo) AllTenants() repository.UserRepository         { return r }
+
+func (r *fakeUserRepo) GetUserByID(id int) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	stored := *user
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) GetUserByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email && user.DeletedAt == nil {
+			stored := *user
+			return &stored, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeUserRepo) GetUserByUsername(username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username && user.DeletedAt == nil {
+			stored := *user
+			return &stored, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+// CreateUser refuses the username or email of another user, even one in the
+// trash, like the unique keys of the table
+func (r *fakeUserRepo) CreateUser(newUser *models.NewUser) (*models.User, error) {
+	for _, user := range r.users {
+		if err := duplicateUser(user, "username", user.Username == newUser.Username); err != nil {
+			return nil, err
+		}
+		if err := duplicateUser(user, "email", user.Email == newUser.Email); err != nil {
+			return nil, err
+		}
+	}
+	id := 1
+	for existing := range r.users {
+		if existing >= id {
+			id = existing + 1
+		}
+	}
+	user := &models.User{
+		ID:       id,
+		Username: newUser.Username,
+		Email:    newUser.Email,
+		FullName: newUser.FullName,
+		Role:     newUser.Role,
+		OrgRole:  newUser.OrgRole,
+		IsActive: true,
+	}
+	r.users[user.ID] = user
+	stored := *user
+	return &stored, nil
+}
+
+func duplicateUser(user *models.User, field string, same bool) error {
+	switch {
+	case !same:
+		return nil
+	case user.DeletedAt != nil:
+		return fmt.Errorf("%s belongs to a deleted user", field)
+	}
+	return fmt.Errorf("%s already exists", field)
+}
+
+func (r *fakeUserRepo) UpdateUser(id int, update *models.UpdateUser) error {
+	user := r.users[id]
+	if update.Email != nil {
+		for _, other := range r.users {
+			if err := duplicateUser(other, "email", other.ID != id && other.Email == *update.Email); err != nil {
+				return err
+			}
+		}
+		user.Email = *update.Email
+	}
+	if update.Role != nil {
+		user.Role = *update.Role
+	}
+	if update.IsActive != nil {
+		user.IsActive = *update.IsActive
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(id int, passwordHash string) error {
+	r.users[id].PasswordHash = passwordHash
+	return nil
+}
+
+func (r *fakeUserRepo) AddPasswordHistory(id int, passwordHash string, keep int) error {
+	history := append([]string{passwordHash}, r.history[id]...)
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	r.history[id] = history
+	return nil
+}
+
+func (r *fakeUserRepo) ListPasswordHistory(id int, limit int) ([]string, error) {
+	history := r.history[id]
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+func (r *fakeUserRepo) MarkEmailVerified(id int) error {
+	now := time.Now()
+	r.users[id].EmailVerifiedAt = &now
+	return nil
+}
+
+func (r *fakeUserRepo) SetPendingEmail(id int, email string) error {
+	r.users[id].PendingEmail = &email
+	return nil
+}
+
+func (r *fakeUserRepo) ConfirmEmailChange(id int) error {
+	user := r.users[id]
+	now := time.Now()
+	user.Email, user.PendingEmail, user.EmailVerifiedAt = *user.PendingEmail, nil, &now
+	return nil
+}
+
+func (r *fakeUserRepo) DeleteUser(id int) error {
+	if _, err := r.GetUserByID(id); err != nil {
+		return err
+	}
+	now := time.Now()
+	r.users[id].DeletedAt = &now
+	return nil
+}
+
+func (r *fakeUserRepo) GetDeletedUser(id int) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	stored := *user
+	return &stored, nil
+}
+
+func (r *fakeUserRepo) ListDeletedUsers() ([]*models.User, error) {
+	return r.ListUsersDeletedBefore(time.Now().Add(time.Hour))
+}
+
+func (r *fakeUserRepo) ListUsersDeletedBefore(before time.Time) ([]*models.User, error) {
+	var deleted []*models.User
+	for id := 1; id <= len(r.users); id++ {
+		if user, ok := r.users[id]; ok && user.DeletedAt != nil && user.DeletedAt.Before(before) {
+			stored := *user
+			deleted = append(deleted, &stored)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *fakeUserRepo) RestoreUser(id int) error {
+	if _, err := r.GetDeletedUser(id); err != nil {
+		return err
+	}
+	r.users[id].DeletedAt = nil
+	return nil
+}
+
+func (r *fakeUserRepo) PurgeUser(id int) error {
+	if _, err := r.GetDeletedUser(id); err != nil {
+		return err
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// fakeSessions hands out session tokens naming their user, and records
+// whose sessions were revoked
+type fakeSessions struct {
+	SessionService
+	started []int
+	revoked []int
+}
+
+func (s *fakeSessions) StartSession(user *models.User, client models.ClientInfo) (string, error) {
+	s.started = append(s.started, user.ID)
+	return fmt.Sprintf("session-of-%d", user.ID), nil
+}
+
+func (s *fakeSessions) RevokeAllSessions(userID int) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
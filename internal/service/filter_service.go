@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/filter"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"time"
+)
+
+// FilterService defines the interface for saved task filters
+type FilterService interface {
+	CreateFilter(f *models.SavedFilter) error
+	GetFilter(id, userID int) (*models.SavedFilter, error)
+	ListFilters(userID int) ([]*models.SavedFilter, error)
+	UpdateFilter(f *models.SavedFilter) error
+	DeleteFilter(id, userID int) error
+	RunFilter(id, userID int) ([]*models.Task, error)
+	// ForTenant returns a copy of the service confined to the tasks and
+	// users of the given organisation
+	ForTenant(orgID int) FilterService
+}
+
+type filterService struct {
+	filterRepo repository.SavedFilterRepository
+	taskRepo   repository.TaskRepository
+	userRepo   repository.UserRepository
+}
+
+// NewFilterService creates a new FilterService
+func NewFilterService(filterRepo repository.SavedFilterRepository, taskRepo repository.TaskRepository,
+	userRepo repository.UserRepository) FilterService {
+	return &filterService{filterRepo: filterRepo, taskRepo: taskRepo, userRepo: userRepo}
+}
+
+func (s *filterService) ForTenant(orgID int) FilterService {
+	return &filterService{filterRepo: s.filterRepo, taskRepo: s.taskRepo.ForTenant(orgID), userRepo: s.userRepo.ForTenant(orgID)}
+}
+
+func (s *filterService) CreateFilter(f *models.SavedFilter) error {
+	if err := s.validate(f); err != nil {
+		return err
+	}
+	return s.filterRepo.CreateFilter(f)
+}
+
+// GetFilter returns a filter the user owns or that is shared with them
+func (s *filterService) GetFilter(id, userID int) (*models.SavedFilter, error) {
+	f, err := s.filterRepo.GetFilterByID(id)
+	if err != nil {
+		if err.Error() == "filter not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+
+	if f.UserID == userID {
+		return f, nil
+	}
+	for _, shared := range f.SharedWith {
+		if shared == userID {
+			return f, nil
+		}
+	}
+	return nil, apierrors.NewNotFoundError("filter not found")
+}
+
+func (s *filterService) ListFilters(userID int) ([]*models.SavedFilter, error) {
+	return s.filterRepo.ListFiltersForUser(userID)
+}
+
+// UpdateFilter replaces the name, query and shares of a filter. Only the
+// owner may change a filter.
+func (s *filterService) UpdateFilter(f *models.SavedFilter) error {
+	current, err := s.GetFilter(f.ID, f.UserID)
+	if err != nil {
+		return err
+	}
+	if current.UserID != f.UserID {
+		return apierrors.NewForbiddenError("only the owner can change a filter")
+	}
+
+	if err := s.validate(f); err != nil {
+		return err
+	}
+	return s.filterRepo.UpdateFilter(f)
+}
+
+func (s *filterService) DeleteFilter(id, userID int) error {
+	current, err := s.GetFilter(id, userID)
+	if err != nil {
+		return err
+	}
+	if current.UserID != userID {
+		return apierrors.NewForbiddenError("only the owner can delete a filter")
+	}
+	return s.filterRepo.DeleteFilter(id)
+}
+
+// RunFilter returns the tasks matching a saved filter. Relative times and
+// "me" are resolved for the calling user at the time of the call.
+func (s *filterService) RunFilter(id, userID int) ([]*models.Task, error) {
+	f, err := s.GetFilter(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := filter.Parse(f.Query, filter.Env{Now: time.Now(), UserID: userID})
+	if err != nil {
+		return nil, apierrors.NewBadRequestError(fmt.Sprintf("saved filter is no longer valid: %v", err))
+	}
+	return s.taskRepo.GetAllTasks(models.TaskFilter{Condition: expr})
+}
+
+func (s *filterService) validate(f *models.SavedFilter) error {
+	if _, err := filter.Parse(f.Query, filter.Env{UserID: f.UserID}); err != nil {
+		return err
+	}
+
+	if f.SharedWith == nil {
+		f.SharedWith = []int{}
+	}
+	for _, userID := range f.SharedWith {
+		if userID == f.UserID {
+			return apierrors.NewBadRequestError("a filter cannot be shared with its owner")
+		}
+		if _, err := s.userRepo.GetUserByID(userID); err != nil {
+			if err.Error() == "user not found" {
+				return apierrors.NewBadRequestError(fmt.Sprintf("cannot share with unknown user %d", userID))
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/internal/taskfile"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// importFields are the task fields an import can set. id only names a row
+// so that other rows can use it as their parent_id.
+var importFields = map[string]bool{
+	"id": true, "parent_id": true, "title": true, "description": true, "status": true, "priority": true, "due_date": true,
+}
+
+// taskValidator checks imported tasks against the same binding rules as the
+// API does
+var taskValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+// errImportRolledBack undoes an import that is a dry run or has invalid rows
+var errImportRolledBack = errors.New("import rolled back")
+
+// ImportService creates tasks from the records of an imported file
+type ImportService interface {
+	// Import creates a task owned by the actor for every record, in one
+	// transaction. mapping renames the columns of the file to task fields;
+	// other columns are matched by name. Nothing is created if any record is
+	// invalid or dryRun is set, but every record is checked.
+	Import(records []*taskfile.Record, mapping map[string]string, dryRun bool, actorID int) (*models.ImportReport, error)
+	// ForTenant returns a copy of the service confined to the tasks of the
+	// given organisation
+	ForTenant(orgID int) ImportService
+}
+
+type importService struct {
+	tasks   TxTaskService
+	tx      repository.TxRunner
+	maxRows int
+}
+
+// NewImportService creates a new ImportService. Tasks are created through
+// tasks, so that they are checked and recorded like any other task.
+func NewImportService(tasks TxTaskService, tx repository.TxRunner, maxRows int) ImportService {
+	return &importService{tasks: tasks, tx: tx, maxRows: maxRows}
+}
+
+func (s *importService) ForTenant(orgID int) ImportService {
+	scoped := *s
+	scoped.tasks = s.tasks.ForTenantTx(orgID)
+	return &scoped
+}
+
+// importRow is a record turned into a task
+type importRow struct {
+	record *taskfile.Record
+	task   *models.Task
+	// ref is the row's id column, parentRef its parent_id column
+	ref, parentRef string
+	parent         *importRow
+	errors         map[string]string
+}
+
+func (s *importService) Import(records []*taskfile.Record, mapping map[string]string, dryRun bool,
+	actorID int) (*models.ImportReport, error) {
+	for column, field := range mapping {
+		if !importFields[field] {
+			return nil, apierrors.NewBadRequestError(fmt.Sprintf("column %q is mapped to unknown field %q", column, field))
+		}
+	}
+	if len(records) == 0 {
+		return nil, apierrors.NewBadRequestError("the file contains no tasks")
+	}
+	if s.maxRows > 0 && len(records) > s.maxRows {
+		return nil, apierrors.NewBadRequestError(fmt.Sprintf("an import may contain at most %d tasks", s.maxRows))
+	}
+
+	rows := make([]*importRow, len(records))
+	byRef := map[string]*importRow{}
+	for i, record := range records {
+		rows[i] = parseImportRow(record, mapping, actorID)
+		if ref := rows[i].ref; ref != "" {
+			if _, ok := byRef[ref]; ok {
+				rows[i].errors["id"] = "unique"
+			} else {
+				byRef[ref] = rows[i]
+			}
+		}
+	}
+	for _, row := range rows {
+		if row.parentRef == "" {
+			continue
+		}
+		if parent, ok := byRef[row.parentRef]; ok {
+			row.parent = parent
+		} else if id, err := strconv.Atoi(row.parentRef); err == nil {
+			row.task.ParentID = &id
+		} else {
+			row.errors["parent_id"] = "int"
+		}
+	}
+
+	report := &models.ImportReport{DryRun: dryRun, Rows: len(rows), Errors: []*models.ImportRowError{}}
+	var refreshed []int
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		tasks := s.tasks.JoinTx(tx, &refreshed)
+		for _, row := range importOrder(rows) {
+			if len(row.errors) > 0 {
+				continue
+			}
+			if row.parent != nil {
+				if row.parent.task.ID == 0 {
+					row.errors["parent_id"] = "invalid parent row"
+					continue
+				}
+				row.task.ParentID = &row.parent.task.ID
+			}
+			if err := tasks.CreateTask(row.task); err != nil {
+				apiErr, ok := err.(*apierrors.APIError)
+				if !ok {
+					return err
+				}
+				row.task.ID = 0
+				row.errors["general"] = apiErr.Message
+				continue
+			}
+			report.TaskIDs = append(report.TaskIDs, row.task.ID)
+		}
+
+		for _, row := range rows {
+			if len(row.errors) > 0 {
+				report.Errors = append(report.Errors, &models.ImportRowError{Row: row.record.Row, Errors: row.errors})
+			}
+		}
+		if dryRun || len(report.Errors) > 0 {
+			return errImportRolledBack
+		}
+		return nil
+	})
+
+	for _, id := range refreshed {
+		s.tasks.Refresh(id)
+	}
+
+	if err != nil && err != errImportRolledBack {
+		return nil, err
+	}
+	sort.Slice(report.Errors, func(a, b int) bool { return report.Errors[a].Row < report.Errors[b].Row })
+	if len(report.Errors) > 0 {
+		report.TaskIDs = nil
+		return report, nil
+	}
+	report.Imported = len(report.TaskIDs)
+	if dryRun {
+		report.TaskIDs = nil
+		return report, nil
+	}
+	report.Committed = true
+	return report, nil
+}
+
+// parseImportRow turns a record into a task owned by the actor, noting the
+// values that cannot be read or break the binding rules of models.Task
+func parseImportRow(record *taskfile.Record, mapping map[string]string, actorID int) *importRow {
+	row := &importRow{record: record, task: &models.Task{UserID: &actorID}, errors: map[string]string{}}
+	for column, value := range record.Values {
+		field, ok := mapping[column]
+		if !ok {
+			field = strings.ToLower(strings.TrimSpace(column))
+		}
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "id":
+			row.ref = value
+		case "parent_id":
+			row.parentRef = value
+		case "title":
+			row.task.Title = value
+		case "description":
+			row.task.Description = value
+		case "status":
+			row.task.Status = models.TaskStatus(value)
+		case "priority":
+			row.task.Priority = models.TaskPriority(value)
+		case "due_date":
+			if value == "" {
+				continue
+			}
+			if due, err := taskfile.ParseTime(value); err != nil {
+				row.errors["due_date"] = "datetime"
+			} else {
+				row.task.DueDate = &due
+			}
+		}
+	}
+
+	if err := taskValidator.Struct(row.task); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range validationErrors {
+				row.errors[strings.ToLower(e.Field())] = e.Tag()
+			}
+		} else {
+			row.errors["general"] = err.Error()
+		}
+	}
+	return row
+}
+
+// importOrder sorts the rows so that every parent comes before its subtasks,
+// keeping the file order otherwise. Rows in a parent cycle are marked as
+// invalid.
+func importOrder(rows []*importRow) []*importRow {
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[*importRow]int, len(rows))
+	ordered := make([]*importRow, 0, len(rows))
+
+	var visit func(row *importRow) bool
+	visit = func(row *importRow) bool {
+		switch state[row] {
+		case visiting:
+			return false
+		case done:
+			return true
+		}
+		state[row] = visiting
+		if row.parent != nil && !visit(row.parent) {
+			row.errors["parent_id"] = "cycle"
+			state[row] = done
+			return false
+		}
+		state[row] = done
+		ordered = append(ordered, row)
+		return true
+	}
+	for _, row := range rows {
+		visit(row)
+	}
+	return ordered
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"task-management-api/internal/models"
+	"task-management-api/internal/taskfile"
+	"testing"
+)
+
+// newTestImportService imports into a task service holding task 1, at most
+// 3 rows at a time
+func newTestImportService(t *testing.T) (ImportService, *testTaskService) {
+	t.Helper()
+	f := newTestTaskService(t, todo(1, nil))
+	return NewImportService(f.taskService, &fakeTx{repo: f.repo}, 3), f
+}
+
+// records numbers rows from 2, under a header row
+func records(values ...map[string]string) []*taskfile.Record {
+	rows := make([]*taskfile.Record, len(values))
+	for i, v := range values {
+		rows[i] = &taskfile.Record{Row: i + 2, Values: v}
+	}
+	return rows
+}
+
+func TestImport(t *testing.T) {
+	s, f := newTestImportService(t)
+	report, err := s.Import(records(
+		// A subtask listed before its parent row
+		map[string]string{"Name": "child", "status": "TODO", "parent_id": "a"},
+		map[string]string{"id": "a", "Name": "parent", "status": "IN_PROGRESS", "due_date": "2024-03-01"},
+		map[string]string{"Name": "under task 1", "status": "TODO", "parent_id": "1", "user_id": "99"},
+	), map[string]string{"Name": "title"}, false, 7)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if !report.Committed || report.Imported != 3 || len(report.Errors) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	// Parents are created before their subtasks
+	parent, child, other := f.repo.tasks[2], f.repo.tasks[3], f.repo.tasks[4]
+	if parent == nil || parent.Title != "parent" || parent.Status != models.TaskStatusInProgress || parent.DueDate == nil {
+		t.Fatalf("parent = %+v", parent)
+	}
+	if child.Title != "child" || child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Errorf("child = %+v, want it under the parent row", child)
+	}
+	// Tasks belong to the importer, whatever the file says
+	if *other.ParentID != 1 || *other.UserID != 7 {
+		t.Errorf("third task = %+v, want it under task 1 and owned by user 7", other)
+	}
+	if f.index.indexed[2] == nil {
+		t.Error("the imported tasks were not indexed")
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	s, f := newTestImportService(t)
+	report, err := s.Import(records(map[string]string{"title": "new", "status": "TODO"}), nil, true, 7)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if !report.DryRun || report.Committed || report.Imported != 1 || report.TaskIDs != nil {
+		t.Errorf("report = %+v", report)
+	}
+	if len(f.repo.tasks) != 1 {
+		t.Errorf("%d tasks, want the dry run to create none", len(f.repo.tasks))
+	}
+}
+
+func TestImportInvalidRows(t *testing.T) {
+	s, f := newTestImportService(t)
+	report, err := s.Import(records(
+		map[string]string{"title": "fine", "status": "DONE"},
+		map[string]string{"title": "", "status": "LATER", "due_date": "someday"},
+		map[string]string{"id": "a", "title": "cycle", "status": "TODO", "parent_id": "a"},
+	), nil, false, 7)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Committed || report.Imported != 0 || len(report.Errors) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if e := report.Errors[0]; e.Row != 3 || e.Errors["title"] != "required" || e.Errors["status"] != "oneof" ||
+		e.Errors["due_date"] != "datetime" {
+		t.Errorf("errors of row 3 = %+v", e)
+	}
+	if e := report.Errors[1]; e.Row != 4 || e.Errors["parent_id"] != "cycle" {
+		t.Errorf("errors of row 4 = %+v", e)
+	}
+	if len(f.repo.tasks) != 1 {
+		t.Errorf("%d tasks, want the valid row rolled back too", len(f.repo.tasks))
+	}
+}
+
+func TestImportRefusesBadRequests(t *testing.T) {
+	s, _ := newTestImportService(t)
+	tests := map[string]struct {
+		records []*taskfile.Record
+		mapping map[string]string
+	}{
+		"no rows":       {nil, nil},
+		"too many rows": {records(map[string]string{"title": "a"}, map[string]string{"title": "b"}, map[string]string{"title": "c"}, map[string]string{"title": "d"}), nil},
+		"unknown field": {records(map[string]string{"title": "a"}), map[string]string{"Owner": "user_id"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Import(tt.records, tt.mapping, false, 7)
+			wantStatus(t, err, 400)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/mail"
+	"time"
+)
+
+// InvitationService lets organisation admins invite people by email. The
+// role, organisation role and team of the account are chosen when inviting,
+// and the account is created when the invitation is accepted, whatever the
+// registration mode.
+type InvitationService interface {
+	// CreateInvitation invites someone on behalf of inviterID, replacing the
+	// invitations sent to the same address before
+	CreateInvitation(inviterID int, invitation *models.NewInvitation) (*models.Invitation, error)
+	ListInvitations() ([]*models.Invitation, error)
+	RevokeInvitation(id int) error
+	// AcceptInvitation creates the account an invitation is for and verifies
+	// its email address, which the token was delivered to
+	AcceptInvitation(acceptance *models.InvitationAcceptance) (*models.User, error)
+	// ForTenant returns a copy of the service confined to the invitations of
+	// the given organisation
+	ForTenant(orgID int) InvitationService
+}
+
+type invitationService struct {
+	repo        repository.InvitationRepository
+	orgRepo     repository.OrganizationRepository
+	teamRepo    repository.TeamRepository
+	userRepo    repository.UserRepository
+	userService UserService
+	tx          repository.TxRunner
+	mailer      mail.Sender
+	cfg         config.RegistrationConfig
+	orgID       int
+}
+
+// NewInvitationService creates a new InvitationService
+func NewInvitationService(repo repository.InvitationRepository, orgRepo repository.OrganizationRepository,
+	teamRepo repository.TeamRepository, userRepo repository.UserRepository, userService UserService,
+	tx repository.TxRunner, mailer mail.Sender, cfg config.RegistrationConfig) InvitationService {
+	return &invitationService{
+		repo: repo, orgRepo: orgRepo, teamRepo: teamRepo, userRepo: userRepo, userService: userService, tx: tx,
+		mailer: mailer, cfg: cfg,
+	}
+}
+
+func (s *invitationService) ForTenant(orgID int) InvitationService {
+	scoped := *s
+	scoped.repo = s.repo.ForTenant(orgID)
+	scoped.orgID = orgID
+	return &scoped
+}
+
+func (s *invitationService) CreateInvitation(inviterID int, newInvitation *models.NewInvitation) (*models.Invitation, error) {
+	if s.orgID == 0 {
+		return nil, apierrors.NewBadRequestError("invitations are sent from an organization")
+	}
+	// Emails are unique across organisations
+	if _, err := s.userRepo.AllTenants().GetUserByEmail(newInvitation.Email); err == nil {
+		return nil, apierrors.NewConflictError("a user with this email already exists")
+	}
+	if newInvitation.TeamID != nil {
+		if _, err := s.teamRepo.ForTenant(s.orgID).GetTeamByID(*newInvitation.TeamID); err != nil {
+			if err.Error() == "team not found" {
+				return nil, apierrors.NewBadRequestError(fmt.Sprintf("team %d does not exist", *newInvitation.TeamID))
+			}
+			return nil, err
+		}
+	}
+	org, err := s.orgRepo.GetOrganizationByID(s.orgID)
+	if err != nil {
+		return nil, err
+	}
+	inviter, err := s.userRepo.AllTenants().GetUserByID(inviterID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("error generating token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	invitation := &models.Invitation{
+		Email:     newInvitation.Email,
+		Role:      newInvitation.Role,
+		OrgRole:   newInvitation.OrgRole,
+		TeamID:    newInvitation.TeamID,
+		InvitedBy: &inviterID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.InvitationTTL),
+	}
+	if invitation.Role == "" {
+		invitation.Role = models.UserRoleUser
+	}
+	if invitation.OrgRole == "" {
+		invitation.OrgRole = models.OrgRoleMember
+	}
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.DeletePendingInvitations(invitation.Email); err != nil {
+			return err
+		}
+		return repo.CreateInvitation(invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inviterName := inviter.FullName
+	if inviterName == "" {
+		inviterName = inviter.Username
+	}
+	msg, err := renderEmail("invitation", invitation.Email, emailData{
+		Link:         strings.ReplaceAll(s.cfg.InviteURL, "{token}", url.QueryEscape(token)),
+		ExpiresIn:    humanDuration(s.cfg.InvitationTTL),
+		Organization: org.Name,
+		Inviter:      inviterName,
+	})
+	if err == nil {
+		err = s.mailer.Send(msg)
+	}
+	if err != nil {
+		// An invitation nobody received cannot be accepted; drop it
+		if err := s.repo.DeleteInvitation(invitation.ID); err != nil {
+			log.Printf("Error deleting unsent invitation %d: %v", invitation.ID, err)
+		}
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *invitationService) ListInvitations() ([]*models.Invitation, error) {
+	return s.repo.ListPendingInvitations()
+}
+
+func (s *invitationService) RevokeInvitation(id int) error {
+	invitation, err := s.repo.GetInvitationByID(id)
+	if err != nil {
+		return notFound(err, "invitation not found")
+	}
+	if invitation.AcceptedAt != nil {
+		return apierrors.NewConflictError("the invitation has already been accepted")
+	}
+	return notFound(s.repo.DeleteInvitation(id), "invitation not found")
+}
+
+func (s *invitationService) AcceptInvitation(acceptance *models.InvitationAcceptance) (*models.User, error) {
+	invalid := apierrors.NewBadRequestError("invalid or expired invitation")
+	invitation, err := s.repo.AllTenants().GetInvitationByHash(hashToken(acceptance.Token))
+	if err != nil {
+		if err.Error() == "invitation not found" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if invitation.AcceptedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, invalid
+	}
+
+	// Creating the account checks the username, the password policy and that
+	// the address is still free, which also stops the invitation being
+	// accepted twice
+	user, err := s.userService.ForTenant(invitation.OrgID).CreateUser(&models.NewUser{
+		Username: acceptance.Username,
+		Email:    invitation.Email,
+		Password: acceptance.Password,
+		FullName: acceptance.FullName,
+		Role:     invitation.Role,
+		OrgRole:  invitation.OrgRole,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		if err := s.repo.WithTx(tx).ForTenant(invitation.OrgID).MarkAccepted(invitation.ID); err != nil {
+			return err
+		}
+		if err := s.userRepo.WithTx(tx).ForTenant(invitation.OrgID).MarkEmailVerified(user.ID); err != nil {
+			return err
+		}
+		if invitation.TeamID == nil {
+			return nil
+		}
+		err := s.teamRepo.WithTx(tx).ForTenant(invitation.OrgID).AddTeamMember(*invitation.TeamID, user.ID)
+		if err != nil && err.Error() == "team not found" {
+			// The team was deleted while the invitation was pending
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.userService.ForTenant(invitation.OrgID).GetUserByID(user.ID)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+type fakeInvitationRepo struct {
+	repository.InvitationRepository
+	invitations map[int]*models.Invitation
+	orgID       int
+}
+
+func (r *fakeInvitationRepo) WithTx(tx *sql.Tx) repository.InvitationRepository { return r }
+
+// ForTenant returns a copy of the repository sharing its invitations
+func (r *fakeInvitationRepo) ForTenant(orgID int) repository.InvitationRepository {
+	scoped := *r
+	scoped.orgID = orgID
+	return &scoped
+}
+
+func (r *fakeInvitationRepo) AllTenants() repository.InvitationRepository { return r.ForTenant(0) }
+
+func (r *fakeInvitationRepo) CreateInvitation(invitation *models.Invitation) error {
+	invitation.ID = len(r.invitations) + 1
+	invitation.OrgID = r.orgID
+	stored := *invitation
+	r.invitations[invitation.ID] = &stored
+	return nil
+}
+
+func (r *fakeInvitationRepo) GetInvitationByHash(hash string) (*models.Invitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == hash && (r.orgID == 0 || invitation.OrgID == r.orgID) {
+			stored := *invitation
+			return &stored, nil
+		}
+	}
+	return nil, errors.New("invitation not found")
+}
+
+func (r *fakeInvitationRepo) DeletePendingInvitations(email string) error {
+	for id, invitation := range r.invitations {
+		if invitation.Email == email && invitation.AcceptedAt == nil && invitation.OrgID == r.orgID {
+			delete(r.invitations, id)
+		}
+	}
+	return nil
+}
+
+func (r *fakeInvitationRepo) MarkAccepted(id int) error {
+	invitation, ok := r.invitations[id]
+	if !ok || invitation.OrgID != r.orgID || invitation.AcceptedAt != nil {
+		return errors.New("invitation not found")
+	}
+	now := time.Now()
+	invitation.AcceptedAt = &now
+	return nil
+}
+
+type fakeTeamRepo struct {
+	repository.TeamRepository
+	teams   map[int]*models.Team
+	members map[int][]int
+}
+
+func (r *fakeTeamRepo) WithTx(tx *sql.Tx) repository.TeamRepository   { return r }
+func (r *fakeTeamRepo) ForTenant(orgID int) repository.TeamRepository { return r }
+
+func (r *fakeTeamRepo) GetTeamByID(id int) (*models.Team, error) {
+	team, ok := r.teams[id]
+	if !ok {
+		return nil, errors.New("team not found")
+	}
+	return team, nil
+}
+
+func (r *fakeTeamRepo) AddTeamMember(teamID, userID int) error {
+	if _, ok := r.teams[teamID]; !ok {
+		return errors.New("team not found")
+	}
+	r.members[teamID] = append(r.members[teamID], userID)
+	return nil
+}
+
+type fakeOrgRepo struct {
+	repository.OrganizationRepository
+}
+
+func (r *fakeOrgRepo) GetOrganizationByID(id int) (*models.Organization, error) {
+	return &models.Organization{ID: id, Name: "Acme"}, nil
+}
+
+func newTestUserService(users *fakeUserRepo, registration config.RegistrationConfig) UserService {
+	return NewUserService(users, &fakeOrgRepo{}, &fakeTx{}, &fakeOutbox{}, &fakeSessions{}, nil, nil,
+		newTestPasswordService(users, 0), config.AccountsConfig{}, registration)
+}
+
+type testInvitationService struct {
+	InvitationService
+	repo   *fakeInvitationRepo
+	teams  *fakeTeamRepo
+	users  *fakeUserRepo
+	mailer *fakeMailer
+}
+
+func newTestInvitationService(users ...*models.User) *testInvitationService {
+	cfg := config.RegistrationConfig{
+		InvitationTTL: 24 * time.Hour,
+		InviteURL:     "https://tasks.example.com/invite?token={token}",
+	}
+	f := &testInvitationService{
+		repo:   &fakeInvitationRepo{invitations: map[int]*models.Invitation{}},
+		teams:  &fakeTeamRepo{teams: map[int]*models.Team{1: {ID: 1, OrgID: 2, Name: "Ops"}}, members: map[int][]int{}},
+		users:  newFakeUserRepo(users...),
+		mailer: &fakeMailer{},
+	}
+	f.InvitationService = NewInvitationService(f.repo, &fakeOrgRepo{}, f.teams, f.users,
+		newTestUserService(f.users, cfg), &fakeTx{}, f.mailer, cfg)
+	return f
+}
+
+func TestRegistrationModes(t *testing.T) {
+	newUser := func(email string) *models.NewUser {
+		return &models.NewUser{Username: "gil", Email: email, Password: "long enough password", Role: models.UserRoleAdmin,
+			OrgRole: models.OrgRoleAdmin}
+	}
+
+	t.Run("invite only", func(t *testing.T) {
+		s := newTestUserService(newFakeUserRepo(), config.RegistrationConfig{Mode: models.RegistrationInviteOnly})
+		_, err := s.Register(newUser("gil@example.com"))
+		wantStatus(t, err, 403)
+	})
+
+	t.Run("domain allowlist", func(t *testing.T) {
+		s := newTestUserService(newFakeUserRepo(), config.RegistrationConfig{
+			Mode: models.RegistrationDomainAllowlist, AllowedDomains: []string{"@Example.com"},
+		})
+		_, err := s.Register(newUser("gil@example.org"))
+		wantStatus(t, err, 403)
+		// The domain of a subdomain is not the domain
+		_, err = s.Register(newUser("gil@mail.example.com"))
+		wantStatus(t, err, 403)
+		if _, err := s.Register(newUser("gil@EXAMPLE.com")); err != nil {
+			t.Errorf("Register at an allowed domain: %v", err)
+		}
+	})
+
+	t.Run("open", func(t *testing.T) {
+		s := newTestUserService(newFakeUserRepo(), config.RegistrationConfig{Mode: models.RegistrationOpen})
+		user, err := s.Register(newUser("gil@example.com"))
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if user.Role != models.UserRoleUser || user.OrgRole != "" {
+			t.Errorf("registered as %s, organisation role %q; want a plain user", user.Role, user.OrgRole)
+		}
+	})
+}
+
+func TestCreateInvitation(t *testing.T) {
+	admin := &models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true}
+
+	t.Run("without an organisation", func(t *testing.T) {
+		f := newTestInvitationService(admin)
+		_, err := f.CreateInvitation(1, &models.NewInvitation{Email: "new@example.com"})
+		wantStatus(t, err, 400)
+	})
+
+	t.Run("existing user", func(t *testing.T) {
+		f := newTestInvitationService(admin)
+		_, err := f.ForTenant(2).CreateInvitation(1, &models.NewInvitation{Email: "ann@example.com"})
+		wantStatus(t, err, 409)
+	})
+
+	t.Run("unknown team", func(t *testing.T) {
+		f := newTestInvitationService(admin)
+		_, err := f.ForTenant(2).CreateInvitation(1, &models.NewInvitation{Email: "new@example.com", TeamID: intPtr(9)})
+		wantStatus(t, err, 400)
+	})
+
+	t.Run("sent", func(t *testing.T) {
+		f := newTestInvitationService(admin)
+		s := f.ForTenant(2)
+		first, err := s.CreateInvitation(1, &models.NewInvitation{Email: "new@example.com"})
+		if err != nil {
+			t.Fatalf("CreateInvitation: %v", err)
+		}
+		if first.Role != models.UserRoleUser || first.OrgRole != models.OrgRoleMember || first.OrgID != 2 {
+			t.Errorf("invitation = %+v; want a member of organisation 2", first)
+		}
+		if len(f.mailer.sent) != 1 || f.mailer.sent[0].To != "new@example.com" {
+			t.Fatalf("sent %v", f.mailer.sent)
+		}
+		if hashToken(f.mailer.token(t)) != first.TokenHash {
+			t.Error("the emailed token does not match the invitation")
+		}
+
+		// Inviting again replaces the pending invitation
+		second, err := s.CreateInvitation(1, &models.NewInvitation{Email: "new@example.com"})
+		if err != nil {
+			t.Fatalf("CreateInvitation: %v", err)
+		}
+		if len(f.repo.invitations) != 1 || f.repo.invitations[second.ID] == nil {
+			t.Errorf("invitations = %v, want only the second", f.repo.invitations)
+		}
+	})
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	admin := &models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true}
+	f := newTestInvitationService(admin)
+	_, err := f.ForTenant(2).CreateInvitation(1, &models.NewInvitation{
+		Email: "new@example.com", Role: models.UserRoleAdmin, OrgRole: models.OrgRoleAdmin, TeamID: intPtr(1),
+	})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	token := f.mailer.token(t)
+
+	_, err = f.AcceptInvitation(&models.InvitationAcceptance{Token: "wrong", Username: "ned", Password: "long enough password"})
+	wantStatus(t, err, 400)
+
+	user, err := f.AcceptInvitation(&models.InvitationAcceptance{Token: token, Username: "ned", Password: "long enough password"})
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if user.Email != "new@example.com" || user.Role != models.UserRoleAdmin || user.OrgRole != models.OrgRoleAdmin {
+		t.Errorf("created %+v; want the invited address and roles", user)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("the email address was not verified")
+	}
+	if members := f.teams.members[1]; len(members) != 1 || members[0] != user.ID {
+		t.Errorf("team members = %v, want user %d", members, user.ID)
+	}
+
+	// Invitations are single use
+	_, err = f.AcceptInvitation(&models.InvitationAcceptance{Token: token, Username: "ned2", Password: "long enough password"})
+	wantStatus(t, err, 400)
+}
+
+func TestAcceptExpiredInvitation(t *testing.T) {
+	f := newTestInvitationService(&models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true})
+	invitation, err := f.ForTenant(2).CreateInvitation(1, &models.NewInvitation{Email: "new@example.com"})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	f.repo.invitations[invitation.ID].ExpiresAt = time.Now().Add(-time.Minute)
+
+	_, err = f.AcceptInvitation(&models.InvitationAcceptance{
+		Token: f.mailer.token(t), Username: "ned", Password: "long enough password",
+	})
+	wantStatus(t, err, 400)
+	if len(f.users.users) != 1 {
+		t.Error("an expired invitation created an account")
+	}
+}
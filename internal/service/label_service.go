@@ -0,0 +1,210 @@
+package service
+
+import (
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+)
+
+const defaultLabelColor = "#808080"
+
+// LabelService defines the interface for label management and tagging tasks
+type LabelService interface {
+	CreateLabel(label *models.Label) error
+	GetLabelByID(id int) (*models.Label, error)
+	ListLabels() ([]*models.Label, error)
+	UpdateLabel(id int, updates *models.UpdateLabel) (*models.Label, error)
+	DeleteLabel(id int) error
+	MergeLabels(sourceID, targetID int) (*models.Label, error)
+	GetTaskLabels(taskID int) ([]*models.Label, error)
+	AttachLabel(taskID, labelID int) error
+	DetachLabel(taskID, labelID int) error
+	// ForTenant returns a copy of the service confined to the labels and
+	// tasks of the given organisation
+	ForTenant(orgID int) LabelService
+}
+
+type labelService struct {
+	labelRepo repository.LabelRepository
+	taskRepo  repository.TaskRepository
+}
+
+// NewLabelService creates a new LabelService
+func NewLabelService(labelRepo repository.LabelRepository, taskRepo repository.TaskRepository) LabelService {
+	return &labelService{labelRepo: labelRepo, taskRepo: taskRepo}
+}
+
+func (s *labelService) ForTenant(orgID int) LabelService {
+	return &labelService{labelRepo: s.labelRepo.ForTenant(orgID), taskRepo: s.taskRepo.ForTenant(orgID)}
+}
+
+func (s *labelService) CreateLabel(label *models.Label) error {
+	if label.Color == "" {
+		label.Color = defaultLabelColor
+	}
+
+	if label.ProjectID != nil {
+		project, err := s.taskRepo.GetTaskByID(*label.ProjectID)
+		if err != nil {
+			if err.Error() == "task not found" {
+				return apierrors.NewBadRequestError("project not found")
+			}
+			return err
+		}
+		if project.ParentID != nil {
+			return apierrors.NewBadRequestError("labels can only be scoped to top-level tasks")
+		}
+	}
+
+	if _, err := s.labelRepo.GetLabelByName(label.Name, label.ProjectID); err == nil {
+		return apierrors.NewConflictError("label already exists")
+	}
+
+	// The unique key catches a label created since the check above
+	if err := s.labelRepo.CreateLabel(label); err != nil {
+		if err.Error() == "label already exists" {
+			return apierrors.NewConflictError(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *labelService) GetLabelByID(id int) (*models.Label, error) {
+	return s.getLabel(id)
+}
+
+func (s *labelService) ListLabels() ([]*models.Label, error) {
+	return s.labelRepo.ListLabels()
+}
+
+func (s *labelService) UpdateLabel(id int, updates *models.UpdateLabel) (*models.Label, error) {
+	label, err := s.getLabel(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if updates.Name != nil && *updates.Name != label.Name {
+		if _, err := s.labelRepo.GetLabelByName(*updates.Name, label.ProjectID); err == nil {
+			return nil, apierrors.NewConflictError("label already exists; merge the labels instead")
+		}
+	}
+
+	if err := s.labelRepo.UpdateLabel(id, updates); err != nil {
+		if err.Error() == "label already exists" {
+			return nil, apierrors.NewConflictError("label already exists; merge the labels instead")
+		}
+		return nil, err
+	}
+	return s.labelRepo.GetLabelByID(id)
+}
+
+func (s *labelService) DeleteLabel(id int) error {
+	if err := s.labelRepo.DeleteLabel(id); err != nil {
+		if err.Error() == "label not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// MergeLabels folds the source label into the target label. A project label
+// can be merged into a global label or one of the same project, never the
+// other way round, so no task ends up with a label from another project.
+func (s *labelService) MergeLabels(sourceID, targetID int) (*models.Label, error) {
+	if sourceID == targetID {
+		return nil, apierrors.NewBadRequestError("a label cannot be merged into itself")
+	}
+
+	source, err := s.getLabel(sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getLabel(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.ProjectID != nil && (source.ProjectID == nil || *source.ProjectID != *target.ProjectID) {
+		return nil, apierrors.NewBadRequestError("cannot merge into a label of a different project")
+	}
+
+	if err := s.labelRepo.MergeLabels(source.ID, target.ID); err != nil {
+		return nil, err
+	}
+	return s.labelRepo.GetLabelByID(target.ID)
+}
+
+func (s *labelService) GetTaskLabels(taskID int) ([]*models.Label, error) {
+	if _, err := s.taskRepo.GetTaskByID(taskID); err != nil {
+		return nil, err
+	}
+	return s.labelRepo.GetTaskLabels(taskID)
+}
+
+func (s *labelService) AttachLabel(taskID, labelID int) error {
+	label, err := s.getLabel(labelID)
+	if err != nil {
+		return err
+	}
+
+	projectID, err := projectOf(s.taskRepo, taskID)
+	if err != nil {
+		return err
+	}
+	if label.ProjectID != nil && *label.ProjectID != projectID {
+		return apierrors.NewBadRequestError("label belongs to a different project")
+	}
+
+	return s.labelRepo.AttachLabel(taskID, labelID)
+}
+
+func (s *labelService) DetachLabel(taskID, labelID int) error {
+	if _, err := s.taskRepo.GetTaskByID(taskID); err != nil {
+		if err.Error() == "task not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	if err := s.labelRepo.DetachLabel(taskID, labelID); err != nil {
+		if err.Error() == "label not attached to task" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *labelService) getLabel(id int) (*models.Label, error) {
+	label, err := s.labelRepo.GetLabelByID(id)
+	if err != nil {
+		if err.Error() == "label not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	return label, nil
+}
+
+// taskGetter reads a task, like both TaskRepository and TaskService do
+type taskGetter interface {
+	GetTaskByID(id int) (*models.Task, error)
+}
+
+// projectOf returns the ID of the top-level task the given task belongs to
+func projectOf(taskRepo taskGetter, taskID int) (int, error) {
+	task, err := taskRepo.GetTaskByID(taskID)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return 0, apierrors.NewNotFoundError(err.Error())
+		}
+		return 0, err
+	}
+	for task.ParentID != nil {
+		if task, err = taskRepo.GetTaskByID(*task.ParentID); err != nil {
+			return 0, err
+		}
+	}
+	return task.ID, nil
+}
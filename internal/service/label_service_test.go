@@ -0,0 +1,178 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+)
+
+type fakeLabelRepo struct {
+	repository.LabelRepository
+	labels map[int]*models.Label
+	// attached maps a task to the labels attached to it
+	attached map[int][]int
+	merged   [][2]int
+	// detached maps a task to the labels detached from it
+	detached map[int][]int
+	// orgID is the organisation the repository was restricted to, if any
+	orgID int
+}
+
+func newFakeLabelRepo(labels ...*models.Label) *fakeLabelRepo {
+	r := &fakeLabelRepo{labels: map[int]*models.Label{}, attached: map[int][]int{}, detached: map[int][]int{}}
+	for _, label := range labels {
+		r.labels[label.ID] = label
+	}
+	return r
+}
+
+func (r *fakeLabelRepo) WithTx(tx *sql.Tx) repository.LabelRepository { return r }
+
+func (r *fakeLabelRepo) ForTenant(orgID int) repository.LabelRepository {
+	scoped := *r
+	scoped.orgID = orgID
+	return &scoped
+}
+
+func (r *fakeLabelRepo) GetLabelByID(id int) (*models.Label, error) {
+	label, ok := r.labels[id]
+	if !ok || (r.orgID != 0 && label.OrgID != r.orgID) {
+		return nil, fmt.Errorf("label not found")
+	}
+	return label, nil
+}
+
+func (r *fakeLabelRepo) AttachLabel(taskID, labelID int) error {
+	r.attached[taskID] = append(r.attached[taskID], labelID)
+	return nil
+}
+
+func (r *fakeLabelRepo) DetachLabel(taskID, labelID int) error {
+	r.detached[taskID] = append(r.detached[taskID], labelID)
+	return nil
+}
+
+func (r *fakeLabelRepo) MergeLabels(sourceID, targetID int) error {
+	r.merged = append(r.merged, [2]int{sourceID, targetID})
+	return nil
+}
+
+// racedLabelRepo has a label of the same name created by another request
+// between the check for one and the insert
+type racedLabelRepo struct {
+	*fakeLabelRepo
+}
+
+func (r racedLabelRepo) GetLabelByName(name string, projectID *int) (*models.Label, error) {
+	return nil, fmt.Errorf("label not found")
+}
+
+func (r racedLabelRepo) CreateLabel(label *models.Label) error {
+	return fmt.Errorf("label already exists")
+}
+
+func (r racedLabelRepo) GetLabelByID(id int) (*models.Label, error) {
+	return &models.Label{ID: id, Name: "old"}, nil
+}
+
+func (r racedLabelRepo) UpdateLabel(id int, updates *models.UpdateLabel) error {
+	return fmt.Errorf("label already exists")
+}
+
+func TestLabelServiceReportsDuplicatesFromTheDatabase(t *testing.T) {
+	s := NewLabelService(racedLabelRepo{newFakeLabelRepo()}, newFakeTaskRepo())
+	wantStatus(t, s.CreateLabel(&models.Label{Name: "bug"}), 409)
+	name := "bug"
+	_, err := s.UpdateLabel(1, &models.UpdateLabel{Name: &name})
+	wantStatus(t, err, 409)
+}
+
+func TestAttachLabelChecksProject(t *testing.T) {
+	// Projects 1 and 3, with 2 a subtask of 1
+	tasks := newFakeTaskRepo(todo(1, nil), todo(2, intPtr(1)), todo(3, nil))
+	labels := newFakeLabelRepo(
+		&models.Label{ID: 10, Name: "global"},
+		&models.Label{ID: 11, Name: "of 1", ProjectID: intPtr(1)},
+	)
+	s := NewLabelService(labels, tasks)
+
+	tests := []struct {
+		name            string
+		taskID, labelID int
+		wantStatus      int
+	}{
+		{"global label", 3, 10, 0},
+		{"label of the project", 2, 11, 0},
+		{"label of another project", 3, 11, 400},
+		{"missing label", 2, 99, 404},
+		{"missing task", 99, 10, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.AttachLabel(tt.taskID, tt.labelID)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("AttachLabel: %v", err)
+				}
+				return
+			}
+			if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != tt.wantStatus {
+				t.Fatalf("AttachLabel = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMergeLabelsKeepsProjectLabelsInTheirProject(t *testing.T) {
+	labels := newFakeLabelRepo(
+		&models.Label{ID: 10, Name: "global"},
+		&models.Label{ID: 11, Name: "of 1", ProjectID: intPtr(1)},
+		&models.Label{ID: 12, Name: "also of 1", ProjectID: intPtr(1)},
+		&models.Label{ID: 13, Name: "of 3", ProjectID: intPtr(3)},
+	)
+	s := NewLabelService(labels, newFakeTaskRepo(todo(1, nil), todo(3, nil)))
+
+	for _, ok := range [][2]int{{11, 10}, {12, 11}} {
+		if _, err := s.MergeLabels(ok[0], ok[1]); err != nil {
+			t.Errorf("MergeLabels(%d, %d): %v", ok[0], ok[1], err)
+		}
+	}
+	for _, refused := range [][2]int{{10, 11}, {13, 11}, {11, 11}} {
+		if _, err := s.MergeLabels(refused[0], refused[1]); err == nil {
+			t.Errorf("MergeLabels(%d, %d) succeeded", refused[0], refused[1])
+		}
+	}
+	if len(labels.merged) != 2 {
+		t.Errorf("%d merges ran, want 2", len(labels.merged))
+	}
+}
+
+func TestLabelServiceForTenant(t *testing.T) {
+	labels := newFakeLabelRepo(
+		&models.Label{ID: 10, OrgID: 1, Name: "ours"},
+		&models.Label{ID: 11, OrgID: 2, Name: "theirs"},
+	)
+	s := NewLabelService(labels, newFakeTaskRepo(todo(1, nil))).ForTenant(1)
+
+	if _, err := s.GetLabelByID(10); err != nil {
+		t.Errorf("GetLabelByID of our label: %v", err)
+	}
+	_, err := s.GetLabelByID(11)
+	wantStatus(t, err, 404)
+	wantStatus(t, s.AttachLabel(1, 11), 404)
+	if len(labels.attached) != 0 {
+		t.Errorf("attached %v", labels.attached)
+	}
+
+	// Detaching checks the task like attaching does
+	wantStatus(t, s.DetachLabel(99, 10), 404)
+	if err := s.DetachLabel(1, 10); err != nil {
+		t.Fatalf("DetachLabel: %v", err)
+	}
+	if len(labels.detached) != 1 || len(labels.detached[1]) != 1 {
+		t.Errorf("detached %v, want only task 1", labels.detached)
+	}
+}
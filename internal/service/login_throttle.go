@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"time"
+)
+
+// LoginLockedError refuses a login attempt made while the username or the
+// client address is locked after too many failures
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed login attempts"
+}
+
+// LoginThrottle protects logins against password guessing. Failures are
+// counted per username within an organisation, whether or not it exists,
+// and per client address.
+// Past a threshold each attempt has to wait a growing delay, and past a
+// higher one the username or address is locked for a while.
+type LoginThrottle interface {
+	// Check refuses an attempt while the username or the address is locked
+	Check(orgID int, username, ip string) error
+	// Failed counts a failed attempt. user is nil if no account has the username.
+	Failed(orgID int, user *models.User, username, ip string)
+	// Succeeded clears the failures counted against the username
+	Succeeded(orgID int, username string)
+	// Unlock lifts a lock on a user's account, on behalf of an admin
+	Unlock(actorID, userID int) error
+	ListBlockedIPs() ([]*models.LoginThrottle, error)
+	UnblockIP(actorID int, ip string) error
+	// StartCleanup forgets old failures periodically until ctx is done
+	StartCleanup(ctx context.Context)
+}
+
+type loginThrottle struct {
+	repo     repository.LoginThrottleRepository
+	userRepo repository.UserRepository
+	outbox   repository.OutboxRepository
+	cfg      config.LockoutConfig
+}
+
+// NewLoginThrottle creates a new LoginThrottle
+func NewLoginThrottle(repo repository.LoginThrottleRepository, userRepo repository.UserRepository,
+	outbox repository.OutboxRepository, cfg config.LockoutConfig) LoginThrottle {
+	return &loginThrottle{repo: repo, userRepo: userRepo, outbox: outbox, cfg: cfg}
+}
+
+// accountKey normalises a username, which matches case-insensitively, and
+// qualifies it with the organisation it is unique in
+func accountKey(orgID int, username string) string {
+	return fmt.Sprintf("%d:%s", orgID, strings.ToLower(strings.TrimSpace(username)))
+}
+
+func (t *loginThrottle) Check(orgID int, username, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+	for scope, key := range map[models.ThrottleScope]string{
+		models.ThrottleScopeAccount: accountKey(orgID, username),
+		models.ThrottleScopeIP:      ip,
+	} {
+		throttle, err := t.repo.GetThrottle(scope, key)
+		if err != nil {
+			if err.Error() == "throttle not found" {
+				continue
+			}
+			return err
+		}
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) && throttle.LockedUntil.Sub(now) > wait {
+			wait = throttle.LockedUntil.Sub(now)
+		}
+	}
+
+	if wait > 0 {
+		return &LoginLockedError{RetryAfter: wait.Truncate(time.Second) + time.Second}
+	}
+	return nil
+}
+
+func (t *loginThrottle) Failed(orgID int, user *models.User, username, ip string) {
+	data := events.SecurityData{Username: username, IPAddress: ip}
+	if user != nil {
+		data.UserID = user.ID
+	}
+
+	account, err := t.repo.RecordFailure(models.ThrottleScopeAccount, accountKey(orgID, username), t.cfg.Window)
+	if err != nil {
+		log.Printf("Error recording failed login for %q: %v", username, err)
+	} else {
+		data.Failures = account.Failures
+		until, lockout := t.lockUntil(account.Failures, t.cfg.MaxAccountFailures, t.cfg.AccountLockout)
+		if until != nil {
+			if err := t.repo.SetLockedUntil(models.ThrottleScopeAccount, account.Key, *until); err != nil {
+				log.Printf("Error locking logins for %q: %v", username, err)
+			}
+			if lockout && user != nil {
+				audit(t.outbox, events.UserLocked, events.SecurityData{
+					UserID: user.ID, Username: username, IPAddress: ip, Failures: account.Failures, LockedUntil: until,
+				})
+			}
+		}
+	}
+	audit(t.outbox, events.UserLoginFailed, data)
+
+	if ip == "" {
+		return
+	}
+	address, err := t.repo.RecordFailure(models.ThrottleScopeIP, ip, t.cfg.Window)
+	if err != nil {
+		log.Printf("Error recording failed login from %s: %v", ip, err)
+		return
+	}
+	until, lockout := t.lockUntil(address.Failures, t.cfg.MaxIPFailures, t.cfg.IPLockout)
+	if until != nil {
+		if err := t.repo.SetLockedUntil(models.ThrottleScopeIP, ip, *until); err != nil {
+			log.Printf("Error blocking logins from %s: %v", ip, err)
+		}
+		if lockout {
+			audit(t.outbox, events.IPBlocked, events.SecurityData{IPAddress: ip, Failures: address.Failures, LockedUntil: until})
+		}
+	}
+}
+
+// lockUntil returns how long further attempts have to wait after the given
+// number of failures, if at all, and whether that is a full lockout
+func (t *loginThrottle) lockUntil(failures, maxFailures int, lockout time.Duration) (*time.Time, bool) {
+	now := time.Now()
+	if maxFailures > 0 && failures >= maxFailures {
+		until := now.Add(lockout)
+		return &until, true
+	}
+	if failures < t.cfg.DelayAfter {
+		return nil, false
+	}
+
+	delay := t.cfg.BaseDelay
+	for i := t.cfg.DelayAfter; i < failures && delay < t.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.cfg.MaxDelay {
+		delay = t.cfg.MaxDelay
+	}
+	until := now.Add(delay)
+	return &until, false
+}
+
+func (t *loginThrottle) Succeeded(orgID int, username string) {
+	if err := t.repo.DeleteThrottle(models.ThrottleScopeAccount, accountKey(orgID, username)); err != nil {
+		log.Printf("Error clearing failed logins for %q: %v", username, err)
+	}
+}
+
+func (t *loginThrottle) Unlock(actorID, userID int) error {
+	user, err := t.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := t.repo.DeleteThrottle(models.ThrottleScopeAccount, accountKey(user.OrgID, user.Username)); err != nil {
+		return err
+	}
+
+	audit(t.outbox, events.UserUnlocked, events.SecurityData{UserID: user.ID, Username: user.Username, ActorID: &actorID})
+	return nil
+}
+
+func (t *loginThrottle) ListBlockedIPs() ([]*models.LoginThrottle, error) {
+	return t.repo.ListLocked(models.ThrottleScopeIP, time.Now())
+}
+
+func (t *loginThrottle) UnblockIP(actorID int, ip string) error {
+	if _, err := t.repo.GetThrottle(models.ThrottleScopeIP, ip); err != nil {
+		if err.Error() == "throttle not found" {
+			return apierrors.NewNotFoundError(fmt.Sprintf("no failed logins from %s", ip))
+		}
+		return err
+	}
+	if err := t.repo.DeleteThrottle(models.ThrottleScopeIP, ip); err != nil {
+		return err
+	}
+
+	audit(t.outbox, events.IPUnblocked, events.SecurityData{IPAddress: ip, ActorID: &actorID})
+	return nil
+}
+
+func (t *loginThrottle) StartCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(t.cfg.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.repo.DeleteStale(time.Now().Add(-t.cfg.Window)); err != nil {
+					log.Printf("Error cleaning up failed logins: %v", err)
+				}
+			}
+		}
+	}()
+}
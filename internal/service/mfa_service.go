@@ -0,0 +1,419 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/jwt"
+	"task-management-api/pkg/totp"
+	"time"
+)
+
+// Purposes of the challenge tokens handed out between the two login steps
+const (
+	challengeMFA       = "mfa"
+	challengeMFAEnroll = "mfa_enroll"
+)
+
+var errInvalidCode = apierrors.NewForbiddenError("invalid two-factor code")
+
+// MFAService manages two-factor authentication with authenticator apps
+// (TOTP) and one-time recovery codes, and the second step of logging in.
+// Every change, failed code and use of a recovery code is recorded as a
+// security event.
+type MFAService interface {
+	Status(userID int) (*models.MFAStatus, error)
+	// StartEnrollment creates a new secret for the user's authenticator app;
+	// it takes effect once confirmed with a code from the app
+	StartEnrollment(userID int, password string) (*models.MFAEnrollment, error)
+	// ConfirmEnrollment enables two-factor authentication and returns the
+	// recovery codes, which are not shown again
+	ConfirmEnrollment(userID int, code string, client models.ClientInfo) ([]string, error)
+	Disable(userID int, disable *models.MFADisable, client models.ClientInfo) error
+	RegenerateRecoveryCodes(userID int, code string, client models.ClientInfo) ([]string, error)
+	// Reset turns off two-factor authentication for a user who lost their
+	// device, on behalf of an admin
+	Reset(actorID, userID int) error
+	GetPolicy() (*models.MFAPolicy, error)
+	SetPolicy(policy *models.MFAPolicy) (*models.MFAPolicy, error)
+
+	// Challenge decides whether a login whose password was accepted needs a
+	// second step. It returns nil if not.
+	Challenge(user *models.User) (*models.LoginResult, error)
+	// CompleteLogin finishes a login with a code from the authenticator app
+	// or a recovery code
+	CompleteLogin(mfaToken, code string, client models.ClientInfo) (*models.LoginResult, error)
+	// StartLoginEnrollment and CompleteLoginEnrollment let a user whose role
+	// requires two-factor authentication enrol while logging in
+	StartLoginEnrollment(mfaToken string) (*models.MFAEnrollment, error)
+	CompleteLoginEnrollment(mfaToken, code string, client models.ClientInfo) (*models.LoginResult, error)
+}
+
+type mfaService struct {
+	userRepo repository.UserRepository
+	mfaRepo  repository.MFARepository
+	tx       repository.TxRunner
+	outbox   repository.OutboxRepository
+	sessions SessionService
+	throttle LoginThrottle
+	cfg      config.MFAConfig
+}
+
+// NewMFAService creates a new MFAService. Failed codes at login count
+// towards the throttle's limits like wrong passwords.
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, tx repository.TxRunner,
+	outbox repository.OutboxRepository, sessions SessionService, throttle LoginThrottle, cfg config.MFAConfig) MFAService {
+	return &mfaService{userRepo: userRepo, mfaRepo: mfaRepo, tx: tx, outbox: outbox, sessions: sessions, throttle: throttle, cfg: cfg}
+}
+
+func (s *mfaService) Status(userID int) (*models.MFAStatus, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := s.required(user)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.mfaRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAStatus{
+		Enabled:                user.MFAEnabledAt != nil,
+		EnabledAt:              user.MFAEnabledAt,
+		Required:               required,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+func (s *mfaService) StartEnrollment(userID int, password string) (*models.MFAEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(user)
+}
+
+func (s *mfaService) startEnrollment(user *models.User) (*models.MFAEnrollment, error) {
+	if user.MFAEnabledAt != nil {
+		return nil, apierrors.NewConflictError("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetMFASecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.cfg.Issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *mfaService) ConfirmEnrollment(userID int, code string, client models.ClientInfo) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.confirmEnrollment(user, code, client)
+}
+
+func (s *mfaService) confirmEnrollment(user *models.User, code string, client models.ClientInfo) ([]string, error) {
+	if user.MFAEnabledAt != nil {
+		return nil, apierrors.NewConflictError("two-factor authentication is already enabled")
+	}
+	if user.MFASecret == "" {
+		return nil, apierrors.NewBadRequestError("two-factor enrolment has not been started")
+	}
+
+	step, ok := totp.Validate(user.MFASecret, code, time.Now(), s.cfg.Skew)
+	if !ok {
+		audit(s.outbox, events.UserMFAFailed, events.SecurityData{UserID: user.ID, IPAddress: client.IPAddress, Method: "totp"})
+		return nil, errInvalidCode
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		users := s.userRepo.WithTx(tx)
+		if err := users.UseMFAStep(user.ID, step); err != nil {
+			return err
+		}
+		if err := users.EnableMFA(user.ID); err != nil {
+			return err
+		}
+		if err := s.mfaRepo.WithTx(tx).ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserMFAEnabled,
+			events.SecurityData{UserID: user.ID, IPAddress: client.IPAddress, Method: "totp"}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Disable(userID int, disable *models.MFADisable, client models.ClientInfo) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, disable.Password); err != nil {
+		return err
+	}
+	if user.MFAEnabledAt == nil {
+		return apierrors.NewBadRequestError("two-factor authentication is not enabled")
+	}
+	required, err := s.required(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return apierrors.NewForbiddenError("two-factor authentication is required for your role")
+	}
+	if err := s.verify(user, disable.Code, client, true); err != nil {
+		return err
+	}
+
+	return s.disable(user.ID, events.SecurityData{UserID: user.ID, IPAddress: client.IPAddress})
+}
+
+func (s *mfaService) Reset(actorID, userID int) error {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return err
+	}
+	return s.disable(userID, events.SecurityData{UserID: userID, ActorID: &actorID})
+}
+
+func (s *mfaService) disable(userID int, data events.SecurityData) error {
+	return s.tx.RunInTx(func(tx *sql.Tx) error {
+		if err := s.userRepo.WithTx(tx).DisableMFA(userID); err != nil {
+			return err
+		}
+		if err := s.mfaRepo.WithTx(tx).DeleteRecoveryCodes(userID); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserMFADisabled, data))
+	})
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(userID int, code string, client models.ClientInfo) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		return nil, apierrors.NewBadRequestError("two-factor authentication is not enabled")
+	}
+	// Only the authenticator app will do here, so that a leaked recovery
+	// code cannot be turned into a fresh set
+	if err := s.verify(user, code, client, false); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		if err := s.mfaRepo.WithTx(tx).ReplaceRecoveryCodes(userID, hashes); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserMFARecoveryCodesGenerated,
+			events.SecurityData{UserID: userID, IPAddress: client.IPAddress}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) GetPolicy() (*models.MFAPolicy, error) {
+	roles, err := s.mfaRepo.GetRequiredRoles()
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAPolicy{RequiredRoles: roles}, nil
+}
+
+func (s *mfaService) SetPolicy(policy *models.MFAPolicy) (*models.MFAPolicy, error) {
+	if err := s.mfaRepo.SetRequiredRoles(policy.RequiredRoles); err != nil {
+		return nil, err
+	}
+	return s.GetPolicy()
+}
+
+func (s *mfaService) Challenge(user *models.User) (*models.LoginResult, error) {
+	purpose := challengeMFA
+	if user.MFAEnabledAt == nil {
+		required, err := s.required(user)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		purpose = challengeMFAEnroll
+	}
+
+	token, err := jwt.GenerateChallengeToken(user.ID, purpose, s.cfg.ChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error generating challenge token: %v", err)
+	}
+	return &models.LoginResult{
+		MFARequired:           purpose == challengeMFA,
+		MFAEnrollmentRequired: purpose == challengeMFAEnroll,
+		MFAToken:              token,
+	}, nil
+}
+
+func (s *mfaService) CompleteLogin(mfaToken, code string, client models.ClientInfo) (*models.LoginResult, error) {
+	user, err := s.challengedUser(mfaToken, challengeMFA)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		return nil, apierrors.NewUnauthorizedError("invalid or expired MFA token")
+	}
+	if err := s.throttle.Check(user.OrgID, user.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+	if err := s.verify(user, code, client, true); err != nil {
+		if err == errInvalidCode {
+			s.throttle.Failed(user.OrgID, user, user.Username, client.IPAddress)
+			return nil, apierrors.NewUnauthorizedError(err.Error())
+		}
+		return nil, err
+	}
+
+	token, err := s.sessions.StartSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	s.throttle.Succeeded(user.OrgID, user.Username)
+	return &models.LoginResult{User: user, Token: token}, nil
+}
+
+func (s *mfaService) StartLoginEnrollment(mfaToken string) (*models.MFAEnrollment, error) {
+	user, err := s.challengedUser(mfaToken, challengeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(user)
+}
+
+func (s *mfaService) CompleteLoginEnrollment(mfaToken, code string, client models.ClientInfo) (*models.LoginResult, error) {
+	user, err := s.challengedUser(mfaToken, challengeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.throttle.Check(user.OrgID, user.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+	codes, err := s.confirmEnrollment(user, code, client)
+	if err != nil {
+		if err == errInvalidCode {
+			s.throttle.Failed(user.OrgID, user, user.Username, client.IPAddress)
+		}
+		return nil, err
+	}
+
+	token, err := s.sessions.StartSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	s.throttle.Succeeded(user.OrgID, user.Username)
+	return &models.LoginResult{User: user, Token: token, RecoveryCodes: codes}, nil
+}
+
+// challengedUser returns the user a challenge token was issued to
+func (s *mfaService) challengedUser(mfaToken, purpose string) (*models.User, error) {
+	claims, err := jwt.ValidateChallengeToken(mfaToken, purpose)
+	if err != nil {
+		return nil, apierrors.NewUnauthorizedError("invalid or expired MFA token")
+	}
+	return s.userRepo.GetUserByID(claims.UserID)
+}
+
+// required reports whether the user's role must use two-factor authentication
+func (s *mfaService) required(user *models.User) (bool, error) {
+	roles, err := s.mfaRepo.GetRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role == user.Role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// verify checks a code from the user's authenticator app or, if allowed, one
+// of their recovery codes. Either can be used only once.
+func (s *mfaService) verify(user *models.User, code string, client models.ClientInfo, allowRecovery bool) error {
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), s.cfg.Skew); ok {
+		err := s.userRepo.UseMFAStep(user.ID, step)
+		if err == nil {
+			return nil
+		}
+		if err.Error() != "code already used" {
+			return err
+		}
+	} else if allowRecovery {
+		err := s.mfaRepo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+		if err == nil {
+			audit(s.outbox, events.UserMFARecoveryCodeUsed,
+				events.SecurityData{UserID: user.ID, IPAddress: client.IPAddress, Method: "recovery_code"})
+			return nil
+		}
+		if err.Error() != "recovery code not found" {
+			return err
+		}
+	}
+
+	audit(s.outbox, events.UserMFAFailed, events.SecurityData{UserID: user.ID, IPAddress: client.IPAddress})
+	return errInvalidCode
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns new recovery codes, formatted for the user,
+// and their hashes for storage
+func (s *mfaService) generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < s.cfg.RecoveryCodes; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("error generating recovery code: %v", err)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode undoes the formatting of a recovery code, so that it
+// can be typed with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,780 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/jwt"
+	"time"
+)
+
+// refreshTokenPrefix starts every refresh token, telling them apart from
+// access tokens at the introspection and revocation endpoints
+const refreshTokenPrefix = "ort_"
+
+// OAuthError is an error from the OAuth protocol endpoints, which answer in
+// the form RFC 6749 prescribes rather than the API's own
+type OAuthError struct {
+	Code        string
+	Description string
+	StatusCode  int
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	status := http.StatusBadRequest
+	if code == "invalid_client" {
+		status = http.StatusUnauthorized
+	}
+	return &OAuthError{Code: code, Description: description, StatusCode: status}
+}
+
+// OAuthService lets third-party apps act for users without their password.
+// Users register apps, which get access tokens through the authorization
+// code grant with PKCE, after the user consents to the requested scopes, or
+// through the client credentials grant, acting for the user who registered
+// them. Scopes are those of personal access tokens. Access tokens are JWTs
+// from pkg/jwt, backed by a record so that they can be revoked.
+type OAuthService interface {
+	// RegisterClient returns the new app with its secret, if it has one,
+	// which is not shown again
+	RegisterClient(ownerID int, newClient *models.NewOAuthClient) (*models.OAuthClient, error)
+	// ListClients lists the apps the user registered, or all of them for admins
+	ListClients(userID int, role models.UserRole) ([]*models.OAuthClient, error)
+	GetClient(userID int, role models.UserRole, id int) (*models.OAuthClient, error)
+	UpdateClient(userID int, role models.UserRole, id int, updates *models.UpdateOAuthClient) (*models.OAuthClient, error)
+	DeleteClient(userID int, role models.UserRole, id int) error
+	// RotateSecret replaces a confidential app's secret and returns the new one
+	RotateSecret(userID int, role models.UserRole, id int) (*models.OAuthClient, error)
+
+	// PrepareAuthorization checks an authorization request and describes
+	// what the user is asked to allow
+	PrepareAuthorization(userID int, req *models.AuthorizationRequest) (*models.AuthorizationPrompt, error)
+	// Authorize records the user's decision and returns where to send them
+	// back to the app, with an authorization code if they approved
+	Authorize(userID int, decision *models.AuthorizationDecision) (string, error)
+	// Token serves the token endpoint; the client may authenticate with
+	// HTTP Basic credentials or in the request
+	Token(req *models.TokenRequest) (*models.TokenResponse, error)
+	Introspect(req *models.TokenLookup) (*models.TokenIntrospection, error)
+	// Revoke revokes a token issued to the client; unknown tokens are ignored
+	Revoke(req *models.TokenLookup) error
+
+	// CheckAccessToken verifies that the token behind an OAuth access token
+	// is still valid, and returns its user and scopes
+	CheckAccessToken(tokenID string) (*models.User, []string, error)
+
+	// ListAuthorizations lists the apps the user allowed to act for them
+	ListAuthorizations(userID int) ([]*models.OAuthConsent, error)
+	// RevokeAuthorization withdraws the user's consent for an app and
+	// revokes the tokens it was given
+	RevokeAuthorization(userID int, clientID string) error
+	// StartCleanup deletes expired codes and tokens periodically until ctx is done
+	StartCleanup(ctx context.Context)
+}
+
+type oauthService struct {
+	repo     repository.OAuthRepository
+	userRepo repository.UserRepository
+	tx       repository.TxRunner
+	outbox   repository.OutboxRepository
+	cfg      config.OAuthConfig
+}
+
+// NewOAuthService creates a new OAuthService
+func NewOAuthService(repo repository.OAuthRepository, userRepo repository.UserRepository, tx repository.TxRunner,
+	outbox repository.OutboxRepository, cfg config.OAuthConfig) OAuthService {
+	return &oauthService{repo: repo, userRepo: userRepo, tx: tx, outbox: outbox, cfg: cfg}
+}
+
+// randomSecret returns a random string with n bytes of entropy
+func randomSecret(prefix string, n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("error generating secret: %v", err)
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRedirectURIs checks that redirect URIs are absolute and carry no
+// fragment, as RFC 6749 requires
+func validateRedirectURIs(uris []string) error {
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return apierrors.NewBadRequestError(fmt.Sprintf("invalid redirect URI %q: it must be absolute, without a fragment", uri))
+		}
+	}
+	return nil
+}
+
+func (s *oauthService) RegisterClient(ownerID int, newClient *models.NewOAuthClient) (*models.OAuthClient, error) {
+	scopes, err := validateScopes(newClient.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	var grantTypes []string
+	for _, grant := range newClient.GrantTypes {
+		if !hasString(grantTypes, grant) {
+			grantTypes = append(grantTypes, grant)
+		}
+	}
+	if hasString(grantTypes, models.GrantAuthorizationCode) && len(newClient.RedirectURIs) == 0 {
+		return nil, apierrors.NewBadRequestError("apps using the authorization code grant need a redirect URI")
+	}
+	if hasString(grantTypes, models.GrantClientCredentials) && !newClient.Confidential {
+		return nil, apierrors.NewBadRequestError("only confidential apps can use the client credentials grant")
+	}
+	if err := validateRedirectURIs(newClient.RedirectURIs); err != nil {
+		return nil, err
+	}
+
+	clientID, err := randomSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	client := &models.OAuthClient{
+		ClientID:     clientID,
+		OwnerID:      ownerID,
+		Name:         newClient.Name,
+		RedirectURIs: newClient.RedirectURIs,
+		Scopes:       scopes,
+		GrantTypes:   grantTypes,
+	}
+	var secret string
+	if newClient.Confidential {
+		if secret, err = randomSecret("", 32); err != nil {
+			return nil, err
+		}
+		client.SecretHash = hashToken(secret)
+		client.Confidential = true
+	}
+
+	if err := s.repo.CreateClient(client); err != nil {
+		return nil, err
+	}
+	client.ClientSecret = secret
+	return client, nil
+}
+
+func (s *oauthService) ListClients(userID int, role models.UserRole) ([]*models.OAuthClient, error) {
+	if role == models.UserRoleAdmin {
+		return s.repo.ListClients(0)
+	}
+	return s.repo.ListClients(userID)
+}
+
+// GetClient returns an app to its owner or an admin; to anyone else it does
+// not exist
+func (s *oauthService) GetClient(userID int, role models.UserRole, id int) (*models.OAuthClient, error) {
+	client, err := s.repo.GetClient(id)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return nil, apierrors.NewNotFoundError("app not found")
+		}
+		return nil, err
+	}
+	if client.OwnerID != userID && role != models.UserRoleAdmin {
+		return nil, apierrors.NewNotFoundError("app not found")
+	}
+	return client, nil
+}
+
+func (s *oauthService) UpdateClient(userID int, role models.UserRole, id int, updates *models.UpdateOAuthClient) (*models.OAuthClient, error) {
+	client, err := s.GetClient(userID, role, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if updates.Name != nil {
+		client.Name = *updates.Name
+	}
+	if updates.RedirectURIs != nil {
+		if err := validateRedirectURIs(updates.RedirectURIs); err != nil {
+			return nil, err
+		}
+		if len(updates.RedirectURIs) == 0 && hasString(client.GrantTypes, models.GrantAuthorizationCode) {
+			return nil, apierrors.NewBadRequestError("apps using the authorization code grant need a redirect URI")
+		}
+		client.RedirectURIs = updates.RedirectURIs
+	}
+	if updates.Scopes != nil {
+		if client.Scopes, err = validateScopes(updates.Scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.UpdateClient(client); err != nil {
+		return nil, err
+	}
+	return s.repo.GetClient(id)
+}
+
+func (s *oauthService) DeleteClient(userID int, role models.UserRole, id int) error {
+	if _, err := s.GetClient(userID, role, id); err != nil {
+		return err
+	}
+	// Its codes, tokens and consents go with it
+	return s.repo.DeleteClient(id)
+}
+
+func (s *oauthService) RotateSecret(userID int, role models.UserRole, id int) (*models.OAuthClient, error) {
+	client, err := s.GetClient(userID, role, id)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential {
+		return nil, apierrors.NewBadRequestError("public apps have no secret")
+	}
+
+	secret, err := randomSecret("", 32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateClientSecret(id, hashToken(secret)); err != nil {
+		return nil, err
+	}
+	client.ClientSecret = secret
+	return client, nil
+}
+
+// checkAuthorizationRequest validates an authorization request, returning
+// the app, the redirect URI to use and the requested scopes
+func (s *oauthService) checkAuthorizationRequest(req *models.AuthorizationRequest) (*models.OAuthClient, string, []string, error) {
+	client, err := s.repo.GetClientByClientID(req.ClientID)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return nil, "", nil, apierrors.NewBadRequestError("unknown client_id")
+		}
+		return nil, "", nil, err
+	}
+	if !hasString(client.GrantTypes, models.GrantAuthorizationCode) {
+		return nil, "", nil, apierrors.NewBadRequestError("this app cannot use the authorization code grant")
+	}
+
+	// The redirect URI must be one registered for the app, and may be left
+	// out when there is only one
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !hasString(client.RedirectURIs, redirectURI) {
+		return nil, "", nil, apierrors.NewBadRequestError("redirect_uri is not registered for this app")
+	}
+
+	if req.ResponseType != "code" {
+		return nil, "", nil, apierrors.NewBadRequestError("response_type must be code")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, "", nil, apierrors.NewBadRequestError("a code_challenge with code_challenge_method S256 is required")
+	}
+
+	scopes, err := s.requestedScopes(client, req.Scope)
+	if err != nil {
+		return nil, "", nil, apierrors.NewBadRequestError(err.(*OAuthError).Description)
+	}
+	return client, redirectURI, scopes, nil
+}
+
+// requestedScopes parses a space-separated scope parameter, which must stay
+// within the app's scopes; an empty one asks for all of them
+func (s *oauthService) requestedScopes(client *models.OAuthClient, scope string) ([]string, error) {
+	fields := strings.Fields(scope)
+	if len(fields) == 0 {
+		return client.Scopes, nil
+	}
+	var scopes []string
+	for _, field := range fields {
+		if !hasString(client.Scopes, field) {
+			return nil, oauthError("invalid_scope", fmt.Sprintf("scope %q is not allowed for this app", field))
+		}
+		if !hasString(scopes, field) {
+			scopes = append(scopes, field)
+		}
+	}
+	return scopes, nil
+}
+
+func (s *oauthService) PrepareAuthorization(userID int, req *models.AuthorizationRequest) (*models.AuthorizationPrompt, error) {
+	client, redirectURI, scopes, err := s.checkAuthorizationRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := &models.AuthorizationPrompt{
+		Client:          models.ClientRef{ClientID: client.ClientID, Name: client.Name},
+		Scopes:          scopes,
+		ConsentRequired: true,
+		RedirectURI:     redirectURI,
+	}
+	consent, err := s.repo.GetConsent(userID, client.ID)
+	if err != nil && err.Error() != "consent not found" {
+		return nil, err
+	}
+	if consent != nil && coversScopes(consent.Scopes, scopes) {
+		prompt.ConsentRequired = false
+	}
+	return prompt, nil
+}
+
+// coversScopes reports whether every wanted scope was granted
+func coversScopes(granted, wanted []string) bool {
+	for _, scope := range wanted {
+		if !hasString(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *oauthService) Authorize(userID int, decision *models.AuthorizationDecision) (string, error) {
+	client, redirectURI, scopes, err := s.checkAuthorizationRequest(&decision.AuthorizationRequest)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", apierrors.NewBadRequestError("invalid redirect_uri")
+	}
+	query := target.Query()
+	if decision.State != "" {
+		query.Set("state", decision.State)
+	}
+	if !decision.Approve {
+		query.Set("error", "access_denied")
+		target.RawQuery = query.Encode()
+		return target.String(), nil
+	}
+
+	consent, err := s.repo.GetConsent(userID, client.ID)
+	if err != nil && err.Error() != "consent not found" {
+		return "", err
+	}
+	if consent == nil || !coversScopes(consent.Scopes, scopes) {
+		// Consent only grows; an app asking for fewer scopes keeps the others
+		granted := scopes
+		if consent != nil {
+			granted = append([]string{}, consent.Scopes...)
+			for _, scope := range scopes {
+				if !hasString(granted, scope) {
+					granted = append(granted, scope)
+				}
+			}
+		}
+		if err := s.repo.SaveConsent(userID, client.ID, granted); err != nil {
+			return "", err
+		}
+		audit(s.outbox, events.UserAppAuthorized, events.SecurityData{UserID: userID, ClientID: client.ClientID, Scopes: granted})
+	}
+
+	code, err := randomSecret("", 32)
+	if err != nil {
+		return "", err
+	}
+	err = s.repo.CreateCode(&models.OAuthCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: decision.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.cfg.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query.Set("code", code)
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// authenticateClient identifies the app calling a protocol endpoint.
+// Confidential apps must present their secret; public apps only their ID.
+func (s *oauthService) authenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	invalid := oauthError("invalid_client", "client authentication failed")
+	if clientID == "" {
+		return nil, invalid
+	}
+	client, err := s.repo.GetClientByClientID(clientID)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	if client.Confidential {
+		if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+			return nil, invalid
+		}
+	} else if secret != "" {
+		return nil, invalid
+	}
+	return client, nil
+}
+
+func (s *oauthService) Token(req *models.TokenRequest) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case models.GrantAuthorizationCode:
+		return s.exchangeCode(client, req)
+	case models.GrantRefreshToken:
+		return s.refresh(client, req)
+	case models.GrantClientCredentials:
+		return s.clientCredentials(client, req)
+	default:
+		return nil, oauthError("unsupported_grant_type", "grant_type must be authorization_code, refresh_token or client_credentials")
+	}
+}
+
+func (s *oauthService) exchangeCode(client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	if !hasString(client.GrantTypes, models.GrantAuthorizationCode) {
+		return nil, oauthError("unauthorized_client", "this app cannot use the authorization code grant")
+	}
+
+	invalid := oauthError("invalid_grant", "the authorization code is invalid, expired or already used")
+	code, err := s.repo.TakeCode(hashToken(req.Code))
+	if err != nil {
+		if err.Error() == "code not found" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if code.ClientID != client.ID || time.Now().After(code.ExpiresAt) || req.RedirectURI != code.RedirectURI {
+		return nil, invalid
+	}
+
+	// PKCE: only whoever started the authorization knows the verifier
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != code.CodeChallenge {
+		return nil, oauthError("invalid_grant", "code_verifier does not match the code_challenge")
+	}
+
+	user, err := s.activeUser(code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(nil, client, user, code.Scopes, true)
+}
+
+func (s *oauthService) refresh(client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	invalid := oauthError("invalid_grant", "the refresh token is invalid, expired or revoked")
+	token, err := s.repo.GetTokenByRefreshHash(hashToken(req.RefreshToken))
+	if err != nil {
+		if err.Error() == "token not found" {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if token.ClientID != client.ID {
+		return nil, invalid
+	}
+	if token.RevokedAt != nil {
+		// Refresh tokens are rotated, so a used one coming back means it
+		// leaked: revoke everything the user granted the app
+		if err := s.repo.RevokeTokens(token.UserID, client.ID); err != nil {
+			log.Printf("Error revoking tokens after refresh token reuse: %v", err)
+		}
+		return nil, invalid
+	}
+	if token.RefreshExpiresAt == nil || time.Now().After(*token.RefreshExpiresAt) {
+		return nil, invalid
+	}
+
+	// The new tokens may have fewer scopes, not more
+	scopes := token.Scopes
+	if fields := strings.Fields(req.Scope); len(fields) > 0 {
+		if !coversScopes(token.Scopes, fields) {
+			return nil, oauthError("invalid_scope", "the requested scope exceeds the one originally granted")
+		}
+		scopes = fields
+	}
+
+	user, err := s.activeUser(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *models.TokenResponse
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		if err := s.repo.WithTx(tx).RevokeToken(token.ID); err != nil {
+			if err.Error() == "token not found" {
+				// Another request rotated it first
+				return invalid
+			}
+			return err
+		}
+		var err error
+		response, err = s.issueTokens(tx, client, user, scopes, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *oauthService) clientCredentials(client *models.OAuthClient, req *models.TokenRequest) (*models.TokenResponse, error) {
+	if !hasString(client.GrantTypes, models.GrantClientCredentials) || !client.Confidential {
+		return nil, oauthError("unauthorized_client", "this app cannot use the client credentials grant")
+	}
+	scopes, err := s.requestedScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// The app acts for the user who registered it
+	owner, err := s.activeUser(client.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(nil, client, owner, scopes, false)
+}
+
+// activeUser returns the user tokens are issued for, who must still be
+// allowed to use the API
+func (s *oauthService) activeUser(id int) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, oauthError("invalid_grant", "the user no longer exists")
+		}
+		return nil, err
+	}
+	if !user.IsActive || user.DeletionScheduledAt != nil {
+		return nil, oauthError("invalid_grant", "the user's account is not active")
+	}
+	return user, nil
+}
+
+// issueTokens records and issues an access token, with a refresh token if asked
+func (s *oauthService) issueTokens(tx *sql.Tx, client *models.OAuthClient, user *models.User, scopes []string,
+	withRefresh bool) (*models.TokenResponse, error) {
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return nil, fmt.Errorf("error generating token ID: %v", err)
+	}
+
+	now := time.Now()
+	record := &models.OAuthToken{
+		TokenID:   hex.EncodeToString(tokenID),
+		ClientID:  client.ID,
+		UserID:    user.ID,
+		Scopes:    scopes,
+		ExpiresAt: now.Add(s.cfg.AccessTokenTTL),
+	}
+	var refreshToken string
+	if withRefresh {
+		var err error
+		if refreshToken, err = randomSecret(refreshTokenPrefix, 32); err != nil {
+			return nil, err
+		}
+		refreshExpiresAt := now.Add(s.cfg.RefreshTokenTTL)
+		record.RefreshHash = hashToken(refreshToken)
+		record.RefreshExpiresAt = &refreshExpiresAt
+	}
+
+	repo := s.repo
+	if tx != nil {
+		repo = repo.WithTx(tx)
+	}
+	if err := repo.CreateToken(record); err != nil {
+		return nil, err
+	}
+
+	scope := strings.Join(scopes, " ")
+	accessToken, err := jwt.GenerateOAuthToken(user.ID, string(user.Role), user.OrgID, string(user.OrgRole), client.ClientID, scope, record.TokenID, s.cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error generating access token: %v", err)
+	}
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.cfg.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// lookupToken finds the record of an access or refresh token
+func (s *oauthService) lookupToken(token string) (*models.OAuthToken, error) {
+	if strings.HasPrefix(token, refreshTokenPrefix) {
+		return s.repo.GetTokenByRefreshHash(hashToken(token))
+	}
+	claims, err := jwt.ValidateOAuthToken(token)
+	if err != nil {
+		return nil, errors.New("token not found")
+	}
+	return s.repo.GetToken(claims.ID)
+}
+
+func (s *oauthService) Introspect(req *models.TokenLookup) (*models.TokenIntrospection, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	// Resource servers must be able to keep a secret
+	if !client.Confidential {
+		return nil, oauthError("invalid_client", "only confidential apps can introspect tokens")
+	}
+
+	inactive := &models.TokenIntrospection{}
+	token, err := s.lookupToken(req.Token)
+	if err != nil {
+		if err.Error() == "token not found" {
+			return inactive, nil
+		}
+		return nil, err
+	}
+
+	tokenType, expiresAt := "access_token", token.ExpiresAt
+	if strings.HasPrefix(req.Token, refreshTokenPrefix) {
+		tokenType, expiresAt = "refresh_token", *token.RefreshExpiresAt
+	}
+	if token.RevokedAt != nil || time.Now().After(expiresAt) {
+		return inactive, nil
+	}
+	owner, err := s.repo.GetClient(token.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(token.UserID)
+	if err != nil || !user.IsActive || user.DeletionScheduledAt != nil {
+		return inactive, nil
+	}
+
+	return &models.TokenIntrospection{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  owner.ClientID,
+		Username:  user.Username,
+		TokenType: tokenType,
+		Subject:   fmt.Sprint(user.ID),
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+	}, nil
+}
+
+func (s *oauthService) Revoke(req *models.TokenLookup) error {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	token, err := s.lookupToken(req.Token)
+	if err != nil {
+		if err.Error() == "token not found" {
+			return nil
+		}
+		return err
+	}
+	// Apps can only revoke their own tokens; others are ignored like unknown ones
+	if token.ClientID != client.ID || token.RevokedAt != nil {
+		return nil
+	}
+	if err := s.repo.RevokeToken(token.ID); err != nil && err.Error() != "token not found" {
+		return err
+	}
+	return nil
+}
+
+func (s *oauthService) CheckAccessToken(tokenID string) (*models.User, []string, error) {
+	revoked := errors.New("access token has been revoked or has expired")
+	token, err := s.repo.GetToken(tokenID)
+	if err != nil {
+		if err.Error() == "token not found" {
+			return nil, nil, revoked
+		}
+		return nil, nil, err
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, nil, revoked
+	}
+
+	user, err := s.userRepo.GetUserByID(token.UserID)
+	if err != nil || !user.IsActive || user.DeletionScheduledAt != nil {
+		return nil, nil, revoked
+	}
+	return user, token.Scopes, nil
+}
+
+func (s *oauthService) ListAuthorizations(userID int) ([]*models.OAuthConsent, error) {
+	return s.repo.ListConsents(userID)
+}
+
+func (s *oauthService) RevokeAuthorization(userID int, clientID string) error {
+	client, err := s.repo.GetClientByClientID(clientID)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return apierrors.NewNotFoundError("app not found")
+		}
+		return err
+	}
+
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.DeleteConsent(userID, client.ID); err != nil {
+			if err.Error() == "consent not found" {
+				return apierrors.NewNotFoundError("you have not authorized this app")
+			}
+			return err
+		}
+		return repo.RevokeTokens(userID, client.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	audit(s.outbox, events.UserAppRevoked, events.SecurityData{UserID: userID, ClientID: client.ClientID})
+	return nil
+}
+
+func (s *oauthService) StartCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.repo.DeleteExpired(time.Now()); err != nil {
+					log.Printf("Error cleaning up OAuth codes and tokens: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,457 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/jwt"
+	"task-management-api/pkg/oidc"
+	"testing"
+	"time"
+)
+
+// fakeOAuthRepo keeps apps, codes, tokens and consents in memory. Like the
+// database, it derives whether an app is confidential from its secret.
+type fakeOAuthRepo struct {
+	repository.OAuthRepository
+	clients  []*models.OAuthClient
+	codes    map[string]*models.OAuthCode
+	tokens   []*models.OAuthToken
+	consents map[[2]int][]string
+}
+
+func newFakeOAuthRepo() *fakeOAuthRepo {
+	return &fakeOAuthRepo{codes: map[string]*models.OAuthCode{}, consents: map[[2]int][]string{}}
+}
+
+func (r *fakeOAuthRepo) WithTx(tx *sql.Tx) repository.OAuthRepository { return r }
+
+func (r *fakeOAuthRepo) CreateClient(client *models.OAuthClient) error {
+	client.ID = len(r.clients) + 1
+	stored := *client
+	stored.Confidential = stored.SecretHash != ""
+	r.clients = append(r.clients, &stored)
+	return nil
+}
+
+func (r *fakeOAuthRepo) GetClient(id int) (*models.OAuthClient, error) {
+	for _, client := range r.clients {
+		if client.ID == id {
+			stored := *client
+			return &stored, nil
+		}
+	}
+	return nil, errors.New("client not found")
+}
+
+func (r *fakeOAuthRepo) GetClientByClientID(clientID string) (*models.OAuthClient, error) {
+	for _, client := range r.clients {
+		if client.ClientID == clientID {
+			return r.GetClient(client.ID)
+		}
+	}
+	return nil, errors.New("client not found")
+}
+
+func (r *fakeOAuthRepo) CreateCode(code *models.OAuthCode) error {
+	r.codes[code.CodeHash] = code
+	return nil
+}
+
+func (r *fakeOAuthRepo) TakeCode(hash string) (*models.OAuthCode, error) {
+	code, ok := r.codes[hash]
+	if !ok {
+		return nil, errors.New("code not found")
+	}
+	delete(r.codes, hash)
+	return code, nil
+}
+
+func (r *fakeOAuthRepo) CreateToken(token *models.OAuthToken) error {
+	token.ID = len(r.tokens) + 1
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens = append(r.tokens, &stored)
+	return nil
+}
+
+func (r *fakeOAuthRepo) findToken(match func(*models.OAuthToken) bool) (*models.OAuthToken, error) {
+	for _, token := range r.tokens {
+		if match(token) {
+			stored := *token
+			return &stored, nil
+		}
+	}
+	return nil, errors.New("token not found")
+}
+
+func (r *fakeOAuthRepo) GetToken(tokenID string) (*models.OAuthToken, error) {
+	return r.findToken(func(t *models.OAuthToken) bool { return t.TokenID == tokenID })
+}
+
+func (r *fakeOAuthRepo) GetTokenByRefreshHash(hash string) (*models.OAuthToken, error) {
+	return r.findToken(func(t *models.OAuthToken) bool { return t.RefreshHash != "" && t.RefreshHash == hash })
+}
+
+func (r *fakeOAuthRepo) RevokeToken(id int) error {
+	for _, token := range r.tokens {
+		if token.ID == id && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			return nil
+		}
+	}
+	return errors.New("token not found")
+}
+
+func (r *fakeOAuthRepo) RevokeTokens(userID, clientID int) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.ClientID == clientID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeOAuthRepo) GetConsent(userID, clientID int) (*models.OAuthConsent, error) {
+	scopes, ok := r.consents[[2]int{userID, clientID}]
+	if !ok {
+		return nil, errors.New("consent not found")
+	}
+	return &models.OAuthConsent{UserID: userID, Scopes: scopes}, nil
+}
+
+func (r *fakeOAuthRepo) SaveConsent(userID, clientID int, scopes []string) error {
+	r.consents[[2]int{userID, clientID}] = scopes
+	return nil
+}
+
+func (r *fakeOAuthRepo) DeleteConsent(userID, clientID int) error {
+	if _, ok := r.consents[[2]int{userID, clientID}]; !ok {
+		return errors.New("consent not found")
+	}
+	delete(r.consents, [2]int{userID, clientID})
+	return nil
+}
+
+type testOAuthService struct {
+	OAuthService
+	repo  *fakeOAuthRepo
+	users *fakeUserRepo
+}
+
+func newTestOAuthService() *testOAuthService {
+	f := &testOAuthService{
+		repo: newFakeOAuthRepo(),
+		users: newFakeUserRepo(
+			&models.User{ID: 1, Username: "owner", IsActive: true},
+			&models.User{ID: 2, Username: "ann", IsActive: true},
+		),
+	}
+	cfg := config.OAuthConfig{CodeTTL: time.Minute, AccessTokenTTL: time.Hour, RefreshTokenTTL: 24 * time.Hour}
+	f.OAuthService = NewOAuthService(f.repo, f.users, &fakeTx{}, &fakeOutbox{}, cfg)
+	return f
+}
+
+const testRedirectURI = "https://app.example.com/callback"
+
+func (f *testOAuthService) registerApp(t *testing.T, confidential bool, grants ...string) *models.OAuthClient {
+	t.Helper()
+	client, err := f.RegisterClient(1, &models.NewOAuthClient{
+		Name:         "App",
+		Confidential: confidential,
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{"tasks:read", "tasks:write"},
+		GrantTypes:   grants,
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient: %v", err)
+	}
+	return client
+}
+
+// authorize has ann approve the app and returns the code it is sent back with
+func (f *testOAuthService) authorize(t *testing.T, client *models.OAuthClient, scope, verifier string) string {
+	t.Helper()
+	location, err := f.Authorize(2, &models.AuthorizationDecision{
+		AuthorizationRequest: models.AuthorizationRequest{
+			ResponseType: "code", ClientID: client.ClientID, Scope: scope, State: "xyz",
+			CodeChallenge: oidc.Challenge(verifier), CodeChallengeMethod: "S256",
+		},
+		Approve: true,
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	u, _ := url.Parse(location)
+	if got := u.Scheme + "://" + u.Host + u.Path; got != testRedirectURI || u.Query().Get("state") != "xyz" {
+		t.Fatalf("redirected to %s", location)
+	}
+	return u.Query().Get("code")
+}
+
+func wantOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	if oauthErr, ok := err.(*OAuthError); !ok || oauthErr.Code != code {
+		t.Fatalf("err = %v, want %s", err, code)
+	}
+}
+
+func TestRegisterClient(t *testing.T) {
+	f := newTestOAuthService()
+
+	client := f.registerApp(t, true, models.GrantAuthorizationCode, models.GrantClientCredentials)
+	if client.ClientID == "" || client.ClientSecret == "" || !client.Confidential {
+		t.Errorf("registered %+v, want an ID and a secret", client)
+	}
+	if f.repo.clients[0].SecretHash != hashToken(client.ClientSecret) {
+		t.Error("the secret must only be stored hashed")
+	}
+	if public := f.registerApp(t, false, models.GrantAuthorizationCode); public.ClientSecret != "" || public.Confidential {
+		t.Errorf("public app %+v has a secret", public)
+	}
+
+	invalid := map[string]*models.NewOAuthClient{
+		"code grant without redirect URI": {Scopes: []string{"tasks:read"}, GrantTypes: []string{"authorization_code"}},
+		"public client credentials":       {Scopes: []string{"tasks:read"}, GrantTypes: []string{"client_credentials"}},
+		"relative redirect URI": {RedirectURIs: []string{"/callback"}, Scopes: []string{"tasks:read"},
+			GrantTypes: []string{"authorization_code"}},
+		"redirect URI with fragment": {RedirectURIs: []string{"https://app.example.com/#x"}, Scopes: []string{"tasks:read"},
+			GrantTypes: []string{"authorization_code"}},
+		"unknown scope": {Confidential: true, Scopes: []string{"everything"}, GrantTypes: []string{"client_credentials"}},
+	}
+	for name, newClient := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := f.RegisterClient(1, newClient)
+			wantBadRequest(t, err)
+		})
+	}
+}
+
+func TestClientsAreOnlyVisibleToOwnersAndAdmins(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, true, models.GrantClientCredentials)
+
+	_, err := f.GetClient(2, models.UserRoleUser, client.ID)
+	wantNotFound(t, err)
+	wantNotFound(t, f.DeleteClient(2, models.UserRoleUser, client.ID))
+	if _, err := f.GetClient(2, models.UserRoleAdmin, client.ID); err != nil {
+		t.Errorf("admin GetClient: %v", err)
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, false, models.GrantAuthorizationCode)
+	request := &models.AuthorizationRequest{
+		ResponseType: "code", ClientID: client.ClientID, Scope: "tasks:read",
+		CodeChallenge: oidc.Challenge("verifier"), CodeChallengeMethod: "S256",
+	}
+
+	prompt, err := f.PrepareAuthorization(2, request)
+	if err != nil || !prompt.ConsentRequired || prompt.RedirectURI != testRedirectURI {
+		t.Fatalf("PrepareAuthorization = %+v, %v", prompt, err)
+	}
+
+	code := f.authorize(t, client, "tasks:read", "verifier")
+	exchange := &models.TokenRequest{
+		GrantType: models.GrantAuthorizationCode, ClientID: client.ClientID, Code: code,
+		RedirectURI: testRedirectURI, CodeVerifier: "verifier",
+	}
+
+	// Only the holder of the verifier may redeem the code
+	wrong := *exchange
+	wrong.CodeVerifier = "guess"
+	_, err = f.Token(&wrong)
+	wantOAuthError(t, err, "invalid_grant")
+
+	code = f.authorize(t, client, "tasks:read", "verifier")
+	exchange.Code = code
+	tokens, err := f.Token(exchange)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tokens.Scope != "tasks:read" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	claims, err := jwt.ValidateOAuthToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateOAuthToken: %v", err)
+	}
+	user, scopes, err := f.CheckAccessToken(claims.ID)
+	if err != nil || user.ID != 2 || len(scopes) != 1 {
+		t.Errorf("CheckAccessToken = %v, %v, %v", user, scopes, err)
+	}
+
+	_, err = f.Token(exchange)
+	wantOAuthError(t, err, "invalid_grant")
+
+	// Consent is remembered
+	if prompt, _ := f.PrepareAuthorization(2, request); prompt.ConsentRequired {
+		t.Error("asked for consent again")
+	}
+}
+
+func TestAuthorizationRequestChecks(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, false, models.GrantAuthorizationCode)
+	valid := models.AuthorizationRequest{
+		ResponseType: "code", ClientID: client.ClientID,
+		CodeChallenge: oidc.Challenge("v"), CodeChallengeMethod: "S256",
+	}
+
+	tests := map[string]func(r *models.AuthorizationRequest){
+		"unregistered redirect URI": func(r *models.AuthorizationRequest) { r.RedirectURI = "https://evil.example.com/" },
+		"without PKCE":              func(r *models.AuthorizationRequest) { r.CodeChallenge = "" },
+		"plain PKCE":                func(r *models.AuthorizationRequest) { r.CodeChallengeMethod = "plain" },
+		"implicit grant":            func(r *models.AuthorizationRequest) { r.ResponseType = "token" },
+		"scope beyond the app's":    func(r *models.AuthorizationRequest) { r.Scope = "users:write" },
+		"unknown app":               func(r *models.AuthorizationRequest) { r.ClientID = "nope" },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			request := valid
+			change(&request)
+			_, err := f.PrepareAuthorization(2, &request)
+			wantBadRequest(t, err)
+		})
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		location, err := f.Authorize(2, &models.AuthorizationDecision{AuthorizationRequest: valid})
+		if err != nil {
+			t.Fatalf("Authorize: %v", err)
+		}
+		u, _ := url.Parse(location)
+		if u.Query().Get("error") != "access_denied" || u.Query().Get("code") != "" {
+			t.Errorf("redirected to %s", location)
+		}
+	})
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, true, models.GrantAuthorizationCode)
+	code := f.authorize(t, client, "tasks:read tasks:write", "v")
+	first, err := f.Token(&models.TokenRequest{
+		GrantType: models.GrantAuthorizationCode, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+		Code: code, RedirectURI: testRedirectURI, CodeVerifier: "v",
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	refresh := func(token, scope string) (*models.TokenResponse, error) {
+		return f.Token(&models.TokenRequest{
+			GrantType: models.GrantRefreshToken, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+			RefreshToken: token, Scope: scope,
+		})
+	}
+
+	_, err = refresh(first.RefreshToken, "users:read")
+	wantOAuthError(t, err, "invalid_scope")
+
+	second, err := refresh(first.RefreshToken, "tasks:read")
+	if err != nil || second.Scope != "tasks:read" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh = %+v, %v", second, err)
+	}
+
+	// Reusing the rotated token revokes everything the app was given
+	_, err = refresh(first.RefreshToken, "")
+	wantOAuthError(t, err, "invalid_grant")
+	_, err = refresh(second.RefreshToken, "")
+	wantOAuthError(t, err, "invalid_grant")
+}
+
+func TestClientCredentials(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, true, models.GrantClientCredentials)
+
+	tokens, err := f.Token(&models.TokenRequest{
+		GrantType: models.GrantClientCredentials, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tokens.RefreshToken != "" || tokens.Scope != "tasks:read tasks:write" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	if claims, _ := jwt.ValidateOAuthToken(tokens.AccessToken); claims == nil || claims.UserID != 1 {
+		t.Error("the token must act for the app's owner")
+	}
+
+	_, err = f.Token(&models.TokenRequest{
+		GrantType: models.GrantClientCredentials, ClientID: client.ClientID, ClientSecret: "wrong",
+	})
+	wantOAuthError(t, err, "invalid_client")
+
+	_, err = f.Token(&models.TokenRequest{
+		GrantType: models.GrantAuthorizationCode, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+	})
+	wantOAuthError(t, err, "unauthorized_client")
+
+	f.users.users[1].IsActive = false
+	_, err = f.Token(&models.TokenRequest{
+		GrantType: models.GrantClientCredentials, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+	})
+	wantOAuthError(t, err, "invalid_grant")
+}
+
+func TestIntrospectAndRevoke(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, true, models.GrantClientCredentials)
+	other := f.registerApp(t, true, models.GrantClientCredentials)
+	tokens, _ := f.Token(&models.TokenRequest{
+		GrantType: models.GrantClientCredentials, ClientID: client.ClientID, ClientSecret: client.ClientSecret,
+	})
+
+	lookup := &models.TokenLookup{Token: tokens.AccessToken, ClientID: client.ClientID, ClientSecret: client.ClientSecret}
+	info, err := f.Introspect(lookup)
+	if err != nil || !info.Active || info.Username != "owner" || info.ClientID != client.ClientID {
+		t.Fatalf("Introspect = %+v, %v", info, err)
+	}
+
+	// Another app's revocation is ignored
+	if err := f.Revoke(&models.TokenLookup{Token: tokens.AccessToken, ClientID: other.ClientID, ClientSecret: other.ClientSecret}); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if info, _ := f.Introspect(lookup); !info.Active {
+		t.Error("another app revoked the token")
+	}
+
+	if err := f.Revoke(lookup); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if info, _ := f.Introspect(lookup); info.Active {
+		t.Error("the revoked token is still active")
+	}
+	if err := f.Revoke(&models.TokenLookup{Token: "garbage", ClientID: client.ClientID, ClientSecret: client.ClientSecret}); err != nil {
+		t.Errorf("revoking an unknown token = %v, want it ignored", err)
+	}
+}
+
+func TestRevokeAuthorization(t *testing.T) {
+	f := newTestOAuthService()
+	client := f.registerApp(t, false, models.GrantAuthorizationCode)
+	code := f.authorize(t, client, "", "v")
+	tokens, err := f.Token(&models.TokenRequest{
+		GrantType: models.GrantAuthorizationCode, ClientID: client.ClientID,
+		Code: code, RedirectURI: testRedirectURI, CodeVerifier: "v",
+	})
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if err := f.RevokeAuthorization(2, client.ClientID); err != nil {
+		t.Fatalf("RevokeAuthorization: %v", err)
+	}
+	claims, _ := jwt.ValidateOAuthToken(tokens.AccessToken)
+	if _, _, err := f.CheckAccessToken(claims.ID); err == nil {
+		t.Error("the app's token still works")
+	}
+	wantNotFound(t, f.RevokeAuthorization(2, client.ClientID))
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/oidc"
+	"time"
+)
+
+// OIDCService logs users in through an OpenID Connect identity provider.
+// Provider accounts are linked to users by issuer and subject; on the first
+// login they are linked by verified email address or given a new account,
+// as configured. Users who log in this way get the same session and token
+// as with a password. Their second factor is the provider's business, so
+// the API does not ask for its own.
+type OIDCService interface {
+	// StartLogin returns the provider URL to send the user to, and the state
+	// the browser must present again at the callback
+	StartLogin(ctx context.Context) (authURL, state string, err error)
+	// CompleteLogin handles the provider's callback; browserState is the
+	// state kept by the browser that started the login
+	CompleteLogin(ctx context.Context, callback *models.OIDCCallback, browserState string, client models.ClientInfo) (*models.LoginResult, error)
+	// StartCleanup deletes abandoned logins periodically until ctx is done
+	StartCleanup(ctx context.Context)
+}
+
+type oidcService struct {
+	provider *oidc.Provider
+	repo     repository.OIDCRepository
+	userRepo repository.UserRepository
+	tx       repository.TxRunner
+	outbox   repository.OutboxRepository
+	sessions SessionService
+	cfg      config.OIDCConfig
+}
+
+// NewOIDCService creates a new OIDCService; provider is nil when single
+// sign-on is disabled
+func NewOIDCService(provider *oidc.Provider, repo repository.OIDCRepository, userRepo repository.UserRepository,
+	tx repository.TxRunner, outbox repository.OutboxRepository, sessions SessionService, cfg config.OIDCConfig) OIDCService {
+	// Identities are stored under the issuer, however it was written
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &oidcService{
+		provider: provider, repo: repo, userRepo: userRepo, tx: tx, outbox: outbox, sessions: sessions, cfg: cfg,
+	}
+}
+
+var errSSODisabled = apierrors.NewNotFoundError("single sign-on is not enabled")
+
+func (s *oidcService) StartLogin(ctx context.Context) (string, string, error) {
+	if s.provider == nil {
+		return "", "", errSSODisabled
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.RandomString(32)
+		if err != nil {
+			return "", "", fmt.Errorf("error generating login state: %v", err)
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.Challenge(verifier))
+	if err != nil {
+		log.Printf("Error starting single sign-on: %v", err)
+		return "", "", apierrors.NewInternalServerError("identity provider is unavailable")
+	}
+
+	err = s.repo.CreateLoginState(&models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.cfg.StateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+func (s *oidcService) CompleteLogin(ctx context.Context, callback *models.OIDCCallback, browserState string,
+	client models.ClientInfo) (*models.LoginResult, error) {
+	if s.provider == nil {
+		return nil, errSSODisabled
+	}
+
+	// The state must come back to the browser that started the login, so
+	// that nobody can log a victim into the attacker's account
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(browserState), []byte(callback.State)) != 1 {
+		return nil, apierrors.NewUnauthorizedError("login was started in another browser")
+	}
+	state, err := s.repo.TakeLoginState(hashToken(callback.State))
+	if err != nil {
+		if err.Error() == "state not found" {
+			return nil, apierrors.NewUnauthorizedError("login has expired or was already completed")
+		}
+		return nil, err
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, apierrors.NewUnauthorizedError("login has expired or was already completed")
+	}
+	if callback.Error != "" {
+		return nil, apierrors.NewUnauthorizedError(strings.TrimSpace("identity provider refused the login: " +
+			callback.Error + " " + callback.ErrorDescription))
+	}
+	if callback.Code == "" {
+		return nil, apierrors.NewBadRequestError("code is required")
+	}
+
+	claims, err := s.provider.Exchange(ctx, callback.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Error completing single sign-on: %v", err)
+		return nil, apierrors.NewUnauthorizedError("single sign-on failed")
+	}
+
+	user, err := s.resolveUser(claims, client)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, apierrors.NewForbiddenError("account is deactivated")
+	}
+
+	token, err := s.sessions.StartSession(user, client)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError("failed to generate token")
+	}
+	return &models.LoginResult{User: user, Token: token}, nil
+}
+
+// resolveUser finds, links or creates the user for a provider account, and
+// brings their role in line with their groups
+func (s *oidcService) resolveUser(claims *oidc.Claims, client models.ClientInfo) (*models.User, error) {
+	identity, err := s.repo.GetIdentity(s.cfg.Issuer, claims.Subject)
+	switch {
+	case err == nil:
+		if err := s.repo.TouchIdentity(identity.ID); err != nil {
+			log.Printf("Error recording login of identity %d: %v", identity.ID, err)
+		}
+		user, err := s.userRepo.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return s.syncRole(user, claims)
+	case err.Error() != "identity not found":
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, apierrors.NewForbiddenError("identity provider did not share an email address")
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(claims.Email)
+	if err != nil && err.Error() != "user not found" {
+		return nil, err
+	}
+	if existing != nil {
+		// Only a verified address proves the provider account belongs to the user
+		if !s.cfg.LinkByEmail || !claims.EmailVerified {
+			return nil, apierrors.NewConflictError("an account with this email address already exists")
+		}
+		if err := s.repo.CreateIdentity(&models.UserIdentity{
+			UserID: existing.ID, Issuer: s.cfg.Issuer, Subject: claims.Subject,
+		}); err != nil {
+			return nil, err
+		}
+		audit(s.outbox, events.UserIdentityLinked, events.SecurityData{
+			UserID: existing.ID, Username: existing.Username, IPAddress: client.IPAddress, Issuer: s.cfg.Issuer,
+		})
+		return s.syncRole(existing, claims)
+	}
+
+	if !s.cfg.AutoProvision {
+		return nil, apierrors.NewForbiddenError("no account is linked to this identity")
+	}
+	return s.provision(claims, client)
+}
+
+// provision creates an account for someone logging in for the first time.
+// It has no password; one can be set with a password reset.
+func (s *oidcService) provision(claims *oidc.Claims, client models.ClientInfo) (*models.User, error) {
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	role, _ := s.mappedRole(claims)
+
+	var user *models.User
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx)
+		created, err := repo.CreateUser(&models.NewUser{
+			Username: username,
+			Email:    claims.Email,
+			FullName: truncate(claims.Name, 100),
+			Role:     role,
+		})
+		if err != nil {
+			return heldInTrash(err)
+		}
+		if claims.EmailVerified {
+			if err := repo.MarkEmailVerified(created.ID); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.WithTx(tx).CreateIdentity(&models.UserIdentity{
+			UserID: created.ID, Issuer: s.cfg.Issuer, Subject: claims.Subject,
+		}); err != nil {
+			return err
+		}
+		if user, err = repo.GetUserByID(created.ID); err != nil {
+			return err
+		}
+
+		return s.outbox.WithTx(tx).Append(
+			events.New(events.UserCreated, user),
+			events.New(events.UserIdentityLinked, events.SecurityData{
+				UserID: user.ID, Username: user.Username, IPAddress: client.IPAddress, Issuer: s.cfg.Issuer,
+			}),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+var usernameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// availableUsername derives a username from the provider's preferred
+// username or the email address, numbering it if it is taken
+func (s *oidcService) availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = truncate(usernameChars.ReplaceAllString(base, ""), 45)
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		if _, err := s.userRepo.GetUserByUsername(candidate); err != nil {
+			if err.Error() == "user not found" {
+				return candidate, nil
+			}
+			return "", err
+		}
+	}
+	return "", apierrors.NewConflictError("could not find a free username")
+}
+
+// mappedRole returns the role the user's groups give them, and whether any
+// role mappings are configured at all
+func (s *oidcService) mappedRole(claims *oidc.Claims) (models.UserRole, bool) {
+	defaultRole := models.UserRole(strings.ToUpper(s.cfg.DefaultRole))
+	if defaultRole != models.UserRoleAdmin {
+		defaultRole = models.UserRoleUser
+	}
+	if len(s.cfg.RoleMappings) == 0 {
+		return defaultRole, false
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range claims.Strings(s.cfg.GroupsClaim) {
+		groups[group] = true
+	}
+	for _, mapping := range s.cfg.RoleMappings {
+		role := models.UserRole(strings.ToUpper(mapping.Role))
+		if groups[mapping.Group] && (role == models.UserRoleUser || role == models.UserRoleAdmin) {
+			return role, true
+		}
+	}
+	return defaultRole, true
+}
+
+// syncRole updates the role of a linked user when role mappings are
+// configured, so that changes to their groups apply at their next login
+func (s *oidcService) syncRole(user *models.User, claims *oidc.Claims) (*models.User, error) {
+	role, mapped := s.mappedRole(claims)
+	if !mapped || role == user.Role {
+		return user, nil
+	}
+
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx)
+		if err := repo.UpdateUser(user.ID, &models.UpdateUser{Role: &role}); err != nil {
+			return err
+		}
+		var err error
+		if user, err = repo.GetUserByID(user.ID); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserUpdated, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *oidcService) StartCleanup(ctx context.Context) {
+	if s.provider == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.cfg.StateTTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.repo.DeleteExpiredLoginStates(time.Now()); err != nil {
+					log.Printf("Error cleaning up single sign-on logins: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/oidc"
+	"task-management-api/pkg/oidc/oidctest"
+	"testing"
+	"time"
+)
+
+type fakeOIDCRepo struct {
+	repository.OIDCRepository
+	states     map[string]*models.OIDCLoginState
+	identities []*models.UserIdentity
+}
+
+func (r *fakeOIDCRepo) WithTx(tx *sql.Tx) repository.OIDCRepository { return r }
+
+func (r *fakeOIDCRepo) CreateLoginState(state *models.OIDCLoginState) error {
+	r.states[state.StateHash] = state
+	return nil
+}
+
+func (r *fakeOIDCRepo) TakeLoginState(hash string) (*models.OIDCLoginState, error) {
+	state, ok := r.states[hash]
+	if !ok {
+		return nil, errors.New("state not found")
+	}
+	delete(r.states, hash)
+	return state, nil
+}
+
+func (r *fakeOIDCRepo) GetIdentity(issuer, subject string) (*models.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, errors.New("identity not found")
+}
+
+func (r *fakeOIDCRepo) CreateIdentity(identity *models.UserIdentity) error {
+	identity.ID = len(r.identities) + 1
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *fakeOIDCRepo) TouchIdentity(id int) error { return nil }
+
+type testOIDCService struct {
+	OIDCService
+	idp      *oidctest.Server
+	repo     *fakeOIDCRepo
+	users    *fakeUserRepo
+	sessions *fakeSessions
+}
+
+func newTestOIDCService(t *testing.T, cfg config.OIDCConfig, users ...*models.User) *testOIDCService {
+	t.Helper()
+	idp := oidctest.NewServer("task-api", "secret")
+	t.Cleanup(idp.Close)
+
+	cfg.Issuer, cfg.ClientID, cfg.ClientSecret = idp.URL+"/", "task-api", "secret"
+	cfg.StateTTL = 10 * time.Minute
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer: cfg.Issuer, ClientID: cfg.ClientID, ClientSecret: cfg.ClientSecret,
+		RedirectURL: "https://tasks.example.com/callback", Scopes: []string{"openid"},
+	})
+
+	f := &testOIDCService{
+		idp:      idp,
+		repo:     &fakeOIDCRepo{states: map[string]*models.OIDCLoginState{}},
+		users:    newFakeUserRepo(users...),
+		sessions: &fakeSessions{},
+	}
+	f.OIDCService = NewOIDCService(provider, f.repo, f.users, &fakeTx{}, &fakeOutbox{}, f.sessions, cfg)
+	return f
+}
+
+// login goes through single sign-on as the provider account with the
+// given claims
+func (f *testOIDCService) login(t *testing.T, claims map[string]interface{}) (*models.LoginResult, error) {
+	t.Helper()
+	authURL, state, err := f.StartLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	code, returned, err := f.idp.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return f.CompleteLogin(context.Background(), &models.OIDCCallback{Code: code, State: returned}, state, models.ClientInfo{})
+}
+
+func wantStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != status {
+		t.Fatalf("err = %v, want status %d", err, status)
+	}
+}
+
+func TestOIDCProvisionsNewUsers(t *testing.T) {
+	taken := &models.User{ID: 1, Username: "alice", Email: "other@example.com", IsActive: true}
+	f := newTestOIDCService(t, config.OIDCConfig{AutoProvision: true, DefaultRole: "user"}, taken)
+
+	result, err := f.login(t, map[string]interface{}{
+		"sub": "alice-1", "email": "alice@example.com", "email_verified": true, "preferred_username": "alice",
+	})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	user := result.User
+	if user.Username != "alice-2" || user.Email != "alice@example.com" || user.EmailVerifiedAt == nil ||
+		user.Role != models.UserRoleUser {
+		t.Errorf("provisioned %+v", user)
+	}
+	if result.Token != "session-of-2" {
+		t.Errorf("token = %q", result.Token)
+	}
+
+	// The next login finds the account by its identity
+	again, err := f.login(t, map[string]interface{}{"sub": "alice-1", "email": "changed@example.com"})
+	if err != nil || again.User.ID != user.ID {
+		t.Errorf("second login = %v, %v; want user %d", again, err, user.ID)
+	}
+	if len(f.users.users) != 2 {
+		t.Errorf("%d users, want no second account", len(f.users.users))
+	}
+}
+
+func TestOIDCLinksByVerifiedEmail(t *testing.T) {
+	existing := &models.User{ID: 1, Username: "bob", Email: "bob@example.com", IsActive: true}
+
+	t.Run("verified", func(t *testing.T) {
+		f := newTestOIDCService(t, config.OIDCConfig{LinkByEmail: true}, existing)
+		result, err := f.login(t, map[string]interface{}{"sub": "b", "email": "bob@example.com", "email_verified": true})
+		if err != nil || result.User.ID != 1 {
+			t.Fatalf("login = %v, %v; want the existing user", result, err)
+		}
+		if len(f.repo.identities) != 1 || f.repo.identities[0].UserID != 1 {
+			t.Errorf("identities = %v", f.repo.identities)
+		}
+	})
+
+	t.Run("unverified", func(t *testing.T) {
+		f := newTestOIDCService(t, config.OIDCConfig{LinkByEmail: true, AutoProvision: true}, existing)
+		_, err := f.login(t, map[string]interface{}{"sub": "b", "email": "bob@example.com"})
+		wantStatus(t, err, 409)
+	})
+
+	t.Run("linking disabled", func(t *testing.T) {
+		f := newTestOIDCService(t, config.OIDCConfig{AutoProvision: true}, existing)
+		_, err := f.login(t, map[string]interface{}{"sub": "b", "email": "bob@example.com", "email_verified": true})
+		wantStatus(t, err, 409)
+	})
+}
+
+func TestOIDCWithoutProvisioning(t *testing.T) {
+	f := newTestOIDCService(t, config.OIDCConfig{})
+	_, err := f.login(t, map[string]interface{}{"sub": "c", "email": "carol@example.com"})
+	wantStatus(t, err, 403)
+}
+
+func TestOIDCSyncsRolesFromGroups(t *testing.T) {
+	cfg := config.OIDCConfig{
+		AutoProvision: true,
+		GroupsClaim:   "groups",
+		RoleMappings:  []config.OIDCRoleMapping{{Group: "ops", Role: "admin"}},
+	}
+	f := newTestOIDCService(t, cfg)
+
+	result, err := f.login(t, map[string]interface{}{"sub": "d", "email": "dan@example.com", "groups": []string{"ops"}})
+	if err != nil || result.User.Role != models.UserRoleAdmin {
+		t.Fatalf("login = %v, %v; want an admin", result, err)
+	}
+	result, err = f.login(t, map[string]interface{}{"sub": "d", "email": "dan@example.com", "groups": []string{"dev"}})
+	if err != nil || result.User.Role != models.UserRoleUser {
+		t.Errorf("login after leaving ops = %v, %v; want a user", result, err)
+	}
+}
+
+func TestOIDCCallbackChecks(t *testing.T) {
+	f := newTestOIDCService(t, config.OIDCConfig{AutoProvision: true})
+	ctx := context.Background()
+
+	authURL, state, _ := f.StartLogin(ctx)
+	code, returned, _ := f.idp.Authorize(authURL, map[string]interface{}{"sub": "e", "email": "eve@example.com"})
+
+	// A callback in another browser, without the state cookie
+	_, err := f.CompleteLogin(ctx, &models.OIDCCallback{Code: code, State: returned}, "", models.ClientInfo{})
+	wantStatus(t, err, 401)
+
+	if _, err := f.CompleteLogin(ctx, &models.OIDCCallback{Code: code, State: returned}, state, models.ClientInfo{}); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	// States are single use
+	_, err = f.CompleteLogin(ctx, &models.OIDCCallback{Code: code, State: returned}, state, models.ClientInfo{})
+	wantStatus(t, err, 401)
+
+	// The provider refusing the login
+	_, state, _ = f.StartLogin(ctx)
+	_, err = f.CompleteLogin(ctx, &models.OIDCCallback{State: state, Error: "access_denied"}, state, models.ClientInfo{})
+	wantStatus(t, err, 401)
+	if f.idp.Exchanges != 1 {
+		t.Errorf("%d codes redeemed, want 1", f.idp.Exchanges)
+	}
+}
+
+func TestOIDCRefusesDeactivatedUsers(t *testing.T) {
+	f := newTestOIDCService(t, config.OIDCConfig{LinkByEmail: true},
+		&models.User{ID: 1, Username: "fay", Email: "fay@example.com"})
+	_, err := f.login(t, map[string]interface{}{"sub": "f", "email": "fay@example.com", "email_verified": true})
+	wantStatus(t, err, 403)
+	if len(f.sessions.started) != 0 {
+		t.Error("started a session for a deactivated user")
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	s := NewOIDCService(nil, nil, nil, nil, nil, nil, config.OIDCConfig{})
+	_, _, err := s.StartLogin(context.Background())
+	wantStatus(t, err, 404)
+}
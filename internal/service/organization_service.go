@@ -0,0 +1,227 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+)
+
+// slugPattern is what organisation slugs, which users give when logging in,
+// look like
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationService manages organisations, the tenants users and tasks
+// belong to, and the roles of their members
+type OrganizationService interface {
+	CreateOrganization(org *models.NewOrganization) (*models.Organization, error)
+	ListOrganizations() ([]*models.Organization, error)
+	GetOrganization(id int) (*models.Organization, error)
+	UpdateOrganization(id int, updates *models.UpdateOrganization) (*models.Organization, error)
+	// GetMember returns a user of the organisation, failing for users of
+	// other organisations as if they did not exist
+	GetMember(orgID, userID int) (*models.User, error)
+	// SetOrgRole changes a member's role in the organisation; actorID is an
+	// admin of the organisation, who cannot change their own role
+	SetOrgRole(actorID, orgID, userID int, role models.OrgRole) (*models.User, error)
+}
+
+type organizationService struct {
+	repo     repository.OrganizationRepository
+	userRepo repository.UserRepository
+	tx       repository.TxRunner
+	outbox   repository.OutboxRepository
+}
+
+// NewOrganizationService creates a new OrganizationService
+func NewOrganizationService(repo repository.OrganizationRepository, userRepo repository.UserRepository,
+	tx repository.TxRunner, outbox repository.OutboxRepository) OrganizationService {
+	return &organizationService{repo: repo, userRepo: userRepo, tx: tx, outbox: outbox}
+}
+
+func (s *organizationService) CreateOrganization(org *models.NewOrganization) (*models.Organization, error) {
+	if !slugPattern.MatchString(org.Slug) {
+		return nil, apierrors.NewBadRequestError("slug may only contain lower-case letters, digits and single hyphens")
+	}
+	if _, err := s.repo.GetOrganizationBySlug(org.Slug); err == nil {
+		return nil, apierrors.NewConflictError("an organization with this slug already exists")
+	}
+	return s.repo.CreateOrganization(org)
+}
+
+func (s *organizationService) ListOrganizations() ([]*models.Organization, error) {
+	return s.repo.ListOrganizations()
+}
+
+func (s *organizationService) GetOrganization(id int) (*models.Organization, error) {
+	org, err := s.repo.GetOrganizationByID(id)
+	if err != nil {
+		if err.Error() == "organization not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *organizationService) UpdateOrganization(id int, updates *models.UpdateOrganization) (*models.Organization, error) {
+	if _, err := s.GetOrganization(id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateOrganization(id, updates); err != nil && err.Error() != "organization not found" {
+		return nil, err
+	}
+	return s.GetOrganization(id)
+}
+
+func (s *organizationService) GetMember(orgID, userID int) (*models.User, error) {
+	if orgID == 0 {
+		return nil, apierrors.NewNotFoundError("user not found")
+	}
+	user, err := s.userRepo.ForTenant(orgID).GetUserByID(userID)
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+	return user, nil
+}
+
+func (s *organizationService) SetOrgRole(actorID, orgID, userID int, role models.OrgRole) (*models.User, error) {
+	if actorID == userID {
+		return nil, apierrors.NewForbiddenError("you cannot change your own organization role")
+	}
+	if _, err := s.GetMember(orgID, userID); err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx).ForTenant(orgID)
+		if err := repo.SetOrgRole(userID, role); err != nil {
+			return err
+		}
+		var err error
+		if user, err = repo.GetUserByID(userID); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserUpdated, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// TeamService manages the teams of an organisation
+type TeamService interface {
+	CreateTeam(team *models.NewTeam) (*models.Team, error)
+	ListTeams() ([]*models.Team, error)
+	GetTeam(id int) (*models.Team, error)
+	UpdateTeam(id int, updates *models.UpdateTeam) (*models.Team, error)
+	DeleteTeam(id int) error
+	ListMembers(id int) ([]*models.User, error)
+	AddMember(id, userID int) error
+	RemoveMember(id, userID int) error
+	// ForTenant returns a copy of the service confined to the teams of the
+	// given organisation
+	ForTenant(orgID int) TeamService
+}
+
+type teamService struct {
+	repo repository.TeamRepository
+}
+
+// NewTeamService creates a new TeamService
+func NewTeamService(repo repository.TeamRepository) TeamService {
+	return &teamService{repo: repo}
+}
+
+func (s *teamService) ForTenant(orgID int) TeamService {
+	return &teamService{repo: s.repo.ForTenant(orgID)}
+}
+
+func (s *teamService) CreateTeam(team *models.NewTeam) (*models.Team, error) {
+	if err := s.checkNameFree(team.Name, 0); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateTeam(team)
+}
+
+func (s *teamService) ListTeams() ([]*models.Team, error) {
+	return s.repo.ListTeams()
+}
+
+func (s *teamService) GetTeam(id int) (*models.Team, error) {
+	team, err := s.repo.GetTeamByID(id)
+	if err != nil {
+		return nil, notFound(err, "team not found")
+	}
+	return team, nil
+}
+
+func (s *teamService) UpdateTeam(id int, updates *models.UpdateTeam) (*models.Team, error) {
+	if updates.Name != nil {
+		if err := s.checkNameFree(*updates.Name, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.UpdateTeam(id, updates); err != nil {
+		return nil, notFound(err, "team not found")
+	}
+	return s.GetTeam(id)
+}
+
+func (s *teamService) DeleteTeam(id int) error {
+	return notFound(s.repo.DeleteTeam(id), "team not found")
+}
+
+func (s *teamService) ListMembers(id int) ([]*models.User, error) {
+	members, err := s.repo.ListTeamMembers(id)
+	if err != nil {
+		return nil, notFound(err, "team not found")
+	}
+	return members, nil
+}
+
+// AddMember only adds members of the team's organisation
+func (s *teamService) AddMember(id, userID int) error {
+	err := s.repo.AddTeamMember(id, userID)
+	if err != nil && err.Error() == "user not found" {
+		return apierrors.NewBadRequestError(fmt.Sprintf("user %d is not a member of the organization", userID))
+	}
+	return notFound(err, "team not found")
+}
+
+func (s *teamService) RemoveMember(id, userID int) error {
+	err := s.repo.RemoveTeamMember(id, userID)
+	if err != nil && (err.Error() == "team not found" || err.Error() == "team member not found") {
+		return apierrors.NewNotFoundError(err.Error())
+	}
+	return err
+}
+
+// checkNameFree refuses a team name already used by another team of the organisation
+func (s *teamService) checkNameFree(name string, id int) error {
+	teams, err := s.repo.ListTeams()
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if strings.EqualFold(team.Name, name) && team.ID != id {
+			return apierrors.NewConflictError("a team with this name already exists")
+		}
+	}
+	return nil
+}
+
+// notFound turns the repository's not-found error with the given message
+// into a 404, leaving other errors alone
+func notFound(err error, message string) error {
+	if err != nil && err.Error() == message {
+		return apierrors.NewNotFoundError(message)
+	}
+	return err
+}
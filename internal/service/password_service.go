@@ -0,0 +1,113 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/password"
+	"task-management-api/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errWrongPassword = errors.New("wrong password")
+
+// PasswordService applies the password policy and hashes passwords with the
+// configured bcrypt cost
+type PasswordService interface {
+	// Validate checks a new password against the policy and, for an existing
+	// user, against the passwords they used recently
+	Validate(user *models.User, newPassword string) error
+	Hash(plain string) (string, error)
+	// Set validates a new password for an existing user and stores it
+	Set(tx *sql.Tx, user *models.User, newPassword string) error
+	// Remember adds a hash to the user's password history
+	Remember(tx *sql.Tx, userID int, hash string) error
+	// Verify compares a password with the user's hash, rehashing it if it
+	// was made with another cost than the configured one
+	Verify(user *models.User, plain string) error
+}
+
+type passwordService struct {
+	userRepo repository.UserRepository
+	policy   *password.Policy
+	cfg      config.PasswordsConfig
+}
+
+// NewPasswordService creates a new PasswordService
+func NewPasswordService(userRepo repository.UserRepository, policy *password.Policy, cfg config.PasswordsConfig) PasswordService {
+	return &passwordService{userRepo: userRepo, policy: policy, cfg: cfg}
+}
+
+func (s *passwordService) Validate(user *models.User, newPassword string) error {
+	if err := s.policy.Check(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	if user.ID == 0 || s.cfg.History <= 0 {
+		return nil
+	}
+
+	// The current hash counts too, for accounts that predate the history
+	history, err := s.userRepo.ListPasswordHistory(user.ID, s.cfg.History)
+	if err != nil {
+		return err
+	}
+	for _, hash := range append([]string{user.PasswordHash}, history...) {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(newPassword)) == nil {
+			return &password.PolicyError{Violations: []string{"must not be one of your recent passwords"}}
+		}
+	}
+	return nil
+}
+
+func (s *passwordService) Hash(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), s.cfg.BcryptCost)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+	return string(hash), nil
+}
+
+func (s *passwordService) Set(tx *sql.Tx, user *models.User, newPassword string) error {
+	if err := s.Validate(user, newPassword); err != nil {
+		return err
+	}
+	hash, err := s.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.WithTx(tx).UpdatePassword(user.ID, hash); err != nil {
+		return err
+	}
+	return s.Remember(tx, user.ID, hash)
+}
+
+func (s *passwordService) Remember(tx *sql.Tx, userID int, hash string) error {
+	if s.cfg.History <= 0 {
+		return nil
+	}
+	return s.userRepo.WithTx(tx).AddPasswordHistory(userID, hash, s.cfg.History)
+}
+
+func (s *passwordService) Verify(user *models.User, plain string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(plain)); err != nil {
+		return errWrongPassword
+	}
+
+	// The password is known now, so a hash with an outdated cost can be
+	// replaced without asking the user
+	if cost, err := bcrypt.Cost([]byte(user.PasswordHash)); err == nil && cost != s.cfg.BcryptCost {
+		hash, err := s.Hash(plain)
+		if err == nil {
+			err = s.userRepo.UpdatePassword(user.ID, hash)
+		}
+		if err != nil {
+			log.Printf("Error rehashing password of user %d: %v", user.ID, err)
+		} else {
+			user.PasswordHash = hash
+		}
+	}
+	return nil
+}
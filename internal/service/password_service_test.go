@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"task-management-api/config"
+	"task-management-api/internal/models"
+	"task-management-api/internal/password"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestPasswordService(users *fakeUserRepo, history int) PasswordService {
+	cfg := config.PasswordsConfig{MinLength: 8, History: history, BcryptCost: bcrypt.MinCost}
+	return NewPasswordService(users, password.NewPolicy(cfg, nil), cfg)
+}
+
+func TestPasswordHistory(t *testing.T) {
+	user := &models.User{ID: 1, Username: "ann", Email: "ann@example.com", PasswordHash: mustHash(t, "first password")}
+	users := newFakeUserRepo(user)
+	s := newTestPasswordService(users, 2)
+
+	var policyErr *password.PolicyError
+	if err := s.Validate(users.users[1], "first password"); !errors.As(err, &policyErr) {
+		t.Errorf("reusing the current password: err = %v, want a policy error", err)
+	}
+
+	for _, p := range []string{"second password", "third password", "fourth password"} {
+		if err := s.Set(nil, users.users[1], p); err != nil {
+			t.Fatalf("Set(%q): %v", p, err)
+		}
+	}
+	if bcrypt.CompareHashAndPassword([]byte(users.users[1].PasswordHash), []byte("fourth password")) != nil {
+		t.Error("the new password was not stored")
+	}
+
+	if err := s.Validate(users.users[1], "third password"); !errors.As(err, &policyErr) {
+		t.Errorf("reusing a recent password: err = %v, want a policy error", err)
+	}
+	// Only the last two are remembered
+	if err := s.Validate(users.users[1], "second password"); err != nil {
+		t.Errorf("reusing a forgotten password: %v", err)
+	}
+	if err := s.Set(nil, users.users[1], "short"); !errors.As(err, &policyErr) {
+		t.Errorf("Set of a short password = %v, want a policy error", err)
+	}
+}
+
+func TestPasswordHistoryIgnoredForNewUsers(t *testing.T) {
+	s := newTestPasswordService(newFakeUserRepo(), 5)
+	if err := s.Validate(&models.User{Username: "bob"}, "a good password"); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestVerifyUpgradesHashCost(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := newFakeUserRepo(&models.User{ID: 1, PasswordHash: string(hash)})
+	s := newTestPasswordService(users, 0)
+
+	user, _ := users.GetUserByID(1)
+	if err := s.Verify(user, "wrong password"); err != errWrongPassword {
+		t.Errorf("Verify of a wrong password = %v", err)
+	}
+	if err := s.Verify(user, "old password"); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(users.users[1].PasswordHash)); cost != bcrypt.MinCost {
+		t.Errorf("stored hash cost = %d, want it upgraded to %d", cost, bcrypt.MinCost)
+	}
+	if user.PasswordHash != users.users[1].PasswordHash {
+		t.Error("the caller's user still has the old hash")
+	}
+}
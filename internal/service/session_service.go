@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"task-management-api/pkg/jwt"
+	"time"
+)
+
+// sessionTouchInterval limits how often a session's last-seen time is written
+const sessionTouchInterval = time.Minute
+
+// SessionService keeps track of login sessions so that users can see where
+// they are logged in and revoke tokens before they expire
+type SessionService interface {
+	// StartSession records a new session and issues its token
+	StartSession(user *models.User, client models.ClientInfo) (string, error)
+	// CheckSession verifies that the session behind a token is still active,
+	// and so is the user's account
+	CheckSession(sessionID string, userID int) error
+	ListSessions(userID int, currentID string) ([]*models.Session, error)
+	RevokeSession(userID int, id string) error
+	// RevokeOtherSessions revokes all of the user's sessions but the current one
+	RevokeOtherSessions(userID int, currentID string) error
+	RevokeAllSessions(userID int) error
+}
+
+type sessionService struct {
+	repo     repository.SessionRepository
+	userRepo repository.UserRepository
+}
+
+// NewSessionService creates a new SessionService
+func NewSessionService(repo repository.SessionRepository, userRepo repository.UserRepository) SessionService {
+	return &sessionService{repo: repo, userRepo: userRepo}
+}
+
+func (s *sessionService) StartSession(user *models.User, client models.ClientInfo) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating session ID: %v", err)
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         hex.EncodeToString(id),
+		UserID:     user.ID,
+		UserAgent:  truncate(client.UserAgent, 255),
+		IPAddress:  client.IPAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(jwt.TokenLifetime),
+	}
+	if err := s.repo.CreateSession(session); err != nil {
+		return "", err
+	}
+
+	return jwt.GenerateToken(user.ID, string(user.Role), user.OrgID, string(user.OrgRole), session.ID)
+}
+
+func (s *sessionService) CheckSession(sessionID string, userID int) error {
+	expired := errors.New("session expired")
+	if sessionID == "" {
+		return expired
+	}
+
+	session, err := s.repo.GetSession(sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			return expired
+		}
+		return err
+	}
+	if session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return expired
+	}
+
+	// Sessions stop working with their account, like access tokens do
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return expired
+		}
+		return err
+	}
+	if !user.IsActive || user.DeletionScheduledAt != nil {
+		return expired
+	}
+
+	return s.repo.TouchSession(sessionID, time.Now().Add(-sessionTouchInterval))
+}
+
+func (s *sessionService) ListSessions(userID int, currentID string) ([]*models.Session, error) {
+	sessions, err := s.repo.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) RevokeSession(userID int, id string) error {
+	err := s.repo.RevokeSession(userID, id)
+	if err != nil && err.Error() == "session not found" {
+		return apierrors.NewNotFoundError(err.Error())
+	}
+	return err
+}
+
+func (s *sessionService) RevokeOtherSessions(userID int, currentID string) error {
+	return s.repo.RevokeSessions(userID, currentID)
+}
+
+func (s *sessionService) RevokeAllSessions(userID int) error {
+	return s.repo.RevokeSessions(userID, "")
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
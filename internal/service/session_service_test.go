@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+type fakeSessionRepo struct {
+	repository.SessionRepository
+	sessions map[string]*models.Session
+	touched  []string
+}
+
+func (r *fakeSessionRepo) GetSession(id string) (*models.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	stored := *session
+	return &stored, nil
+}
+
+func (r *fakeSessionRepo) TouchSession(id string, since time.Time) error {
+	r.touched = append(r.touched, id)
+	return nil
+}
+
+func TestCheckSession(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		session *models.Session
+		user    *models.User
+		wantErr bool
+	}{
+		{
+			name:    "active",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(time.Hour)},
+			user:    &models.User{ID: 1, IsActive: true},
+		},
+		{
+			name:    "revoked",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(time.Hour), RevokedAt: &now},
+			user:    &models.User{ID: 1, IsActive: true},
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(-time.Second)},
+			user:    &models.User{ID: 1, IsActive: true},
+			wantErr: true,
+		},
+		{
+			name:    "another user's",
+			session: &models.Session{UserID: 2, ExpiresAt: now.Add(time.Hour)},
+			user:    &models.User{ID: 1, IsActive: true},
+			wantErr: true,
+		},
+		{
+			name:    "deactivated user",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(time.Hour)},
+			user:    &models.User{ID: 1},
+			wantErr: true,
+		},
+		{
+			name:    "user scheduled for deletion",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(time.Hour)},
+			user:    &models.User{ID: 1, IsActive: true, DeletionScheduledAt: &now},
+			wantErr: true,
+		},
+		{
+			name:    "deleted user",
+			session: &models.Session{UserID: 1, ExpiresAt: now.Add(time.Hour)},
+			user:    &models.User{ID: 1, IsActive: true, DeletedAt: &now},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.session.ID = "s1"
+			repo := &fakeSessionRepo{sessions: map[string]*models.Session{"s1": tt.session}}
+			s := NewSessionService(repo, newFakeUserRepo(tt.user))
+
+			err := s.CheckSession("s1", 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckSession = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && len(repo.touched) != 0 {
+				t.Error("touched a session that was refused")
+			}
+		})
+	}
+
+	t.Run("unknown session", func(t *testing.T) {
+		s := NewSessionService(&fakeSessionRepo{}, newFakeUserRepo())
+		if err := s.CheckSession("nope", 1); err == nil || err.Error() != "session expired" {
+			t.Errorf("CheckSession = %v, want session expired", err)
+		}
+	})
+}
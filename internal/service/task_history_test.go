@@ -0,0 +1,129 @@
+package service
+
+import (
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+// rename changes the title of a task as the given user
+func rename(t *testing.T, s *testTaskService, id int, title string, actorID int) {
+	t.Helper()
+	task, err := s.GetTaskByID(id)
+	if err != nil {
+		t.Fatalf("GetTaskByID: %v", err)
+	}
+	task.Title = title
+	if err := s.UpdateTask(task, models.Actor{UserID: actorID}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+}
+
+func TestTaskHistory(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	task := todo(1, nil)
+	task.CreatedAt, task.UpdatedAt = created, created
+	s := newTestTaskService(t, task, todo(2, nil))
+
+	// A task nobody changed since before history was recorded
+	history, err := s.GetTaskHistory(2)
+	if err != nil || len(history) != 0 {
+		t.Errorf("GetTaskHistory of an unchanged task = %v, %v; want none", history, err)
+	}
+	_, err = s.GetTaskHistory(9)
+	wantStatus(t, err, 404)
+
+	rename(t, s, 1, "renamed", 7)
+	update, _ := s.GetTaskByID(1)
+	update.Status = models.TaskStatusInProgress
+	if err := s.UpdateTask(update, models.Actor{UserID: 8}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	history, err = s.GetTaskHistory(1)
+	if err != nil {
+		t.Fatalf("GetTaskHistory: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("%d revisions, want the baseline and two changes", len(history))
+	}
+	baseline := history[0]
+	if baseline.ActorID != nil || !baseline.CreatedAt.Equal(created) || len(baseline.Changes) != 0 {
+		t.Errorf("baseline = %+v; want the state before the first change, by nobody", baseline)
+	}
+	if changes := history[1].Changes; len(changes) != 1 || changes[0].Field != "title" ||
+		changes[0].From != "task" || changes[0].To != "renamed" {
+		t.Errorf("first change = %+v, want the title", changes)
+	}
+	if changes := history[2].Changes; len(changes) != 1 || changes[0].Field != "status" {
+		t.Errorf("second change = %+v, want the status", changes)
+	}
+	if *history[1].ActorID != 7 || *history[2].ActorID != 8 {
+		t.Errorf("changes made by %d and %d, want 7 and 8", *history[1].ActorID, *history[2].ActorID)
+	}
+}
+
+func TestGetTaskAsOf(t *testing.T) {
+	created := time.Now().Add(-3 * time.Hour)
+	task := todo(1, nil)
+	task.CreatedAt, task.UpdatedAt = created, created
+	s := newTestTaskService(t, task)
+
+	// Before any history, the task has been as it is since it was created
+	if got, err := s.GetTaskAsOf(1, created.Add(time.Minute)); err != nil || got.Title != "task" {
+		t.Errorf("GetTaskAsOf without history = %v, %v", got, err)
+	}
+	_, err := s.GetTaskAsOf(1, created.Add(-time.Minute))
+	wantStatus(t, err, 404)
+
+	rename(t, s, 1, "renamed", 7)
+	renamed := time.Now().Add(-time.Hour)
+	s.revisions.revisions[1].CreatedAt = renamed
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{created.Add(time.Minute), "task"},
+		{renamed.Add(-time.Second), "task"},
+		{renamed, "renamed"},
+		{time.Now(), "renamed"},
+	}
+	for _, tt := range tests {
+		if got, err := s.GetTaskAsOf(1, tt.at); err != nil || got.Title != tt.want {
+			t.Errorf("GetTaskAsOf(%v) = %v, %v; want %q", tt.at, got, err, tt.want)
+		}
+	}
+	_, err = s.GetTaskAsOf(1, created.Add(-time.Minute))
+	wantStatus(t, err, 404)
+}
+
+func TestRevertTask(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil))
+	rename(t, s, 1, "second", 7)
+	rename(t, s, 1, "third", 7)
+
+	reverted, err := s.RevertTask(1, 1, models.Actor{UserID: 8})
+	if err != nil {
+		t.Fatalf("RevertTask: %v", err)
+	}
+	if reverted.Title != "task" {
+		t.Errorf("title = %q, want the first one back", reverted.Title)
+	}
+
+	// Reverting adds a revision rather than rewriting history
+	history, err := s.GetTaskHistory(1)
+	if err != nil {
+		t.Fatalf("GetTaskHistory: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("%d revisions, want 4", len(history))
+	}
+	last := history[3]
+	if *last.ActorID != 8 || len(last.Changes) != 1 || last.Changes[0].From != "third" || last.Changes[0].To != "task" {
+		t.Errorf("last revision = %+v, want user 8 changing the title back", last)
+	}
+
+	_, err = s.RevertTask(1, 9, models.Actor{UserID: 8})
+	wantStatus(t, err, 404)
+}
@@ -1,42 +1,999 @@
 package service
 
 import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/filter"
 	"task-management-api/internal/models"
 	"task-management-api/internal/repository"
+	"task-management-api/internal/search"
+	"time"
 )
 
 type TaskService interface {
 	CreateTask(task *models.Task) error
 	GetTaskByID(id int) (*models.Task, error)
-	GetAllTasks() ([]*models.Task, error)
-	UpdateTask(task *models.Task) error
-	DeleteTask(id int) error
+	GetAllTasks(filter models.TaskFilter) ([]*models.Task, error)
+	// EachTask calls fn with the tasks GetAllTasks would return, one at a
+	// time as they are read, so that exports need not hold them all
+	EachTask(filter models.TaskFilter, fn func(task *models.Task) error) error
+	GetSubtasks(id int) ([]*models.Task, error)
+	GetTaskTree(id int) (*models.TaskNode, error)
+	// UpdateTask changes a task on behalf of the actor, recording the new
+	// state as a revision
+	UpdateTask(task *models.Task, actor models.Actor) error
+	// GetTaskHistory returns the revisions of a task, oldest first, each
+	// with the fields it changed
+	GetTaskHistory(id int) ([]*models.TaskRevision, error)
+	// GetTaskAsOf returns the state a task was in at the given time
+	GetTaskAsOf(id int, at time.Time) (*models.Task, error)
+	// RevertTask brings a task back to the state of an earlier revision,
+	// recording it as a new revision
+	RevertTask(id, revision int, actor models.Actor) (*models.Task, error)
+	// DeleteTask moves a task to the trash on behalf of the actor, where it
+	// can be restored until it is purged
+	DeleteTask(id int, actor models.Actor) error
+	// CheckChange refuses the changes of a task the actor may not make. Only
+	// admins of the organisation change the tasks of other users or give
+	// tasks to them; assignee is the user the change gives the task to, if any.
+	CheckChange(task *models.Task, actor models.Actor, assignee *int) error
+	ListDeletedTasks() ([]*models.Task, error)
+	// RestoreTask takes a task out of the trash, with the subtasks deleted
+	// along with it
+	RestoreTask(id int) (*models.Task, error)
+	// PurgeTask permanently deletes a task in the trash, with its subtasks
+	// in the trash and their attachments
+	PurgeTask(id int) error
+	// PurgeDeletedTasks permanently deletes the tasks moved to the trash
+	// before the given time
+	PurgeDeletedTasks(before time.Time) error
+	// ArchiveTask moves a task to the archive together with its subtasks,
+	// all of which have to be DONE
+	ArchiveTask(id int) (*models.Task, error)
+	// UnarchiveTask brings a task back from the archive, with the subtasks
+	// archived along with it
+	UnarchiveTask(id int) (*models.Task, error)
+	// ArchiveCompletedTasks archives the top-level tasks that have been DONE,
+	// with all of their subtasks, since before the given time
+	ArchiveCompletedTasks(before time.Time) error
+	GetDependencies(id int) (*models.TaskDependencies, error)
+	AddDependency(blockerID, blockedID int) error
+	RemoveDependency(blockerID, blockedID int) error
+	GetTaskPlan(id int) (*models.TaskPlan, error)
+	SearchTasks(query string, cond filter.Expr, includeArchived bool, limit int) ([]*models.SearchResult, error)
+	// ForTenant returns a copy of the service confined to the tasks of the
+	// given organisation
+	ForTenant(orgID int) TaskService
+}
+
+// TxTaskService is a TaskService that can make its changes inside a
+// transaction opened by another service, so that bulk changes and imports
+// are checked and recorded like any other change
+type TxTaskService interface {
+	TaskService
+	// JoinTx returns a copy of the service that runs inside tx instead of
+	// opening transactions of its own. It adds the tasks it changes to
+	// refreshed instead of refreshing them in the search index, which the
+	// caller does with Refresh once the transaction is over.
+	JoinTx(tx *sql.Tx, refreshed *[]int) TxTaskService
+	// UpdateTaskAndAssignee is UpdateTask also changing the user the task
+	// belongs to when assign is set
+	UpdateTaskAndAssignee(task *models.Task, actor models.Actor, assign bool) error
+	// Refresh brings the search index up to date with a task
+	Refresh(id int)
+	// ForTenantTx is ForTenant for callers that need a TxTaskService
+	ForTenantTx(orgID int) TxTaskService
 }
 
 type taskService struct {
-	repo repository.TaskRepository
+	repo        repository.TaskRepository
+	depRepo     repository.DependencyRepository
+	revisions   repository.TaskRevisionRepository
+	attachments AttachmentService
+	index       search.Index
+	tx          repository.TxRunner
+	outbox      repository.OutboxRepository
+	orgID       int
+	// deferred collects the tasks to refresh in the search index once the
+	// caller's transaction is committed, when the service runs inside one
+	deferred *[]int
+}
+
+// NewTaskService creates a new TaskService. Every change is written together
+// with the events describing it, in one transaction, through tx and outbox.
+func NewTaskService(repo repository.TaskRepository, depRepo repository.DependencyRepository,
+	revisions repository.TaskRevisionRepository, attachments AttachmentService, index search.Index, tx repository.TxRunner,
+	outbox repository.OutboxRepository) TxTaskService {
+	return &taskService{repo: repo, depRepo: depRepo, revisions: revisions, attachments: attachments, index: index, tx: tx,
+		outbox: outbox}
+}
+
+func (s *taskService) ForTenant(orgID int) TaskService {
+	return s.ForTenantTx(orgID)
+}
+
+func (s *taskService) ForTenantTx(orgID int) TxTaskService {
+	scoped := *s
+	scoped.repo = s.repo.ForTenant(orgID)
+	scoped.revisions = s.revisions.ForTenant(orgID)
+	scoped.orgID = orgID
+	return &scoped
 }
 
-func NewTaskService(repo repository.TaskRepository) TaskService {
-	return &taskService{repo: repo}
+func (s *taskService) JoinTx(tx *sql.Tx, refreshed *[]int) TxTaskService {
+	joined := *s
+	joined.repo = s.repo.WithTx(tx)
+	joined.depRepo = s.depRepo.WithTx(tx)
+	joined.revisions = s.revisions.WithTx(tx)
+	joined.tx = joinedTx{tx: tx}
+	joined.deferred = refreshed
+	return &joined
+}
+
+// joinedTx runs functions in a transaction that is already open, leaving
+// the commit to whoever opened it
+type joinedTx struct {
+	tx *sql.Tx
+}
+
+func (j joinedTx) RunInTx(fn func(tx *sql.Tx) error) error {
+	return fn(j.tx)
 }
 
 func (s *taskService) CreateTask(task *models.Task) error {
-	return s.repo.CreateTask(task)
+	if task.ParentID != nil {
+		if err := s.checkParentExists(*task.ParentID); err != nil {
+			return err
+		}
+	}
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.CreateTask(task); err != nil {
+			return err
+		}
+		stored, err := repo.GetTaskByID(task.ID)
+		if err != nil {
+			return err
+		}
+		*task = *stored
+		revision := &models.TaskRevision{TaskID: stored.ID, OrgID: stored.OrgID, ActorID: stored.UserID, Task: stored}
+		if err := s.revisions.WithTx(tx).CreateRevision(revision); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.TaskCreated, stored))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.refresh(task.ID)
+	return nil
 }
 
 func (s *taskService) GetTaskByID(id int) (*models.Task, error) {
 	return s.repo.GetTaskByID(id)
 }
 
-func (s *taskService) GetAllTasks() ([]*models.Task, error) {
-	return s.repo.GetAllTasks()
+func (s *taskService) GetAllTasks(filter models.TaskFilter) ([]*models.Task, error) {
+	return s.repo.GetAllTasks(filter)
+}
+
+func (s *taskService) EachTask(filter models.TaskFilter, fn func(task *models.Task) error) error {
+	return s.repo.EachTask(filter, fn)
+}
+
+// GetSubtasks returns the direct children of a task
+func (s *taskService) GetSubtasks(id int) ([]*models.Task, error) {
+	if _, err := s.repo.GetTaskByID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetChildren(id)
+}
+
+// GetTaskTree returns a task with all of its descendants nested below it,
+// each node carrying the share of its descendants that are DONE
+func (s *taskService) GetTaskTree(id int) (*models.TaskNode, error) {
+	root, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants, err := s.repo.GetDescendants(id)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[int]*models.TaskNode{root.ID: {Task: root, Children: []*models.TaskNode{}}}
+	for _, task := range descendants {
+		nodes[task.ID] = &models.TaskNode{Task: task, Children: []*models.TaskNode{}}
+	}
+	for _, task := range descendants {
+		// A task whose parent was deleted or archived on its own is left
+		// out, along with whatever is below it
+		parent, ok := nodes[*task.ParentID]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, nodes[task.ID])
+	}
+
+	rollUpProgress(nodes[root.ID])
+	return nodes[root.ID], nil
+}
+
+// rollUpProgress fills in Progress for the node and everything below it and
+// returns the number of descendants and how many of them are DONE
+func rollUpProgress(node *models.TaskNode) (total, done int) {
+	for _, child := range node.Children {
+		childTotal, childDone := rollUpProgress(child)
+		total += childTotal + 1
+		done += childDone
+		if child.Status == models.TaskStatusDone {
+			done++
+		}
+	}
+
+	switch {
+	case total > 0:
+		node.Progress = float64(done) * 100 / float64(total)
+	case node.Status == models.TaskStatusDone:
+		node.Progress = 100
+	default:
+		node.Progress = 0
+	}
+	return total, done
+}
+
+func (s *taskService) UpdateTask(task *models.Task, actor models.Actor) error {
+	return s.update(task, actor, false)
+}
+
+func (s *taskService) UpdateTaskAndAssignee(task *models.Task, actor models.Actor, assign bool) error {
+	return s.update(task, actor, assign)
 }
 
-func (s *taskService) UpdateTask(task *models.Task) error {
-	return s.repo.UpdateTask(task)
+func (s *taskService) CheckChange(task *models.Task, actor models.Actor, assignee *int) error {
+	if actor.IsAdmin() {
+		return nil
+	}
+	if task.UserID != nil && *task.UserID != actor.UserID {
+		return apierrors.NewForbiddenError("you may not change this task")
+	}
+	if assignee != nil && *assignee != actor.UserID {
+		return apierrors.NewForbiddenError("only admins can assign tasks to other users")
+	}
+	return nil
 }
 
-func (s *taskService) DeleteTask(id int) error {
-	return s.repo.DeleteTask(id)
-}
\ No newline at end of file
+// update changes a task, including the user it belongs to when assign is set.
+// The checks run in the transaction writing the change, on locked rows, so
+// that concurrent updates cannot slip past them.
+func (s *taskService) update(task *models.Task, actor models.Actor, assign bool) error {
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		revisions := s.revisions.WithTx(tx)
+
+		current, err := repo.LockTask(task.ID)
+		if err != nil {
+			return err
+		}
+		var assignee *int
+		if assign {
+			assignee = task.UserID
+		}
+		if err := s.CheckChange(current, actor, assignee); err != nil {
+			return err
+		}
+		if task.KeepParent {
+			task.ParentID = current.ParentID
+		}
+
+		if task.Status != current.Status && (task.Status == models.TaskStatusInProgress || task.Status == models.TaskStatusDone) {
+			if err := checkNotBlocked(s.depRepo.WithTx(tx), task.ID); err != nil {
+				return err
+			}
+		}
+		if task.ParentID != nil && !equalIntPtr(task.ParentID, current.ParentID) {
+			if err := checkMove(repo, task.ID, *task.ParentID); err != nil {
+				return err
+			}
+		}
+
+		// Tasks from before history was recorded start it with the state
+		// they are in now
+		recorded, err := revisions.HasRevisions(task.ID)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			baseline := &models.TaskRevision{TaskID: current.ID, OrgID: current.OrgID, Task: current, CreatedAt: current.UpdatedAt}
+			if err := revisions.CreateRevision(baseline); err != nil {
+				return err
+			}
+		}
+
+		if err := repo.UpdateTask(task); err != nil {
+			return err
+		}
+		if assign {
+			if err := repo.AssignTask(task.ID, task.UserID); err != nil {
+				return err
+			}
+		}
+		stored, err := repo.GetTaskByID(task.ID)
+		if err != nil {
+			return err
+		}
+		revision := &models.TaskRevision{TaskID: stored.ID, OrgID: stored.OrgID, ActorID: &actor.UserID, Task: stored}
+		if err := revisions.CreateRevision(revision); err != nil {
+			return err
+		}
+
+		evts := []events.Event{events.New(events.TaskUpdated, stored)}
+		if stored.Status != current.Status {
+			evts = append(evts, events.New(events.TaskStatusChanged, events.StatusChangedData{
+				Task:      stored,
+				OldStatus: string(current.Status),
+				NewStatus: string(stored.Status),
+			}))
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.refresh(task.ID)
+	return nil
+}
+
+func (s *taskService) GetTaskHistory(id int) ([]*models.TaskRevision, error) {
+	revisions, err := s.revisions.ListRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		// The task may not have changed since before history was recorded
+		if _, err := s.repo.GetTaskByID(id); err != nil {
+			if err.Error() == "task not found" {
+				return nil, apierrors.NewNotFoundError(err.Error())
+			}
+			return nil, err
+		}
+		return []*models.TaskRevision{}, nil
+	}
+
+	for i, revision := range revisions {
+		if i == 0 {
+			revision.Changes = []models.FieldChange{}
+			continue
+		}
+		revision.Changes = diffTasks(revisions[i-1].Task, revision.Task)
+	}
+	return revisions, nil
+}
+
+func (s *taskService) GetTaskAsOf(id int, at time.Time) (*models.Task, error) {
+	revision, err := s.revisions.GetRevisionAsOf(id, at)
+	if err == nil {
+		return revision.Task, nil
+	}
+	if err.Error() != "revision not found" {
+		return nil, err
+	}
+
+	// A task without history has been in its current state since it was
+	// created
+	recorded, err := s.revisions.HasRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		task, err := s.repo.GetTaskByID(id)
+		if err != nil && err.Error() != "task not found" {
+			return nil, err
+		}
+		if task != nil && !task.CreatedAt.After(at) {
+			return task, nil
+		}
+	}
+	return nil, apierrors.NewNotFoundError("task did not exist at that time")
+}
+
+func (s *taskService) RevertTask(id, revision int, actor models.Actor) (*models.Task, error) {
+	target, err := s.revisions.GetRevision(id, revision)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	current, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+
+	task := *current
+	task.ParentID = target.Task.ParentID
+	task.Title = target.Task.Title
+	task.Description = target.Task.Description
+	task.Status = target.Task.Status
+	task.Priority = target.Task.Priority
+	task.DueDate = target.Task.DueDate
+	if err := s.UpdateTask(&task, actor); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTaskByID(id)
+}
+
+// diffTasks lists the fields that differ between two states of a task,
+// leaving out the timestamps
+func diffTasks(from, to *models.Task) []models.FieldChange {
+	changes := []models.FieldChange{}
+	add := func(field string, old, new interface{}) {
+		changes = append(changes, models.FieldChange{Field: field, From: old, To: new})
+	}
+
+	if !equalIntPtr(from.ParentID, to.ParentID) {
+		add("parent_id", from.ParentID, to.ParentID)
+	}
+	if !equalIntPtr(from.UserID, to.UserID) {
+		add("user_id", from.UserID, to.UserID)
+	}
+	if from.Title != to.Title {
+		add("title", from.Title, to.Title)
+	}
+	if from.Description != to.Description {
+		add("description", from.Description, to.Description)
+	}
+	if from.Status != to.Status {
+		add("status", from.Status, to.Status)
+	}
+	if from.Priority != to.Priority {
+		add("priority", from.Priority, to.Priority)
+	}
+	if !equalTimePtr(from.DueDate, to.DueDate) {
+		add("due_date", from.DueDate, to.DueDate)
+	}
+	return changes
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (s *taskService) DeleteTask(id int, actor models.Actor) error {
+	task, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	if err := s.CheckChange(task, actor, nil); err != nil {
+		return err
+	}
+	descendants, err := s.repo.GetDescendants(id)
+	if err != nil {
+		return err
+	}
+	tasks := append([]*models.Task{task}, descendants...)
+
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.DeleteTask(id); err != nil {
+			return err
+		}
+
+		// Depending on the delete policy the subtasks may have survived
+		var evts []events.Event
+		for _, t := range tasks {
+			if _, err := repo.GetTaskByID(t.ID); err != nil && err.Error() == "task not found" {
+				evts = append(evts, events.New(events.TaskDeleted, events.TaskDeletedData{ID: t.ID, OrgID: t.OrgID, UserID: t.UserID}))
+			}
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+	if err != nil {
+		if err.Error() == "task has subtasks" {
+			return apierrors.NewConflictError("task has subtasks; delete or move them first")
+		}
+		return err
+	}
+
+	for _, t := range tasks {
+		s.refresh(t.ID)
+	}
+	return nil
+}
+
+func (s *taskService) ListDeletedTasks() ([]*models.Task, error) {
+	return s.repo.ListDeletedTasks()
+}
+
+func (s *taskService) RestoreTask(id int) (*models.Task, error) {
+	var restored []*models.Task
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.RestoreTask(id); err != nil {
+			return err
+		}
+		task, err := repo.GetTaskByID(id)
+		if err != nil {
+			return err
+		}
+		descendants, err := repo.GetDescendants(id)
+		if err != nil {
+			return err
+		}
+		restored = append([]*models.Task{task}, descendants...)
+
+		evts := make([]events.Event, len(restored))
+		for i, t := range restored {
+			evts[i] = events.New(events.TaskRestored, t)
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+	if err != nil {
+		switch err.Error() {
+		case "task not found":
+			return nil, apierrors.NewNotFoundError("task not found in the trash")
+		case "parent task is deleted":
+			return nil, apierrors.NewConflictError("the parent task is in the trash; restore it first")
+		}
+		return nil, err
+	}
+
+	for _, t := range restored {
+		s.refresh(t.ID)
+	}
+	return restored[0], nil
+}
+
+func (s *taskService) PurgeTask(id int) error {
+	// Remember which blobs the tasks use, since purging takes the
+	// attachment rows with it
+	task, err := s.repo.GetDeletedTask(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return apierrors.NewNotFoundError("task not found in the trash")
+		}
+		return err
+	}
+	descendants, err := s.repo.GetDeletedDescendants(id)
+	if err != nil {
+		return err
+	}
+	ids := []int{task.ID}
+	for _, d := range descendants {
+		ids = append(ids, d.ID)
+	}
+	checksums, err := s.attachments.TaskChecksums(ids)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.PurgeTask(id); err != nil {
+		return err
+	}
+	s.attachments.ReleaseBlobs(checksums)
+	return nil
+}
+
+func (s *taskService) PurgeDeletedTasks(before time.Time) error {
+	tasks, err := s.repo.ListTasksDeletedBefore(before)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		// Subtasks go with their parent, which may have come first
+		if err := s.PurgeTask(task.ID); err != nil && err.Error() != "task not found in the trash" {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *taskService) ArchiveTask(id int) (*models.Task, error) {
+	task, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		if err.Error() == "task not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	descendants, err := s.repo.GetDescendants(id)
+	if err != nil {
+		return nil, err
+	}
+	tasks := append([]*models.Task{task}, descendants...)
+
+	var archived *models.Task
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.ArchiveTask(id); err != nil {
+			return err
+		}
+
+		evts := make([]events.Event, len(tasks))
+		for i, t := range tasks {
+			stored, err := repo.GetArchivedTask(t.ID)
+			if err != nil {
+				return err
+			}
+			if t.ID == id {
+				archived = stored
+			}
+			evts[i] = events.New(events.TaskArchived, stored)
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+	if err != nil {
+		switch err.Error() {
+		case "task not found":
+			return nil, apierrors.NewNotFoundError(err.Error())
+		case "task is not completed":
+			return nil, apierrors.NewConflictError("only DONE tasks whose subtasks are all DONE can be archived")
+		}
+		return nil, err
+	}
+
+	for _, t := range tasks {
+		s.refresh(t.ID)
+	}
+	return archived, nil
+}
+
+func (s *taskService) UnarchiveTask(id int) (*models.Task, error) {
+	var unarchived []*models.Task
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		if err := repo.UnarchiveTask(id); err != nil {
+			return err
+		}
+		task, err := repo.GetTaskByID(id)
+		if err != nil {
+			return err
+		}
+		descendants, err := repo.GetDescendants(id)
+		if err != nil {
+			return err
+		}
+		unarchived = append([]*models.Task{task}, descendants...)
+
+		evts := make([]events.Event, len(unarchived))
+		for i, t := range unarchived {
+			evts[i] = events.New(events.TaskUnarchived, t)
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+	if err != nil {
+		switch err.Error() {
+		case "task not found":
+			return nil, apierrors.NewNotFoundError("task not found in the archive")
+		case "parent task is archived":
+			return nil, apierrors.NewConflictError("the parent task is archived; unarchive it first")
+		case "parent task is deleted":
+			return nil, apierrors.NewConflictError("the parent task is in the trash; restore it first")
+		}
+		return nil, err
+	}
+
+	for _, t := range unarchived {
+		s.refresh(t.ID)
+	}
+	return unarchived[0], nil
+}
+
+func (s *taskService) ArchiveCompletedTasks(before time.Time) error {
+	tasks, err := s.repo.ListTasksToArchive(before)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		descendants, err := s.repo.GetDescendants(task.ID)
+		if err != nil {
+			return err
+		}
+		completed := true
+		for _, d := range descendants {
+			if d.Status != models.TaskStatusDone || !d.UpdatedAt.Before(before) {
+				completed = false
+				break
+			}
+		}
+		if !completed {
+			continue
+		}
+
+		// The task may have changed since it was listed
+		if _, err := s.ArchiveTask(task.ID); err != nil {
+			if apiErr, ok := err.(*apierrors.APIError); ok && apiErr.StatusCode < 500 {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchTasks runs a full-text search over the tasks of the organisation,
+// optionally narrowed down by a filter expression
+func (s *taskService) SearchTasks(query string, cond filter.Expr, includeArchived bool,
+	limit int) ([]*models.SearchResult, error) {
+	if len(search.Tokenize(query)) == 0 {
+		return nil, apierrors.NewBadRequestError("search query must contain at least one word")
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return s.index.Search(query, cond, s.orgID, includeArchived, limit)
+}
+
+func (s *taskService) Refresh(id int) {
+	s.refresh(id)
+}
+
+// refresh re-reads a task after a change and brings the search index up to
+// date with it, dropping it from the index when it no longer exists. Archived
+// tasks stay in the index.
+func (s *taskService) refresh(id int) (*models.Task, error) {
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, id)
+		return nil, nil
+	}
+	task, err := s.repo.GetTaskByID(id)
+	if err != nil && err.Error() == "task not found" {
+		task, err = s.repo.GetArchivedTask(id)
+	}
+	if err != nil {
+		if err.Error() == "task not found" {
+			s.index.Remove(id)
+		} else {
+			log.Printf("Error reloading task %d: %v", id, err)
+		}
+		return nil, err
+	}
+	s.index.Index(task)
+	return task, nil
+}
+
+// checkNotBlocked refuses to start or finish a task while any of its blockers is still open
+func checkNotBlocked(depRepo repository.DependencyRepository, taskID int) error {
+	blockers, err := depRepo.GetBlockers(taskID)
+	if err != nil {
+		return err
+	}
+	var open []int
+	for _, blocker := range blockers {
+		if blocker.Status != models.TaskStatusDone {
+			open = append(open, blocker.ID)
+		}
+	}
+	if len(open) > 0 {
+		return apierrors.NewConflictError(fmt.Sprintf("task is blocked by open tasks %v", open))
+	}
+	return nil
+}
+
+func (s *taskService) GetDependencies(id int) (*models.TaskDependencies, error) {
+	if _, err := s.repo.GetTaskByID(id); err != nil {
+		return nil, err
+	}
+
+	blockedBy, err := s.depRepo.GetBlockers(id)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := s.depRepo.GetBlockedTasks(id)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := &models.TaskDependencies{BlockedBy: blockedBy, Blocks: blocks}
+	if deps.BlockedBy == nil {
+		deps.BlockedBy = []*models.Task{}
+	}
+	if deps.Blocks == nil {
+		deps.Blocks = []*models.Task{}
+	}
+	return deps, nil
+}
+
+// AddDependency records that blockerID blocks blockedID, rejecting edges that
+// would close a cycle in the dependency graph
+func (s *taskService) AddDependency(blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return apierrors.NewBadRequestError("a task cannot block itself")
+	}
+
+	// Both tasks are locked, lowest ID first, before looking for a cycle, so
+	// that two requests adding opposite dependencies run one after the other
+	// and the second sees the dependency of the first
+	return s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.repo.WithTx(tx)
+		deps := s.depRepo.WithTx(tx)
+		first, second := blockerID, blockedID
+		if first > second {
+			first, second = second, first
+		}
+		for _, id := range []int{first, second} {
+			if _, err := repo.LockTask(id); err != nil {
+				if err.Error() == "task not found" && id == blockerID {
+					return apierrors.NewBadRequestError("blocking task not found")
+				}
+				return err
+			}
+		}
+
+		cycle, err := deps.PathExists(blockedID, blockerID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return apierrors.NewConflictError("dependency would create a cycle")
+		}
+
+		if err := deps.AddDependency(blockerID, blockedID); err != nil {
+			if err.Error() == "dependency already exists" {
+				return apierrors.NewConflictError(err.Error())
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *taskService) RemoveDependency(blockerID, blockedID int) error {
+	if _, err := s.repo.GetTaskByID(blockedID); err != nil {
+		if err.Error() == "task not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	if err := s.depRepo.RemoveDependency(blockerID, blockedID); err != nil {
+		if err.Error() == "dependency not found" {
+			return apierrors.NewNotFoundError(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// GetTaskPlan orders a task and its subtasks topologically by their
+// dependencies and finds the critical path, the longest chain of tasks that
+// have to be completed one after another
+func (s *taskService) GetTaskPlan(id int) (*models.TaskPlan, error) {
+	root, err := s.repo.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := s.repo.GetDescendants(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := append([]*models.Task{root}, descendants...)
+	byID := make(map[int]*models.Task, len(tasks))
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		ids = append(ids, task.ID)
+	}
+
+	deps, err := s.depRepo.GetDependenciesAmong(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[int][]int)
+	inDegree := make(map[int]int, len(tasks))
+	for _, dep := range deps {
+		blocks[dep.BlockerID] = append(blocks[dep.BlockerID], dep.BlockedID)
+		inDegree[dep.BlockedID]++
+	}
+
+	// Kahn's algorithm, always picking the lowest ready ID so the order is stable
+	var ready []int
+	for _, id := range ids {
+		if inDegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	plan := &models.TaskPlan{Order: []*models.Task{}, CriticalPath: []*models.Task{}}
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		current := ready[0]
+		ready = ready[1:]
+		plan.Order = append(plan.Order, byID[current])
+		for _, next := range blocks[current] {
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(plan.Order) != len(tasks) {
+		return nil, fmt.Errorf("dependency graph contains a cycle")
+	}
+
+	// Longest path through the DAG, walking the topological order
+	length := make(map[int]int, len(tasks))
+	previous := make(map[int]int, len(tasks))
+	end := 0
+	for _, task := range plan.Order {
+		if length[task.ID] == 0 {
+			length[task.ID] = 1
+		}
+		if end == 0 || length[task.ID] > length[end] {
+			end = task.ID
+		}
+		for _, next := range blocks[task.ID] {
+			if length[task.ID]+1 > length[next] {
+				length[next] = length[task.ID] + 1
+				previous[next] = task.ID
+			}
+		}
+	}
+	for id := end; id != 0; id = previous[id] {
+		plan.CriticalPath = append([]*models.Task{byID[id]}, plan.CriticalPath...)
+	}
+
+	return plan, nil
+}
+
+// checkMove refuses to put a task below itself or one of its own subtasks.
+// It walks up from the new parent locking every ancestor, so that two moves
+// running at the same time cannot build a cycle between them.
+func checkMove(repo repository.TaskRepository, id, parentID int) error {
+	seen := map[int]bool{}
+	for ancestorID := parentID; !seen[ancestorID]; {
+		if ancestorID == id {
+			if parentID == id {
+				return apierrors.NewBadRequestError("a task cannot be its own parent")
+			}
+			return apierrors.NewBadRequestError("a task cannot be moved below one of its own subtasks")
+		}
+		seen[ancestorID] = true
+
+		ancestor, err := repo.LockTask(ancestorID)
+		if err != nil {
+			if err.Error() == "task not found" && ancestorID == parentID {
+				return apierrors.NewBadRequestError("parent task not found")
+			}
+			return err
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		ancestorID = *ancestor.ParentID
+	}
+	return nil
+}
+
+func (s *taskService) checkParentExists(parentID int) error {
+	if _, err := s.repo.GetTaskByID(parentID); err != nil {
+		if err.Error() == "task not found" {
+			return apierrors.NewBadRequestError("parent task not found")
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/models"
+	"testing"
+)
+
+func todo(id int, parentID *int) *models.Task {
+	return &models.Task{ID: id, OrgID: 1, ParentID: parentID, Title: "task", Status: models.TaskStatusTodo}
+}
+
+func TestUpdateTaskKeepsParentWhenLeftOut(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)))
+
+	update := todo(2, nil)
+	update.Title = "renamed"
+	update.KeepParent = true
+	if err := s.UpdateTask(update, models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	stored, _ := s.repo.GetTaskByID(2)
+	if stored.ParentID == nil || *stored.ParentID != 1 {
+		t.Errorf("parent = %v, want 1", stored.ParentID)
+	}
+	if stored.Title != "renamed" {
+		t.Errorf("title = %q, want renamed", stored.Title)
+	}
+}
+
+func TestUpdateTaskDetachesWithNullParent(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)))
+
+	if err := s.UpdateTask(todo(2, nil), models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	stored, _ := s.repo.GetTaskByID(2)
+	if stored.ParentID != nil {
+		t.Errorf("parent = %d, want none", *stored.ParentID)
+	}
+}
+
+func TestUpdateTaskRefusesBadParents(t *testing.T) {
+	// 1 > 2 > 3, and 4 on its own
+	tests := []struct {
+		name     string
+		id       int
+		parentID int
+	}{
+		{"own parent", 1, 1},
+		{"child", 1, 2},
+		{"grandchild", 1, 3},
+		{"missing parent", 4, 99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)), todo(3, intPtr(2)), todo(4, nil))
+
+			err := s.UpdateTask(todo(tt.id, intPtr(tt.parentID)), models.Actor{UserID: 7})
+			apiErr, ok := err.(*apierrors.APIError)
+			if !ok || apiErr.StatusCode != 400 {
+				t.Fatalf("UpdateTask = %v, want a bad request", err)
+			}
+			if len(s.outbox.events) != 0 || len(s.revisions.revisions) != 0 {
+				t.Error("a refused update was recorded")
+			}
+		})
+	}
+}
+
+func TestUpdateTaskLocksTheNewAncestors(t *testing.T) {
+	// 1 > 2 > 3, and 4 on its own
+	s := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)), todo(3, intPtr(2)), todo(4, nil))
+
+	if err := s.UpdateTask(todo(4, intPtr(3)), models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	want := []int{4, 3, 2, 1}
+	if len(s.repo.locked) != len(want) {
+		t.Fatalf("locked %v, want %v", s.repo.locked, want)
+	}
+	for i := range want {
+		if s.repo.locked[i] != want[i] {
+			t.Fatalf("locked %v, want %v", s.repo.locked, want)
+		}
+	}
+	if s.tx.runs != 1 {
+		t.Errorf("ran %d transactions, want the checks and the write in one", s.tx.runs)
+	}
+}
+
+func TestUpdateTaskRefusesToStartBlockedTask(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil), todo(2, nil))
+	s.deps.AddDependency(1, 2)
+
+	update := todo(2, nil)
+	update.Status = models.TaskStatusInProgress
+	err := s.UpdateTask(update, models.Actor{UserID: 7})
+	if apiErr, ok := err.(*apierrors.APIError); !ok || apiErr.StatusCode != 409 {
+		t.Fatalf("UpdateTask = %v, want a conflict", err)
+	}
+}
+
+func TestChangesOfAnotherUsersTaskNeedAnAdmin(t *testing.T) {
+	theirs := func() *models.Task {
+		task := todo(1, nil)
+		task.UserID = intPtr(8)
+		return task
+	}
+	tests := []struct {
+		name  string
+		actor models.Actor
+		want  int
+	}{
+		{"user", models.Actor{UserID: 7, Role: models.UserRoleUser, OrgRole: models.OrgRoleMember}, 403},
+		{"owner", models.Actor{UserID: 8, Role: models.UserRoleUser, OrgRole: models.OrgRoleMember}, 0},
+		{"organisation admin", models.Actor{UserID: 7, Role: models.UserRoleUser, OrgRole: models.OrgRoleAdmin}, 0},
+		{"admin", models.Actor{UserID: 7, Role: models.UserRoleAdmin}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestTaskService(t, theirs())
+			update := theirs()
+			update.Title = "renamed"
+			err := s.UpdateTask(update, tt.actor)
+			if tt.want != 0 {
+				wantStatus(t, err, tt.want)
+				if s.repo.tasks[1].Title != "task" {
+					t.Error("the task was changed")
+				}
+			} else if err != nil {
+				t.Errorf("UpdateTask: %v", err)
+			}
+
+			err = s.DeleteTask(1, tt.actor)
+			if tt.want != 0 {
+				wantStatus(t, err, tt.want)
+			} else if err != nil {
+				t.Errorf("DeleteTask: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetTaskTreeRollsUpProgress(t *testing.T) {
+	// 1 > (2 > (3, 4), 5), with 3 and 5 done
+	tasks := []*models.Task{todo(1, nil), todo(2, intPtr(1)), todo(3, intPtr(2)), todo(4, intPtr(2)), todo(5, intPtr(1))}
+	tasks[2].Status = models.TaskStatusDone
+	tasks[4].Status = models.TaskStatusDone
+	s := newTestTaskService(t, tasks...)
+
+	tree, err := s.GetTaskTree(1)
+	if err != nil {
+		t.Fatalf("GetTaskTree: %v", err)
+	}
+
+	if tree.Progress != 50 {
+		t.Errorf("root progress = %v, want 50", tree.Progress)
+	}
+	if len(tree.Children) != 2 {
+		t.Fatalf("root has %d children, want 2", len(tree.Children))
+	}
+	if got := tree.Children[0].Progress; got != 50 {
+		t.Errorf("progress of 2 = %v, want 50", got)
+	}
+	if got := tree.Children[1].Progress; got != 100 {
+		t.Errorf("progress of 5 = %v, want 100", got)
+	}
+}
+
+// orphanedDescendants also finds task 4 below task 1, under a parent that is
+// no longer there
+type orphanedDescendants struct {
+	*fakeTaskRepo
+}
+
+func (r orphanedDescendants) GetDescendants(id int) ([]*models.Task, error) {
+	descendants, err := r.fakeTaskRepo.GetDescendants(id)
+	return append(descendants, todo(4, intPtr(3)), todo(5, intPtr(4))), err
+}
+
+func TestGetTaskTreeLeavesOutOrphans(t *testing.T) {
+	repo := orphanedDescendants{newFakeTaskRepo(todo(1, nil), todo(2, intPtr(1)))}
+	s := NewTaskService(repo, &fakeDependencyRepo{}, &fakeRevisionRepo{}, nil, &fakeIndex{}, &fakeTx{}, &fakeOutbox{})
+
+	tree, err := s.GetTaskTree(1)
+	if err != nil {
+		t.Fatalf("GetTaskTree: %v", err)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].ID != 2 || len(tree.Children[0].Children) != 0 {
+		t.Errorf("tree = %+v, want only task 2 below task 1", tree)
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"log"
+	"task-management-api/config"
+	"time"
+)
+
+// StartTrashPurger permanently deletes the tasks and users that have been in
+// the trash for longer than the retention period, checking every purge
+// interval until ctx is done. The services must not be confined to a tenant.
+func StartTrashPurger(ctx context.Context, tasks TaskService, users UserService, cfg config.TrashConfig) {
+	go func() {
+		ticker := time.NewTicker(cfg.PurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				before := time.Now().Add(-cfg.Retention)
+				if err := tasks.PurgeDeletedTasks(before); err != nil {
+					log.Printf("Error purging deleted tasks: %v", err)
+				}
+				if err := users.PurgeDeletedUsers(before); err != nil {
+					log.Printf("Error purging deleted users: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+// fakeAttachments knows the blobs each task uses and records the ones
+// released
+type fakeAttachments struct {
+	AttachmentService
+	checksums map[int][]string
+	released  []string
+}
+
+func (a *fakeAttachments) TaskChecksums(taskIDs []int) ([]string, error) {
+	var checksums []string
+	for _, id := range taskIDs {
+		checksums = append(checksums, a.checksums[id]...)
+	}
+	return checksums, nil
+}
+
+func (a *fakeAttachments) ReleaseBlobs(checksums []string) {
+	a.released = append(a.released, checksums...)
+}
+
+// newTestTrashService is a task service whose task 1 has the subtask 2, and
+// whose tasks use the given blobs
+func newTestTrashService(t *testing.T, checksums map[int][]string) (*testTaskService, *fakeAttachments) {
+	t.Helper()
+	f := newTestTaskService(t, todo(1, nil), todo(2, intPtr(1)), todo(3, nil))
+	attachments := &fakeAttachments{checksums: checksums}
+	f.attachments = attachments
+	return f, attachments
+}
+
+func eventTypes(evts []events.Event) []string {
+	types := make([]string, len(evts))
+	for i, event := range evts {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestDeleteAndRestoreTask(t *testing.T) {
+	f, _ := newTestTrashService(t, nil)
+	for id := 1; id <= 3; id++ {
+		f.refresh(id)
+	}
+
+	if err := f.DeleteTask(1, models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if got := eventTypes(f.outbox.events); len(got) != 2 || got[0] != events.TaskDeleted || got[1] != events.TaskDeleted {
+		t.Errorf("events = %v, want the task and its subtask deleted", got)
+	}
+	if _, ok := f.index.indexed[2]; ok {
+		t.Error("the deleted subtask is still in the search index")
+	}
+	if _, err := f.GetTaskByID(1); err == nil {
+		t.Error("a deleted task can still be read")
+	}
+	deleted, err := f.ListDeletedTasks()
+	if err != nil || len(deleted) != 2 {
+		t.Errorf("ListDeletedTasks = %v, %v; want both tasks", deleted, err)
+	}
+
+	// The subtask cannot come back without its parent
+	_, err = f.RestoreTask(2)
+	wantStatus(t, err, 409)
+
+	f.outbox.events = nil
+	restored, err := f.RestoreTask(1)
+	if err != nil || restored.ID != 1 {
+		t.Fatalf("RestoreTask = %v, %v", restored, err)
+	}
+	if got := eventTypes(f.outbox.events); len(got) != 2 || got[0] != events.TaskRestored {
+		t.Errorf("events = %v, want the task and its subtask restored", got)
+	}
+	if _, ok := f.index.indexed[2]; !ok {
+		t.Error("the restored subtask is not back in the search index")
+	}
+
+	_, err = f.RestoreTask(3)
+	wantStatus(t, err, 404)
+}
+
+func TestPurgeTask(t *testing.T) {
+	f, attachments := newTestTrashService(t, map[int][]string{1: {"aaa"}, 2: {"bbb"}, 3: {"ccc"}})
+
+	// Only tasks in the trash can be purged
+	wantStatus(t, f.PurgeTask(1), 404)
+
+	if err := f.DeleteTask(1, models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := f.PurgeTask(1); err != nil {
+		t.Fatalf("PurgeTask: %v", err)
+	}
+	if len(f.repo.tasks) != 1 {
+		t.Errorf("%d tasks left, want only task 3", len(f.repo.tasks))
+	}
+	if len(attachments.released) != 2 || attachments.released[0] != "aaa" || attachments.released[1] != "bbb" {
+		t.Errorf("released %v, want the blobs of the task and its subtask", attachments.released)
+	}
+}
+
+func TestPurgeDeletedTasks(t *testing.T) {
+	f, _ := newTestTrashService(t, nil)
+	for _, id := range []int{1, 3} {
+		if err := f.DeleteTask(id, models.Actor{UserID: 7}); err != nil {
+			t.Fatalf("DeleteTask: %v", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	f.repo.tasks[1].DeletedAt, f.repo.tasks[2].DeletedAt = &old, &old
+
+	// The subtask is listed too but goes with its parent
+	if err := f.PurgeDeletedTasks(time.Now().Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedTasks: %v", err)
+	}
+	if len(f.repo.tasks) != 1 || f.repo.tasks[3] == nil {
+		t.Errorf("tasks left = %v, want only the recently deleted task 3", f.repo.ids())
+	}
+}
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	users := newFakeUserRepo(&models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true})
+	sessions := &fakeSessions{}
+	outbox := &fakeOutbox{}
+	s := NewUserService(users, &fakeOrgRepo{}, &fakeTx{}, outbox, sessions, nil, nil,
+		newTestPasswordService(users, 0), config.AccountsConfig{}, config.RegistrationConfig{})
+
+	wantStatus(t, s.PurgeUser(1), 404)
+	if err := s.DeleteUser(1); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if len(sessions.revoked) != 1 || sessions.revoked[0] != 1 {
+		t.Errorf("revoked the sessions of %v, want user 1", sessions.revoked)
+	}
+	if _, err := s.GetUserByID(1); err == nil {
+		t.Error("a deleted user can still be read")
+	}
+
+	user, err := s.RestoreUser(1)
+	if err != nil || user.ID != 1 {
+		t.Fatalf("RestoreUser = %v, %v", user, err)
+	}
+	if got := eventTypes(outbox.events); len(got) != 2 || got[0] != events.UserDeleted || got[1] != events.UserRestored {
+		t.Errorf("events = %v", got)
+	}
+	_, err = s.RestoreUser(1)
+	wantStatus(t, err, 404)
+
+	if err := s.DeleteUser(1); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := s.PurgeUser(1); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	if len(users.users) != 0 {
+		t.Error("the purged user is still stored")
+	}
+}
+
+func TestDeletedUsersKeepTheirUsernameAndEmail(t *testing.T) {
+	users := newFakeUserRepo(
+		&models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true},
+		&models.User{ID: 2, Username: "bob", Email: "bob@example.com", IsActive: true},
+	)
+	s := NewUserService(users, &fakeOrgRepo{}, &fakeTx{}, &fakeOutbox{}, &fakeSessions{}, nil, nil,
+		newTestPasswordService(users, 0), config.AccountsConfig{}, config.RegistrationConfig{})
+	if err := s.DeleteUser(1); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// Until the user is purged, taking over their account is refused with a
+	// pointer to the trash rather than a bare "already exists"
+	_, err := s.CreateUser(&models.NewUser{Username: "ann", Email: "new@example.com", Password: "a long enough password"})
+	wantStatus(t, err, 409)
+	if err == nil || !strings.Contains(err.Error(), "trash") {
+		t.Errorf("CreateUser = %v, want the trash named", err)
+	}
+	_, err = s.CreateUser(&models.NewUser{Username: "ann2", Email: "ann@example.com", Password: "a long enough password"})
+	wantStatus(t, err, 409)
+	email := "ann@example.com"
+	wantStatus(t, s.UpdateUser(2, &models.UpdateUser{Email: &email}), 409)
+
+	if err := s.PurgeUser(1); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	if _, err := s.CreateUser(&models.NewUser{Username: "ann", Email: "ann@example.com", Password: "a long enough password"}); err != nil {
+		t.Errorf("CreateUser once the old account is purged: %v", err)
+	}
+}
+
+func TestTrashPurger(t *testing.T) {
+	f, _ := newTestTrashService(t, nil)
+	users := newFakeUserRepo(
+		&models.User{ID: 1, Username: "ann", Email: "ann@example.com", IsActive: true},
+		&models.User{ID: 2, Username: "bob", Email: "bob@example.com", IsActive: true},
+	)
+	userService := NewUserService(users, &fakeOrgRepo{}, &fakeTx{}, &fakeOutbox{}, &fakeSessions{}, nil, nil,
+		newTestPasswordService(users, 0), config.AccountsConfig{}, config.RegistrationConfig{})
+
+	old, recent := time.Now().Add(-48*time.Hour), time.Now()
+	f.repo.tasks[3].DeletedAt = &old
+	f.repo.tasks[1].DeletedAt, f.repo.tasks[2].DeletedAt = &recent, &recent
+	users.users[1].DeletedAt = &old
+	users.users[2].DeletedAt = &recent
+
+	mu := &sync.Mutex{}
+	purged := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartTrashPurger(ctx, lockedTasks{f.taskService, mu}, lockedUsers{userService, mu, purged},
+		config.TrashConfig{Retention: 24 * time.Hour, PurgeInterval: time.Millisecond})
+	select {
+	case <-purged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the trash was never purged")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(f.repo.tasks) != 2 || f.repo.tasks[3] != nil {
+		t.Errorf("tasks left = %v, want the recently deleted 1 and 2", f.repo.ids())
+	}
+	if len(users.users) != 1 || users.users[2] == nil {
+		t.Errorf("%d users left, want only the recently deleted user 2", len(users.users))
+	}
+}
+
+// lockedTasks and lockedUsers purge under a lock, so that a test can look at
+// the fakes while the purger is running
+type lockedTasks struct {
+	TaskService
+	mu *sync.Mutex
+}
+
+func (s lockedTasks) PurgeDeletedTasks(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.TaskService.PurgeDeletedTasks(before)
+}
+
+type lockedUsers struct {
+	UserService
+	mu *sync.Mutex
+	// purged is signalled after each round, which purges users last
+	purged chan struct{}
+}
+
+func (s lockedUsers) PurgeDeletedUsers(before time.Time) error {
+	s.mu.Lock()
+	err := s.UserService.PurgeDeletedUsers(before)
+	s.mu.Unlock()
+	select {
+	case s.purged <- struct{}{}:
+	default:
+	}
+	return err
+}
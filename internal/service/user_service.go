@@ -1,10 +1,17 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"log"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
 	"task-management-api/internal/models"
 	"task-management-api/internal/repository"
-	"task-management-api/pkg/jwt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,44 +19,150 @@ import (
 // UserService defines the interface for user-related business logic
 type UserService interface {
 	CreateUser(newUser *models.NewUser) (*models.User, error)
+	// Register creates an account through public registration, if the
+	// registration mode allows it. It always has the USER role.
+	Register(newUser *models.NewUser) (*models.User, error)
 	GetUserByID(id int) (*models.User, error)
 	GetUserByUsername(username string) (*models.User, error)
 	GetUserByEmail(email string) (*models.User, error)
 	UpdateUser(id int, updates *models.UpdateUser) error
+	// DeleteUser moves a user to the trash and logs them out everywhere;
+	// they can be restored until they are purged
 	DeleteUser(id int) error
+	ListDeletedUsers() ([]*models.User, error)
+	GetDeletedUser(id int) (*models.User, error)
+	RestoreUser(id int) (*models.User, error)
+	// PurgeUser permanently deletes a user in the trash
+	PurgeUser(id int) error
+	// PurgeDeletedUsers permanently deletes the users moved to the trash
+	// before the given time
+	PurgeDeletedUsers(before time.Time) error
 	ListUsers(page, pageSize int) ([]*models.User, error)
-	Authenticate(credentials *models.UserCredentials) (*models.User, string, error)
+	// Authenticate checks the user's password and logs them in, unless a
+	// second factor is needed, in which case the result carries a challenge
+	Authenticate(credentials *models.UserCredentials, client models.ClientInfo) (*models.LoginResult, error)
+	ChangePassword(id int, sessionID string, change *models.PasswordChange) error
+	ScheduleDeletion(id int, password string) (*models.User, error)
+	CancelDeletion(id int) (*models.User, error)
+	// StartDeletionPurger deletes accounts whose deletion grace period has
+	// ended, checking every interval until ctx is done
+	StartDeletionPurger(ctx context.Context, interval time.Duration)
+	// ForTenant returns a copy of the service confined to the users of the
+	// given organisation, which is where it creates users too
+	ForTenant(orgID int) UserService
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo  repository.UserRepository
+	orgRepo   repository.OrganizationRepository
+	tx        repository.TxRunner
+	outbox    repository.OutboxRepository
+	sessions  SessionService
+	mfa       MFAService
+	throttle  LoginThrottle
+	passwords PasswordService
+	cfg       config.AccountsConfig
+	// registration decides who may use Register
+	registration config.RegistrationConfig
+	// dummyHash is compared against when a username does not exist, so that
+	// the response takes as long as for a wrong password
+	dummyHash []byte
 }
 
-// NewUserService creates a new UserService
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+// NewUserService creates a new UserService. Every change is written together
+// with the events describing it, in one transaction, through tx and outbox.
+func NewUserService(userRepo repository.UserRepository, orgRepo repository.OrganizationRepository, tx repository.TxRunner,
+	outbox repository.OutboxRepository, sessions SessionService, mfa MFAService, throttle LoginThrottle,
+	passwords PasswordService, cfg config.AccountsConfig, registration config.RegistrationConfig) UserService {
+	dummyHash, err := passwords.Hash("not a real password")
+	if err != nil {
+		log.Fatalf("Failed to hash dummy password: %v", err)
+	}
+	return &userService{
+		userRepo: userRepo, orgRepo: orgRepo, tx: tx, outbox: outbox, sessions: sessions, mfa: mfa, throttle: throttle,
+		passwords: passwords, cfg: cfg, registration: registration, dummyHash: []byte(dummyHash),
+	}
+}
+
+func (s *userService) ForTenant(orgID int) UserService {
+	scoped := *s
+	scoped.userRepo = s.userRepo.ForTenant(orgID)
+	return &scoped
 }
 
 func (s *userService) CreateUser(newUser *models.NewUser) (*models.User, error) {
-	// Check if username already exists
+	if newUser.Role == "" {
+		newUser.Role = models.UserRoleUser
+	}
+
+	// Check if username already exists in the organisation
 	if _, err := s.userRepo.GetUserByUsername(newUser.Username); err == nil {
 		return nil, errors.New("username already exists")
 	}
 
-	// Check if email already exists
-	if _, err := s.userRepo.GetUserByEmail(newUser.Email); err == nil {
+	// Check if email already exists; emails are unique across organisations
+	if _, err := s.userRepo.AllTenants().GetUserByEmail(newUser.Email); err == nil {
 		return nil, errors.New("email already exists")
 	}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcrypt.DefaultCost)
+	// Check the password against the policy and hash it
+	if err := s.passwords.Validate(&models.User{Username: newUser.Username, Email: newUser.Email}, newUser.Password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.passwords.Hash(newUser.Password)
 	if err != nil {
-		return nil, errors.New("failed to hash password")
+		return nil, err
 	}
-	newUser.Password = string(hashedPassword)
+	newUser.Password = hashedPassword
 
 	// Create the user
-	return s.userRepo.CreateUser(newUser)
+	var user *models.User
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		var err error
+		if user, err = s.userRepo.WithTx(tx).CreateUser(newUser); err != nil {
+			return heldInTrash(err)
+		}
+		if err := s.passwords.Remember(tx, user.ID, hashedPassword); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserCreated, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) Register(newUser *models.NewUser) (*models.User, error) {
+	switch s.registration.Mode {
+	case models.RegistrationInviteOnly:
+		return nil, apierrors.NewForbiddenError("registration is by invitation only")
+	case models.RegistrationDomainAllowlist:
+		if !domainAllowed(newUser.Email, s.registration.AllowedDomains) {
+			return nil, apierrors.NewForbiddenError("registration is not open to this email domain")
+		}
+	}
+
+	// Whatever the request asked for, registering never grants a role;
+	// admins and organisation admins are made by other admins
+	newUser.Role = models.UserRoleUser
+	newUser.OrgRole = ""
+	return s.ForTenant(models.DefaultOrganizationID).CreateUser(newUser)
+}
+
+// domainAllowed reports whether the email address is at one of the domains
+func domainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *userService) GetUserByID(id int) (*models.User, error) {
@@ -66,17 +179,107 @@ func (s *userService) GetUserByEmail(email string) (*models.User, error) {
 
 func (s *userService) UpdateUser(id int, updates *models.UpdateUser) error {
 	if updates.Email != nil {
-		// Check if new email already exists
-		if user, err := s.userRepo.GetUserByEmail(*updates.Email); err == nil && user.ID != id {
+		// Check if new email already exists, in any organisation
+		if user, err := s.userRepo.AllTenants().GetUserByEmail(*updates.Email); err == nil && user.ID != id {
 			return errors.New("email already exists")
 		}
 	}
 
-	return s.userRepo.UpdateUser(id, updates)
+	current, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx)
+		if err := repo.UpdateUser(id, updates); err != nil {
+			return heldInTrash(err)
+		}
+		user, err := repo.GetUserByID(id)
+		if err != nil {
+			return err
+		}
+
+		evts := []events.Event{events.New(events.UserUpdated, user)}
+		if current.IsActive && !user.IsActive {
+			evts = append(evts, events.New(events.UserDeactivated, user))
+		}
+		return s.outbox.WithTx(tx).Append(evts...)
+	})
+}
+
+// heldInTrash turns the error for a username or email still held by a user
+// in the trash into a conflict saying how to free it
+func heldInTrash(err error) error {
+	switch err.Error() {
+	case "username belongs to a deleted user", "email belongs to a deleted user":
+		return apierrors.NewConflictError(err.Error() + "; restore or purge them from the trash first")
+	}
+	return err
 }
 
 func (s *userService) DeleteUser(id int) error {
-	return s.userRepo.DeleteUser(id)
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		if err := s.userRepo.WithTx(tx).DeleteUser(id); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserDeleted, events.UserDeletedData{ID: id}))
+	})
+	if err != nil {
+		return err
+	}
+	return s.sessions.RevokeAllSessions(id)
+}
+
+func (s *userService) ListDeletedUsers() ([]*models.User, error) {
+	return s.userRepo.ListDeletedUsers()
+}
+
+func (s *userService) GetDeletedUser(id int) (*models.User, error) {
+	user, err := s.userRepo.GetDeletedUser(id)
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+	return user, nil
+}
+
+func (s *userService) RestoreUser(id int) (*models.User, error) {
+	var user *models.User
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx)
+		if err := repo.RestoreUser(id); err != nil {
+			return err
+		}
+		var err error
+		if user, err = repo.GetUserByID(id); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserRestored, user))
+	})
+	if err != nil {
+		return nil, notFound(err, "user not found")
+	}
+	return user, nil
+}
+
+func (s *userService) PurgeUser(id int) error {
+	if _, err := s.GetDeletedUser(id); err != nil {
+		return err
+	}
+	return notFound(s.userRepo.PurgeUser(id), "user not found")
+}
+
+func (s *userService) PurgeDeletedUsers(before time.Time) error {
+	users, err := s.userRepo.ListUsersDeletedBefore(before)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := s.userRepo.PurgeUser(user.ID); err != nil && err.Error() != "user not found" {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *userService) ListUsers(page, pageSize int) ([]*models.User, error) {
@@ -90,22 +293,175 @@ func (s *userService) ListUsers(page, pageSize int) ([]*models.User, error) {
 	return s.userRepo.ListUsers(offset, pageSize)
 }
 
-func (s *userService) Authenticate(credentials *models.UserCredentials) (*models.User, string, error) {
-	user, err := s.userRepo.GetUserByUsername(credentials.Username)
+func (s *userService) Authenticate(credentials *models.UserCredentials, client models.ClientInfo) (*models.LoginResult, error) {
+	orgID := models.DefaultOrganizationID
+	if credentials.Organization != "" {
+		org, err := s.orgRepo.GetOrganizationBySlug(credentials.Organization)
+		if err != nil {
+			if err.Error() != "organization not found" {
+				return nil, err
+			}
+			// Answer like for an unknown username
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(credentials.Password))
+			s.throttle.Failed(0, nil, credentials.Username, client.IPAddress)
+			return nil, errors.New("invalid credentials")
+		}
+		orgID = org.ID
+	}
+
+	if err := s.throttle.Check(orgID, credentials.Username, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.ForTenant(orgID).GetUserByUsername(credentials.Username)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		if err.Error() != "user not found" {
+			return nil, err
+		}
+		// Spend the same time as for a wrong password, so that timing does
+		// not tell which usernames exist
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(credentials.Password))
+		s.throttle.Failed(orgID, nil, credentials.Username, client.IPAddress)
+		return nil, errors.New("invalid credentials")
+	}
+
+	// Verify also upgrades the hash if the configured cost changed
+	if err := s.passwords.Verify(user, credentials.Password); err != nil {
+		s.throttle.Failed(orgID, user, credentials.Username, client.IPAddress)
+		return nil, errors.New("invalid credentials")
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(credentials.Password))
+	if s.cfg.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return nil, errors.New("email not verified")
+	}
+
+	// Ask for a second factor if the user has one or their role requires it
+	challenge, err := s.mfa.Challenge(user)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		return nil, err
+	}
+	if challenge != nil {
+		return challenge, nil
 	}
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, string(user.Role))
+	// Start a session and issue its token
+	token, err := s.sessions.StartSession(user, client)
 	if err != nil {
-		return nil, "", errors.New("failed to generate token")
+		return nil, errors.New("failed to generate token")
 	}
+	s.throttle.Succeeded(orgID, credentials.Username)
 
-	return user, token, nil
+	return &models.LoginResult{User: user, Token: token}, nil
+}
+
+// ChangePassword sets a new password after checking the current one, and
+// logs the user out everywhere but the current session
+func (s *userService) ChangePassword(id int, sessionID string, change *models.PasswordChange) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, change.CurrentPassword); err != nil {
+		return err
+	}
+
+	err = s.tx.RunInTx(func(tx *sql.Tx) error {
+		return s.passwords.Set(tx, user, change.NewPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sessions.RevokeOtherSessions(id, sessionID)
+}
+
+// ScheduleDeletion deletes the user's account once the grace period has
+// passed, and logs them out everywhere. Logging back in and cancelling
+// within the grace period keeps the account.
+func (s *userService) ScheduleDeletion(id int, password string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPassword(user, password); err != nil {
+		return nil, err
+	}
+
+	at := time.Now().Add(s.cfg.DeletionGrace)
+	user, err = s.setDeletion(id, &at)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.RevokeAllSessions(id); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) CancelDeletion(id int) (*models.User, error) {
+	return s.setDeletion(id, nil)
+}
+
+func (s *userService) setDeletion(id int, at *time.Time) (*models.User, error) {
+	var user *models.User
+	err := s.tx.RunInTx(func(tx *sql.Tx) error {
+		repo := s.userRepo.WithTx(tx)
+		if err := repo.ScheduleDeletion(id, at); err != nil {
+			return err
+		}
+		var err error
+		if user, err = repo.GetUserByID(id); err != nil {
+			return err
+		}
+		return s.outbox.WithTx(tx).Append(events.New(events.UserUpdated, user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) StartDeletionPurger(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.purgeDeletedAccounts()
+			}
+		}
+	}()
+}
+
+func (s *userService) purgeDeletedAccounts() {
+	users, err := s.userRepo.ListUsersDueForDeletion(time.Now())
+	if err != nil {
+		log.Printf("Error listing accounts due for deletion: %v", err)
+		return
+	}
+	// The owner asked for the deletion and had the grace period to change
+	// their mind, so the account skips the trash
+	for _, user := range users {
+		err := s.tx.RunInTx(func(tx *sql.Tx) error {
+			if err := s.userRepo.WithTx(tx).PurgeUser(user.ID); err != nil {
+				return err
+			}
+			return s.outbox.WithTx(tx).Append(events.New(events.UserDeleted, events.UserDeletedData{ID: user.ID}))
+		})
+		if err != nil {
+			log.Printf("Error deleting account %d: %v", user.ID, err)
+		}
+	}
+}
+
+// checkPassword confirms a sensitive change with the user's current password
+func checkPassword(user *models.User, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return apierrors.NewForbiddenError("incorrect password")
+	}
+	return nil
 }
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-management-api/config"
+	apierrors "task-management-api/internal/errors"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"time"
+)
+
+const (
+	// maxResponseBodyLog caps how much of a subscriber's response is kept in the attempt log
+	maxResponseBodyLog = 2048
+	deliveryBatchSize  = 50
+	deliveryListLimit  = 100
+)
+
+// WebhookService manages webhook subscriptions and delivers events to them.
+// It is an events.Publisher fed by the outbox relay: published events are
+// queued as deliveries and sent in the background by the dispatcher.
+type WebhookService interface {
+	events.Publisher
+	CreateWebhook(webhook *models.Webhook, createdBy int) error
+	GetWebhook(id int) (*models.Webhook, error)
+	ListWebhooks() ([]*models.Webhook, error)
+	UpdateWebhook(id int, updates *models.UpdateWebhook) (*models.Webhook, error)
+	DeleteWebhook(id int) error
+	ListDeliveries(webhookID int) ([]*models.WebhookDelivery, error)
+	GetDelivery(webhookID, deliveryID int) (*models.WebhookDelivery, error)
+	Redeliver(webhookID, deliveryID int) (*models.WebhookDelivery, error)
+	StartDispatcher(ctx context.Context)
+}
+
+type webhookService struct {
+	repo   repository.WebhookRepository
+	cfg    config.WebhooksConfig
+	client *http.Client
+}
+
+// NewWebhookService creates a new WebhookService
+func NewWebhookService(repo repository.WebhookRepository, cfg config.WebhooksConfig) WebhookService {
+	return &webhookService{
+		repo:   repo,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (s *webhookService) CreateWebhook(webhook *models.Webhook, createdBy int) error {
+	if err := validateEventTypes(webhook.EventTypes); err != nil {
+		return err
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+	webhook.IsActive = true
+	webhook.CreatedBy = &createdBy
+
+	return s.repo.CreateWebhook(webhook)
+}
+
+func (s *webhookService) GetWebhook(id int) (*models.Webhook, error) {
+	webhook, err := s.repo.GetWebhookByID(id)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) ListWebhooks() ([]*models.Webhook, error) {
+	return s.repo.ListWebhooks()
+}
+
+func (s *webhookService) UpdateWebhook(id int, updates *models.UpdateWebhook) (*models.Webhook, error) {
+	webhook, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if updates.URL != nil {
+		webhook.URL = *updates.URL
+	}
+	if updates.Secret != nil {
+		webhook.Secret = *updates.Secret
+	}
+	if updates.EventTypes != nil {
+		if err := validateEventTypes(updates.EventTypes); err != nil {
+			return nil, err
+		}
+		webhook.EventTypes = updates.EventTypes
+	}
+	if updates.IsActive != nil {
+		webhook.IsActive = *updates.IsActive
+	}
+
+	if err := s.repo.UpdateWebhook(webhook); err != nil {
+		return nil, err
+	}
+
+	return s.GetWebhook(id)
+}
+
+func (s *webhookService) DeleteWebhook(id int) error {
+	err := s.repo.DeleteWebhook(id)
+	if err != nil && err.Error() == "webhook not found" {
+		return apierrors.NewNotFoundError(err.Error())
+	}
+	return err
+}
+
+func (s *webhookService) ListDeliveries(webhookID int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(webhookID, deliveryListLimit)
+}
+
+func (s *webhookService) GetDelivery(webhookID, deliveryID int) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.GetDelivery(deliveryID)
+	if err != nil {
+		if err.Error() == "delivery not found" {
+			return nil, apierrors.NewNotFoundError(err.Error())
+		}
+		return nil, err
+	}
+	if delivery.WebhookID != webhookID {
+		return nil, apierrors.NewNotFoundError("delivery not found")
+	}
+	return delivery, nil
+}
+
+// Redeliver queues a delivery to be sent again straight away, whatever its
+// current status. Its attempt count and log are kept.
+func (s *webhookService) Redeliver(webhookID, deliveryID int) (*models.WebhookDelivery, error) {
+	if _, err := s.GetDelivery(webhookID, deliveryID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ResetDelivery(deliveryID, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.GetDelivery(webhookID, deliveryID)
+}
+
+// Publish queues a delivery of the event for every active webhook subscribed
+// to its type. Queueing the same event twice for a webhook is a no-op, so a
+// retried publish does not send duplicates.
+func (s *webhookService) Publish(event events.Event) error {
+	webhooks, err := s.repo.ListActiveWebhooks()
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if !subscribesTo(webhook, event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("error encoding %s event: %v", event.Type, err)
+			}
+		}
+
+		delivery := &models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := s.repo.CreateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartDispatcher sends due deliveries every poll interval until ctx is done
+func (s *webhookService) StartDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.dispatchDue()
+			}
+		}
+	}()
+}
+
+func (s *webhookService) dispatchDue() {
+	deliveries, err := s.repo.GetDueDeliveries(time.Now(), deliveryBatchSize)
+	if err != nil {
+		log.Printf("webhooks: failed to load due deliveries: %v", err)
+		return
+	}
+
+	webhooks := make(map[int]*models.Webhook)
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			if webhook, err = s.repo.GetWebhookByID(delivery.WebhookID); err != nil {
+				log.Printf("webhooks: failed to load webhook %d: %v", delivery.WebhookID, err)
+				continue
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+		s.deliver(webhook, delivery)
+	}
+}
+
+// deliver makes one attempt at sending a delivery and records the outcome.
+// A 2xx response marks it delivered; anything else schedules a retry with
+// exponential backoff until MaxAttempts is reached and it is marked dead.
+func (s *webhookService) deliver(webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	attempt := &models.WebhookAttempt{DeliveryID: delivery.ID}
+	start := time.Now()
+	statusCode, body, err := s.send(webhook, delivery)
+	attempt.DurationMs = int(time.Since(start) / time.Millisecond)
+
+	delivery.Attempts++
+	delivery.LastError = ""
+	delivery.LastStatusCode = nil
+	if err != nil {
+		attempt.Error = err.Error()
+		delivery.LastError = attempt.Error
+	} else {
+		attempt.StatusCode = &statusCode
+		attempt.ResponseBody = body
+		delivery.LastStatusCode = &statusCode
+	}
+
+	now := time.Now()
+	switch {
+	case err == nil && statusCode >= 200 && statusCode < 300:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= s.cfg.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.NextAttemptAt = nil
+	default:
+		if err == nil {
+			delivery.LastError = fmt.Sprintf("unexpected status %d", statusCode)
+		}
+		next := now.Add(s.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := s.repo.RecordAttempt(delivery, attempt); err != nil {
+		log.Printf("webhooks: failed to record attempt for delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send POSTs the payload signed with the webhook secret and returns the
+// response status and the start of its body
+func (s *webhookService) send(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "task-management-api-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", delivery.EventID)
+	timestamp := time.Now().Unix()
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(webhook.Secret, SignedContent(timestamp, delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyLog))
+	return resp.StatusCode, string(body), nil
+}
+
+// backoff returns the wait before the next attempt after the given number of
+// failed attempts: InitialBackoff, doubling each time
+func (s *webhookService) backoff(attempts int) time.Duration {
+	wait := s.cfg.InitialBackoff
+	for i := 1; i < attempts && wait < 24*time.Hour; i++ {
+		wait *= 2
+	}
+	return wait
+}
+
+// Sign returns the hex HMAC-SHA256 of the content keyed with the webhook
+// secret. The X-Webhook-Signature header signs SignedContent.
+func Sign(secret string, content []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedContent is what a delivery's signature covers: the Unix time of the
+// X-Webhook-Timestamp header, a dot and the body. Receivers should refuse
+// old timestamps, so that a captured delivery cannot be replayed later.
+func SignedContent(timestamp int64, body []byte) []byte {
+	return append([]byte(strconv.FormatInt(timestamp, 10)+"."), body...)
+}
+
+func subscribesTo(webhook *models.Webhook, eventType string) bool {
+	for _, t := range webhook.EventTypes {
+		if t == "*" || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEventTypes(eventTypes []string) error {
+	for _, t := range eventTypes {
+		if t == "*" {
+			continue
+		}
+		if !isEventType(t) {
+			return apierrors.NewBadRequestError(fmt.Sprintf("unknown event type %q, expected one of %s or *",
+				t, strings.Join(events.Types, ", ")))
+		}
+	}
+	return nil
+}
+
+func isEventType(eventType string) bool {
+	for _, t := range events.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %v", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"task-management-api/config"
+	"task-management-api/internal/events"
+	"task-management-api/internal/models"
+	"task-management-api/internal/repository"
+	"testing"
+	"time"
+)
+
+type fakeWebhookRepo struct {
+	repository.WebhookRepository
+	webhooks   []*models.Webhook
+	deliveries []*models.WebhookDelivery
+	attempts   []*models.WebhookAttempt
+}
+
+func (r *fakeWebhookRepo) ListActiveWebhooks() ([]*models.Webhook, error) {
+	var active []*models.Webhook
+	for _, webhook := range r.webhooks {
+		if webhook.IsActive {
+			active = append(active, webhook)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakeWebhookRepo) GetWebhookByID(id int) (*models.Webhook, error) {
+	for _, webhook := range r.webhooks {
+		if webhook.ID == id {
+			return webhook, nil
+		}
+	}
+	return nil, fmt.Errorf("webhook not found")
+}
+
+// CreateDelivery ignores an event queued twice for a webhook, like the
+// unique key of the table
+func (r *fakeWebhookRepo) CreateDelivery(delivery *models.WebhookDelivery) error {
+	for _, queued := range r.deliveries {
+		if queued.WebhookID == delivery.WebhookID && queued.EventID == delivery.EventID {
+			return nil
+		}
+	}
+	delivery.ID = len(r.deliveries) + 1
+	r.deliveries = append(r.deliveries, delivery)
+	return nil
+}
+
+func (r *fakeWebhookRepo) GetDueDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	var due []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error {
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+func newTestWebhookService(url string, eventTypes ...string) (*webhookService, *fakeWebhookRepo) {
+	repo := &fakeWebhookRepo{webhooks: []*models.Webhook{
+		{ID: 1, URL: url, Secret: "0123456789abcdef", EventTypes: eventTypes, IsActive: true},
+	}}
+	cfg := config.WebhooksConfig{MaxAttempts: 3, InitialBackoff: time.Minute, Timeout: time.Second}
+	return NewWebhookService(repo, cfg).(*webhookService), repo
+}
+
+func TestSignMatchesHMACSHA256(t *testing.T) {
+	// RFC 4231, test case 2
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	if want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"; got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSecretIsShownOnlyOnCreation(t *testing.T) {
+	webhook := &models.Webhook{ID: 1, URL: "https://example.com", Secret: "0123456789abcdef"}
+	shown, _ := json.Marshal(webhook)
+	if strings.Contains(string(shown), webhook.Secret) {
+		t.Errorf("webhook = %s, want no secret", shown)
+	}
+	created, _ := json.Marshal(models.CreatedWebhook{Webhook: webhook, Secret: webhook.Secret})
+	if !strings.Contains(string(created), `"secret":"0123456789abcdef"`) || !strings.Contains(string(created), `"url"`) {
+		t.Errorf("created webhook = %s, want the webhook and its secret", created)
+	}
+}
+
+func TestPublishQueuesSubscribedWebhooksOnce(t *testing.T) {
+	s, repo := newTestWebhookService("http://example.invalid", events.TaskStatusChanged)
+	repo.webhooks = append(repo.webhooks,
+		&models.Webhook{ID: 2, EventTypes: []string{"*"}, IsActive: true},
+		&models.Webhook{ID: 3, EventTypes: []string{"*"}},
+	)
+
+	event := events.New(events.TaskStatusChanged, nil)
+	for i := 0; i < 2; i++ {
+		if err := s.Publish(event); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if err := s.Publish(events.New(events.TaskCreated, nil)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	var queued []string
+	for _, delivery := range repo.deliveries {
+		queued = append(queued, fmt.Sprintf("%d:%s", delivery.WebhookID, delivery.EventType))
+	}
+	want := []string{"1:task.status_changed", "2:task.status_changed", "2:task.created"}
+	if fmt.Sprint(queued) != fmt.Sprint(want) {
+		t.Errorf("queued %v, want %v", queued, want)
+	}
+}
+
+func TestDispatchSendsSignedDeliveries(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte("thanks"))
+	}))
+	defer server.Close()
+
+	s, repo := newTestWebhookService(server.URL, "*")
+	event := events.New(events.TaskCreated, map[string]int{"id": 5})
+	s.Publish(event)
+	s.dispatchDue()
+
+	if got == nil {
+		t.Fatal("nothing was delivered")
+	}
+	// The signature covers the time of sending, so that it cannot be replayed
+	// later
+	timestamp, err := strconv.ParseInt(got.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Errorf("timestamp = %q, want the time of sending", got.Header.Get("X-Webhook-Timestamp"))
+	}
+	want := "sha256=" + Sign("0123456789abcdef", SignedContent(timestamp, body))
+	if got.Header.Get("X-Webhook-Signature") != want {
+		t.Errorf("signature = %s, want %s", got.Header.Get("X-Webhook-Signature"), want)
+	}
+	if got.Header.Get("X-Webhook-Event") != events.TaskCreated || got.Header.Get("X-Webhook-Delivery") != event.ID {
+		t.Errorf("event headers = %v", got.Header)
+	}
+	var sent events.Event
+	if err := json.Unmarshal(body, &sent); err != nil || sent.ID != event.ID {
+		t.Errorf("body = %s, want the event", body)
+	}
+
+	delivery := repo.deliveries[0]
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.DeliveredAt == nil {
+		t.Errorf("status = %s, want delivered", delivery.Status)
+	}
+	if len(repo.attempts) != 1 || repo.attempts[0].ResponseBody != "thanks" {
+		t.Errorf("attempts = %v, want one with the response logged", repo.attempts)
+	}
+}
+
+func TestDispatchRetriesWithBackoffUntilDead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s, repo := newTestWebhookService(server.URL, "*")
+	s.Publish(events.New(events.TaskCreated, nil))
+	delivery := repo.deliveries[0]
+
+	for attempt, wantWait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		s.deliver(repo.webhooks[0], delivery)
+		if delivery.Status != models.WebhookDeliveryPending {
+			t.Fatalf("attempt %d: status = %s, want pending", attempt+1, delivery.Status)
+		}
+		if wait := delivery.NextAttemptAt.Sub(before); wait < wantWait || wait > wantWait+time.Second {
+			t.Errorf("attempt %d: next attempt in %v, want %v", attempt+1, wait, wantWait)
+		}
+		if delivery.LastError != "unexpected status 503" {
+			t.Errorf("attempt %d: last error = %q", attempt+1, delivery.LastError)
+		}
+	}
+
+	s.deliver(repo.webhooks[0], delivery)
+	if delivery.Status != models.WebhookDeliveryDead || delivery.NextAttemptAt != nil {
+		t.Errorf("status = %s after %d attempts, want dead", delivery.Status, delivery.Attempts)
+	}
+	if len(repo.attempts) != 3 {
+		t.Errorf("logged %d attempts, want 3", len(repo.attempts))
+	}
+}
+
+func TestUpdateTaskEmitsStatusChanged(t *testing.T) {
+	s := newTestTaskService(t, todo(1, nil))
+
+	update := todo(1, nil)
+	update.Status = models.TaskStatusInProgress
+	if err := s.UpdateTask(update, models.Actor{UserID: 7}); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	var types []string
+	for _, event := range s.outbox.events {
+		types = append(types, event.Type)
+	}
+	if want := []string{events.TaskUpdated, events.TaskStatusChanged}; fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,302 @@
+// Package taskfile reads and writes tasks as CSV, NDJSON and XLSX files, for
+// exporting them to spreadsheets and importing them back.
+package taskfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"task-management-api/internal/models"
+	"task-management-api/pkg/xlsx"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+// Columns are the columns of exported CSV and XLSX files, in order
+var Columns = []string{"id", "parent_id", "title", "description", "status", "priority", "due_date", "user_id",
+	"created_at", "updated_at", "archived_at"}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// FormatOf guesses the format of a file from its name, returning "" when
+// the extension is not known
+func FormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return ""
+}
+
+// Writer writes tasks to a file one at a time
+type Writer interface {
+	Write(task *models.Task) error
+	// Close finishes the file without closing the underlying writer
+	Close() error
+}
+
+// NewWriter returns a Writer for the format. CSV and XLSX files start with
+// a header row of Columns; NDJSON files have one task object per line.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{csv: cw}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		xw, err := xlsx.NewWriter(w, "Tasks")
+		if err != nil {
+			return nil, err
+		}
+		if err := xw.WriteRow(Columns); err != nil {
+			return nil, err
+		}
+		return &xlsxWriter{xlsx: xw}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvWriter struct {
+	csv *csv.Writer
+}
+
+func (w *csvWriter) Write(task *models.Task) error {
+	values := row(task)
+	for i, value := range values {
+		values[i] = escapeFormula(value)
+	}
+	return w.csv.Write(values)
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(task *models.Task) error {
+	return w.enc.Encode(task)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+type xlsxWriter struct {
+	xlsx *xlsx.Writer
+}
+
+func (w *xlsxWriter) Write(task *models.Task) error {
+	return w.xlsx.WriteRow(row(task))
+}
+
+func (w *xlsxWriter) Close() error {
+	return w.xlsx.Close()
+}
+
+// formulaPrefixes are the characters that make a spreadsheet program read a
+// CSV cell as a formula
+const formulaPrefixes = "=+-@"
+
+// escapeFormula quotes a cell that would be read as a formula with a leading
+// "'", which spreadsheet programs show as text and do not evaluate
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula undoes escapeFormula, so that exported files import back
+// unchanged
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// row renders a task as the values of Columns
+func row(task *models.Task) []string {
+	optionalInt := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(task.ID), optionalInt(task.ParentID), task.Title, task.Description, string(task.Status),
+		string(task.Priority), optionalTime(task.DueDate), optionalInt(task.UserID),
+		task.CreatedAt.Format(time.RFC3339), task.UpdatedAt.Format(time.RFC3339), optionalTime(task.ArchivedAt),
+	}
+}
+
+// Record is a row of an imported file, with its values by column name
+type Record struct {
+	// Row is the line of the file, or the row of the spreadsheet, the
+	// record was read from
+	Row    int
+	Values map[string]string
+}
+
+// Read returns the records of a file. CSV and XLSX files need a header row
+// naming the columns; NDJSON objects use their keys. Empty rows are skipped.
+func Read(format string, data []byte) ([]*Record, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(data)
+	case FormatNDJSON:
+		return readNDJSON(data)
+	case FormatXLSX:
+		rows, err := xlsx.ReadRows(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		return tableRecords(rows, func(i int) int { return i + 1 })
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func readCSV(data []byte) ([]*Record, error) {
+	// Spreadsheet programs often start CSV files with a byte order mark
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	var rows [][]string
+	var lines []int
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i, field := range fields {
+			fields[i] = unescapeFormula(field)
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, fields)
+		lines = append(lines, line)
+	}
+	return tableRecords(rows, func(i int) int { return lines[i] })
+}
+
+// tableRecords turns rows under a header row into records, numbering them
+// with rowNumber
+func tableRecords(rows [][]string, rowNumber func(i int) int) ([]*Record, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	header := rows[0]
+	var records []*Record
+	for i := 1; i < len(rows); i++ {
+		values := make(map[string]string, len(header))
+		empty := true
+		for j, value := range rows[i] {
+			if j >= len(header) || header[j] == "" {
+				continue
+			}
+			values[header[j]] = value
+			if strings.TrimSpace(value) != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			records = append(records, &Record{Row: rowNumber(i), Values: values})
+		}
+	}
+	return records, nil
+}
+
+func readNDJSON(data []byte) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, fmt.Errorf("line %d is not a JSON object: %v", line, err)
+		}
+
+		values := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				values[key] = ""
+			case string:
+				values[key] = v
+			case float64:
+				values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				values[key] = strconv.FormatBool(v)
+			default:
+				encoded, _ := json.Marshal(v)
+				values[key] = string(encoded)
+			}
+		}
+		records = append(records, &Record{Row: line, Values: values})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ParseTime reads a date or timestamp in RFC 3339, "2006-01-02 15:04:05" or
+// "2006-01-02" form, or a spreadsheet date serial number
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	if days, err := strconv.ParseFloat(value, 64); err == nil && days > 0 {
+		// Spreadsheets count days from 1899-12-30
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return epoch.Add(time.Duration(days * float64(24*time.Hour))).Round(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,162 @@
+package taskfile
+
+import (
+	"bytes"
+	"strings"
+	"task-management-api/internal/models"
+	"testing"
+	"time"
+)
+
+func exampleTask() *models.Task {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	parentID, userID := 1, 7
+	return &models.Task{
+		ID: 2, ParentID: &parentID, Title: "=HYPERLINK(\"http://evil\")", Description: "-1 day, @home",
+		Status: models.TaskStatusTodo, Priority: models.TaskPriorityHigh, UserID: &userID,
+		CreatedAt: created, UpdatedAt: created,
+	}
+}
+
+// export writes the tasks in a format
+func export(t *testing.T, format string, tasks ...*models.Task) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, task := range tasks {
+		if err := w.Write(task); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	data := string(export(t, FormatCSV, exampleTask()))
+	if !strings.Contains(data, `"'=HYPERLINK(""http://evil"")"`) || !strings.Contains(data, `"'-1 day, @home"`) {
+		t.Errorf("formulas were not escaped:\n%s", data)
+	}
+
+	tests := map[string]string{
+		"=1+1": "'=1+1", "+1": "'+1", "-1": "'-1", "@SUM(A1)": "'@SUM(A1)", "a=b": "a=b", "": "", "'=1": "'=1",
+	}
+	for value, want := range tests {
+		if got := escapeFormula(value); got != want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	task := exampleTask()
+	for _, format := range []string{FormatCSV, FormatNDJSON, FormatXLSX} {
+		t.Run(format, func(t *testing.T) {
+			records, err := Read(format, export(t, format, task))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("%d records, want 1", len(records))
+			}
+			values := records[0].Values
+			want := map[string]string{
+				"id": "2", "parent_id": "1", "title": task.Title, "description": task.Description,
+				"status": "TODO", "priority": "HIGH", "user_id": "7",
+			}
+			for column, value := range want {
+				if values[column] != value {
+					t.Errorf("%s = %q, want %q", column, values[column], value)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	data := "\xef\xbb\xbftitle,status,\n" +
+		"first,TODO,ignored\n" +
+		",,\n" +
+		"\"multi\nline\",DONE\n" +
+		"'-x,'y\n"
+	records, err := Read(FormatCSV, []byte(data))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("%d records, want the empty row skipped", len(records))
+	}
+	if records[0].Row != 2 || records[0].Values["title"] != "first" || len(records[0].Values) != 2 {
+		t.Errorf("first record = %+v", records[0])
+	}
+	// Rows are numbered by the line they start on
+	if records[1].Row != 4 || records[1].Values["title"] != "multi\nline" {
+		t.Errorf("second record = %+v", records[1])
+	}
+	// Only the quote escaping a formula is removed
+	if records[2].Values["title"] != "-x" || records[2].Values["status"] != "'y" {
+		t.Errorf("third record = %+v", records[2])
+	}
+
+	if _, err := Read(FormatCSV, nil); err == nil {
+		t.Error("an empty file was read")
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	data := `{"title": "first", "priority": null, "user_id": 7, "done": true}
+
+{"title": "second", "tags": ["a"]}
+`
+	records, err := Read(FormatNDJSON, []byte(data))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(records) != 2 || records[1].Row != 3 {
+		t.Fatalf("records = %+v, want lines 1 and 3", records)
+	}
+	want := map[string]string{"title": "first", "priority": "", "user_id": "7", "done": "true"}
+	for key, value := range want {
+		if got := records[0].Values[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if got := records[1].Values["tags"]; got != `["a"]` {
+		t.Errorf("tags = %q", got)
+	}
+
+	if _, err := Read(FormatNDJSON, []byte("{\"title\": \"a\"}\n[1]\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Read of a line that is not an object = %v, want an error for line 2", err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, value := range []string{"2024-03-01T00:00:00Z", "2024-03-01 00:00:00", "2024-03-01", "45352"} {
+		if got, err := ParseTime(value); err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "tomorrow", "-3", "01/03/2024"} {
+		if _, err := ParseTime(value); err == nil {
+			t.Errorf("ParseTime(%q) succeeded", value)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"tasks.csv": FormatCSV, "TASKS.CSV": FormatCSV, "tasks.ndjson": FormatNDJSON, "tasks.jsonl": FormatNDJSON,
+		"tasks.xlsx": FormatXLSX, "tasks.json": "", "tasks": "",
+	}
+	for name, want := range tests {
+		if got := FormatOf(name); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
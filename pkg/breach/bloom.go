@@ -0,0 +1,136 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// bloomMagic starts every Bloom filter file. It is followed by the number
+// of bits m (uint64), the number of hash functions k (uint32) and the number
+// of entries n (uint64), all big-endian, and then the m bits.
+const bloomMagic = "PWBLOOM1"
+
+const bloomHeaderSize = len(bloomMagic) + 8 + 4 + 8
+
+// BloomChecker looks passwords up in a Bloom filter of breached password
+// hashes. It is far smaller than the full dataset, at the cost of a small,
+// configurable rate of false positives. Bits are read from the file as
+// needed rather than loaded into memory.
+type BloomChecker struct {
+	f *os.File
+	m uint64
+	k uint32
+}
+
+func OpenBloomChecker(path string) (*BloomChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening breached password filter: %v", err)
+	}
+
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(bloomMagic)]) != bloomMagic {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a breached password filter", path)
+	}
+	c := &BloomChecker{
+		f: f,
+		m: binary.BigEndian.Uint64(header[len(bloomMagic):]),
+		k: binary.BigEndian.Uint32(header[len(bloomMagic)+8:]),
+	}
+	if c.m == 0 || c.k == 0 {
+		f.Close()
+		return nil, fmt.Errorf("breached password filter %s is empty", path)
+	}
+	return c, nil
+}
+
+func (c *BloomChecker) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	var b [1]byte
+	for _, bit := range bloomBits(sum[:], c.m, c.k) {
+		if _, err := c.f.ReadAt(b[:], int64(bloomHeaderSize)+int64(bit/8)); err != nil {
+			return false, fmt.Errorf("error reading breached password filter: %v", err)
+		}
+		if b[0]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *BloomChecker) Close() error {
+	return c.f.Close()
+}
+
+// bloomBits returns the k bit positions of a SHA-1 hash. The hash is already
+// uniformly distributed, so its first two words serve as the two hashes of
+// double hashing.
+func bloomBits(sum []byte, m uint64, k uint32) []uint64 {
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	bits := make([]uint64, k)
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % m
+	}
+	return bits
+}
+
+// BloomBuilder builds a Bloom filter file from breached password hashes
+type BloomBuilder struct {
+	bits []byte
+	m    uint64
+	k    uint32
+	n    uint64
+}
+
+// NewBloomBuilder sizes a filter for n entries with the given rate of false
+// positives, such as 0.001
+func NewBloomBuilder(n uint64, falsePositiveRate float64) (*BloomBuilder, error) {
+	if n == 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("need at least one entry and a false positive rate between 0 and 1")
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomBuilder{bits: make([]byte, (m+7)/8), m: m, k: k}, nil
+}
+
+// AddHash adds an entry given as a hex SHA-1 hash. Anything after the hash,
+// such as the ":COUNT" of dataset lines, is ignored.
+func (b *BloomBuilder) AddHash(hash string) error {
+	if len(hash) > 40 {
+		hash = hash[:40]
+	}
+	sum, err := hex.DecodeString(strings.TrimSpace(hash))
+	if err != nil || len(sum) != sha1.Size {
+		return fmt.Errorf("invalid SHA-1 hash %q", hash)
+	}
+	for _, bit := range bloomBits(sum, b.m, b.k) {
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+	b.n++
+	return nil
+}
+
+// WriteTo writes the filter in the format OpenBloomChecker reads
+func (b *BloomBuilder) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint64(header[len(bloomMagic):], b.m)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+8:], b.k)
+	binary.BigEndian.PutUint64(header[len(bloomMagic)+12:], b.n)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(b.bits)
+	return int64(n + m), err
+}
@@ -0,0 +1,38 @@
+// Package breach checks passwords against a local copy of a dataset of
+// breached passwords, identified by their SHA-1 hashes, without sending
+// anything over the network.
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"task-management-api/config"
+)
+
+// Checker reports whether a password appears in a breach dataset
+type Checker interface {
+	Breached(password string) (bool, error)
+}
+
+// New returns the checker selected by the configuration, or nil if the
+// check is disabled
+func New(cfg config.BreachedPasswordsConfig) (Checker, error) {
+	switch cfg.Driver {
+	case "", "none":
+		return nil, nil
+	case "range":
+		return NewRangeChecker(cfg.Path, cfg.MinCount)
+	case "bloom":
+		return OpenBloomChecker(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown breached password driver %q", cfg.Driver)
+	}
+}
+
+// Hash returns the upper-case hex SHA-1 hash datasets identify passwords by
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,123 @@
+package breach
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"task-management-api/config"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	if got := Hash("password"); got != "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8" {
+		t.Errorf("Hash = %s", got)
+	}
+}
+
+// writeRange writes a range dataset file for the prefix of each password
+func writeRange(t *testing.T, dir, name string, lines ...string) {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line + "\r\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRangeChecker(t *testing.T) {
+	dir := t.TempDir()
+	// "password" is 5BAA6 1E4C9..., "letmein" is B7A87 5FC1E...
+	writeRange(t, dir, "5BAA6.txt", "0018A45C4D1DEF81644B54AB7F969B88D65:1", "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493")
+	writeRange(t, dir, "b7a87", "5FC1EA228B9061041B7CEC4BD3C52AB3CE3:0")
+
+	checker, err := NewRangeChecker(dir, 1)
+	if err != nil {
+		t.Fatalf("NewRangeChecker: %v", err)
+	}
+	if breached, err := checker.Breached("password"); err != nil || !breached {
+		t.Errorf("Breached(password) = %v, %v; want true", breached, err)
+	}
+	// Padding entries have a count of 0
+	if breached, err := checker.Breached("letmein"); err != nil || breached {
+		t.Errorf("Breached(letmein) = %v, %v; want false", breached, err)
+	}
+
+	rare, _ := NewRangeChecker(dir, 5000000)
+	if breached, _ := rare.Breached("password"); breached {
+		t.Error("counted a password seen fewer than min_count times")
+	}
+
+	if _, err := checker.Breached("no file for this prefix"); err == nil {
+		t.Error("a missing prefix file must be an error")
+	}
+}
+
+func TestNewRangeCheckerNeedsADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0o644)
+	if _, err := NewRangeChecker(file, 1); err == nil {
+		t.Error("accepted a file")
+	}
+	if _, err := NewRangeChecker(filepath.Join(t.TempDir(), "missing"), 1); err == nil {
+		t.Error("accepted a missing directory")
+	}
+}
+
+func TestBloomChecker(t *testing.T) {
+	builder, err := NewBloomBuilder(100, 0.001)
+	if err != nil {
+		t.Fatalf("NewBloomBuilder: %v", err)
+	}
+	breachedPasswords := []string{"password", "123456", "qwerty", "letmein"}
+	for _, p := range breachedPasswords {
+		if err := builder.AddHash(Hash(p) + ":42"); err != nil {
+			t.Fatalf("AddHash: %v", err)
+		}
+	}
+	if err := builder.AddHash("not a hash"); err == nil {
+		t.Error("AddHash accepted an invalid hash")
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.WriteTo(f); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	f.Close()
+
+	checker, err := OpenBloomChecker(path)
+	if err != nil {
+		t.Fatalf("OpenBloomChecker: %v", err)
+	}
+	defer checker.Close()
+	for _, p := range breachedPasswords {
+		if breached, err := checker.Breached(p); err != nil || !breached {
+			t.Errorf("Breached(%q) = %v, %v; want true", p, breached, err)
+		}
+	}
+	if breached, _ := checker.Breached("a long and unusual passphrase"); breached {
+		t.Error("reported an unknown password as breached")
+	}
+}
+
+func TestOpenBloomCheckerRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other")
+	os.WriteFile(path, []byte("definitely not a bloom filter file"), 0o644)
+	if _, err := OpenBloomChecker(path); err == nil {
+		t.Error("opened a file without the magic")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if checker, err := New(config.BreachedPasswordsConfig{}); checker != nil || err != nil {
+		t.Errorf("New with no driver = %v, %v; want nil, nil", checker, err)
+	}
+	if _, err := New(config.BreachedPasswordsConfig{Driver: "cloud"}); err == nil {
+		t.Error("New accepted an unknown driver")
+	}
+}
@@ -0,0 +1,78 @@
+package breach
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLength is the length of the hash prefix the range dataset is split by
+const prefixLength = 5
+
+// RangeChecker looks passwords up in a k-anonymity range dataset: one file
+// per 5-digit hash prefix, as served by the Have I Been Pwned range API and
+// produced by its downloader. Each line of a file is "SUFFIX:COUNT", where
+// SUFFIX is the rest of the hash. Only the one file for the password's
+// prefix is read.
+type RangeChecker struct {
+	dir      string
+	minCount int
+}
+
+func NewRangeChecker(dir string, minCount int) (*RangeChecker, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening breached password dataset: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password dataset %s is not a directory", dir)
+	}
+	return &RangeChecker{dir: dir, minCount: minCount}, nil
+}
+
+func (c *RangeChecker) Breached(password string) (bool, error) {
+	hash := Hash(password)
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	f, err := c.open(prefix)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, countStr, _ := strings.Cut(line, ":")
+		if !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+		// Padding entries added by the range API have a count of 0
+		count, err := strconv.Atoi(countStr)
+		if err != nil {
+			count = 1
+		}
+		return count >= c.minCount && count > 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("error reading breached password dataset: %v", err)
+	}
+	return false, nil
+}
+
+// open finds the file for a prefix, with or without a .txt extension
+func (c *RangeChecker) open(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		f, err := os.Open(filepath.Join(c.dir, name))
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading breached password dataset: %v", err)
+		}
+	}
+	return nil, fmt.Errorf("breached password dataset has no file for prefix %s", prefix)
+}
@@ -14,15 +14,87 @@ var (
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Role   string `json:"role"`
+	// OrgID is the user's organisation, which every request is confined to,
+	// and OrgRole their role in it
+	OrgID   int    `json:"org_id"`
+	OrgRole string `json:"org_role,omitempty"`
+	// Purpose is set on challenge tokens, which only prove part of a login
+	// and are not accepted in place of an access token
+	Purpose string `json:"purpose,omitempty"`
+	// ClientID and Scope are set on tokens issued to OAuth clients
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, role string) (string, error) {
+// TokenLifetime is how long a token stays valid after it is issued
+const TokenLifetime = 24 * time.Hour
+
+// GenerateToken issues a token for the user's login session; the session ID
+// is carried in the jti claim
+func GenerateToken(userID int, role string, orgID int, orgRole string, sessionID string) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Role:    role,
+		OrgID:   orgID,
+		OrgRole: orgRole,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secretKey)
+}
+
+// GenerateChallengeToken issues a short-lived token for a login that is
+// waiting on a further step, such as a second factor
+func GenerateChallengeToken(userID int, purpose string, lifetime time.Duration) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(lifetime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secretKey)
+}
+
+// ValidateChallengeToken checks a challenge token issued for purpose
+func ValidateChallengeToken(tokenString string, purpose string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// PurposeOAuth marks access tokens issued to OAuth clients, which act for
+// a user within the granted scopes
+const PurposeOAuth = "oauth"
+
+// GenerateOAuthToken issues an access token to an OAuth client; the ID of
+// the token's record is carried in the jti claim so that it can be revoked
+func GenerateOAuthToken(userID int, role string, orgID int, orgRole, clientID, scope, tokenID string, lifetime time.Duration) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:   userID,
+		Role:     role,
+		OrgID:    orgID,
+		OrgRole:  orgRole,
+		Purpose:  PurposeOAuth,
+		ClientID: clientID,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(lifetime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -31,7 +103,23 @@ func GenerateToken(userID int, role string) (string, error) {
 	return token.SignedString(secretKey)
 }
 
+// ValidateOAuthToken checks an access token issued to an OAuth client
+func ValidateOAuthToken(tokenString string) (*Claims, error) {
+	return ValidateChallengeToken(tokenString, PurposeOAuth)
+}
+
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func parseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return secretKey, nil
 	})
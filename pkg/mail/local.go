@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender writes each email to a .eml file in a directory instead of
+// sending it, for development and testing
+type FileSender struct {
+	dir  string
+	from string
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating mail directory: %v", err)
+	}
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+func (s *FileSender) Send(msg *Message) error {
+	body, err := msg.Bytes(s.from)
+	if err != nil {
+		return fmt.Errorf("error building email: %v", err)
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	if err := os.WriteFile(filepath.Join(s.dir, name), body, 0o644); err != nil {
+		return fmt.Errorf("error writing email: %v", err)
+	}
+	return nil
+}
+
+// LogSender writes the plain text of each email to the log instead of sending it
+type LogSender struct {
+	from string
+}
+
+func NewLogSender(from string) *LogSender {
+	return &LogSender{from: from}
+}
+
+func (s *LogSender) Send(msg *Message) error {
+	log.Printf("mail: from %s to %s: %s\n%s", s.from, msg.To, msg.Subject, msg.Text)
+	return nil
+}
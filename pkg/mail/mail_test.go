@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSenderWritesParseableMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender, err := NewFileSender(dir, "Tasks <tasks@example.com>")
+	if err != nil {
+		t.Fatalf("NewFileSender: %v", err)
+	}
+	msg := &Message{
+		To:      "ann@example.com",
+		Subject: "Réinitialiser le mot de passe",
+		Text:    "Open https://app.example.com/reset?token=abc to continue",
+		HTML:    `<a href="https://app.example.com/reset?token=abc">Reset</a>`,
+	}
+	if err := sender.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("wrote %d files, want 1", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject || parsed.Header.Get("To") != msg.To {
+		t.Errorf("headers = %v", parsed.Header)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %s, want one in the sender's domain", id)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %s, want multipart/alternative", mediaType)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []string{msg.Text, msg.HTML} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		// NextPart undoes the quoted-printable encoding
+		body, _ := io.ReadAll(part)
+		if string(body) != want {
+			t.Errorf("part = %q, want %q", body, want)
+		}
+	}
+}
+
+func TestPlainTextMessage(t *testing.T) {
+	raw, err := (&Message{To: "ann@example.com", Subject: "Hi", Text: "Hello"}).Bytes("tasks@example.com")
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if ct := parsed.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type = %s, want text/plain", ct)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if string(body) != "Hello" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"task-management-api/config"
+)
+
+// SMTPSender delivers email through an SMTP server. It upgrades the
+// connection with STARTTLS when the server offers it, or connects over TLS
+// from the start when ImplicitTLS is set (usually port 465).
+type SMTPSender struct {
+	cfg  config.SMTPConfig
+	from string
+}
+
+func NewSMTPSender(cfg config.SMTPConfig, from string) *SMTPSender {
+	return &SMTPSender{cfg: cfg, from: from}
+}
+
+func (s *SMTPSender) Send(msg *Message) error {
+	body, err := msg.Bytes(s.from)
+	if err != nil {
+		return fmt.Errorf("error building email: %v", err)
+	}
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %v", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %v", err)
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	if !s.cfg.ImplicitTLS {
+		if err := smtp.SendMail(addr, auth, from.Address, []string{to.Address}, body); err != nil {
+			return fmt.Errorf("error sending email: %v", err)
+		}
+		return nil
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: s.cfg.Host})
+	if err != nil {
+		return fmt.Errorf("error connecting to SMTP server: %v", err)
+	}
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error connecting to SMTP server: %v", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating with SMTP server: %v", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,152 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"task-management-api/pkg/oidc/oidctest"
+	"testing"
+	"time"
+)
+
+func TestChallenge(t *testing.T) {
+	// S256 is the unpadded base64url SHA-256 of the verifier
+	if got := Challenge("dBjftJeZ4CVP-mJ92zHZ2RqKr6ZUFAjF4M5j8fYG0q0"); got != "xM_F_M9ZPWi4foo09K8cYwW7wJBLob1pIY0QrtfFK-0" {
+		t.Errorf("Challenge = %s", got)
+	}
+}
+
+func newTestProvider(t *testing.T, secret string) (*Provider, *oidctest.Server) {
+	t.Helper()
+	idp := oidctest.NewServer("task-api", secret)
+	t.Cleanup(idp.Close)
+	p := NewProvider(Config{
+		Issuer:       idp.URL + "/",
+		ClientID:     "task-api",
+		ClientSecret: secret,
+		RedirectURL:  "https://tasks.example.com/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	return p, idp
+}
+
+// login runs a login through the provider, with the ID token carrying the
+// given claims, and returns what Exchange makes of it
+func login(t *testing.T, p *Provider, idp *oidctest.Server, claims map[string]interface{}) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+	verifier, _ := RandomString(32)
+	authURL, err := p.AuthCodeURL(ctx, "the-state", "the-nonce", Challenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := idp.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "the-state" {
+		t.Fatalf("state = %q", state)
+	}
+	return p.Exchange(ctx, code, verifier, "the-nonce")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, idp := newTestProvider(t, "")
+	authURL, err := p.AuthCodeURL(context.Background(), "s", "n", "c")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if got := u.Scheme + "://" + u.Host + u.Path; got != idp.URL+"/authorize" {
+		t.Errorf("endpoint = %s", got)
+	}
+	want := url.Values{
+		"response_type": {"code"}, "client_id": {"task-api"}, "redirect_uri": {"https://tasks.example.com/callback"},
+		"scope": {"openid email"}, "state": {"s"}, "nonce": {"n"}, "code_challenge": {"c"}, "code_challenge_method": {"S256"},
+	}
+	if got := u.Query(); got.Encode() != want.Encode() {
+		t.Errorf("query = %v, want %v", got, want)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	for name, secret := range map[string]string{"confidential client": "s3cret&=", "public client": ""} {
+		t.Run(name, func(t *testing.T) {
+			p, idp := newTestProvider(t, secret)
+			claims, err := login(t, p, idp, map[string]interface{}{
+				"sub":                "alice-123",
+				"email":              "alice@example.com",
+				"email_verified":     "true",
+				"name":               "Alice",
+				"preferred_username": "alice",
+				"groups":             []string{"staff", "admins"},
+			})
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if claims.Subject != "alice-123" || claims.Email != "alice@example.com" || !claims.EmailVerified ||
+				claims.Name != "Alice" || claims.PreferredUsername != "alice" {
+				t.Errorf("claims = %+v", claims)
+			}
+			if groups := claims.Strings("groups"); strings.Join(groups, ",") != "staff,admins" {
+				t.Errorf("groups = %v", groups)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsBadTokens(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"wrong nonce":     {"sub": "a", "nonce": "another"},
+		"wrong audience":  {"sub": "a", "aud": "another-client"},
+		"wrong issuer":    {"sub": "a", "iss": "https://evil.example.com"},
+		"expired":         {"sub": "a", "exp": time.Now().Add(-2 * clockSkew).Unix()},
+		"from the future": {"sub": "a", "iat": time.Now().Add(2 * clockSkew).Unix()},
+		"other azp":       {"sub": "a", "aud": []string{"task-api", "other"}, "azp": "other"},
+		"without subject": {},
+	}
+	for name, claims := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, idp := newTestProvider(t, "")
+			if _, err := login(t, p, idp, claims); err == nil {
+				t.Error("Exchange accepted the token")
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	p, idp := newTestProvider(t, "")
+	ctx := context.Background()
+	authURL, _ := p.AuthCodeURL(ctx, "s", "n", Challenge("the verifier"))
+	code, _, _ := idp.Authorize(authURL, map[string]interface{}{"sub": "a"})
+	if _, err := p.Exchange(ctx, code, "another verifier", "n"); err == nil {
+		t.Error("Exchange succeeded with the wrong PKCE verifier")
+	}
+}
+
+func TestExchangeFollowsKeyRotation(t *testing.T) {
+	p, idp := newTestProvider(t, "")
+	if _, err := login(t, p, idp, map[string]interface{}{"sub": "a"}); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	idp.RotateKey()
+	// Unknown keys are not fetched again straight away
+	if _, err := login(t, p, idp, map[string]interface{}{"sub": "a"}); err == nil {
+		t.Error("accepted a token signed with a key not fetched yet")
+	}
+	p.keys.fetchedAt = time.Now().Add(-keyRefreshInterval)
+	if _, err := login(t, p, idp, map[string]interface{}{"sub": "a"}); err != nil {
+		t.Errorf("Exchange after rotation: %v", err)
+	}
+}
+
+func TestDiscoveryChecksIssuer(t *testing.T) {
+	idp := oidctest.NewServer("task-api", "")
+	defer idp.Close()
+	p := NewProvider(Config{Issuer: idp.URL + "/realms/other", ClientID: "task-api"})
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil {
+		t.Error("accepted a discovery document for another issuer")
+	}
+}
@@ -0,0 +1,95 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// clockSkew is how far the provider's clock may be off from ours
+const clockSkew = time.Minute
+
+// Claims are the verified claims of an ID token
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	raw               jwt.MapClaims
+}
+
+// Strings returns a claim holding a list of strings, such as groups; a
+// single string counts as a list of one
+func (c *Claims) Strings(name string) []string {
+	switch v := c.raw[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// verify checks an ID token's signature with the provider's keys, and that
+// it was issued by the provider, for this client and this login
+func (p *Provider) verify(ctx context.Context, keys *keySet, raw, nonce string) (*Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithoutClaimsValidation(),
+	)
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.VerifyIssuer(p.cfg.Issuer, true) && !claims.VerifyIssuer(p.cfg.Issuer+"/", true):
+		return nil, errors.New("invalid ID token: wrong issuer")
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return nil, errors.New("invalid ID token: wrong audience")
+	case !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true):
+		return nil, errors.New("invalid ID token: expired")
+	case !claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), false):
+		return nil, errors.New("invalid ID token: issued in the future")
+	}
+	// With several audiences, the authorized party must be this client
+	if azp, ok := claims["azp"].(string); ok && azp != p.cfg.ClientID {
+		return nil, errors.New("invalid ID token: wrong authorized party")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid ID token: wrong nonce")
+	}
+
+	c := &Claims{raw: claims}
+	c.Subject, _ = claims["sub"].(string)
+	if c.Subject == "" {
+		return nil, errors.New("invalid ID token: no subject")
+	}
+	c.Email, _ = claims["email"].(string)
+	c.Name, _ = claims["name"].(string)
+	c.PreferredUsername, _ = claims["preferred_username"].(string)
+	// Some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		c.EmailVerified = v
+	case string:
+		c.EmailVerified = v == "true"
+	}
+	return c, nil
+}
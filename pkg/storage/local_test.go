@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	if err := s.Put("abc123", strings.NewReader("contents"), 8, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if exists, err := s.Exists("abc123"); err != nil || !exists {
+		t.Fatalf("Exists = %v, %v; want true", exists, err)
+	}
+	rc, err := s.Open("abc123", 3)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "tents" {
+		t.Errorf("read %q from offset 3, want tents", got)
+	}
+
+	if err := s.Delete("abc123"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Open("abc123", 0); err != ErrNotFound {
+		t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorageRefusesShortWritesAndBadKeys(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	if err := s.Put("abc123", strings.NewReader("short"), 10, ""); err == nil {
+		t.Error("Put accepted fewer bytes than announced")
+	}
+	if exists, _ := s.Exists("abc123"); exists {
+		t.Error("a partial blob was left behind")
+	}
+	for _, key := range []string{"", "../etc", "a/b", "x.y"} {
+		if err := s.Put(key, strings.NewReader(""), 0, ""); err == nil {
+			t.Errorf("Put accepted key %q", key)
+		}
+	}
+}
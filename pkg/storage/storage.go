@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"task-management-api/config"
+)
+
+// ErrNotFound is returned when a blob does not exist in the store
+var ErrNotFound = errors.New("blob not found")
+
+// Storage is a flat key/value store for file contents
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any existing blob
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Open returns the blob stored under key, starting at the given byte offset
+	Open(key string, offset int64) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+}
+
+// New creates the blob store selected in the configuration
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath)
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// blobReader adapts a blob to io.ReadSeeker so it can be served with
+// http.ServeContent. Every seek drops the open stream and the next read
+// reopens the blob at the new offset, so a range request only fetches the
+// bytes it needs.
+type blobReader struct {
+	store  Storage
+	key    string
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+}
+
+// NewReadSeeker returns a seekable reader over the blob stored under key,
+// which must be size bytes long
+func NewReadSeeker(store Storage, key string, size int64) io.ReadSeekCloser {
+	return &blobReader{store: store, key: key, size: size}
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	if b.rc == nil {
+		rc, err := b.store.Open(b.key, b.offset)
+		if err != nil {
+			return 0, err
+		}
+		b.rc = rc
+	}
+	n, err := b.rc.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += b.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != b.offset && b.rc != nil {
+		b.rc.Close()
+		b.rc = nil
+	}
+	b.offset = offset
+	return offset, nil
+}
+
+func (b *blobReader) Close() error {
+	if b.rc == nil {
+		return nil
+	}
+	err := b.rc.Close()
+	b.rc = nil
+	return err
+}
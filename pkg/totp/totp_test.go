@@ -0,0 +1,98 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the HMAC-SHA1 seed of RFC 6238 Appendix B, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the SHA1 test vectors of RFC 6238 Appendix B. The
+// RFC lists 8-digit codes; ours are their last 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		rfc  string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if want := tt.rfc[len(tt.rfc)-Digits:]; code != want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, code, want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecrets(t *testing.T) {
+	code, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil || code != "287082" {
+		t.Errorf("Code = %s, %v; want 287082", code, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	if got, ok := Validate(rfcSecret, " 050471 ", now, 1); !ok || got != step {
+		t.Errorf("Validate = %d, %v; want the current step", got, ok)
+	}
+
+	previous, _ := Code(rfcSecret, step-1)
+	if got, ok := Validate(rfcSecret, previous, now, 1); !ok || got != step-1 {
+		t.Errorf("Validate of the previous code = %d, %v; want step %d", got, ok, step-1)
+	}
+	if _, ok := Validate(rfcSecret, previous, now, 0); ok {
+		t.Error("Validate accepted the previous code without skew")
+	}
+
+	twoAhead, _ := Code(rfcSecret, step+2)
+	if _, ok := Validate(rfcSecret, twoAhead, now, 1); ok {
+		t.Error("Validate accepted a code outside the skew")
+	}
+	if _, ok := Validate(rfcSecret, "05047", now, 1); ok {
+		t.Error("Validate accepted a short code")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Errorf("secret %q decodes to %d bytes (%v), want %d", secret, len(key), err, secretSize)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("GenerateSecret returned the same secret twice")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("Task API", "ann@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Task API:ann@example.com" {
+		t.Errorf("URI = %s", u)
+	}
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Task API" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}